@@ -0,0 +1,183 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package jwks
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// MaxAdminRequestBytes limits the size of request bodies accepted by AdminHandler
+const MaxAdminRequestBytes = 1 << 20
+
+// AdminAuthorizer decides whether a request to an AdminHandler is allowed
+type AdminAuthorizer func(r *http.Request) bool
+
+// BearerTokenAuthorizer returns an AdminAuthorizer allowing requests that carry token as a bearer token in the
+// Authorization header. The scheme is matched case-insensitively and required, tokens are compared in constant time.
+// An empty token allows no requests.
+func BearerTokenAuthorizer(token string) AdminAuthorizer {
+	return func(r *http.Request) bool {
+		if token == "" {
+			return false
+		}
+
+		scheme, presented, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			return false
+		}
+
+		presented = strings.TrimLeft(presented, " ")
+
+		return subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1
+	}
+}
+
+// AdminHandler returns an http.Handler that lets operators change a Set at runtime, for example one served by
+// Handler, so compromised keys can be retired without a restart. Every change is applied atomically. Requests must be
+// allowed by authorize, a nil authorize denies all requests.
+//
+//   - GET returns the public keys of the set as a JWK Set
+//   - POST adds the JWK in the body, answering 409 Conflict if its kid is already present
+//   - PUT replaces the whole set with the JWK Set in the body
+//   - DELETE removes the keys with the kid given by the "kid" query parameter, answering 404 if there are none
+//
+// Keys are validated before they are added and must have a kid.
+func AdminHandler(set *Set, authorize AdminAuthorizer) http.Handler {
+	return &adminHandler{
+		set:       set,
+		authorize: authorize,
+	}
+}
+
+type adminHandler struct {
+	set       *Set
+	authorize AdminAuthorizer
+}
+
+func (h *adminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.authorize == nil || !h.authorize(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		body, err := json.Marshal(h.set.Response().PublicOnly())
+
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(body)
+	case http.MethodPost:
+		body, ok := readAdminBody(w, r)
+
+		if !ok {
+			return
+		}
+
+		key, err := ParseKey(body)
+
+		if err == nil {
+			err = validateAdminKey(key)
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if !h.set.AddUnique(*key) {
+			http.Error(w, fmt.Sprintf("key %s already exists", key.KeyId), http.StatusConflict)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodPut:
+		body, ok := readAdminBody(w, r)
+
+		if !ok {
+			return
+		}
+
+		parser := &Parser{DuplicateKids: DuplicateKidsReject}
+		response, err := parser.Parse(body)
+
+		if err == nil {
+			for i := range response.Keys {
+				if err = validateAdminKey(&response.Keys[i]); err != nil {
+					break
+				}
+			}
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		h.set.Replace(response.Keys)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		kid := r.URL.Query().Get("kid")
+
+		if kid == "" {
+			http.Error(w, "missing kid query parameter", http.StatusBadRequest)
+			return
+		}
+
+		if !h.set.Remove(kid) {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, POST, PUT, DELETE")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// readAdminBody reads a request body of at most MaxAdminRequestBytes, answering 413 and returning false if it is
+// larger
+func readAdminBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, MaxAdminRequestBytes))
+
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+
+	return body, true
+}
+
+func validateAdminKey(key *Key) error {
+	if key.KeyId == "" {
+		return errors.New("key has no kid")
+	}
+
+	return key.Validate()
+}
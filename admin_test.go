@@ -0,0 +1,175 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_BearerTokenAuthorizer(t *testing.T) {
+	authorize := BearerTokenAuthorizer("secret")
+
+	authorized := func(header string) bool {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+
+		if header != "" {
+			request.Header.Set("Authorization", header)
+		}
+
+		return authorize(request)
+	}
+
+	t.Run("allows the token with the bearer scheme in any case", func(t *testing.T) {
+		req := require.New(t)
+
+		req.True(authorized("Bearer secret"))
+		req.True(authorized("bearer secret"))
+		req.True(authorized("BEARER  secret"))
+	})
+
+	t.Run("rejects a missing scheme", func(t *testing.T) {
+		req := require.New(t)
+
+		req.False(authorized("secret"))
+		req.False(authorized(" secret"))
+		req.False(authorized(""))
+	})
+
+	t.Run("rejects other schemes and tokens", func(t *testing.T) {
+		req := require.New(t)
+
+		req.False(authorized("Basic secret"))
+		req.False(authorized("Bearersecret"))
+		req.False(authorized("Bearer wrong"))
+		req.False(authorized("Bearer secret "))
+	})
+}
+
+func Test_AdminHandler(t *testing.T) {
+	serve := func(handler http.Handler, method string, target string, body string, token string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, target, strings.NewReader(body))
+
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	newKeyJson := func(req *require.Assertions, kid string) (*Key, string) {
+		private, _, err := GenerateECKey("P-256", kid)
+		req.NoError(err)
+
+		raw, err := json.Marshal(private)
+		req.NoError(err)
+
+		return private, string(raw)
+	}
+
+	t.Run("requires authorization", func(t *testing.T) {
+		req := require.New(t)
+
+		set := NewSet()
+
+		recorder := serve(AdminHandler(set, BearerTokenAuthorizer("secret")), http.MethodGet, "/", "", "wrong")
+		req.Equal(http.StatusUnauthorized, recorder.Code)
+		req.Equal("Bearer", recorder.Header().Get("WWW-Authenticate"))
+
+		recorder = serve(AdminHandler(set, BearerTokenAuthorizer("")), http.MethodGet, "/", "", "")
+		req.Equal(http.StatusUnauthorized, recorder.Code)
+
+		recorder = serve(AdminHandler(set, nil), http.MethodGet, "/", "", "secret")
+		req.Equal(http.StatusUnauthorized, recorder.Code)
+	})
+
+	t.Run("can add, list and remove keys", func(t *testing.T) {
+		req := require.New(t)
+
+		set := NewSet()
+		handler := AdminHandler(set, BearerTokenAuthorizer("secret"))
+		served := Handler(set)
+
+		_, body := newKeyJson(req, "first")
+
+		req.Equal(http.StatusCreated, serve(handler, http.MethodPost, "/", body, "secret").Code)
+		req.Equal(http.StatusConflict, serve(handler, http.MethodPost, "/", body, "secret").Code)
+
+		recorder := serve(handler, http.MethodGet, "/", "", "secret")
+		req.Equal(http.StatusOK, recorder.Code)
+
+		listed, err := ParseResponse(recorder.Body.Bytes())
+		req.NoError(err)
+		req.Len(listed.Keys, 1)
+		req.True(listed.Keys[0].IsPublic())
+
+		recorder = serve(served, http.MethodGet, "/", "", "")
+		published, err := ParseResponse(recorder.Body.Bytes())
+		req.NoError(err)
+		req.Equal("first", published.Keys[0].KeyId)
+
+		req.Equal(http.StatusNoContent, serve(handler, http.MethodDelete, "/?kid=first", "", "secret").Code)
+		req.Equal(http.StatusNotFound, serve(handler, http.MethodDelete, "/?kid=first", "", "secret").Code)
+		req.Equal(http.StatusBadRequest, serve(handler, http.MethodDelete, "/", "", "secret").Code)
+		req.Equal(0, set.Len())
+	})
+
+	t.Run("can replace the set", func(t *testing.T) {
+		req := require.New(t)
+
+		old, _ := newKeyJson(req, "old")
+		set := NewSet(*old)
+		handler := AdminHandler(set, BearerTokenAuthorizer("secret"))
+
+		_, first := newKeyJson(req, "a")
+		_, second := newKeyJson(req, "b")
+
+		recorder := serve(handler, http.MethodPut, "/", `{"keys":[`+first+`,`+second+`]}`, "secret")
+		req.Equal(http.StatusNoContent, recorder.Code)
+		req.Equal(2, set.Len())
+
+		_, found := set.Get("old")
+		req.False(found)
+
+		recorder = serve(handler, http.MethodPut, "/", `{"keys":[`+first+`,`+first+`]}`, "secret")
+		req.Equal(http.StatusBadRequest, recorder.Code)
+		req.Equal(2, set.Len())
+	})
+
+	t.Run("rejects invalid keys", func(t *testing.T) {
+		req := require.New(t)
+
+		set := NewSet()
+		handler := AdminHandler(set, BearerTokenAuthorizer("secret"))
+
+		_, body := newKeyJson(req, "")
+		noKid := strings.Replace(body, `"kid"`, `"x-kid"`, 1)
+
+		req.Equal(http.StatusBadRequest, serve(handler, http.MethodPost, "/", noKid, "secret").Code)
+		req.Equal(http.StatusBadRequest, serve(handler, http.MethodPost, "/", `{"kty":"EC","kid":"bad"}`, "secret").Code)
+		req.Equal(http.StatusBadRequest, serve(handler, http.MethodPost, "/", `not json`, "secret").Code)
+		req.Equal(http.StatusMethodNotAllowed, serve(handler, http.MethodPatch, "/", "", "secret").Code)
+		req.Equal(0, set.Len())
+	})
+}
@@ -0,0 +1,165 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package jwks
+
+import (
+	"crypto"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AggregateUpstream is an issuer whose keys an Aggregator republishes
+type AggregateUpstream struct {
+	// Prefix is prepended to the kid of every key from this upstream so kids from different issuers can not collide
+	Prefix string
+
+	// Location is passed to Resolver to fetch the upstream's keys
+	Location string
+
+	// Resolver fetches the keys, an HttpResolver if nil
+	Resolver Resolver
+}
+
+// AggregateError lists the upstreams an Aggregator failed to refresh
+type AggregateError struct {
+	Errors []error
+}
+
+func (e *AggregateError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+
+	return fmt.Sprintf("could not refresh %d upstream(s): %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// Aggregator is a ResponseSource that merges the keys of multiple upstream issuers into a single set, for example to
+// serve an internal JWKS proxy or federation point with Handler. Every kid is prefixed with its upstream's Prefix;
+// keys without a kid get the prefix followed by their SHA-256 thumbprint. Only public keys are republished.
+//
+// Upstreams are fetched on first use and again once the refresh interval has passed. If an upstream can not be
+// fetched its previously fetched keys are kept.
+type Aggregator struct {
+	upstreams []AggregateUpstream
+	refresh   time.Duration
+
+	lock      sync.Mutex
+	keys      [][]Key
+	fetchedAt time.Time
+}
+
+// NewAggregator returns an Aggregator for upstreams that refetches them at most once per refresh interval
+func NewAggregator(refresh time.Duration, upstreams ...AggregateUpstream) *Aggregator {
+	return &Aggregator{
+		upstreams: upstreams,
+		refresh:   refresh,
+		keys:      make([][]Key, len(upstreams)),
+	}
+}
+
+// Response returns the aggregated keys, refreshing them first if the refresh interval has passed. Refresh errors are
+// not reported, the previously fetched keys of failing upstreams are used instead.
+func (a *Aggregator) Response() *Response {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.fetchedAt.IsZero() || time.Since(a.fetchedAt) >= a.refresh {
+		_ = a.refreshLocked()
+	}
+
+	return a.responseLocked()
+}
+
+// Refresh fetches all upstreams now. Upstreams that fail keep their previously fetched keys and are reported in an
+// *AggregateError.
+func (a *Aggregator) Refresh() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	return a.refreshLocked()
+}
+
+func (a *Aggregator) refreshLocked() error {
+	var errs []error
+
+	for i, upstream := range a.upstreams {
+		keys, err := upstream.fetch()
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("upstream %s: %s", upstream.Location, err))
+			continue
+		}
+
+		a.keys[i] = keys
+	}
+
+	a.fetchedAt = time.Now()
+
+	if len(errs) > 0 {
+		return &AggregateError{Errors: errs}
+	}
+
+	return nil
+}
+
+func (a *Aggregator) responseLocked() *Response {
+	ret := &Response{
+		Keys: []Key{},
+	}
+
+	for _, keys := range a.keys {
+		for i := range keys {
+			ret.Keys = append(ret.Keys, keys[i].clone())
+		}
+	}
+
+	return ret
+}
+
+// fetch resolves the upstream's keys and returns their public parts with prefixed kids
+func (u *AggregateUpstream) fetch() ([]Key, error) {
+	resolver := u.Resolver
+
+	if resolver == nil {
+		resolver = &HttpResolver{}
+	}
+
+	response, _, err := resolver.Get(u.Location)
+
+	if err != nil {
+		return nil, err
+	}
+
+	keys := response.PublicOnly().Keys
+
+	for i := range keys {
+		kid := keys[i].KeyId
+
+		if kid == "" {
+			if kid, err = keys[i].Thumbprint(crypto.SHA256); err != nil {
+				return nil, err
+			}
+		}
+
+		keys[i].KeyId = u.Prefix + kid
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,143 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testResolver is a Resolver returning canned responses per location
+type testResolver struct {
+	responses map[string]*Response
+	calls     int
+}
+
+func (r *testResolver) Get(location string) (*Response, []byte, error) {
+	r.calls++
+
+	response, ok := r.responses[location]
+
+	if !ok {
+		return nil, nil, errors.New("not found")
+	}
+
+	return response, nil, nil
+}
+
+func Test_Aggregator(t *testing.T) {
+	newResponse := func(req *require.Assertions, kid string) *Response {
+		private, _, err := GenerateECKey("P-256", kid)
+		req.NoError(err)
+
+		return &Response{Keys: []Key{*private}}
+	}
+
+	t.Run("can aggregate upstreams with prefixed kids", func(t *testing.T) {
+		req := require.New(t)
+
+		resolver := &testResolver{responses: map[string]*Response{
+			"a": newResponse(req, "key"),
+			"b": newResponse(req, "key"),
+		}}
+
+		aggregator := NewAggregator(time.Hour,
+			AggregateUpstream{Prefix: "a:", Location: "a", Resolver: resolver},
+			AggregateUpstream{Prefix: "b:", Location: "b", Resolver: resolver},
+		)
+
+		response := aggregator.Response()
+		req.Len(response.Keys, 2)
+		req.Equal("a:key", response.Keys[0].KeyId)
+		req.Equal("b:key", response.Keys[1].KeyId)
+		req.True(response.Keys[0].IsPublic())
+
+		req.Equal("key", resolver.responses["a"].Keys[0].KeyId)
+	})
+
+	t.Run("prefixes the thumbprint of keys without a kid", func(t *testing.T) {
+		req := require.New(t)
+
+		response := newResponse(req, "")
+		response.Keys[0].KeyId = ""
+
+		resolver := &testResolver{responses: map[string]*Response{"a": response}}
+		aggregator := NewAggregator(time.Hour, AggregateUpstream{Prefix: "a:", Location: "a", Resolver: resolver})
+
+		thumbprint, err := response.Keys[0].Thumbprint(crypto.SHA256)
+		req.NoError(err)
+		req.Equal("a:"+thumbprint, aggregator.Response().Keys[0].KeyId)
+	})
+
+	t.Run("refreshes only after the interval", func(t *testing.T) {
+		req := require.New(t)
+
+		resolver := &testResolver{responses: map[string]*Response{"a": newResponse(req, "key")}}
+		aggregator := NewAggregator(time.Hour, AggregateUpstream{Prefix: "a:", Location: "a", Resolver: resolver})
+
+		aggregator.Response()
+		aggregator.Response()
+		req.Equal(1, resolver.calls)
+
+		req.NoError(aggregator.Refresh())
+		req.Equal(2, resolver.calls)
+	})
+
+	t.Run("keeps the keys of failing upstreams", func(t *testing.T) {
+		req := require.New(t)
+
+		resolver := &testResolver{responses: map[string]*Response{
+			"a": newResponse(req, "key"),
+			"b": newResponse(req, "key"),
+		}}
+
+		aggregator := NewAggregator(time.Hour,
+			AggregateUpstream{Prefix: "a:", Location: "a", Resolver: resolver},
+			AggregateUpstream{Prefix: "b:", Location: "b", Resolver: resolver},
+		)
+
+		req.NoError(aggregator.Refresh())
+
+		delete(resolver.responses, "b")
+
+		err := aggregator.Refresh()
+		req.Error(err)
+		req.IsType(&AggregateError{}, err)
+		req.Len(err.(*AggregateError).Errors, 1)
+
+		req.Len(aggregator.Response().Keys, 2)
+	})
+
+	t.Run("can be served by a Handler", func(t *testing.T) {
+		req := require.New(t)
+
+		resolver := &testResolver{responses: map[string]*Response{"a": newResponse(req, "key")}}
+		aggregator := NewAggregator(time.Hour, AggregateUpstream{Prefix: "a:", Location: "a", Resolver: resolver})
+
+		recorder := httptest.NewRecorder()
+		Handler(aggregator).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		response, err := ParseResponse(recorder.Body.Bytes())
+		req.NoError(err)
+		req.Equal("a:key", response.Keys[0].KeyId)
+	})
+}
@@ -0,0 +1,195 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"github.com/pkg/errors"
+)
+
+const (
+	// AttestationMember is the additional member holding the Attestation of a hardware generated key
+	AttestationMember = "x-attestation"
+
+	// AttestationFormatX509 is the format of attestations proven by an attestation certificate chain alone, as issued
+	// by many HSMs and secure elements, see X509AttestationVerifier
+	AttestationFormatX509 = "x509"
+
+	ErrorAttestationMissingMsg     = "key has no attestation"
+	ErrorAttestationUnsupportedMsg = "unsupported attestation format"
+)
+
+// Attestation is proof, issued by the hardware that generated a key, that the key is hardware bound. It is stored in
+// the AttestationMember of the key. The Statement is specific to the Format, such as a TPM quote or a vendor
+// attestation blob, and is interpreted by the AttestationVerifier registered for the format.
+type Attestation struct {
+	Format string `json:"fmt"`
+
+	// Statement is the base64url encoded, format specific attestation statement
+	Statement string `json:"stmt,omitempty"`
+
+	// Certificates is the attestation certificate chain as base64 encoded DER, leaf first
+	Certificates []string `json:"x5c,omitempty"`
+}
+
+// AttestationError is returned when the attestation of a key is missing or can not be verified
+type AttestationError struct {
+	error
+	KeyId string
+}
+
+func (e *AttestationError) Error() string {
+	return fmt.Sprintf("key %s attestation: %s", e.KeyId, e.error.Error())
+}
+
+func (e *AttestationError) Unwrap() error {
+	return e.error
+}
+
+// AttestationVerifier verifies that an attestation proves the key is hardware bound
+type AttestationVerifier interface {
+	VerifyAttestation(key Key, attestation Attestation) error
+}
+
+// AttestationVerifierFunc adapts a function to an AttestationVerifier
+type AttestationVerifierFunc func(key Key, attestation Attestation) error
+
+func (f AttestationVerifierFunc) VerifyAttestation(key Key, attestation Attestation) error {
+	return f(key, attestation)
+}
+
+// Attestation returns the attestation of the key or nil if it has none
+func (k *Key) Attestation() (*Attestation, error) {
+	attestation := &Attestation{}
+
+	ok, err := k.GetExtra(AttestationMember, attestation)
+
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	return attestation, nil
+}
+
+// SetAttestation stores attestation in the AttestationMember of the key, a nil attestation removes it
+func (k *Key) SetAttestation(attestation *Attestation) error {
+	if attestation == nil {
+		k.DeleteExtra(AttestationMember)
+		return nil
+	}
+
+	if attestation.Format == "" {
+		return errors.New("attestation format is required")
+	}
+
+	return k.SetExtra(AttestationMember, attestation)
+}
+
+// VerifyAttestation verifies the attestation of the key with the verifier registered for its format. An
+// *AttestationError is returned if the key has no attestation, its format has no verifier, or verification fails.
+func (k *Key) VerifyAttestation(verifiers map[string]AttestationVerifier) error {
+	attestation, err := k.Attestation()
+
+	if err != nil {
+		return &AttestationError{error: err, KeyId: k.KeyId}
+	}
+
+	if attestation == nil {
+		return &AttestationError{error: errors.New(ErrorAttestationMissingMsg), KeyId: k.KeyId}
+	}
+
+	verifier, ok := verifiers[attestation.Format]
+
+	if !ok {
+		return &AttestationError{
+			error: fmt.Errorf("%s: %s", ErrorAttestationUnsupportedMsg, attestation.Format),
+			KeyId: k.KeyId,
+		}
+	}
+
+	if err = verifier.VerifyAttestation(k.Public(), *attestation); err != nil {
+		return &AttestationError{error: err, KeyId: k.KeyId}
+	}
+
+	return nil
+}
+
+// VerifyAttestations requires every key in the response to have an attestation verified by the verifier registered
+// for its format, see Key.VerifyAttestation. The first failure is returned.
+func (r *Response) VerifyAttestations(verifiers map[string]AttestationVerifier) error {
+	for i := range r.Keys {
+		if err := r.Keys[i].VerifyAttestation(verifiers); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// X509AttestationVerifier returns an AttestationVerifier for AttestationFormatX509, which verifies that the leaf of
+// the attestation certificate chain certifies the key and chains to roots, the attestation CAs of trusted hardware
+// vendors. The Roots and Intermediates of opts are replaced, all other options are honored.
+func X509AttestationVerifier(roots *x509.CertPool, opts x509.VerifyOptions) AttestationVerifier {
+	return AttestationVerifierFunc(func(key Key, attestation Attestation) error {
+		if len(attestation.Certificates) == 0 {
+			return errors.New("attestation has no certificate chain")
+		}
+
+		certs := make([]*x509.Certificate, 0, len(attestation.Certificates))
+
+		for i, encoded := range attestation.Certificates {
+			der, err := base64.StdEncoding.DecodeString(encoded)
+
+			if err != nil {
+				return fmt.Errorf("could not decode attestation certificate %d: %s", i, err)
+			}
+
+			cert, err := x509.ParseCertificate(der)
+
+			if err != nil {
+				return fmt.Errorf("could not parse attestation certificate %d: %s", i, err)
+			}
+
+			certs = append(certs, cert)
+		}
+
+		publicKey, err := KeyToPublicKey(key)
+
+		if err != nil {
+			return err
+		}
+
+		if !certMatchesPublicKey(certs[0], publicKey) {
+			return errors.New("attestation certificate does not certify the key")
+		}
+
+		opts.Roots = roots
+		opts.Intermediates = x509.NewCertPool()
+
+		for _, cert := range certs[1:] {
+			opts.Intermediates.AddCert(cert)
+		}
+
+		if _, err = certs[0].Verify(opts); err != nil {
+			return fmt.Errorf("could not verify attestation certificate chain: %s", err)
+		}
+
+		return nil
+	})
+}
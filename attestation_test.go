@@ -0,0 +1,148 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_Attestation(t *testing.T) {
+	root, rootKey, err := newTestCa("TEST Attestation Root", nil, nil)
+	require.NoError(t, err)
+
+	intermediate, intermediateKey, err := newTestCa("TEST Attestation Intermediate", root, rootKey)
+	require.NoError(t, err)
+
+	leaf, leafKey, err := newTestLeaf(intermediate, intermediateKey)
+	require.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+
+	newAttestedKey := func(req *require.Assertions) Key {
+		key, err := NewKeyFromPublicKey("attested", &leafKey.PublicKey)
+		req.NoError(err)
+
+		req.NoError(key.SetAttestation(&Attestation{
+			Format: AttestationFormatX509,
+			Certificates: []string{
+				base64.StdEncoding.EncodeToString(leaf.Raw),
+				base64.StdEncoding.EncodeToString(intermediate.Raw),
+			},
+		}))
+
+		return *key
+	}
+
+	verifiers := map[string]AttestationVerifier{
+		AttestationFormatX509: X509AttestationVerifier(roots, x509.VerifyOptions{}),
+	}
+
+	t.Run("can set and get an attestation", func(t *testing.T) {
+		req := require.New(t)
+
+		key := newAttestedKey(req)
+
+		data, err := json.Marshal(key)
+		req.NoError(err)
+
+		parsed, err := ParseKey(data)
+		req.NoError(err)
+
+		attestation, err := parsed.Attestation()
+		req.NoError(err)
+		req.Equal(AttestationFormatX509, attestation.Format)
+		req.Len(attestation.Certificates, 2)
+
+		public := key.Public()
+		attestation, err = public.Attestation()
+		req.NoError(err)
+		req.NotNil(attestation)
+
+		req.NoError(key.SetAttestation(nil))
+		attestation, err = key.Attestation()
+		req.NoError(err)
+		req.Nil(attestation)
+	})
+
+	t.Run("can verify an x509 attestation", func(t *testing.T) {
+		req := require.New(t)
+
+		response := &Response{Keys: []Key{newAttestedKey(req)}}
+		req.NoError(response.VerifyAttestations(verifiers))
+	})
+
+	t.Run("rejects keys without attestations or with unsupported formats", func(t *testing.T) {
+		req := require.New(t)
+
+		_, unattested, err := GenerateECKey("P-256", "unattested")
+		req.NoError(err)
+
+		err = unattested.VerifyAttestation(verifiers)
+		req.IsType(&AttestationError{}, err)
+		req.EqualError(err, "key unattested attestation: "+ErrorAttestationMissingMsg)
+
+		req.NoError(unattested.SetAttestation(&Attestation{Format: "tpm"}))
+		err = unattested.VerifyAttestation(verifiers)
+		req.EqualError(err, "key unattested attestation: "+ErrorAttestationUnsupportedMsg+": tpm")
+
+		req.Error(unattested.SetAttestation(&Attestation{}))
+	})
+
+	t.Run("rejects attestations for other keys or untrusted roots", func(t *testing.T) {
+		req := require.New(t)
+
+		_, other, err := GenerateECKey("P-256", "other")
+		req.NoError(err)
+
+		attested := newAttestedKey(req)
+		attestation, err := attested.Attestation()
+		req.NoError(err)
+		req.NoError(other.SetAttestation(attestation))
+		req.Error(other.VerifyAttestation(verifiers))
+
+		untrusted := map[string]AttestationVerifier{
+			AttestationFormatX509: X509AttestationVerifier(x509.NewCertPool(), x509.VerifyOptions{}),
+		}
+
+		key := newAttestedKey(req)
+		req.Error(key.VerifyAttestation(untrusted))
+	})
+
+	t.Run("can use custom verifiers", func(t *testing.T) {
+		req := require.New(t)
+
+		failure := errors.New("quote does not match")
+
+		key := newAttestedKey(req)
+		req.NoError(key.SetAttestation(&Attestation{Format: "tpm", Statement: "cXVvdGU"}))
+
+		err := key.VerifyAttestation(map[string]AttestationVerifier{
+			"tpm": AttestationVerifierFunc(func(key Key, attestation Attestation) error {
+				req.Equal("attested", key.KeyId)
+				req.Equal("cXVvdGU", attestation.Statement)
+				return failure
+			}),
+		})
+		req.ErrorIs(err, failure)
+	})
+}
@@ -0,0 +1,217 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// AuditSeverity ranks the risk of an AuditFinding
+type AuditSeverity int
+
+const (
+	// AuditLow findings are worth reviewing but rarely a problem on their own
+	AuditLow AuditSeverity = iota
+
+	// AuditMedium findings weaken interoperability or key hygiene
+	AuditMedium
+
+	// AuditHigh findings indicate keys that should not be trusted
+	AuditHigh
+)
+
+func (s AuditSeverity) String() string {
+	switch s {
+	case AuditLow:
+		return "low"
+	case AuditMedium:
+		return "medium"
+	case AuditHigh:
+		return "high"
+	}
+
+	return fmt.Sprintf("AuditSeverity(%d)", int(s))
+}
+
+// MarshalText renders the severity as its name
+func (s AuditSeverity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// AuditFinding describes one weak or risky property of the Key at Index in a Response
+type AuditFinding struct {
+	Index    int           `json:"index"`
+	KeyId    string        `json:"kid"`
+	Severity AuditSeverity `json:"severity"`
+	Message  string        `json:"message"`
+}
+
+// AuditReport is the result of Audit
+type AuditReport struct {
+	Keys     int            `json:"keys"`
+	Findings []AuditFinding `json:"findings"`
+}
+
+// MaxSeverity returns the highest severity in the report and false if there are no findings
+func (r *AuditReport) MaxSeverity() (AuditSeverity, bool) {
+	if len(r.Findings) == 0 {
+		return AuditLow, false
+	}
+
+	ret := AuditLow
+
+	for _, finding := range r.Findings {
+		if finding.Severity > ret {
+			ret = finding.Severity
+		}
+	}
+
+	return ret, true
+}
+
+// ForKey returns the findings for the key at index
+func (r *AuditReport) ForKey(index int) []AuditFinding {
+	var ret []AuditFinding
+
+	for _, finding := range r.Findings {
+		if finding.Index == index {
+			ret = append(ret, finding)
+		}
+	}
+
+	return ret
+}
+
+// String renders the report as indented JSON
+func (r *AuditReport) String() string {
+	data, _ := json.MarshalIndent(r, "", "  ")
+	return string(data)
+}
+
+// auditRecommendedCurves are the curves Audit does not report as deprecated or unusual
+var auditRecommendedCurves = map[string]bool{
+	"P-256":      true,
+	"P-384":      true,
+	"P-521":      true,
+	CurveEd25519: true,
+	CurveX25519:  true,
+	"Ed448":      true,
+	"X448":       true,
+}
+
+// auditMaxRsaExponentBits is the largest RSA public exponent size, in bits, Audit does not report. Many
+// implementations, including Go's, reject exponents larger than 2^31-1.
+const auditMaxRsaExponentBits = 31
+
+// Audit reviews the keys of a third party issuer for weak or risky properties, such as RSA moduli below 2048 bits,
+// SHA-1 only certificate thumbprints, deprecated curves, missing kid or alg members, enormous RSA exponents, and
+// published private key material. Unlike Validate it does not reject keys, it reports findings by severity for an
+// operator to review before trusting the issuer.
+func Audit(response *Response) *AuditReport {
+	report := &AuditReport{
+		Keys:     len(response.Keys),
+		Findings: []AuditFinding{},
+	}
+
+	for i := range response.Keys {
+		key := &response.Keys[i]
+
+		for _, finding := range auditKey(key) {
+			finding.Index = i
+			finding.KeyId = key.KeyId
+			report.Findings = append(report.Findings, finding)
+		}
+	}
+
+	return report
+}
+
+// auditKey returns the findings for a single key without Index and KeyId
+func auditKey(key *Key) []AuditFinding {
+	var findings []AuditFinding
+
+	add := func(severity AuditSeverity, format string, args ...interface{}) {
+		findings = append(findings, AuditFinding{
+			Severity: severity,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	if key.IsPrivate() || key.IsSymmetric() {
+		add(AuditHigh, "key exposes private or symmetric key material")
+	}
+
+	if key.KeyId == "" {
+		add(AuditMedium, "key has no kid, verifiers can not select it unambiguously")
+	} else if isSha1Kid(key) {
+		add(AuditLow, "kid is the SHA-1 fingerprint of the x5c leaf certificate")
+	}
+
+	if key.Algorithm == "" {
+		add(AuditLow, "key has no alg, the algorithm it may be used with is not pinned")
+	}
+
+	if key.X509Thumbprint != "" && key.X509ThumbprintSha256 == "" {
+		add(AuditMedium, "key has a SHA-1 x5t certificate thumbprint without x5t#S256")
+	}
+
+	switch key.KeyType {
+	case KeyTypeRsa:
+		findings = append(findings, auditRsaKey(key)...)
+	case KeyTypeEc, KeyTypeOkp:
+		if !auditRecommendedCurves[key.Curve] {
+			add(AuditHigh, "curve %s is deprecated or not widely supported", key.Curve)
+		}
+	}
+
+	return findings
+}
+
+// auditRsaKey returns the findings for an RSA modulus and exponent
+func auditRsaKey(key *Key) []AuditFinding {
+	var findings []AuditFinding
+
+	if n, err := base64.RawURLEncoding.DecodeString(key.N); err == nil {
+		if bits := new(big.Int).SetBytes(n).BitLen(); bits < DefaultMinRsaBits {
+			findings = append(findings, AuditFinding{
+				Severity: AuditHigh,
+				Message:  fmt.Sprintf("RSA modulus is %d bits, less than %d", bits, DefaultMinRsaBits),
+			})
+		}
+	}
+
+	if e, err := base64.RawURLEncoding.DecodeString(key.E); err == nil {
+		exponent := new(big.Int).SetBytes(e)
+
+		if bits := exponent.BitLen(); bits > auditMaxRsaExponentBits {
+			findings = append(findings, AuditFinding{
+				Severity: AuditHigh,
+				Message:  fmt.Sprintf("RSA public exponent is %d bits, more than %d", bits, auditMaxRsaExponentBits),
+			})
+		} else if exponent.Int64() < 65537 {
+			findings = append(findings, AuditFinding{
+				Severity: AuditMedium,
+				Message:  fmt.Sprintf("RSA public exponent %d is less than 65537", exponent.Int64()),
+			})
+		}
+	}
+
+	return findings
+}
@@ -0,0 +1,127 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+)
+
+func Test_Audit(t *testing.T) {
+	t.Run("can audit a set without findings", func(t *testing.T) {
+		req := require.New(t)
+
+		_, rsaKey, err := GenerateRSAKey(2048, "rsa")
+		req.NoError(err)
+
+		_, ecKey, err := GenerateECKey("P-256", "ec")
+		req.NoError(err)
+
+		report := Audit(&Response{Keys: []Key{*rsaKey, *ecKey}})
+		req.Equal(2, report.Keys)
+		req.Empty(report.Findings)
+
+		_, ok := report.MaxSeverity()
+		req.False(ok)
+	})
+
+	t.Run("can report weak RSA keys", func(t *testing.T) {
+		req := require.New(t)
+
+		privateKey, err := rsa.GenerateKey(rand.Reader, 1024)
+		req.NoError(err)
+
+		private, err := NewKeyFromPrivateKey("weak", privateKey)
+		req.NoError(err)
+
+		weak := private.Public()
+
+		_, large, err := GenerateRSAKey(2048, "large")
+		req.NoError(err)
+		large.E = base64.RawURLEncoding.EncodeToString(new(big.Int).Lsh(big.NewInt(1), 64).Bytes())
+
+		_, small, err := GenerateRSAKey(2048, "small")
+		req.NoError(err)
+		small.E = base64.RawURLEncoding.EncodeToString([]byte{3})
+
+		report := Audit(&Response{Keys: []Key{weak, *large, *small}})
+
+		req.Len(report.ForKey(0), 1)
+		req.Equal(AuditHigh, report.ForKey(0)[0].Severity)
+		req.Equal("weak", report.ForKey(0)[0].KeyId)
+		req.Equal("RSA modulus is 1024 bits, less than 2048", report.ForKey(0)[0].Message)
+
+		req.Len(report.ForKey(1), 1)
+		req.Equal(AuditHigh, report.ForKey(1)[0].Severity)
+		req.Equal("RSA public exponent is 65 bits, more than 31", report.ForKey(1)[0].Message)
+
+		req.Len(report.ForKey(2), 1)
+		req.Equal(AuditMedium, report.ForKey(2)[0].Severity)
+
+		severity, ok := report.MaxSeverity()
+		req.True(ok)
+		req.Equal(AuditHigh, severity)
+	})
+
+	t.Run("can report missing members, thumbprints, curves, and private keys", func(t *testing.T) {
+		req := require.New(t)
+
+		_, ecKey, err := GenerateECKey("P-256", "ec")
+		req.NoError(err)
+
+		anonymous := *ecKey
+		anonymous.KeyId = ""
+		anonymous.Algorithm = ""
+
+		sha1Only := *ecKey
+		sha1Only.X509Thumbprint = "thumbprint"
+
+		deprecated := *ecKey
+		deprecated.Curve = "P-224"
+
+		private, _, err := GenerateOKPKey(CurveEd25519, "ed")
+		req.NoError(err)
+
+		report := Audit(&Response{Keys: []Key{anonymous, sha1Only, deprecated, *private}})
+
+		severities := func(index int) []AuditSeverity {
+			var ret []AuditSeverity
+			for _, finding := range report.ForKey(index) {
+				ret = append(ret, finding.Severity)
+			}
+			return ret
+		}
+
+		req.Equal([]AuditSeverity{AuditMedium, AuditLow}, severities(0))
+		req.Equal([]AuditSeverity{AuditMedium}, severities(1))
+		req.Equal([]AuditSeverity{AuditHigh}, severities(2))
+		req.Equal("curve P-224 is deprecated or not widely supported", report.ForKey(2)[0].Message)
+		req.Equal([]AuditSeverity{AuditHigh}, severities(3))
+	})
+
+	t.Run("can render the report as JSON", func(t *testing.T) {
+		req := require.New(t)
+
+		report := Audit(&Response{Keys: []Key{{KeyType: KeyTypeEc, Curve: "P-256", Algorithm: AlgorithmEs256}}})
+
+		req.JSONEq(`{"keys": 1, "findings": [{"index": 0, "kid": "", "severity": "medium", "message": "key has no kid, verifiers can not select it unambiguously"}]}`, report.String())
+	})
+}
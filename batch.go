@@ -0,0 +1,94 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// KeyPairGenerator generates a private Key and its public counterpart with the given kid, as GenerateRSAKey,
+// GenerateECKey, and GenerateOKPKey do
+type KeyPairGenerator func(kid string, opts ...KeyOption) (*Key, *Key, error)
+
+// RSAKeyPairGenerator returns a KeyPairGenerator generating RSA keys of the given size
+func RSAKeyPairGenerator(bits int) KeyPairGenerator {
+	return func(kid string, opts ...KeyOption) (*Key, *Key, error) {
+		return GenerateRSAKey(bits, kid, opts...)
+	}
+}
+
+// ECKeyPairGenerator returns a KeyPairGenerator generating EC keys on the named curve
+func ECKeyPairGenerator(curve string) KeyPairGenerator {
+	return func(kid string, opts ...KeyOption) (*Key, *Key, error) {
+		return GenerateECKey(curve, kid, opts...)
+	}
+}
+
+// OKPKeyPairGenerator returns a KeyPairGenerator generating OKP keys on the named curve
+func OKPKeyPairGenerator(curve string) KeyPairGenerator {
+	return func(kid string, opts ...KeyOption) (*Key, *Key, error) {
+		return GenerateOKPKey(curve, kid, opts...)
+	}
+}
+
+// GenerateRotationSet generates count key pairs to bootstrap a rotation scheme. Key i, counting from 0, becomes
+// active at start plus i intervals and expires one interval after its successor becomes active, so tokens signed
+// just before a rotation remain verifiable. All keys are issued at start.
+//
+// If kidPrefix is not empty string the kids are kidPrefix followed by 1 through count, otherwise they are generated
+// as by the generator. The public keys are returned as a Response for publication and the private keys as a Set.
+func GenerateRotationSet(count int, start time.Time, interval time.Duration, kidPrefix string, generate KeyPairGenerator, opts ...KeyOption) (*Response, *Set, error) {
+	if count <= 0 {
+		return nil, nil, fmt.Errorf("invalid rotation set size: %d", count)
+	}
+
+	if interval <= 0 {
+		return nil, nil, fmt.Errorf("invalid rotation interval: %s", interval)
+	}
+
+	response := &Response{}
+	set := NewSet()
+
+	for i := 0; i < count; i++ {
+		kid := ""
+
+		if kidPrefix != "" {
+			kid = kidPrefix + strconv.Itoa(i+1)
+		}
+
+		private, public, err := generate(kid, opts...)
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not generate key %d of rotation set: %s", i+1, err)
+		}
+
+		notBefore := start.Add(time.Duration(i) * interval)
+
+		for _, key := range []*Key{private, public} {
+			key.IssuedAt = NewNumericDate(start)
+			key.NotBefore = NewNumericDate(notBefore)
+			key.ExpiresAt = NewNumericDate(notBefore.Add(2 * interval))
+		}
+
+		response.Keys = append(response.Keys, *public)
+		set.Add(*private)
+	}
+
+	return response, set, nil
+}
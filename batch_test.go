@@ -0,0 +1,80 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_GenerateRotationSet(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+
+	t.Run("can generate a staggered rotation set", func(t *testing.T) {
+		req := require.New(t)
+
+		response, set, err := GenerateRotationSet(3, start, time.Hour, "key-", ECKeyPairGenerator("P-256"))
+		req.NoError(err)
+		req.Len(response.Keys, 3)
+		req.Equal(3, set.Len())
+
+		for i, public := range response.Keys {
+			req.True(public.IsPublic())
+			req.Equal(NewNumericDate(start), public.IssuedAt)
+			req.Equal(NewNumericDate(start.Add(time.Duration(i)*time.Hour)), public.NotBefore)
+			req.Equal(NewNumericDate(start.Add(time.Duration(i+2)*time.Hour)), public.ExpiresAt)
+
+			private, ok := set.Get(public.KeyId)
+			req.True(ok)
+			req.True(private.IsPrivate())
+			req.Equal(public.X, private.X)
+			req.Equal(public.NotBefore, private.NotBefore)
+		}
+
+		req.Equal("key-1", response.Keys[0].KeyId)
+		req.Equal("key-2", response.Keys[1].KeyId)
+		req.Equal("key-3", response.Keys[2].KeyId)
+
+		active := response.ActiveAt(start.Add(90 * time.Minute))
+		req.Len(active.Keys, 2)
+		req.Equal("key-1", active.Keys[0].KeyId)
+		req.Equal("key-2", active.Keys[1].KeyId)
+	})
+
+	t.Run("generates kids with the generator if no prefix is given", func(t *testing.T) {
+		req := require.New(t)
+
+		response, _, err := GenerateRotationSet(2, start, time.Hour, "", OKPKeyPairGenerator(CurveEd25519))
+		req.NoError(err)
+		req.NotEmpty(response.Keys[0].KeyId)
+		req.NotEqual(response.Keys[0].KeyId, response.Keys[1].KeyId)
+	})
+
+	t.Run("returns an error for invalid arguments", func(t *testing.T) {
+		req := require.New(t)
+
+		_, _, err := GenerateRotationSet(0, start, time.Hour, "key-", ECKeyPairGenerator("P-256"))
+		req.Error(err)
+
+		_, _, err = GenerateRotationSet(2, start, 0, "key-", ECKeyPairGenerator("P-256"))
+		req.Error(err)
+
+		_, _, err = GenerateRotationSet(2, start, time.Hour, "key-", ECKeyPairGenerator("P-224"))
+		req.Error(err)
+	})
+}
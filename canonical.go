@@ -0,0 +1,101 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+// MarshalCanonical renders the key as compact JSON with members, including those nested in AdditionalMembers, in
+// lexicographic order and without HTML escaping. The output is stable across processes and suitable for hashing,
+// signing and diffing.
+func (k *Key) MarshalCanonical() ([]byte, error) {
+	data, err := json.Marshal(k)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return canonicalizeJson(data)
+}
+
+// MarshalCanonical renders the set as canonical JSON, see Key.MarshalCanonical. Keys are ordered by kid, then by their
+// canonical form, so the output does not depend on the order of r.Keys.
+func (r *Response) MarshalCanonical() ([]byte, error) {
+	type canonicalKey struct {
+		keyId string
+		data  []byte
+	}
+
+	keys := make([]canonicalKey, 0, len(r.Keys))
+
+	for i := range r.Keys {
+		data, err := r.Keys[i].MarshalCanonical()
+
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, canonicalKey{keyId: r.Keys[i].KeyId, data: data})
+	}
+
+	sort.SliceStable(keys, func(i, j int) bool {
+		if keys[i].keyId != keys[j].keyId {
+			return keys[i].keyId < keys[j].keyId
+		}
+
+		return bytes.Compare(keys[i].data, keys[j].data) < 0
+	})
+
+	buf := bytes.NewBufferString(`{"keys":[`)
+
+	for i, key := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		buf.Write(key.data)
+	}
+
+	buf.WriteString("]}")
+
+	return buf.Bytes(), nil
+}
+
+// canonicalizeJson re-encodes data with sorted object members, preserving number literals
+func canonicalizeJson(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
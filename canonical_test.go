@@ -0,0 +1,79 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_MarshalCanonical(t *testing.T) {
+	t.Run("orders members lexicographically", func(t *testing.T) {
+		req := require.New(t)
+
+		key := &Key{}
+		req.NoError(json.Unmarshal([]byte(`{"kty":"oct","k":"AQAB","kid":"a<b","z-ext":{"b":1,"a":1.50}}`), key))
+
+		data, err := key.MarshalCanonical()
+		req.NoError(err)
+		req.Equal(`{"k":"AQAB","kid":"a<b","kty":"oct","z-ext":{"a":1.50,"b":1}}`, string(data))
+	})
+
+	t.Run("orders keys independently of the input order", func(t *testing.T) {
+		req := require.New(t)
+
+		response := &Response{}
+		req.NoError(json.Unmarshal([]byte(testJwksRfc7517Examples), response))
+
+		expected, err := response.MarshalCanonical()
+		req.NoError(err)
+
+		reversed := &Response{Keys: []Key{response.Keys[2], response.Keys[1], response.Keys[0]}}
+		actual, err := reversed.MarshalCanonical()
+		req.NoError(err)
+		req.Equal(string(expected), string(actual))
+
+		parsed := &Response{}
+		req.NoError(json.Unmarshal(actual, parsed))
+		req.Equal("1", parsed.Keys[0].KeyId)
+		req.Equal("2011-04-29", parsed.Keys[1].KeyId)
+		req.Equal("juliet@capulet.lit", parsed.Keys[2].KeyId)
+	})
+
+	t.Run("orders keys sharing a kid by content", func(t *testing.T) {
+		req := require.New(t)
+
+		a := Key{KeyType: KeyTypeOct, KeyId: "same", K: "AQAB"}
+		b := Key{KeyType: KeyTypeOct, KeyId: "same", K: "AQAC"}
+
+		first, err := (&Response{Keys: []Key{a, b}}).MarshalCanonical()
+		req.NoError(err)
+
+		second, err := (&Response{Keys: []Key{b, a}}).MarshalCanonical()
+		req.NoError(err)
+		req.Equal(string(first), string(second))
+	})
+
+	t.Run("renders an empty set", func(t *testing.T) {
+		req := require.New(t)
+
+		data, err := (&Response{}).MarshalCanonical()
+		req.NoError(err)
+		req.Equal(`{"keys":[]}`, string(data))
+	})
+}
@@ -0,0 +1,65 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"encoding/json"
+)
+
+// Clone returns an independent deep copy of the key, including its key_ops, x5c and additional members
+func (k *Key) Clone() *Key {
+	ret := k.clone()
+	return &ret
+}
+
+// Clone returns an independent deep copy of the set, see Key.Clone
+func (r *Response) Clone() *Response {
+	ret := &Response{}
+
+	if r.Keys != nil {
+		ret.Keys = make([]Key, 0, len(r.Keys))
+
+		for i := range r.Keys {
+			ret.Keys = append(ret.Keys, r.Keys[i].clone())
+		}
+	}
+
+	return ret
+}
+
+// clone returns a deep copy of k
+func (k Key) clone() Key {
+	if k.KeyOperations != nil {
+		k.KeyOperations = append([]string{}, k.KeyOperations...)
+	}
+
+	if k.X509Chain != nil {
+		k.X509Chain = append([]string{}, k.X509Chain...)
+	}
+
+	if k.AdditionalMembers != nil {
+		members := make(map[string]json.RawMessage, len(k.AdditionalMembers))
+
+		for name, value := range k.AdditionalMembers {
+			members[name] = append(json.RawMessage{}, value...)
+		}
+
+		k.AdditionalMembers = members
+	}
+
+	return k
+}
@@ -0,0 +1,72 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_Clone(t *testing.T) {
+	t.Run("can deep copy a key", func(t *testing.T) {
+		req := require.New(t)
+
+		response := &Response{}
+		req.NoError(json.Unmarshal([]byte(testJwksWithExtensions), response))
+
+		key := &response.Keys[0]
+		req.NotEmpty(key.AdditionalMembers)
+		key.KeyOperations = []string{KeyOperationVerify}
+		key.X509Chain = []string{"AA=="}
+
+		clone := key.Clone()
+		req.Equal(key, clone)
+
+		clone.KeyOperations[0] = KeyOperationSign
+		clone.X509Chain[0] = "AQ=="
+		for name := range clone.AdditionalMembers {
+			clone.AdditionalMembers[name][0] = ' '
+		}
+
+		req.Equal([]string{KeyOperationVerify}, key.KeyOperations)
+		req.Equal([]string{"AA=="}, key.X509Chain)
+		req.NotEqual(key.AdditionalMembers, clone.AdditionalMembers)
+	})
+
+	t.Run("can deep copy a response", func(t *testing.T) {
+		req := require.New(t)
+
+		response := &Response{}
+		req.NoError(json.Unmarshal([]byte(testJwksRfc7517Examples), response))
+
+		clone := response.Clone()
+		req.Equal(response, clone)
+
+		clone.Keys[0].KeyId = "changed"
+		clone.Keys = append(clone.Keys[:1], clone.Keys[2:]...)
+
+		req.Equal("1", response.Keys[0].KeyId)
+		req.Equal("2011-04-29", response.Keys[1].KeyId)
+	})
+
+	t.Run("keeps nil keys nil", func(t *testing.T) {
+		req := require.New(t)
+
+		req.Nil((&Response{}).Clone().Keys)
+	})
+}
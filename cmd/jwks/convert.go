@@ -0,0 +1,295 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"github.com/openziti/jwks"
+	"github.com/pkg/errors"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+const (
+	pemTypeCertificate = "CERTIFICATE"
+
+	formatPem  = "pem"
+	formatDer  = "der"
+	formatJwk  = "jwk"
+	formatJwks = "jwks"
+)
+
+// stdin is read by commands given "-" as input
+var stdin io.Reader = os.Stdin
+
+// convert converts keys between PEM, DER, and JWK or JWKS
+func convert(args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("convert", "<file | ->", stderr)
+	from := flags.String("from", "", "input format, one of pem, der, jwk (also accepts JWKS), detected if empty")
+	to := flags.String("to", "", "output format, one of pem, der, jwk, jwks, defaults to pem for JWK input and jwks otherwise")
+	public := flags.Bool("public", false, "only output public keys, dropping private key material")
+	kid := flags.String("kid", "", "kid of keys read from PEM or DER, the RFC 7638 thumbprint if empty")
+	alg := flags.String("alg", "", "alg of keys read from PEM or DER, inferred if empty")
+	passphraseEnv := flags.String("passphrase-env", "", "environment variable holding the passphrase of encrypted PKCS#8 input")
+	encrypt := flags.Bool("encrypt", false, "encrypt PEM private keys with the passphrase from -passphrase-env")
+	output := flags.String("o", "", "write the result to this file instead of stdout")
+	compact := flags.Bool("compact", false, "do not indent JSON output")
+
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+
+	var passphrase []byte
+
+	if *passphraseEnv != "" {
+		var err error
+
+		if passphrase, err = passphraseFromEnv(*passphraseEnv); err != nil {
+			return err
+		}
+	}
+
+	if *encrypt && len(passphrase) == 0 {
+		_, _ = fmt.Fprintln(stderr, "-encrypt requires -passphrase-env")
+		flags.Usage()
+		return errUsage
+	}
+
+	data, err := readInput(flags.Arg(0))
+
+	if err != nil {
+		return err
+	}
+
+	if *from == "" {
+		*from = detectFormat(data)
+	}
+
+	var opts []jwks.KeyOption
+
+	if *kid != "" {
+		opts = append(opts, jwks.WithKeyId(*kid))
+	}
+
+	if *alg != "" {
+		opts = append(opts, jwks.WithAlgorithm(*alg))
+	}
+
+	if passphrase != nil {
+		opts = append(opts, jwks.WithPassphrase(passphrase))
+	}
+
+	keys, err := decodeKeys(*from, data, opts)
+
+	if err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		return errors.New("no keys found in input")
+	}
+
+	if *public {
+		keys = (&jwks.Response{Keys: keys}).PublicOnly().Keys
+	}
+
+	if *to == "" {
+		*to = formatJwks
+
+		if *from == formatJwk {
+			*to = formatPem
+		}
+	}
+
+	if *encrypt && *to != formatPem {
+		return errors.New("-encrypt is only supported for pem output")
+	}
+
+	if !*encrypt {
+		passphrase = nil
+	}
+
+	result, err := encodeKeys(*to, keys, passphrase, *compact)
+
+	if err != nil {
+		return err
+	}
+
+	if *to != formatDer {
+		result = withNewline(result)
+	}
+
+	return write(*output, result, stdout)
+}
+
+// passphraseFromEnv returns the passphrase held by the environment variable name
+func passphraseFromEnv(name string) ([]byte, error) {
+	passphrase := os.Getenv(name)
+
+	if passphrase == "" {
+		return nil, fmt.Errorf("environment variable %s is empty", name)
+	}
+
+	return []byte(passphrase), nil
+}
+
+// readInput reads the file at path, or stdin if path is "-"
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return ioutil.ReadAll(stdin)
+	}
+
+	return os.ReadFile(path)
+}
+
+// detectFormat returns the format of data, pem if it contains a PEM block, jwk if it is a JSON object and der
+// otherwise
+func detectFormat(data []byte) string {
+	if block, _ := pem.Decode(data); block != nil {
+		return formatPem
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		return formatJwk
+	}
+
+	return formatDer
+}
+
+// decodeKeys decodes the keys in data, which is in the given format
+func decodeKeys(format string, data []byte, opts []jwks.KeyOption) ([]jwks.Key, error) {
+	switch format {
+	case formatPem:
+		return decodePem(data, opts)
+	case formatDer:
+		key, err := jwks.NewKeyFromDER(data, opts...)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return []jwks.Key{*key}, nil
+	case formatJwk, formatJwks:
+		response, err := jwks.ParseResponse(data)
+
+		if err != nil {
+			return nil, fmt.Errorf("could not parse JWK: %s", err)
+		}
+
+		return response.Keys, nil
+	}
+
+	return nil, fmt.Errorf("unsupported input format %q", format)
+}
+
+// decodePem decodes the keys in the PEM blocks of data as jwks.ParsePEMBundle does, additionally accepting
+// certificates, which result in keys with their x5c, x5t, and x5t#S256 set
+func decodePem(data []byte, opts []jwks.KeyOption) ([]jwks.Key, error) {
+	var keys []jwks.Key
+	var key *jwks.Key
+	var err error
+
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		switch block.Type {
+		case jwks.PemTypeEcParameters:
+			continue
+		case pemTypeCertificate:
+			var cert *x509.Certificate
+
+			if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+				key, err = jwks.NewKey("", cert, []*x509.Certificate{cert}, opts...)
+			}
+		default:
+			key, err = jwks.ParsePEMKey(pem.EncodeToMemory(block), opts...)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, *key)
+	}
+
+	return keys, nil
+}
+
+// encodeKeys encodes keys in the given format. Private keys are encoded as PKCS#8 unless only public, encrypted
+// with passphrase if it is not empty, and public keys as PKIX.
+func encodeKeys(format string, keys []jwks.Key, passphrase []byte, compact bool) ([]byte, error) {
+	switch format {
+	case formatJwks:
+		return marshal(&jwks.Response{Keys: keys}, compact)
+	case formatJwk:
+		if len(keys) != 1 {
+			return nil, fmt.Errorf("jwk output requires exactly one key, found %d, use jwks", len(keys))
+		}
+
+		return marshal(keys[0], compact)
+	case formatPem:
+		var result []byte
+
+		for i := range keys {
+			keyPem, err := encodePem(&keys[i], passphrase)
+
+			if err != nil {
+				return nil, err
+			}
+
+			result = append(result, keyPem...)
+		}
+
+		return result, nil
+	case formatDer:
+		if len(keys) != 1 {
+			return nil, fmt.Errorf("der output requires exactly one key, found %d", len(keys))
+		}
+
+		keyPem, err := encodePem(&keys[0], nil)
+
+		if err != nil {
+			return nil, err
+		}
+
+		block, _ := pem.Decode(keyPem)
+
+		return block.Bytes, nil
+	}
+
+	return nil, fmt.Errorf("unsupported output format %q", format)
+}
+
+// encodePem returns the private key of key as a PKCS#8 PEM block if it has private key material, and its public key
+// as a PKIX PEM block otherwise
+func encodePem(key *jwks.Key, passphrase []byte) ([]byte, error) {
+	if key.IsSymmetric() {
+		return nil, fmt.Errorf("symmetric key %s can not be encoded as PEM or DER", key.KeyId)
+	}
+
+	if !key.IsPrivate() {
+		return key.PublicPEM()
+	}
+
+	if len(passphrase) > 0 {
+		return key.EncryptedPrivatePEM(passphrase)
+	}
+
+	return key.PrivatePEM()
+}
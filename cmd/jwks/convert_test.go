@@ -0,0 +1,178 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/openziti/jwks"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// convertWith runs the convert command with input as stdin and returns stdout
+func convertWith(t *testing.T, input []byte, args ...string) []byte {
+	stdin = bytes.NewReader(input)
+	defer func() { stdin = os.Stdin }()
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+	require.Equal(t, 0, run(append(append([]string{"convert"}, args...), "-"), stdout, stderr), stderr.String())
+
+	return stdout.Bytes()
+}
+
+func Test_convert(t *testing.T) {
+	private, public, err := jwks.GenerateECKey("P-256", "ec")
+	require.NoError(t, err)
+
+	privateRsa, _, err := jwks.GenerateRSAKey(2048, "rsa")
+	require.NoError(t, err)
+
+	privateJson, err := json.Marshal(private)
+	require.NoError(t, err)
+
+	t.Run("can convert a private JWK to PEM and back", func(t *testing.T) {
+		req := require.New(t)
+
+		privatePem := convertWith(t, privateJson)
+
+		block, _ := pem.Decode(privatePem)
+		req.NotNil(block)
+		req.Equal(jwks.PemTypePrivateKey, block.Type)
+
+		response, err := jwks.ParseResponse(convertWith(t, privatePem, "-kid", "ec", "-alg", jwks.AlgorithmEs256))
+		req.NoError(err)
+		req.Len(response.Keys, 1)
+		req.Equal(private.D, response.Keys[0].D)
+		req.Equal("ec", response.Keys[0].KeyId)
+	})
+
+	t.Run("can convert only the public key", func(t *testing.T) {
+		req := require.New(t)
+
+		publicPem := convertWith(t, privateJson, "-public")
+
+		block, _ := pem.Decode(publicPem)
+		req.NotNil(block)
+		req.Equal(jwks.PemTypePublicKey, block.Type)
+
+		key, err := jwks.ParseKey(convertWith(t, privateJson, "-public", "-to", "jwk"))
+		req.NoError(err)
+		req.Equal(public.X, key.X)
+		req.Empty(key.D)
+	})
+
+	t.Run("can convert DER to a JWK and back", func(t *testing.T) {
+		req := require.New(t)
+
+		der := convertWith(t, privateJson, "-to", "der")
+
+		key, err := jwks.ParseKey(convertWith(t, der, "-to", "jwk"))
+		req.NoError(err)
+		req.Equal(private.D, key.D)
+
+		_, err = jwks.NewKeyFromDER(der)
+		req.NoError(err)
+	})
+
+	t.Run("can convert PEM bundles to a JWKS", func(t *testing.T) {
+		req := require.New(t)
+
+		bundle, err := (&jwks.Response{Keys: []jwks.Key{*private, *privateRsa}}).PublicOnly().PublicPEM()
+		req.NoError(err)
+
+		response, err := jwks.ParseResponse(convertWith(t, bundle, "-from", "pem"))
+		req.NoError(err)
+		req.Len(response.Keys, 2)
+		req.Equal(jwks.KeyTypeEc, response.Keys[0].KeyType)
+		req.Equal(jwks.KeyTypeRsa, response.Keys[1].KeyType)
+	})
+
+	t.Run("can encrypt and decrypt private PEM keys", func(t *testing.T) {
+		req := require.New(t)
+
+		t.Setenv("JWKS_TEST_PASSPHRASE", "secret")
+
+		encrypted := convertWith(t, privateJson, "-encrypt", "-passphrase-env", "JWKS_TEST_PASSPHRASE")
+		req.True(strings.Contains(string(encrypted), jwks.PemTypeEncryptedPrivateKey))
+
+		key, err := jwks.ParseKey(convertWith(t, encrypted, "-to", "jwk", "-passphrase-env", "JWKS_TEST_PASSPHRASE"))
+		req.NoError(err)
+		req.Equal(private.D, key.D)
+	})
+
+	t.Run("can write private keys to a file", func(t *testing.T) {
+		req := require.New(t)
+
+		path := filepath.Join(t.TempDir(), "key.json")
+		input := filepath.Join(t.TempDir(), "key.pem")
+
+		privatePem, err := private.PrivatePEM()
+		req.NoError(err)
+		req.NoError(os.WriteFile(input, privatePem, 0600))
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		req.Equal(0, run([]string{"convert", "-o", path, input}, stdout, stderr), stderr.String())
+		req.Empty(stdout.String())
+
+		written, err := os.ReadFile(path)
+		req.NoError(err)
+
+		response, err := jwks.ParseResponse(written)
+		req.NoError(err)
+		req.Len(response.Keys, 1)
+	})
+
+	t.Run("rejects unsupported conversions", func(t *testing.T) {
+		req := require.New(t)
+
+		secret, err := jwks.GenerateOctKey(32, "oct")
+		req.NoError(err)
+
+		secretJson, err := json.Marshal(secret)
+		req.NoError(err)
+
+		set, err := json.Marshal(&jwks.Response{Keys: []jwks.Key{*private, *privateRsa}})
+		req.NoError(err)
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		for _, c := range []struct {
+			input []byte
+			args  []string
+			code  int
+		}{
+			{secretJson, []string{"-to", "pem"}, 1},
+			{set, []string{"-to", "jwk"}, 1},
+			{set, []string{"-to", "der"}, 1},
+			{privateJson, []string{"-to", "xml"}, 1},
+			{[]byte("garbage"), nil, 1},
+			{privateJson, []string{"-encrypt"}, 2},
+		} {
+			stdin = bytes.NewReader(c.input)
+			req.Equal(c.code, run(append(append([]string{"convert"}, c.args...), "-"), stdout, stderr), c.args)
+		}
+
+		stdin = os.Stdin
+	})
+}
@@ -0,0 +1,121 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"github.com/openziti/jwks"
+	"github.com/pkg/errors"
+	"io"
+	"strings"
+)
+
+// diff reports the keys added, removed, and changed between two JWKS sources
+func diff(args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("diff", "<old> <new>", stderr)
+	source := addSourceFlags(flags)
+	asJson := flags.Bool("json", false, "output the diff as JSON, including the keys")
+	compact := flags.Bool("compact", false, "do not indent JSON output")
+	exitCode := flags.Bool("exit-code", false, "fail if the key sets differ")
+
+	if err := parseFlags(flags, args, 2); err != nil {
+		return err
+	}
+
+	old, _, err := source.load(flags.Arg(0))
+
+	if err != nil {
+		return err
+	}
+
+	updated, _, err := source.load(flags.Arg(1))
+
+	if err != nil {
+		return err
+	}
+
+	result, err := jwks.DiffResponses(old, updated)
+
+	if err != nil {
+		return err
+	}
+
+	var output []byte
+
+	if *asJson {
+		if output, err = marshal(result, *compact); err != nil {
+			return err
+		}
+	} else {
+		output = []byte(formatDiff(result))
+	}
+
+	if err = write("", withNewline(output), stdout); err != nil {
+		return err
+	}
+
+	if *exitCode && !result.IsEmpty() {
+		return errors.New("key sets differ")
+	}
+
+	return nil
+}
+
+// formatDiff renders result as one line per added (+), removed (-), and changed (~) key followed by a summary. Only
+// identifying members are shown so private key material is never printed.
+func formatDiff(result *jwks.ResponseDiff) string {
+	text := &strings.Builder{}
+
+	for i := range result.Added {
+		_, _ = fmt.Fprintf(text, "+ %s\n", describeKey(&result.Added[i]))
+	}
+
+	for i := range result.Removed {
+		_, _ = fmt.Fprintf(text, "- %s\n", describeKey(&result.Removed[i]))
+	}
+
+	for i := range result.Changed {
+		_, _ = fmt.Fprintf(text, "~ %s: %s\n", describeKey(&result.Changed[i].New), strings.Join(result.Changed[i].Members, ", "))
+	}
+
+	_, _ = fmt.Fprintf(text, "%d added, %d removed, %d changed, %d unchanged", len(result.Added), len(result.Removed), len(result.Changed), result.Unchanged)
+
+	return text.String()
+}
+
+// describeKey returns the kid, or the thumbprint for keys without one, followed by the kty, crv, alg, and use
+func describeKey(key *jwks.Key) string {
+	name := key.KeyId
+
+	if name == "" {
+		name = "(no kid)"
+
+		if thumbprint, err := key.Jkt(); err == nil {
+			name = "(thumbprint " + thumbprint + ")"
+		}
+	}
+
+	var details []string
+
+	for _, detail := range []string{key.KeyType, key.Curve, key.Algorithm, key.Use} {
+		if detail != "" {
+			details = append(details, detail)
+		}
+	}
+
+	return fmt.Sprintf("%s [%s]", name, strings.Join(details, " "))
+}
@@ -0,0 +1,110 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/openziti/jwks"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_diff(t *testing.T) {
+	server, body := newTestServer(t)
+
+	served, err := jwks.ParseResponse(body)
+	require.NoError(t, err)
+
+	_, added, err := jwks.GenerateOKPKey(jwks.CurveEd25519, "three")
+	require.NoError(t, err)
+
+	changed := served.Keys[0]
+	changed.Use = jwks.UseEncryption
+
+	data, err := json.Marshal(&jwks.Response{Keys: []jwks.Key{changed, *added}})
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, data, 0600))
+
+	t.Run("can diff a URL and a file", func(t *testing.T) {
+		req := require.New(t)
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		req.Equal(0, run([]string{"diff", server.URL + "/keys", path}, stdout, stderr), stderr.String())
+
+		lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+		req.Equal([]string{
+			"+ three [OKP Ed25519 EdDSA sig]",
+			"- two [EC P-256 ES256 sig]",
+			"~ one [EC P-256 ES256 enc]: use",
+			"1 added, 1 removed, 1 changed, 0 unchanged",
+		}, lines)
+	})
+
+	t.Run("can output the diff as JSON", func(t *testing.T) {
+		req := require.New(t)
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		req.Equal(0, run([]string{"diff", "-json", path, server.URL + "/keys"}, stdout, stderr), stderr.String())
+
+		result := &struct {
+			Added   []jwks.Key `json:"added"`
+			Removed []jwks.Key `json:"removed"`
+			Changed []struct {
+				KeyId   string   `json:"kid"`
+				Members []string `json:"members"`
+			} `json:"changed"`
+		}{}
+
+		req.NoError(json.Unmarshal(stdout.Bytes(), result))
+		req.Len(result.Added, 1)
+		req.Equal("two", result.Added[0].KeyId)
+		req.Len(result.Removed, 1)
+		req.Equal("three", result.Removed[0].KeyId)
+		req.Equal([]string{"use"}, result.Changed[0].Members)
+	})
+
+	t.Run("can fail if the sets differ", func(t *testing.T) {
+		req := require.New(t)
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		req.Equal(1, run([]string{"diff", "-exit-code", server.URL + "/keys", path}, stdout, stderr))
+		req.True(strings.Contains(stderr.String(), "key sets differ"), stderr.String())
+
+		stdout.Reset()
+
+		req.Equal(0, run([]string{"diff", "-exit-code", "-issuer", server.URL, server.URL}, stdout, stderr))
+		req.Equal("0 added, 0 removed, 0 changed, 2 unchanged\n", stdout.String())
+	})
+
+	t.Run("rejects invalid arguments", func(t *testing.T) {
+		req := require.New(t)
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		req.Equal(2, run([]string{"diff", path}, stdout, stderr))
+		req.Equal(1, run([]string{"diff", path, server.URL + "/missing"}, stdout, stderr))
+	})
+}
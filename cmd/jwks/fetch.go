@@ -0,0 +1,99 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/openziti/jwks"
+	"io"
+	"os"
+)
+
+// fetch retrieves a JWKS from a URL, or from the jwks_uri of an issuer with -issuer, and prints it
+func fetch(args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("fetch", "<url | issuer>", stderr)
+	source := addSourceFlags(flags)
+	kid := flags.String("kid", "", "only output keys with this kid")
+	output := flags.String("o", "", "write the JWKS to this file instead of stdout")
+	compact := flags.Bool("compact", false, "do not indent the output")
+	raw := flags.Bool("raw", false, "output the response body as received, cannot be combined with -kid")
+
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+
+	if *raw && *kid != "" {
+		_, _ = fmt.Fprintln(stderr, "-raw can not be combined with -kid")
+		flags.Usage()
+		return errUsage
+	}
+
+	response, body, err := source.load(flags.Arg(0))
+
+	if err != nil {
+		return err
+	}
+
+	if !*raw {
+		if *kid != "" {
+			response = &jwks.Response{
+				Keys: response.Filter(func(key *jwks.Key) bool {
+					return key.KeyId == *kid
+				}),
+			}
+
+			if len(response.Keys) == 0 {
+				return fmt.Errorf("no key with kid %q", *kid)
+			}
+		}
+
+		if body, err = marshal(response, *compact); err != nil {
+			return err
+		}
+	}
+
+	return write(*output, withNewline(body), stdout)
+}
+
+// marshal returns the JSON form of v, indented unless compact is set
+func marshal(v interface{}, compact bool) ([]byte, error) {
+	if compact {
+		return json.Marshal(v)
+	}
+
+	return json.MarshalIndent(v, "", "  ")
+}
+
+// withNewline returns data with a trailing newline
+func withNewline(data []byte) []byte {
+	if len(data) == 0 || data[len(data)-1] != '\n' {
+		data = append(data, '\n')
+	}
+
+	return data
+}
+
+// write writes data to the file at path, or to stdout if path is empty
+func write(path string, data []byte, stdout io.Writer) error {
+	if path == "" {
+		_, err := stdout.Write(data)
+		return err
+	}
+
+	return os.WriteFile(path, data, 0600)
+}
@@ -0,0 +1,136 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/openziti/jwks"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestServer serves a JWKS with two keys at /keys and an OpenID Provider configuration pointing to it
+func newTestServer(t *testing.T) (*httptest.Server, []byte) {
+	var keys []jwks.Key
+
+	for _, kid := range []string{"one", "two"} {
+		_, public, err := jwks.GenerateECKey("P-256", kid)
+		require.NoError(t, err)
+
+		keys = append(keys, *public)
+	}
+
+	body, err := json.Marshal(&jwks.Response{Keys: keys})
+	require.NoError(t, err)
+
+	var server *httptest.Server
+
+	server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case jwks.DiscoveryPath:
+			rw.Header().Set("content-type", "application/json")
+			_, _ = fmt.Fprintf(rw, `{"issuer": %q, "jwks_uri": %q}`, server.URL, server.URL+"/keys")
+		case "/keys":
+			rw.Header().Set("content-type", "application/json")
+			_, _ = rw.Write(body)
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+
+	t.Cleanup(server.Close)
+
+	return server, body
+}
+
+func Test_fetch(t *testing.T) {
+	server, body := newTestServer(t)
+
+	t.Run("can fetch and pretty-print a JWKS", func(t *testing.T) {
+		req := require.New(t)
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		req.Equal(0, run([]string{"fetch", server.URL + "/keys"}, stdout, stderr), stderr.String())
+		req.JSONEq(string(body), stdout.String())
+		req.True(strings.Contains(stdout.String(), "\n  \"keys\""), stdout.String())
+	})
+
+	t.Run("can fetch the JWKS of an issuer", func(t *testing.T) {
+		req := require.New(t)
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		req.Equal(0, run([]string{"fetch", "-issuer", "-compact", server.URL}, stdout, stderr), stderr.String())
+		req.JSONEq(string(body), stdout.String())
+		req.Equal(1, strings.Count(stdout.String(), "\n"))
+	})
+
+	t.Run("can filter by kid", func(t *testing.T) {
+		req := require.New(t)
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		req.Equal(0, run([]string{"fetch", "-kid", "two", server.URL + "/keys"}, stdout, stderr), stderr.String())
+
+		response, err := jwks.ParseResponse(stdout.Bytes())
+		req.NoError(err)
+		req.Len(response.Keys, 1)
+		req.Equal("two", response.Keys[0].KeyId)
+	})
+
+	t.Run("fails for unknown kids", func(t *testing.T) {
+		req := require.New(t)
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		req.Equal(1, run([]string{"fetch", "-kid", "three", server.URL + "/keys"}, stdout, stderr))
+		req.Empty(stdout.String())
+		req.True(strings.Contains(stderr.String(), `no key with kid "three"`), stderr.String())
+	})
+
+	t.Run("can write the raw body to a file", func(t *testing.T) {
+		req := require.New(t)
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		path := filepath.Join(t.TempDir(), "jwks.json")
+
+		req.Equal(0, run([]string{"fetch", "-raw", "-o", path, server.URL + "/keys"}, stdout, stderr), stderr.String())
+		req.Empty(stdout.String())
+
+		written, err := os.ReadFile(path)
+		req.NoError(err)
+		req.Equal(string(body)+"\n", string(written))
+	})
+
+	t.Run("fails for unreachable documents", func(t *testing.T) {
+		req := require.New(t)
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		req.Equal(1, run([]string{"fetch", server.URL + "/missing"}, stdout, stderr))
+		req.Equal(1, run([]string{"fetch", "-issuer", server.URL + "/missing"}, stdout, stderr))
+		req.Equal(1, run([]string{"fetch", "-max-body-size", "10", server.URL + "/keys"}, stdout, stderr))
+	})
+}
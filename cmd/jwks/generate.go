@@ -0,0 +1,136 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"github.com/openziti/jwks"
+	"io"
+	"strings"
+)
+
+// kidStrategies maps the -kid-strategy names to KidStrategy
+var kidStrategies = map[string]jwks.KidStrategy{
+	"thumbprint": jwks.ThumbprintKidStrategy,
+	"uuid":       jwks.UuidKidStrategy,
+}
+
+// generate generates a key and outputs it as a private JWK and a public JWKS
+func generate(args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("generate", "", stderr)
+	kty := flags.String("kty", jwks.KeyTypeEc, "key type, one of RSA, EC, OKP, oct")
+	bits := flags.Int("bits", 2048, "size of RSA keys in bits")
+	size := flags.Int("bytes", 32, "size of oct keys in bytes")
+	crv := flags.String("crv", "", "curve of EC and OKP keys, defaults to P-256 and Ed25519")
+	kid := flags.String("kid", "", "kid of the key, generated with -kid-strategy if empty")
+	kidStrategy := flags.String("kid-strategy", "", "strategy for generated kids, one of thumbprint, uuid, defaults to uuid for oct keys and thumbprint otherwise")
+	alg := flags.String("alg", "", "alg of the key, inferred if empty")
+	use := flags.String("use", "", "use of the key, one of sig, enc")
+	output := flags.String("o", "", "write the private JWK to this file instead of stdout")
+	publicOutput := flags.String("public-out", "", "write the public JWKS to this file instead of stdout, not supported for oct keys")
+	compact := flags.Bool("compact", false, "do not indent the output")
+
+	if err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+
+	var opts []jwks.KeyOption
+
+	if *kidStrategy != "" {
+		strategy, ok := kidStrategies[*kidStrategy]
+
+		if !ok {
+			_, _ = fmt.Fprintf(stderr, "unknown kid strategy %q\n", *kidStrategy)
+			flags.Usage()
+			return errUsage
+		}
+
+		opts = append(opts, jwks.WithKidStrategy(strategy))
+	}
+
+	if *alg != "" {
+		opts = append(opts, jwks.WithAlgorithm(*alg))
+	}
+
+	if *use != "" && *use != jwks.UseSignature && *use != jwks.UseEncryption {
+		_, _ = fmt.Fprintf(stderr, "unknown use %q\n", *use)
+		flags.Usage()
+		return errUsage
+	}
+
+	private, public, err := generateKey(*kty, *bits, *size, *crv, *kid, opts)
+
+	if err != nil {
+		return err
+	}
+
+	if public == nil && *publicOutput != "" {
+		return fmt.Errorf("%s keys are symmetric and have no public set", jwks.KeyTypeOct)
+	}
+
+	if *use != "" {
+		private.Use = *use
+
+		if public != nil {
+			public.Use = *use
+		}
+	}
+
+	privateJson, err := marshal(private, *compact)
+
+	if err != nil {
+		return err
+	}
+
+	if err = write(*output, withNewline(privateJson), stdout); err != nil || public == nil {
+		return err
+	}
+
+	publicJson, err := marshal(&jwks.Response{Keys: []jwks.Key{*public}}, *compact)
+
+	if err != nil {
+		return err
+	}
+
+	return write(*publicOutput, withNewline(publicJson), stdout)
+}
+
+// generateKey generates a private key of type kty and its public counterpart, which is nil for oct keys
+func generateKey(kty string, bits, size int, crv, kid string, opts []jwks.KeyOption) (*jwks.Key, *jwks.Key, error) {
+	switch strings.ToUpper(kty) {
+	case strings.ToUpper(jwks.KeyTypeRsa):
+		return jwks.GenerateRSAKey(bits, kid, opts...)
+	case strings.ToUpper(jwks.KeyTypeEc):
+		if crv == "" {
+			crv = "P-256"
+		}
+
+		return jwks.GenerateECKey(crv, kid, opts...)
+	case strings.ToUpper(jwks.KeyTypeOkp):
+		if crv == "" {
+			crv = jwks.CurveEd25519
+		}
+
+		return jwks.GenerateOKPKey(crv, kid, opts...)
+	case strings.ToUpper(jwks.KeyTypeOct):
+		key, err := jwks.GenerateOctKey(size, kid, opts...)
+
+		return key, nil, err
+	}
+
+	return nil, nil, fmt.Errorf("unsupported key type %q", kty)
+}
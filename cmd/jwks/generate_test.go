@@ -0,0 +1,151 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/json"
+	"github.com/openziti/jwks"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// decodeGenerated decodes the private JWK and, unless symmetric, public JWKS written to stdout by generate
+func decodeGenerated(t *testing.T, stdout *bytes.Buffer, symmetric bool) (*jwks.Key, *jwks.Response) {
+	decoder := json.NewDecoder(stdout)
+
+	private := &jwks.Key{}
+	require.NoError(t, decoder.Decode(private))
+
+	if symmetric {
+		require.False(t, decoder.More())
+		return private, nil
+	}
+
+	public := &jwks.Response{}
+	require.NoError(t, decoder.Decode(public))
+
+	return private, public
+}
+
+func Test_generate(t *testing.T) {
+	t.Run("can generate every key type", func(t *testing.T) {
+		req := require.New(t)
+
+		for _, kty := range []string{jwks.KeyTypeRsa, jwks.KeyTypeEc, jwks.KeyTypeOkp} {
+			stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+			req.Equal(0, run([]string{"generate", "-kty", kty}, stdout, stderr), stderr.String())
+
+			private, public := decodeGenerated(t, stdout, false)
+			req.Equal(kty, private.KeyType)
+			req.True(private.IsPrivate())
+			req.Len(public.Keys, 1)
+			req.True(public.Keys[0].IsPublic())
+			req.Equal(private.KeyId, public.Keys[0].KeyId)
+		}
+	})
+
+	t.Run("can generate oct keys without a public set", func(t *testing.T) {
+		req := require.New(t)
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		req.Equal(0, run([]string{"generate", "-kty", "oct", "-bytes", "64"}, stdout, stderr), stderr.String())
+
+		private, _ := decodeGenerated(t, stdout, true)
+		req.Equal(jwks.KeyTypeOct, private.KeyType)
+		req.Equal(jwks.AlgorithmHs512, private.Algorithm)
+
+		req.Equal(1, run([]string{"generate", "-kty", "oct", "-public-out", "x"}, stdout, stderr))
+	})
+
+	t.Run("can set the kid, alg, and use", func(t *testing.T) {
+		req := require.New(t)
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		args := []string{"generate", "-kty", "EC", "-crv", "P-384", "-kid", "my-key", "-alg", jwks.AlgorithmEs384, "-use", "sig"}
+		req.Equal(0, run(args, stdout, stderr), stderr.String())
+
+		private, public := decodeGenerated(t, stdout, false)
+		req.Equal("my-key", private.KeyId)
+		req.Equal("P-384", private.Curve)
+		req.Equal(jwks.AlgorithmEs384, public.Keys[0].Algorithm)
+		req.Equal(jwks.UseSignature, public.Keys[0].Use)
+	})
+
+	t.Run("can use a kid strategy", func(t *testing.T) {
+		req := require.New(t)
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		req.Equal(0, run([]string{"generate", "-kid-strategy", "uuid"}, stdout, stderr), stderr.String())
+
+		private, _ := decodeGenerated(t, stdout, false)
+		req.Len(private.KeyId, 36)
+
+		req.Equal(0, run([]string{"generate", "-kid-strategy", "thumbprint"}, stdout, stderr), stderr.String())
+
+		private, _ = decodeGenerated(t, stdout, false)
+		thumbprint, err := private.Thumbprint(crypto.SHA256)
+		req.NoError(err)
+		req.Equal(thumbprint, private.KeyId)
+	})
+
+	t.Run("can write the keys to files", func(t *testing.T) {
+		req := require.New(t)
+
+		dir := t.TempDir()
+		privatePath, publicPath := filepath.Join(dir, "private.json"), filepath.Join(dir, "public.json")
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		req.Equal(0, run([]string{"generate", "-o", privatePath, "-public-out", publicPath}, stdout, stderr), stderr.String())
+		req.Empty(stdout.String())
+
+		data, err := os.ReadFile(privatePath)
+		req.NoError(err)
+
+		private, err := jwks.ParseKey(data)
+		req.NoError(err)
+		req.True(private.IsPrivate())
+
+		data, err = os.ReadFile(publicPath)
+		req.NoError(err)
+
+		public, err := jwks.ParseResponse(data)
+		req.NoError(err)
+		req.Equal(private.X, public.Keys[0].X)
+		req.True(public.Keys[0].IsPublic())
+	})
+
+	t.Run("rejects invalid arguments", func(t *testing.T) {
+		req := require.New(t)
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		req.Equal(2, run([]string{"generate", "-kid-strategy", "nope"}, stdout, stderr))
+		req.Equal(2, run([]string{"generate", "-use", "nope"}, stdout, stderr))
+		req.Equal(2, run([]string{"generate", "extra"}, stdout, stderr))
+		req.Equal(1, run([]string{"generate", "-kty", "DSA"}, stdout, stderr))
+		req.Equal(1, run([]string{"generate", "-crv", "P-224"}, stdout, stderr))
+	})
+}
@@ -0,0 +1,164 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command jwks fetches and inspects JSON Web Key Sets.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"os"
+	"sort"
+)
+
+// command is a jwks subcommand, run with the arguments following its name
+type command struct {
+	summary string
+	run     func(args []string, stdout, stderr io.Writer) error
+}
+
+var commands = map[string]command{
+	"convert": {
+		summary: "convert keys between PEM, DER, and JWK or JWKS",
+		run:     convert,
+	},
+	"generate": {
+		summary: "generate a key as a private JWK and a public JWKS",
+		run:     generate,
+	},
+	"rotate": {
+		summary: "rotate the keys of a file-backed key store",
+		run:     rotate,
+	},
+	"serve": {
+		summary: "serve a JWKS file or rotated key store over HTTP",
+		run:     serve,
+	},
+	"verify": {
+		summary: "verify a JWT or JWS against a JWKS",
+		run:     verify,
+	},
+	"validate": {
+		summary: "validate and audit a JWKS, failing with a JSON report",
+		run:     validate,
+	},
+	"thumbprint": {
+		summary: "compute thumbprints, thumbprint URIs, and x5t values of keys",
+		run:     thumbprint,
+	},
+	"diff": {
+		summary: "report keys added, removed, and changed between two JWKS",
+		run:     diff,
+	},
+	"fetch": {
+		summary: "fetch a JWKS from a URL or an issuer",
+		run:     fetch,
+	},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the subcommand named by args[0] and returns the exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+
+	cmd, ok := commands[args[0]]
+
+	if !ok {
+		if args[0] == "help" || args[0] == "-h" || args[0] == "-help" || args[0] == "--help" {
+			usage(stdout)
+			return 0
+		}
+
+		_, _ = fmt.Fprintf(stderr, "jwks: unknown command %q\n", args[0])
+		usage(stderr)
+		return 2
+	}
+
+	if err := cmd.run(args[1:], stdout, stderr); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+
+		if err == errUsage {
+			return 2
+		}
+
+		_, _ = fmt.Fprintf(stderr, "jwks %s: %s\n", args[0], err)
+		return 1
+	}
+
+	return 0
+}
+
+func usage(w io.Writer) {
+	_, _ = fmt.Fprintln(w, "usage: jwks <command> [arguments]")
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "commands:")
+
+	var names []string
+
+	for name := range commands {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		_, _ = fmt.Fprintf(w, "  %-10s %s\n", name, commands[name].summary)
+	}
+}
+
+// errUsage is returned by commands whose arguments were invalid, the flag set has already reported the problem
+var errUsage = errors.New("invalid usage")
+
+// newFlagSet returns a flag set for the named command that reports errors to stderr
+func newFlagSet(name, arguments string, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet("jwks "+name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		_, _ = fmt.Fprintf(stderr, "usage: jwks %s [flags] %s\n", name, arguments)
+		flags.PrintDefaults()
+	}
+
+	return flags
+}
+
+// parseFlags parses args, returning flag.ErrHelp if help was requested and errUsage for invalid flags or a number of
+// positional arguments other than positional
+func parseFlags(flags *flag.FlagSet, args []string, positional int) error {
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
+		}
+
+		return errUsage
+	}
+
+	if flags.NArg() != positional {
+		flags.Usage()
+		return errUsage
+	}
+
+	return nil
+}
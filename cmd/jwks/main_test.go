@@ -0,0 +1,65 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func Test_run(t *testing.T) {
+	t.Run("prints usage without a command", func(t *testing.T) {
+		req := require.New(t)
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		req.Equal(2, run(nil, stdout, stderr))
+		req.Empty(stdout.String())
+		req.True(strings.Contains(stderr.String(), "fetch"), stderr.String())
+	})
+
+	t.Run("prints usage for help", func(t *testing.T) {
+		req := require.New(t)
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		req.Equal(0, run([]string{"help"}, stdout, stderr))
+		req.True(strings.HasPrefix(stdout.String(), "usage: jwks"), stdout.String())
+	})
+
+	t.Run("rejects unknown commands", func(t *testing.T) {
+		req := require.New(t)
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		req.Equal(2, run([]string{"nope"}, stdout, stderr))
+		req.True(strings.Contains(stderr.String(), `unknown command "nope"`), stderr.String())
+	})
+
+	t.Run("rejects invalid arguments", func(t *testing.T) {
+		req := require.New(t)
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		req.Equal(2, run([]string{"fetch"}, stdout, stderr))
+		req.Equal(2, run([]string{"fetch", "-nope", "x"}, stdout, stderr))
+		req.Equal(0, run([]string{"fetch", "-h"}, stdout, stderr))
+		req.True(strings.Contains(stderr.String(), "usage: jwks fetch"), stderr.String())
+	})
+}
@@ -0,0 +1,129 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"github.com/openziti/jwks"
+	"io"
+)
+
+// rotate drives a RotationManager against a file-backed key store and prints the resulting published set
+func rotate(args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("rotate", "<store>", stderr)
+	initOnly := flags.Bool("init", false, "only generate missing active and next keys, without rotating")
+	dryRun := flags.Bool("dry-run", false, "rotate a copy of the store in memory and leave the file untouched")
+	kty := flags.String("kty", jwks.KeyTypeEc, "type of generated keys, one of RSA, EC, OKP")
+	bits := flags.Int("bits", 2048, "size of generated RSA keys")
+	crv := flags.String("crv", "", "curve of generated EC and OKP keys, defaults to P-256 and Ed25519")
+	retention := flags.Duration("retention", jwks.DefaultRetention, "how long retired keys stay published")
+	passphraseEnv := flags.String("passphrase-env", "", "environment variable holding the passphrase of an encrypted store")
+	output := flags.String("o", "", "write the published JWKS to this file instead of stdout")
+	compact := flags.Bool("compact", false, "do not indent the output")
+
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+
+	generate, err := keyPairGenerator(*kty, *bits, *crv)
+
+	if err != nil {
+		return err
+	}
+
+	var passphrase []byte
+
+	if *passphraseEnv != "" {
+		if passphrase, err = passphraseFromEnv(*passphraseEnv); err != nil {
+			return err
+		}
+	}
+
+	var store jwks.KeyStore = jwks.NewFileKeyStore(flags.Arg(0), passphrase)
+
+	if *dryRun {
+		keys, err := store.List()
+
+		if err != nil {
+			return err
+		}
+
+		store = jwks.NewSet(keys...)
+	}
+
+	// keys generated by NewRotationManager are reported as pre-published like those generated by Rotate
+	var listed []jwks.Key
+
+	if listed, err = store.List(); err != nil {
+		return err
+	}
+
+	existing := map[string]bool{}
+
+	for _, key := range listed {
+		existing[key.KeyId] = true
+	}
+
+	manager, err := jwks.NewRotationManager(store, generate, jwks.WithRetention(*retention))
+
+	if err != nil {
+		return err
+	}
+
+	prefix := ""
+
+	if *dryRun {
+		prefix = "dry run: "
+	}
+
+	if listed, err = store.List(); err != nil {
+		return err
+	}
+
+	for _, key := range listed {
+		if !existing[key.KeyId] {
+			_, _ = fmt.Fprintf(stderr, "%s%s %s\n", prefix, jwks.KeyPrePublished, key.KeyId)
+		}
+	}
+
+	if !*initOnly {
+		unsubscribe := manager.Subscribe(jwks.RotationSubscriberFunc(func(event jwks.RotationEvent) {
+			_, _ = fmt.Fprintf(stderr, "%s%s %s\n", prefix, event.Type, event.KeyId)
+		}))
+
+		err = manager.Rotate()
+		unsubscribe()
+
+		if err != nil {
+			return err
+		}
+	}
+
+	published, err := manager.PublicSet()
+
+	if err != nil {
+		return err
+	}
+
+	data, err := marshal(published, *compact)
+
+	if err != nil {
+		return err
+	}
+
+	return write(*output, withNewline(data), stdout)
+}
@@ -0,0 +1,134 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"github.com/openziti/jwks"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// rotateWith runs the rotate command and returns the published set and stderr
+func rotateWith(t *testing.T, args ...string) (*jwks.Response, string) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+	require.Equal(t, 0, run(append([]string{"rotate"}, args...), stdout, stderr), stderr.String())
+
+	response, err := jwks.ParseResponse(stdout.Bytes())
+	require.NoError(t, err)
+
+	return response, stderr.String()
+}
+
+func Test_rotate(t *testing.T) {
+	t.Run("can initialize a store with active and next keys", func(t *testing.T) {
+		req := require.New(t)
+
+		path := filepath.Join(t.TempDir(), "store.json")
+
+		published, events := rotateWith(t, "-init", "-kty", "OKP", path)
+		req.Len(published.Keys, 2)
+		req.Equal(2, strings.Count(events, string(jwks.KeyPrePublished)), events)
+
+		for _, key := range published.Keys {
+			req.True(key.IsPublic())
+			req.Equal(jwks.CurveEd25519, key.Curve)
+		}
+
+		again, events := rotateWith(t, "-init", "-kty", "OKP", path)
+		req.Equal(published.Keys, again.Keys)
+		req.Empty(events)
+	})
+
+	t.Run("can rotate the keys of a store", func(t *testing.T) {
+		req := require.New(t)
+
+		path := filepath.Join(t.TempDir(), "store.json")
+
+		initial, _ := rotateWith(t, "-init", path)
+		active, next := initial.Keys[0].KeyId, initial.Keys[1].KeyId
+
+		rotated, events := rotateWith(t, path)
+		req.Len(rotated.Keys, 3)
+		req.Equal(next, rotated.Keys[0].KeyId)
+		req.Equal(active, rotated.Keys[2].KeyId)
+		req.True(strings.Contains(events, string(jwks.KeyActivated)+" "+next), events)
+		req.True(strings.Contains(events, string(jwks.KeyRetired)+" "+active), events)
+		req.NotNil(rotated.Keys[2].ExpiresAt)
+	})
+
+	t.Run("can rotate without writing the store", func(t *testing.T) {
+		req := require.New(t)
+
+		path := filepath.Join(t.TempDir(), "store.json")
+
+		rotateWith(t, "-init", path)
+
+		before, err := os.ReadFile(path)
+		req.NoError(err)
+
+		rotated, events := rotateWith(t, "-dry-run", path)
+		req.Len(rotated.Keys, 3)
+		req.True(strings.Contains(events, "dry run: "+string(jwks.KeyActivated)), events)
+
+		after, err := os.ReadFile(path)
+		req.NoError(err)
+		req.Equal(before, after)
+
+		missing := filepath.Join(t.TempDir(), "missing.json")
+		rotateWith(t, "-dry-run", missing)
+
+		_, err = os.Stat(missing)
+		req.True(os.IsNotExist(err))
+	})
+
+	t.Run("can rotate encrypted stores and write the published set", func(t *testing.T) {
+		req := require.New(t)
+
+		t.Setenv("JWKS_TEST_PASSPHRASE", "secret")
+
+		dir := t.TempDir()
+		path, publishedPath := filepath.Join(dir, "store.jwe"), filepath.Join(dir, "jwks.json")
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		req.Equal(0, run([]string{"rotate", "-passphrase-env", "JWKS_TEST_PASSPHRASE", "-o", publishedPath, path}, stdout, stderr), stderr.String())
+		req.Empty(stdout.String())
+
+		data, err := os.ReadFile(publishedPath)
+		req.NoError(err)
+
+		published, err := jwks.ParseResponse(data)
+		req.NoError(err)
+		req.Len(published.Keys, 3)
+
+		req.Equal(1, run([]string{"rotate", path}, stdout, stderr))
+	})
+
+	t.Run("rejects invalid arguments", func(t *testing.T) {
+		req := require.New(t)
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		req.Equal(2, run([]string{"rotate"}, stdout, stderr))
+		req.Equal(1, run([]string{"rotate", "-kty", "oct", "store.json"}, stdout, stderr))
+	})
+}
@@ -0,0 +1,300 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/openziti/jwks"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// serveOptions configures the handler built by newServeHandler
+type serveOptions struct {
+	path       string
+	jwksPath   string
+	maxAge     time.Duration
+	cors       string
+	issuer     string
+	passphrase []byte
+
+	rotate      bool
+	generate    jwks.KeyPairGenerator
+	rotateEvery time.Duration
+	prePublish  time.Duration
+	retention   time.Duration
+}
+
+// serve serves a JWKS file, or with -rotate a key store managed by a RotationManager, over HTTP
+func serve(args []string, _, stderr io.Writer) error {
+	flags := newFlagSet("serve", "<file>", stderr)
+	addr := flags.String("addr", "127.0.0.1:8080", "address to listen on")
+	jwksPath := flags.String("path", "/.well-known/jwks.json", "path to serve the JWKS at")
+	maxAge := flags.Duration("max-age", jwks.DefaultHandlerMaxAge, "Cache-Control max-age, 0 emits no-cache")
+	cors := flags.String("cors", "", "comma separated origins allowed to fetch the JWKS, * allows any")
+	discovery := flags.Bool("discovery", false, "also serve an OpenID Provider configuration pointing to the JWKS")
+	issuer := flags.String("issuer", "", "issuer of the OpenID Provider configuration, defaults to http://<addr>")
+	passphraseEnv := flags.String("passphrase-env", "", "environment variable holding the passphrase of an encrypted key store, requires -rotate")
+	rotate := flags.Bool("rotate", false, "manage the file as a key store with a RotationManager, generating keys as needed")
+	kty := flags.String("kty", jwks.KeyTypeEc, "type of keys generated with -rotate, one of RSA, EC, OKP")
+	bits := flags.Int("bits", 2048, "size of RSA keys generated with -rotate")
+	crv := flags.String("crv", "", "curve of EC and OKP keys generated with -rotate, defaults to P-256 and Ed25519")
+	rotateEvery := flags.Duration("rotate-every", 0, "rotate keys at this interval, 0 disables scheduled rotation, requires -rotate")
+	prePublish := flags.Duration("pre-publish", 0, "how long next keys are published before activation, defaults to -max-age")
+	retention := flags.Duration("retention", jwks.DefaultRetention, "how long retired keys stay published")
+
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+
+	options := serveOptions{
+		path:        flags.Arg(0),
+		jwksPath:    *jwksPath,
+		maxAge:      *maxAge,
+		cors:        *cors,
+		rotate:      *rotate,
+		rotateEvery: *rotateEvery,
+		prePublish:  *prePublish,
+		retention:   *retention,
+	}
+
+	if !*rotate && (*passphraseEnv != "" || *rotateEvery != 0) {
+		_, _ = fmt.Fprintln(stderr, "-passphrase-env and -rotate-every require -rotate")
+		flags.Usage()
+		return errUsage
+	}
+
+	if *passphraseEnv != "" {
+		var err error
+
+		if options.passphrase, err = passphraseFromEnv(*passphraseEnv); err != nil {
+			return err
+		}
+	}
+
+	if *rotate {
+		var err error
+
+		if options.generate, err = keyPairGenerator(*kty, *bits, *crv); err != nil {
+			return err
+		}
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+
+	if err != nil {
+		return err
+	}
+
+	if *discovery {
+		options.issuer = *issuer
+
+		if options.issuer == "" {
+			options.issuer = "http://" + listener.Addr().String()
+		}
+	}
+
+	handler, stop, err := newServeHandler(options, stderr)
+
+	if err != nil {
+		_ = listener.Close()
+		return err
+	}
+
+	defer stop()
+
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	go func() {
+		if _, ok := <-signals; ok {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			_ = server.Shutdown(ctx)
+		}
+	}()
+
+	_, _ = fmt.Fprintf(stderr, "serving %s at http://%s%s\n", options.path, listener.Addr(), options.jwksPath)
+
+	if err = server.Serve(listener); err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+// keyPairGenerator returns the generator of keys of type kty for -rotate
+func keyPairGenerator(kty string, bits int, crv string) (jwks.KeyPairGenerator, error) {
+	switch strings.ToUpper(kty) {
+	case strings.ToUpper(jwks.KeyTypeRsa):
+		return jwks.RSAKeyPairGenerator(bits), nil
+	case strings.ToUpper(jwks.KeyTypeEc):
+		if crv == "" {
+			crv = "P-256"
+		}
+
+		return jwks.ECKeyPairGenerator(crv), nil
+	case strings.ToUpper(jwks.KeyTypeOkp):
+		if crv == "" {
+			crv = jwks.CurveEd25519
+		}
+
+		return jwks.OKPKeyPairGenerator(crv), nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type for rotation %q", kty)
+}
+
+// newServeHandler returns the handler serving the JWKS and, with an issuer, the OpenID Provider configuration, and a
+// function stopping scheduled rotations
+func newServeHandler(options serveOptions, stderr io.Writer) (http.Handler, func(), error) {
+	stop := func() {}
+
+	var source jwks.ResponseSource
+
+	if options.rotate {
+		manager, err := jwks.NewRotationManager(jwks.NewFileKeyStore(options.path, options.passphrase), options.generate, jwks.WithRetention(options.retention))
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		source = manager
+
+		if options.rotateEvery > 0 {
+			prePublish := options.prePublish
+
+			if prePublish == 0 {
+				prePublish = options.maxAge
+			}
+
+			scheduler, err := jwks.NewRotationScheduler(manager, jwks.EverySchedule(options.rotateEvery), prePublish)
+
+			if err != nil {
+				return nil, nil, err
+			}
+
+			scheduler.OnError = func(err error) {
+				_, _ = fmt.Fprintf(stderr, "rotation failed: %s\n", err)
+			}
+
+			for _, warning := range scheduler.CheckOverlap(options.maxAge) {
+				_, _ = fmt.Fprintf(stderr, "warning: %s\n", warning)
+			}
+
+			scheduler.Start()
+			stop = scheduler.Stop
+		}
+	} else {
+		source = &fileSource{
+			path:   options.path,
+			stderr: stderr,
+		}
+	}
+
+	handlerOpts := []jwks.HandlerOption{jwks.WithMaxAge(options.maxAge)}
+
+	if options.cors != "" {
+		handlerOpts = append(handlerOpts, jwks.WithCors(jwks.CorsConfig{
+			AllowedOrigins: strings.Split(options.cors, ","),
+		}))
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(options.jwksPath, jwks.Handler(source, handlerOpts...))
+
+	if options.issuer != "" {
+		configuration, err := json.Marshal(map[string]string{
+			"issuer":   options.issuer,
+			"jwks_uri": strings.TrimSuffix(options.issuer, "/") + options.jwksPath,
+		})
+
+		if err != nil {
+			stop()
+			return nil, nil, err
+		}
+
+		mux.HandleFunc(jwks.DiscoveryPath, func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("content-type", "application/json")
+			_, _ = w.Write(configuration)
+		})
+	}
+
+	return mux, stop, nil
+}
+
+// fileSource is a ResponseSource reading a JWKS file, which is read again when its modification time or size changes
+// so edits are served without a restart. If the file can not be read the last good set is served.
+type fileSource struct {
+	path   string
+	stderr io.Writer
+
+	lock     sync.Mutex
+	modTime  time.Time
+	size     int64
+	response *jwks.Response
+}
+
+func (s *fileSource) Response() *jwks.Response {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	info, err := os.Stat(s.path)
+
+	if err != nil {
+		_, _ = fmt.Fprintf(s.stderr, "could not read %s: %s\n", s.path, err)
+		return s.response
+	}
+
+	if s.response != nil && info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return s.response
+	}
+
+	data, err := os.ReadFile(s.path)
+
+	if err == nil {
+		var response *jwks.Response
+
+		if response, err = jwks.ParseResponse(data); err == nil {
+			s.response = response
+			s.modTime = info.ModTime()
+			s.size = info.Size()
+		}
+	}
+
+	if err != nil {
+		_, _ = fmt.Fprintf(s.stderr, "could not read %s: %s\n", s.path, err)
+	}
+
+	return s.response
+}
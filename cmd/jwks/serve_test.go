@@ -0,0 +1,206 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/openziti/jwks"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestSet writes a JWKS of new private EC keys with the given kids to path
+func writeTestSet(t *testing.T, path string, kids ...string) {
+	var keys []jwks.Key
+
+	for _, kid := range kids {
+		private, _, err := jwks.GenerateECKey("P-256", kid)
+		require.NoError(t, err)
+
+		keys = append(keys, *private)
+	}
+
+	data, err := json.Marshal(&jwks.Response{Keys: keys})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data, 0600))
+}
+
+// getTestSet fetches the JWKS at url and returns the response and its parsed body
+func getTestSet(t *testing.T, url string) (*http.Response, *jwks.Response) {
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode, string(body))
+
+	response, err := jwks.ParseResponse(body)
+	require.NoError(t, err)
+
+	return resp, response
+}
+
+func Test_serve(t *testing.T) {
+	t.Run("can serve the public keys of a file", func(t *testing.T) {
+		req := require.New(t)
+
+		path := filepath.Join(t.TempDir(), "jwks.json")
+		writeTestSet(t, path, "one", "two")
+
+		handler, stop, err := newServeHandler(serveOptions{path: path, jwksPath: "/keys", maxAge: time.Minute}, &bytes.Buffer{})
+		req.NoError(err)
+		defer stop()
+
+		server := httptest.NewServer(handler)
+		defer server.Close()
+
+		resp, response := getTestSet(t, server.URL+"/keys")
+		req.Equal("public, max-age=60", resp.Header.Get("Cache-Control"))
+		req.NotEmpty(resp.Header.Get("ETag"))
+		req.Len(response.Keys, 2)
+
+		for _, key := range response.Keys {
+			req.True(key.IsPublic())
+		}
+
+		notModified, err := http.NewRequest(http.MethodGet, server.URL+"/keys", nil)
+		req.NoError(err)
+		notModified.Header.Set("If-None-Match", resp.Header.Get("ETag"))
+
+		cached, err := http.DefaultClient.Do(notModified)
+		req.NoError(err)
+		_ = cached.Body.Close()
+		req.Equal(http.StatusNotModified, cached.StatusCode)
+	})
+
+	t.Run("serves edits of the file", func(t *testing.T) {
+		req := require.New(t)
+
+		path := filepath.Join(t.TempDir(), "jwks.json")
+		writeTestSet(t, path, "one")
+
+		stderr := &bytes.Buffer{}
+
+		handler, stop, err := newServeHandler(serveOptions{path: path, jwksPath: "/keys"}, stderr)
+		req.NoError(err)
+		defer stop()
+
+		server := httptest.NewServer(handler)
+		defer server.Close()
+
+		_, response := getTestSet(t, server.URL+"/keys")
+		req.Equal("one", response.Keys[0].KeyId)
+
+		writeTestSet(t, path, "two", "three")
+		req.NoError(os.Chtimes(path, time.Now().Add(time.Hour), time.Now().Add(time.Hour)))
+
+		_, response = getTestSet(t, server.URL+"/keys")
+		req.Len(response.Keys, 2)
+		req.Equal("two", response.Keys[0].KeyId)
+
+		req.NoError(os.WriteFile(path, []byte("broken"), 0600))
+
+		_, response = getTestSet(t, server.URL+"/keys")
+		req.Len(response.Keys, 2)
+		req.Contains(stderr.String(), "could not read")
+	})
+
+	t.Run("can serve a provider configuration", func(t *testing.T) {
+		req := require.New(t)
+
+		path := filepath.Join(t.TempDir(), "jwks.json")
+		writeTestSet(t, path, "one")
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		req.NoError(err)
+
+		options := serveOptions{path: path, jwksPath: "/keys", issuer: "http://" + listener.Addr().String()}
+
+		handler, stop, err := newServeHandler(options, &bytes.Buffer{})
+		req.NoError(err)
+		defer stop()
+
+		server := httptest.NewUnstartedServer(handler)
+		_ = server.Listener.Close()
+		server.Listener = listener
+		server.Start()
+		defer server.Close()
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		req.Equal(0, run([]string{"fetch", "-issuer", server.URL}, stdout, stderr), stderr.String())
+
+		response, err := jwks.ParseResponse(stdout.Bytes())
+		req.NoError(err)
+		req.Equal("one", response.Keys[0].KeyId)
+	})
+
+	t.Run("can serve a rotated key store", func(t *testing.T) {
+		req := require.New(t)
+
+		path := filepath.Join(t.TempDir(), "store.json")
+
+		generate, err := keyPairGenerator("OKP", 0, "")
+		req.NoError(err)
+
+		options := serveOptions{
+			path:        path,
+			jwksPath:    "/keys",
+			rotate:      true,
+			generate:    generate,
+			rotateEvery: time.Hour,
+			retention:   time.Hour,
+		}
+
+		stderr := &bytes.Buffer{}
+
+		handler, stop, err := newServeHandler(options, stderr)
+		req.NoError(err)
+		defer stop()
+
+		server := httptest.NewServer(handler)
+		defer server.Close()
+
+		_, response := getTestSet(t, server.URL+"/keys")
+		req.Len(response.Keys, 2)
+		req.Equal(jwks.CurveEd25519, response.Keys[0].Curve)
+
+		_, err = os.Stat(path)
+		req.NoError(err)
+	})
+
+	t.Run("rejects invalid arguments", func(t *testing.T) {
+		req := require.New(t)
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		req.Equal(2, run([]string{"serve"}, stdout, stderr))
+		req.Equal(2, run([]string{"serve", "-rotate-every", "1h", "jwks.json"}, stdout, stderr))
+		req.Equal(1, run([]string{"serve", "-rotate", "-kty", "oct", "jwks.json"}, stdout, stderr))
+		req.Equal(1, run([]string{"serve", "-addr", "256.0.0.1:0", "jwks.json"}, stdout, stderr))
+	})
+}
@@ -0,0 +1,81 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/openziti/jwks"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// sourceFlags are the flags of commands reading a JWKS from a URL, an issuer, a file, or stdin
+type sourceFlags struct {
+	issuer      *bool
+	timeout     *time.Duration
+	maxBodySize *int64
+}
+
+// addSourceFlags registers the source flags with flags
+func addSourceFlags(flags *flag.FlagSet) *sourceFlags {
+	return &sourceFlags{
+		issuer:      flags.Bool("issuer", false, "treat URL arguments as issuers and discover their jwks_uri"),
+		timeout:     flags.Duration("timeout", 30*time.Second, "timeout for each HTTP request"),
+		maxBodySize: flags.Int64("max-body-size", 1<<20, "reject responses larger than this many bytes, 0 disables the limit"),
+	}
+}
+
+// isUrl returns true if location is an HTTP(S) URL rather than a file
+func isUrl(location string) bool {
+	return strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://")
+}
+
+// load returns the JWKS at location, and its raw bytes, fetching HTTP(S) URLs with the resolver stack and reading
+// anything else as a file, or stdin if location is "-"
+func (s *sourceFlags) load(location string) (*jwks.Response, []byte, error) {
+	if !isUrl(location) {
+		if *s.issuer {
+			return nil, nil, fmt.Errorf("issuer %s is not an HTTP(S) URL", location)
+		}
+
+		data, err := readInput(location)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		response, err := jwks.ParseResponse(data)
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not parse %s: %s", location, err)
+		}
+
+		return response, data, nil
+	}
+
+	client := &http.Client{Timeout: *s.timeout}
+
+	var resolver jwks.Resolver = &jwks.HttpResolver{Client: client, MaxBodySize: *s.maxBodySize}
+
+	if *s.issuer {
+		resolver = &jwks.DiscoveryResolver{Client: client, MaxBodySize: *s.maxBodySize}
+	}
+
+	return resolver.Get(location)
+}
@@ -0,0 +1,82 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_sourceFlags(t *testing.T) {
+	server, body := newTestServer(t)
+
+	newSource := func(args ...string) *sourceFlags {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		flags.SetOutput(ioutil.Discard)
+
+		source := addSourceFlags(flags)
+		require.NoError(t, flags.Parse(args))
+
+		return source
+	}
+
+	t.Run("can load URLs, issuers, files, and stdin", func(t *testing.T) {
+		req := require.New(t)
+
+		path := filepath.Join(t.TempDir(), "jwks.json")
+		req.NoError(os.WriteFile(path, body, 0600))
+
+		stdin = bytes.NewReader(body)
+		defer func() { stdin = os.Stdin }()
+
+		for _, c := range []struct {
+			source   *sourceFlags
+			location string
+		}{
+			{newSource(), server.URL + "/keys"},
+			{newSource("-issuer"), server.URL},
+			{newSource(), path},
+			{newSource(), "-"},
+		} {
+			response, raw, err := c.source.load(c.location)
+			req.NoError(err, c.location)
+			req.Len(response.Keys, 2, c.location)
+			req.Equal(string(body), string(raw), c.location)
+		}
+	})
+
+	t.Run("rejects issuers that are not URLs and malformed files", func(t *testing.T) {
+		req := require.New(t)
+
+		path := filepath.Join(t.TempDir(), "jwks.json")
+		req.NoError(os.WriteFile(path, []byte("{"), 0600))
+
+		_, _, err := newSource("-issuer").load(path)
+		req.Error(err)
+
+		_, _, err = newSource().load(path)
+		req.Error(err)
+
+		_, _, err = newSource("-max-body-size", "10").load(server.URL + "/keys")
+		req.Error(err)
+	})
+}
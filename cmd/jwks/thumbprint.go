@@ -0,0 +1,178 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"crypto"
+	"fmt"
+	"github.com/openziti/jwks"
+	"github.com/pkg/errors"
+	"io"
+	"strings"
+)
+
+// thumbprintHashes maps the -hash names to hashes
+var thumbprintHashes = map[string]crypto.Hash{
+	"sha-256": crypto.SHA256,
+	"sha-384": crypto.SHA384,
+	"sha-512": crypto.SHA512,
+}
+
+// thumbprintResult holds the thumbprints of a key
+type thumbprintResult struct {
+	KeyId         string `json:"kid,omitempty"`
+	Thumbprint    string `json:"thumbprint"`
+	ThumbprintUri string `json:"thumbprint_uri"`
+	X5t           string `json:"x5t,omitempty"`
+	X5tS256       string `json:"x5t#S256,omitempty"`
+}
+
+// thumbprint prints the RFC 7638 thumbprints, RFC 9278 thumbprint URIs, and, for keys with a certificate, x5t and
+// x5t#S256 of keys given as JWK, JWKS, or PEM
+func thumbprint(args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("thumbprint", "<file | ->", stderr)
+	from := flags.String("from", "", "input format, one of pem, der, jwk (also accepts JWKS), detected if empty")
+	hashName := flags.String("hash", "sha-256", "thumbprint hash, one of sha-256, sha-384, sha-512")
+	passphraseEnv := flags.String("passphrase-env", "", "environment variable holding the passphrase of encrypted PKCS#8 input")
+	asJson := flags.Bool("json", false, "output a JSON array instead of text")
+	compact := flags.Bool("compact", false, "do not indent JSON output")
+
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+
+	hash, ok := thumbprintHashes[*hashName]
+
+	if !ok {
+		_, _ = fmt.Fprintf(stderr, "unknown hash %q\n", *hashName)
+		flags.Usage()
+		return errUsage
+	}
+
+	var opts []jwks.KeyOption
+
+	if *passphraseEnv != "" {
+		passphrase, err := passphraseFromEnv(*passphraseEnv)
+
+		if err != nil {
+			return err
+		}
+
+		opts = append(opts, jwks.WithPassphrase(passphrase))
+	}
+
+	data, err := readInput(flags.Arg(0))
+
+	if err != nil {
+		return err
+	}
+
+	if *from == "" {
+		*from = detectFormat(data)
+	}
+
+	keys, err := decodeKeys(*from, data, opts)
+
+	if err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		return errors.New("no keys found in input")
+	}
+
+	results := make([]thumbprintResult, 0, len(keys))
+
+	for i := range keys {
+		result, err := thumbprints(&keys[i], hash)
+
+		if err != nil {
+			return err
+		}
+
+		results = append(results, *result)
+	}
+
+	if *asJson {
+		output, err := marshal(results, *compact)
+
+		if err != nil {
+			return err
+		}
+
+		return write("", withNewline(output), stdout)
+	}
+
+	text := &strings.Builder{}
+
+	for i, result := range results {
+		if i > 0 {
+			text.WriteString("\n")
+		}
+
+		if result.KeyId != "" {
+			_, _ = fmt.Fprintf(text, "kid:            %s\n", result.KeyId)
+		}
+
+		_, _ = fmt.Fprintf(text, "thumbprint:     %s\n", result.Thumbprint)
+		_, _ = fmt.Fprintf(text, "thumbprint uri: %s\n", result.ThumbprintUri)
+
+		if result.X5t != "" {
+			_, _ = fmt.Fprintf(text, "x5t:            %s\n", result.X5t)
+		}
+
+		if result.X5tS256 != "" {
+			_, _ = fmt.Fprintf(text, "x5t#S256:       %s\n", result.X5tS256)
+		}
+	}
+
+	return write("", []byte(text.String()), stdout)
+}
+
+// thumbprints computes the thumbprints of key. The x5t values are recomputed from the x5c leaf certificate if there
+// is one and copied from the key otherwise.
+func thumbprints(key *jwks.Key, hash crypto.Hash) (*thumbprintResult, error) {
+	result := &thumbprintResult{
+		KeyId: key.KeyId,
+	}
+
+	var err error
+
+	if result.Thumbprint, err = key.Thumbprint(hash); err != nil {
+		return nil, err
+	}
+
+	if result.ThumbprintUri, err = key.ThumbprintURI(hash); err != nil {
+		return nil, err
+	}
+
+	result.X5t = key.X509Thumbprint
+	result.X5tS256 = key.X509ThumbprintSha256
+
+	if len(key.X509Chain) > 0 {
+		certKey := jwks.Key{X509Chain: key.X509Chain}
+
+		if err = certKey.PopulateX5cThumbprints(); err != nil {
+			return nil, err
+		}
+
+		result.X5t = certKey.X509Thumbprint
+		result.X5tS256 = certKey.X509ThumbprintSha256
+	}
+
+	return result, nil
+}
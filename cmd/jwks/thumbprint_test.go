@@ -0,0 +1,125 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/openziti/jwks"
+	"github.com/stretchr/testify/require"
+	"os"
+	"strings"
+	"testing"
+)
+
+// thumbprintWith runs the thumbprint command with input as stdin and returns stdout
+func thumbprintWith(t *testing.T, input []byte, args ...string) string {
+	stdin = bytes.NewReader(input)
+	defer func() { stdin = os.Stdin }()
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+	require.Equal(t, 0, run(append(append([]string{"thumbprint"}, args...), "-"), stdout, stderr), stderr.String())
+
+	return stdout.String()
+}
+
+func Test_thumbprint(t *testing.T) {
+	private, public, err := jwks.GenerateECKey("P-256", "ec")
+	require.NoError(t, err)
+
+	expected, err := public.Thumbprint(crypto.SHA256)
+	require.NoError(t, err)
+
+	publicJson, err := json.Marshal(public)
+	require.NoError(t, err)
+
+	t.Run("can compute the thumbprint and URI of a JWK", func(t *testing.T) {
+		req := require.New(t)
+
+		output := thumbprintWith(t, publicJson)
+		req.True(strings.Contains(output, "kid:            ec\n"), output)
+		req.True(strings.Contains(output, "thumbprint:     "+expected+"\n"), output)
+		req.True(strings.Contains(output, jwks.ThumbprintUriPrefix+"sha-256:"+expected), output)
+		req.False(strings.Contains(output, "x5t"), output)
+	})
+
+	t.Run("can compute thumbprints of PEM keys with other hashes", func(t *testing.T) {
+		req := require.New(t)
+
+		privatePem, err := private.PrivatePEM()
+		req.NoError(err)
+
+		expected, err := public.Thumbprint(crypto.SHA512)
+		req.NoError(err)
+
+		output := thumbprintWith(t, privatePem, "-hash", "sha-512")
+		req.True(strings.Contains(output, "thumbprint:     "+expected+"\n"), output)
+		req.True(strings.Contains(output, jwks.ThumbprintUriPrefix+"sha-512:"+expected), output)
+	})
+
+	t.Run("can compute the x5t values of certificates", func(t *testing.T) {
+		req := require.New(t)
+
+		certKey := *private
+
+		cert, err := certKey.SelfSignCertificate(nil)
+		req.NoError(err)
+
+		var results []thumbprintResult
+
+		certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+		req.NoError(json.Unmarshal([]byte(thumbprintWith(t, certPem, "-json")), &results))
+		req.Len(results, 1)
+		req.Equal(expected, results[0].Thumbprint)
+		req.Equal(certKey.X509Thumbprint, results[0].X5t)
+		req.Equal(certKey.X509ThumbprintSha256, results[0].X5tS256)
+		req.Equal(jwks.CertificateX5tS256(cert), results[0].X5tS256)
+	})
+
+	t.Run("can compute the thumbprints of every key of a JWKS", func(t *testing.T) {
+		req := require.New(t)
+
+		_, other, err := jwks.GenerateOKPKey(jwks.CurveEd25519, "ed")
+		req.NoError(err)
+
+		set, err := json.Marshal(&jwks.Response{Keys: []jwks.Key{*public, *other}})
+		req.NoError(err)
+
+		var results []thumbprintResult
+
+		req.NoError(json.Unmarshal([]byte(thumbprintWith(t, set, "-json", "-compact")), &results))
+		req.Len(results, 2)
+		req.Equal("ec", results[0].KeyId)
+		req.Equal("ed", results[1].KeyId)
+		req.Equal(expected, results[0].Thumbprint)
+	})
+
+	t.Run("rejects invalid arguments", func(t *testing.T) {
+		req := require.New(t)
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		req.Equal(2, run([]string{"thumbprint", "-hash", "md5", "-"}, stdout, stderr))
+
+		stdin = bytes.NewReader([]byte(`{"keys": []}`))
+		req.Equal(1, run([]string{"thumbprint", "-"}, stdout, stderr))
+		stdin = os.Stdin
+	})
+}
@@ -0,0 +1,148 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"github.com/openziti/jwks"
+	"github.com/pkg/errors"
+	"io"
+	"strings"
+)
+
+// auditSeverities maps the -fail-on names to audit severities
+var auditSeverities = map[string]jwks.AuditSeverity{
+	"low":    jwks.AuditLow,
+	"medium": jwks.AuditMedium,
+	"high":   jwks.AuditHigh,
+}
+
+// validateReport is the machine-readable result of the validate command
+type validateReport struct {
+	Source        string                 `json:"source"`
+	Valid         bool                   `json:"valid"`
+	Error         string                 `json:"error,omitempty"`
+	Validation    *jwks.ValidationReport `json:"validation,omitempty"`
+	DuplicateKids map[string][]int       `json:"duplicate_kids,omitempty"`
+	Audit         *jwks.AuditReport      `json:"audit,omitempty"`
+}
+
+// validate validates and audits a JWKS, printing a JSON report and failing if the set is invalid, has duplicate kids,
+// or has audit findings of at least the -fail-on severity
+func validate(args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("validate", "<url | issuer | file | ->", stderr)
+	source := addSourceFlags(flags)
+	policyName := flags.String("policy", "default", "validation policy, one of default, fips")
+	minRsaBits := flags.Int("min-rsa-bits", -1, "minimum RSA modulus size, overrides the policy if not negative")
+	keyTypes := flags.String("kty", "", "comma separated key types to allow, overrides the policy")
+	algorithms := flags.String("alg", "", "comma separated algorithms to allow, overrides the policy")
+	curves := flags.String("crv", "", "comma separated curves to allow, overrides the policy")
+	forbidSha1Kid := flags.Bool("forbid-sha1-kid", false, "reject kids that are SHA-1 certificate fingerprints")
+	keyOpsWarnOnly := flags.Bool("key-ops-warn-only", false, "report illegal use and key_ops as warnings")
+	allowDuplicateKids := flags.Bool("allow-duplicate-kids", false, "do not fail on keys sharing a kid")
+	failOn := flags.String("fail-on", "high", "fail on audit findings of at least this severity, one of low, medium, high, none")
+	compact := flags.Bool("compact", false, "do not indent the report")
+
+	if err := parseFlags(flags, args, 1); err != nil {
+		return err
+	}
+
+	var policy *jwks.Policy
+
+	switch *policyName {
+	case "default":
+		policy = jwks.DefaultPolicy()
+	case "fips":
+		policy = jwks.FipsPolicy()
+	default:
+		_, _ = fmt.Fprintf(stderr, "unknown policy %q\n", *policyName)
+		flags.Usage()
+		return errUsage
+	}
+
+	failSeverity, failOnAudit := auditSeverities[*failOn]
+
+	if !failOnAudit && *failOn != "none" {
+		_, _ = fmt.Fprintf(stderr, "unknown severity %q\n", *failOn)
+		flags.Usage()
+		return errUsage
+	}
+
+	if *minRsaBits >= 0 {
+		policy.MinRsaBits = *minRsaBits
+	}
+
+	if *keyTypes != "" {
+		policy.AllowedKeyTypes = strings.Split(*keyTypes, ",")
+	}
+
+	if *algorithms != "" {
+		policy.AllowedAlgorithms = strings.Split(*algorithms, ",")
+	}
+
+	if *curves != "" {
+		policy.AllowedCurves = strings.Split(*curves, ",")
+	}
+
+	policy.ForbidSha1Kid = policy.ForbidSha1Kid || *forbidSha1Kid
+	policy.KeyOpsWarnOnly = *keyOpsWarnOnly
+
+	report := &validateReport{
+		Source: flags.Arg(0),
+	}
+
+	var failures []string
+
+	if response, _, err := source.load(flags.Arg(0)); err != nil {
+		report.Error = err.Error()
+		failures = append(failures, "could not load the key set")
+	} else {
+		report.Validation = response.Validate(policy)
+		report.Audit = jwks.Audit(response)
+		report.DuplicateKids = response.DuplicateKids()
+
+		if !report.Validation.IsValid() {
+			failures = append(failures, fmt.Sprintf("%d of %d keys are invalid", report.Validation.Invalid, len(response.Keys)))
+		}
+
+		if len(report.DuplicateKids) > 0 && !*allowDuplicateKids {
+			failures = append(failures, fmt.Sprintf("%d kids are shared by multiple keys", len(report.DuplicateKids)))
+		}
+
+		if severity, ok := report.Audit.MaxSeverity(); ok && failOnAudit && severity >= failSeverity {
+			failures = append(failures, fmt.Sprintf("audit found %s severity issues", severity))
+		}
+	}
+
+	report.Valid = len(failures) == 0
+
+	output, err := marshal(report, *compact)
+
+	if err != nil {
+		return err
+	}
+
+	if err = write("", withNewline(output), stdout); err != nil {
+		return err
+	}
+
+	if !report.Valid {
+		return errors.New(strings.Join(failures, ", "))
+	}
+
+	return nil
+}
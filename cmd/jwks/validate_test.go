@@ -0,0 +1,177 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/openziti/jwks"
+	"github.com/stretchr/testify/require"
+	"os"
+	"strings"
+	"testing"
+)
+
+// testValidateReport decodes the report printed by validate, whose errors and severities are only marshalled
+type testValidateReport struct {
+	Source     string `json:"source"`
+	Valid      bool   `json:"valid"`
+	Error      string `json:"error"`
+	Validation *struct {
+		Keys []struct {
+			Errors []string `json:"errors"`
+		} `json:"keys"`
+		Valid   int `json:"valid"`
+		Invalid int `json:"invalid"`
+	} `json:"validation"`
+	DuplicateKids map[string][]int `json:"duplicate_kids"`
+	Audit         *struct {
+		Keys     int               `json:"keys"`
+		Findings []json.RawMessage `json:"findings"`
+	} `json:"audit"`
+}
+
+// validateWith runs the validate command with input as stdin and returns the exit code, report, and stderr
+func validateWith(t *testing.T, input []byte, args ...string) (int, *testValidateReport, string) {
+	stdin = bytes.NewReader(input)
+	defer func() { stdin = os.Stdin }()
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+	code := run(append(append([]string{"validate"}, args...), "-"), stdout, stderr)
+
+	report := &testValidateReport{}
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), report), stdout.String())
+
+	return code, report, stderr.String()
+}
+
+func Test_validate(t *testing.T) {
+	_, ec, err := jwks.GenerateECKey("P-256", "ec")
+	require.NoError(t, err)
+
+	_, rsa, err := jwks.GenerateRSAKey(2048, "rsa")
+	require.NoError(t, err)
+
+	set := func(keys ...jwks.Key) []byte {
+		data, err := json.Marshal(&jwks.Response{Keys: keys})
+		require.NoError(t, err)
+		return data
+	}
+
+	t.Run("passes valid sets", func(t *testing.T) {
+		req := require.New(t)
+
+		code, report, stderr := validateWith(t, set(*ec, *rsa))
+		req.Equal(0, code, stderr)
+		req.True(report.Valid)
+		req.Equal(2, report.Validation.Valid)
+		req.Equal(2, report.Audit.Keys)
+		req.Equal("-", report.Source)
+	})
+
+	t.Run("fails invalid keys", func(t *testing.T) {
+		req := require.New(t)
+
+		offCurve := *ec
+		offCurve.Y = offCurve.X
+
+		code, report, stderr := validateWith(t, set(*rsa, offCurve))
+		req.Equal(1, code)
+		req.False(report.Valid)
+		req.Equal(1, report.Validation.Invalid)
+		req.NotEmpty(report.Validation.Keys[1].Errors)
+		req.True(strings.Contains(stderr, "1 of 2 keys are invalid"), stderr)
+	})
+
+	t.Run("applies the policy", func(t *testing.T) {
+		req := require.New(t)
+
+		_, ed, err := jwks.GenerateOKPKey(jwks.CurveEd25519, "ed")
+		req.NoError(err)
+
+		code, _, _ := validateWith(t, set(*ed))
+		req.Equal(0, code)
+
+		code, report, _ := validateWith(t, set(*ed), "-policy", "fips")
+		req.Equal(1, code)
+		req.False(report.Valid)
+
+		code, _, _ = validateWith(t, set(*ec, *rsa), "-kty", "EC")
+		req.Equal(1, code)
+
+		code, _, _ = validateWith(t, set(*rsa), "-min-rsa-bits", "4096")
+		req.Equal(1, code)
+	})
+
+	t.Run("fails duplicate kids unless allowed", func(t *testing.T) {
+		req := require.New(t)
+
+		duplicate := *rsa
+		duplicate.KeyId = ec.KeyId
+
+		code, report, _ := validateWith(t, set(*ec, duplicate))
+		req.Equal(1, code)
+		req.Equal([]int{0, 1}, report.DuplicateKids[ec.KeyId])
+
+		code, _, _ = validateWith(t, set(*ec, duplicate), "-allow-duplicate-kids")
+		req.Equal(0, code)
+	})
+
+	t.Run("fails audit findings of the configured severity", func(t *testing.T) {
+		req := require.New(t)
+
+		noAlg := *ec
+		noAlg.Algorithm = ""
+
+		code, report, _ := validateWith(t, set(noAlg))
+		req.Equal(0, code)
+		req.NotEmpty(report.Audit.Findings)
+
+		code, _, stderr := validateWith(t, set(noAlg), "-fail-on", "low")
+		req.Equal(1, code)
+		req.True(strings.Contains(stderr, "audit found low severity issues"), stderr)
+
+		private, _, err := jwks.GenerateECKey("P-256", "private")
+		req.NoError(err)
+
+		code, _, _ = validateWith(t, set(*private))
+		req.Equal(1, code)
+
+		code, _, _ = validateWith(t, set(*private), "-fail-on", "none")
+		req.Equal(0, code)
+	})
+
+	t.Run("reports sets that can not be loaded", func(t *testing.T) {
+		req := require.New(t)
+
+		code, report, _ := validateWith(t, []byte("{"))
+		req.Equal(1, code)
+		req.False(report.Valid)
+		req.NotEmpty(report.Error)
+		req.Nil(report.Validation)
+	})
+
+	t.Run("rejects invalid arguments", func(t *testing.T) {
+		req := require.New(t)
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		req.Equal(2, run([]string{"validate", "-policy", "nope", "-"}, stdout, stderr))
+		req.Equal(2, run([]string{"validate", "-fail-on", "nope", "-"}, stdout, stderr))
+	})
+}
@@ -0,0 +1,170 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/openziti/jwks"
+	"io"
+	"strings"
+	"time"
+)
+
+// verifyResult is the output of the verify command
+type verifyResult struct {
+	Header json.RawMessage `json:"header"`
+	KeyId  string          `json:"kid"`
+	Claims json.RawMessage `json:"claims,omitempty"`
+
+	// Payload holds JWS payloads that are not JSON objects
+	Payload string `json:"payload,omitempty"`
+}
+
+// verify verifies a compact JWT or JWS against a JWKS and prints its header, the kid of the verifying key, and its
+// claims
+func verify(args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("verify", "<jwks url | issuer | file> <token | ->", stderr)
+	source := addSourceFlags(flags)
+	ignoreTimes := flags.Bool("ignore-times", false, "do not check the exp and nbf claims")
+	leeway := flags.Duration("leeway", 0, "clock skew tolerated when checking exp and nbf")
+	asJson := flags.Bool("json", false, "output a JSON object instead of text")
+	compact := flags.Bool("compact", false, "do not indent JSON output")
+
+	if err := parseFlags(flags, args, 2); err != nil {
+		return err
+	}
+
+	token := []byte(flags.Arg(1))
+
+	if flags.Arg(1) == "-" {
+		var err error
+
+		if token, err = readInput("-"); err != nil {
+			return err
+		}
+	}
+
+	token = bytes.TrimSpace(token)
+
+	response, _, err := source.load(flags.Arg(0))
+
+	if err != nil {
+		return err
+	}
+
+	verified, err := response.VerifyCompactJws(token)
+
+	if err != nil {
+		return fmt.Errorf("%s%s", err, describeHeader(token))
+	}
+
+	result := &verifyResult{
+		Header: verified.RawHeader,
+		KeyId:  verified.Key.KeyId,
+	}
+
+	var claims map[string]interface{}
+
+	if err = json.Unmarshal(verified.Payload, &claims); err == nil && claims != nil {
+		result.Claims = verified.Payload
+	} else {
+		result.Payload = string(verified.Payload)
+	}
+
+	var output []byte
+
+	if *asJson {
+		if output, err = marshal(result, *compact); err != nil {
+			return err
+		}
+	} else {
+		text := &strings.Builder{}
+
+		_, _ = fmt.Fprintf(text, "header:\n%s\n", indentJson(result.Header))
+		_, _ = fmt.Fprintf(text, "kid: %s\n", result.KeyId)
+
+		if result.Claims != nil {
+			_, _ = fmt.Fprintf(text, "claims:\n%s", indentJson(result.Claims))
+		} else {
+			_, _ = fmt.Fprintf(text, "payload:\n%s", result.Payload)
+		}
+
+		output = []byte(text.String())
+	}
+
+	if err = write("", withNewline(output), stdout); err != nil {
+		return err
+	}
+
+	if claims != nil && !*ignoreTimes {
+		return checkTimes(claims, time.Now(), *leeway)
+	}
+
+	return nil
+}
+
+// describeHeader returns the alg and kid of the unverified header of token for error messages, or empty string if
+// it can not be decoded
+func describeHeader(token []byte) string {
+	header := &jwks.JwsHeader{}
+
+	encoded := token
+
+	if i := bytes.IndexByte(token, '.'); i >= 0 {
+		encoded = token[:i]
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(string(encoded))
+
+	if err != nil || json.Unmarshal(decoded, header) != nil {
+		return ""
+	}
+
+	return fmt.Sprintf(" (alg %q, kid %q)", header.Algorithm, header.KeyId)
+}
+
+// indentJson returns data indented, or as is if it is not valid JSON
+func indentJson(data []byte) string {
+	indented := &bytes.Buffer{}
+
+	if err := json.Indent(indented, data, "", "  "); err != nil {
+		return string(data)
+	}
+
+	return indented.String()
+}
+
+// checkTimes returns an error if the exp claim has passed or the nbf claim has not been reached at now, tolerating
+// leeway
+func checkTimes(claims map[string]interface{}, now time.Time, leeway time.Duration) error {
+	if exp, ok := claims["exp"].(float64); ok {
+		if expiresAt := time.Unix(int64(exp), 0); now.After(expiresAt.Add(leeway)) {
+			return fmt.Errorf("token expired at %s", expiresAt.UTC().Format(time.RFC3339))
+		}
+	}
+
+	if nbf, ok := claims["nbf"].(float64); ok {
+		if notBefore := time.Unix(int64(nbf), 0); now.Before(notBefore.Add(-leeway)) {
+			return fmt.Errorf("token is not valid before %s", notBefore.UTC().Format(time.RFC3339))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,155 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/openziti/jwks"
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_verify(t *testing.T) {
+	private, public, err := jwks.GenerateECKey("P-256", "ec")
+	require.NoError(t, err)
+
+	_, other, err := jwks.GenerateRSAKey(2048, "rsa")
+	require.NoError(t, err)
+
+	data, err := json.Marshal(&jwks.Response{Keys: []jwks.Key{*other, *public}})
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, data, 0600))
+
+	sign := func(claims string) string {
+		token, err := jwks.SignCompactJws([]byte(claims), *private, "JWT")
+		require.NoError(t, err)
+		return string(token)
+	}
+
+	exp := time.Now().Add(time.Hour).Unix()
+	token := sign(fmt.Sprintf(`{"sub":"someone","exp":%d}`, exp))
+
+	t.Run("can verify a token against a file", func(t *testing.T) {
+		req := require.New(t)
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		req.Equal(0, run([]string{"verify", path, token}, stdout, stderr), stderr.String())
+
+		output := stdout.String()
+		req.True(strings.Contains(output, `"alg": "ES256"`), output)
+		req.True(strings.Contains(output, "kid: ec\n"), output)
+		req.True(strings.Contains(output, `"sub": "someone"`), output)
+	})
+
+	t.Run("can verify a token from stdin against an issuer", func(t *testing.T) {
+		req := require.New(t)
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		req.NoError(err)
+
+		handler, stop, err := newServeHandler(serveOptions{path: path, jwksPath: "/keys", issuer: "http://" + listener.Addr().String()}, &bytes.Buffer{})
+		req.NoError(err)
+		defer stop()
+
+		server := httptest.NewUnstartedServer(handler)
+		_ = server.Listener.Close()
+		server.Listener = listener
+		server.Start()
+		defer server.Close()
+
+		stdin = strings.NewReader(token + "\n")
+		defer func() { stdin = os.Stdin }()
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		req.Equal(0, run([]string{"verify", "-issuer", "-json", server.URL, "-"}, stdout, stderr), stderr.String())
+
+		result := &verifyResult{}
+		req.NoError(json.Unmarshal(stdout.Bytes(), result))
+		req.Equal("ec", result.KeyId)
+		req.JSONEq(fmt.Sprintf(`{"sub":"someone","exp":%d}`, exp), string(result.Claims))
+		req.JSONEq(`{"alg":"ES256","kid":"ec","typ":"JWT"}`, string(result.Header))
+	})
+
+	t.Run("prints payloads that are not claims", func(t *testing.T) {
+		req := require.New(t)
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		req.Equal(0, run([]string{"verify", path, sign("hello")}, stdout, stderr), stderr.String())
+		req.True(strings.HasSuffix(stdout.String(), "payload:\nhello\n"), stdout.String())
+	})
+
+	t.Run("fails invalid signatures", func(t *testing.T) {
+		req := require.New(t)
+
+		_, stranger, err := jwks.GenerateECKey("P-256", "ec")
+		req.NoError(err)
+
+		data, err := json.Marshal(&jwks.Response{Keys: []jwks.Key{*stranger}})
+		req.NoError(err)
+
+		strangerPath := filepath.Join(t.TempDir(), "jwks.json")
+		req.NoError(os.WriteFile(strangerPath, data, 0600))
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		req.Equal(1, run([]string{"verify", strangerPath, token}, stdout, stderr))
+		req.Empty(stdout.String())
+		req.True(strings.Contains(stderr.String(), jwks.ErrorJwsSignatureMsg+` (alg "ES256", kid "ec")`), stderr.String())
+	})
+
+	t.Run("checks exp and nbf", func(t *testing.T) {
+		req := require.New(t)
+
+		expired := sign(fmt.Sprintf(`{"exp":%d}`, time.Now().Add(-time.Minute).Unix()))
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		req.Equal(1, run([]string{"verify", path, expired}, stdout, stderr))
+		req.True(strings.Contains(stderr.String(), "token expired at"), stderr.String())
+
+		req.Equal(0, run([]string{"verify", "-ignore-times", path, expired}, stdout, stderr))
+		req.Equal(0, run([]string{"verify", "-leeway", "2m", path, expired}, stdout, stderr))
+
+		early := sign(fmt.Sprintf(`{"nbf":%d}`, time.Now().Add(time.Hour).Unix()))
+
+		stderr.Reset()
+		req.Equal(1, run([]string{"verify", path, early}, stdout, stderr))
+		req.True(strings.Contains(stderr.String(), "token is not valid before"), stderr.String())
+	})
+
+	t.Run("rejects invalid arguments", func(t *testing.T) {
+		req := require.New(t)
+
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+		req.Equal(2, run([]string{"verify", path}, stdout, stderr))
+		req.Equal(1, run([]string{"verify", path, "not-a-token"}, stdout, stderr))
+	})
+}
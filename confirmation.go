@@ -0,0 +1,119 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"github.com/pkg/errors"
+)
+
+const (
+	ErrorCnfJktMissingMsg  = "cnf claim does not contain jkt"
+	ErrorCnfJktMismatchMsg = "key does not match cnf jkt"
+
+	ErrorCnfX5tS256MissingMsg  = "cnf claim does not contain x5t#S256"
+	ErrorCnfX5tS256MismatchMsg = "certificate does not match cnf x5t#S256"
+	ErrorKeyX5tS256MissingMsg  = "key has neither x5t#S256 nor x5c"
+	ErrorKeyX5tS256MismatchMsg = "certificate does not match key x5t#S256"
+)
+
+// Confirmation is the "cnf" confirmation claim of a proof-of-possession token, RFC 7800
+type Confirmation struct {
+	// Jkt is the base64url SHA-256 JWK thumbprint of a DPoP key, https://www.rfc-editor.org/rfc/rfc9449#section-6.1
+	Jkt string `json:"jkt,omitempty"`
+
+	// X5tS256 is the base64url SHA-256 thumbprint of a client certificate bound to the token,
+	// https://www.rfc-editor.org/rfc/rfc8705#section-3.1
+	X5tS256 string `json:"x5t#S256,omitempty"`
+}
+
+// CertificateX5tS256 returns the "x5t#S256" confirmation value of cert: the base64url SHA-256 thumbprint of its DER
+// encoding, as used by RFC 8705 certificate-bound access tokens
+func CertificateX5tS256(cert *x509.Certificate) string {
+	_, x5tS256 := x509Thumbprints(cert)
+	return x5tS256
+}
+
+// Jkt returns the DPoP "jkt" confirmation value of the key: its RFC 7638 SHA-256 thumbprint, base64url encoded
+func (k *Key) Jkt() (string, error) {
+	return k.Thumbprint(crypto.SHA256)
+}
+
+// VerifyJkt returns nil if key is the key confirmed by c.Jkt, such as the key presented in a DPoP proof
+func (c *Confirmation) VerifyJkt(key *Key) error {
+	if c.Jkt == "" {
+		return errors.New(ErrorCnfJktMissingMsg)
+	}
+
+	jkt, err := key.Jkt()
+
+	if err != nil {
+		return err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(jkt), []byte(c.Jkt)) != 1 {
+		return errors.New(ErrorCnfJktMismatchMsg)
+	}
+
+	return nil
+}
+
+// VerifyX5tS256 returns nil if cert, such as the client certificate of a mutual TLS connection, is the certificate
+// confirmed by c.X5tS256
+func (c *Confirmation) VerifyX5tS256(cert *x509.Certificate) error {
+	if c.X5tS256 == "" {
+		return errors.New(ErrorCnfX5tS256MissingMsg)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(CertificateX5tS256(cert)), []byte(c.X5tS256)) != 1 {
+		return errors.New(ErrorCnfX5tS256MismatchMsg)
+	}
+
+	return nil
+}
+
+// VerifyX5tS256 returns nil if cert is the certificate of the key, comparing its thumbprint with the key's x5t#S256
+// member, which may be base64url or hex encoded, see VerifyX5cThumbprints, or, if that is empty, the thumbprint of the
+// x5c leaf certificate
+func (k *Key) VerifyX5tS256(cert *x509.Certificate) error {
+	expected := k.X509ThumbprintSha256
+
+	if expected == "" {
+		certs, err := k.ParseX509Chain()
+
+		if err != nil {
+			return err
+		}
+
+		if len(certs) == 0 {
+			return errors.New(ErrorKeyX5tS256MissingMsg)
+		}
+
+		expected = CertificateX5tS256(certs[0])
+	}
+
+	sum := sha256.Sum256(cert.Raw)
+
+	if !thumbprintMatches(expected, sum[:]) {
+		return errors.New(ErrorKeyX5tS256MismatchMsg)
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_ConfirmationJkt(t *testing.T) {
+	response := &Response{}
+	require.NoError(t, json.Unmarshal([]byte(testJwksRfc7517Examples), response))
+
+	t.Run("can compute the jkt of a key", func(t *testing.T) {
+		req := require.New(t)
+
+		// RFC 7638 section 3.1
+		jkt, err := response.Keys[1].Jkt()
+		req.NoError(err)
+		req.Equal("NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", jkt)
+	})
+
+	t.Run("can verify a key against a cnf claim", func(t *testing.T) {
+		req := require.New(t)
+
+		cnf := &Confirmation{}
+		req.NoError(json.Unmarshal([]byte(`{"jkt":"NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"}`), cnf))
+		req.NoError(cnf.VerifyJkt(&response.Keys[1]))
+	})
+
+	t.Run("can not verify a different key", func(t *testing.T) {
+		req := require.New(t)
+
+		cnf := &Confirmation{Jkt: "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"}
+		req.EqualError(cnf.VerifyJkt(&response.Keys[0]), ErrorCnfJktMismatchMsg)
+	})
+
+	t.Run("can not verify without a jkt", func(t *testing.T) {
+		req := require.New(t)
+
+		cnf := &Confirmation{}
+		req.EqualError(cnf.VerifyJkt(&response.Keys[1]), ErrorCnfJktMissingMsg)
+	})
+}
+
+func Test_ConfirmationX5tS256(t *testing.T) {
+	cert, _, err := newEcCert()
+	require.NoError(t, err)
+
+	other, _, err := newEcCert()
+	require.NoError(t, err)
+
+	t.Run("can compute the x5t#S256 of a certificate", func(t *testing.T) {
+		req := require.New(t)
+
+		sum := sha256.Sum256(cert.Raw)
+		req.Equal(base64.RawURLEncoding.EncodeToString(sum[:]), CertificateX5tS256(cert))
+	})
+
+	t.Run("can verify a certificate against a cnf claim", func(t *testing.T) {
+		req := require.New(t)
+
+		cnf := &Confirmation{}
+		req.NoError(json.Unmarshal([]byte(`{"x5t#S256":"`+CertificateX5tS256(cert)+`"}`), cnf))
+		req.NoError(cnf.VerifyX5tS256(cert))
+		req.EqualError(cnf.VerifyX5tS256(other), ErrorCnfX5tS256MismatchMsg)
+
+		req.EqualError((&Confirmation{}).VerifyX5tS256(cert), ErrorCnfX5tS256MissingMsg)
+	})
+
+	t.Run("can verify a certificate against a key", func(t *testing.T) {
+		req := require.New(t)
+
+		key, err := NewKey("", cert, []*x509.Certificate{cert})
+		req.NoError(err)
+		req.NoError(key.VerifyX5tS256(cert))
+		req.EqualError(key.VerifyX5tS256(other), ErrorKeyX5tS256MismatchMsg)
+
+		key.X509ThumbprintSha256 = ""
+		req.NoError(key.VerifyX5tS256(cert))
+		req.EqualError(key.VerifyX5tS256(other), ErrorKeyX5tS256MismatchMsg)
+
+		key.X509Chain = nil
+		req.EqualError(key.VerifyX5tS256(cert), ErrorKeyX5tS256MissingMsg)
+	})
+}
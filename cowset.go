@@ -0,0 +1,247 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CopyOnWriteSet is a collection of keys optimized for read-heavy workloads. Readers load an immutable snapshot
+// atomically and never take a lock, mutations copy the keys into a new snapshot and swap it in, so many goroutines
+// can look up keys concurrently without contention. Writers are serialized and pay for a full copy, prefer Set for
+// frequently modified collections. It behaves as Set, including revocations, and implements KeyStore.
+type CopyOnWriteSet struct {
+	writeLock sync.Mutex
+	snapshot  atomic.Value
+}
+
+// copyOnWriteSnapshot is an immutable state of a CopyOnWriteSet
+type copyOnWriteSnapshot struct {
+	keys        []Key
+	kids        map[string][]int
+	revocations *RevocationList
+}
+
+// newCopyOnWriteSnapshot returns a snapshot of keys, which must not be modified afterwards, indexed by kid
+func newCopyOnWriteSnapshot(keys []Key, revocations *RevocationList) *copyOnWriteSnapshot {
+	kids := make(map[string][]int, len(keys))
+
+	for i := range keys {
+		kids[keys[i].KeyId] = append(kids[keys[i].KeyId], i)
+	}
+
+	return &copyOnWriteSnapshot{
+		keys:        keys,
+		kids:        kids,
+		revocations: revocations,
+	}
+}
+
+// NewCopyOnWriteSet returns a CopyOnWriteSet containing copies of keys
+func NewCopyOnWriteSet(keys ...Key) *CopyOnWriteSet {
+	set := &CopyOnWriteSet{}
+	set.Replace(keys)
+
+	return set
+}
+
+// load returns the current snapshot
+func (s *CopyOnWriteSet) load() *copyOnWriteSnapshot {
+	if snapshot, ok := s.snapshot.Load().(*copyOnWriteSnapshot); ok {
+		return snapshot
+	}
+
+	return newCopyOnWriteSnapshot(nil, nil)
+}
+
+// update replaces the snapshot with the one returned by f for a copy of the current keys. Keys in the copy may be
+// replaced but not modified in place, as they share members with the current snapshot.
+func (s *CopyOnWriteSet) update(f func(keys []Key, revocations *RevocationList) ([]Key, *RevocationList)) {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+
+	current := s.load()
+	keys, revocations := f(append([]Key{}, current.keys...), current.revocations)
+	s.snapshot.Store(newCopyOnWriteSnapshot(keys, revocations))
+}
+
+// Add appends a copy of key to the set
+func (s *CopyOnWriteSet) Add(key Key) {
+	s.update(func(keys []Key, revocations *RevocationList) ([]Key, *RevocationList) {
+		return append(keys, key.clone()), revocations
+	})
+}
+
+// AddUnique adds a copy of key to the set unless a key with the same kid is present, checking and adding in one step.
+// It returns true if the key was added.
+func (s *CopyOnWriteSet) AddUnique(key Key) bool {
+	added := false
+
+	s.update(func(keys []Key, revocations *RevocationList) ([]Key, *RevocationList) {
+		for i := range keys {
+			if keys[i].KeyId == key.KeyId {
+				return keys, revocations
+			}
+		}
+
+		added = true
+
+		return append(keys, key.clone()), revocations
+	})
+
+	return added
+}
+
+// Remove removes every key with the given kid and returns true if any was removed
+func (s *CopyOnWriteSet) Remove(kid string) bool {
+	removed := false
+
+	s.update(func(keys []Key, revocations *RevocationList) ([]Key, *RevocationList) {
+		kept := keys[:0]
+
+		for _, key := range keys {
+			if key.KeyId != kid {
+				kept = append(kept, key)
+			}
+		}
+
+		removed = len(kept) != len(keys)
+
+		return kept, revocations
+	})
+
+	return removed
+}
+
+// Replace replaces the contents of the set with copies of keys
+func (s *CopyOnWriteSet) Replace(keys []Key) {
+	copied := make([]Key, 0, len(keys))
+
+	for _, key := range keys {
+		copied = append(copied, key.clone())
+	}
+
+	s.update(func(_ []Key, revocations *RevocationList) ([]Key, *RevocationList) {
+		return copied, revocations
+	})
+}
+
+// Len returns the number of keys in the set
+func (s *CopyOnWriteSet) Len() int {
+	return len(s.load().keys)
+}
+
+// Get returns a copy of the first key with the given kid that is not revoked, without locking
+func (s *CopyOnWriteSet) Get(kid string) (Key, bool) {
+	snapshot := s.load()
+	now := time.Now()
+
+	for _, i := range snapshot.kids[kid] {
+		if !snapshot.revocations.IsRevoked(&snapshot.keys[i], now) {
+			return snapshot.keys[i].clone(), true
+		}
+	}
+
+	return Key{}, false
+}
+
+// SetRevocations replaces the revocation list of the set, nil clears it, see Set.SetRevocations
+func (s *CopyOnWriteSet) SetRevocations(list *RevocationList) {
+	s.update(func(keys []Key, _ *RevocationList) ([]Key, *RevocationList) {
+		return keys, list
+	})
+}
+
+// Response returns a Response containing copies of the keys in the set that are not revoked
+func (s *CopyOnWriteSet) Response() *Response {
+	snapshot := s.load()
+	keys := make([]Key, 0, len(snapshot.keys))
+	now := time.Now()
+
+	for i := range snapshot.keys {
+		if !snapshot.revocations.IsRevoked(&snapshot.keys[i], now) {
+			keys = append(keys, snapshot.keys[i].clone())
+		}
+	}
+
+	return &Response{Keys: keys}
+}
+
+// MarshalJSON renders the set as a JWK Set document
+func (s *CopyOnWriteSet) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Response())
+}
+
+// Load returns a copy of the first key with the given kid, including revoked keys, or a *KeyNotFoundError
+func (s *CopyOnWriteSet) Load(kid string) (Key, error) {
+	snapshot := s.load()
+
+	if indexes := snapshot.kids[kid]; len(indexes) > 0 {
+		return snapshot.keys[indexes[0]].clone(), nil
+	}
+
+	return Key{}, newKeyNotFoundError(kid)
+}
+
+// Save replaces the keys with the same kid as key with a copy of key, or appends it if there are none
+func (s *CopyOnWriteSet) Save(key Key) error {
+	s.update(func(keys []Key, revocations *RevocationList) ([]Key, *RevocationList) {
+		saved := keys[:0]
+		replaced := false
+
+		for _, existing := range keys {
+			if existing.KeyId != key.KeyId {
+				saved = append(saved, existing)
+			} else if !replaced {
+				saved = append(saved, key.clone())
+				replaced = true
+			}
+		}
+
+		if !replaced {
+			saved = append(saved, key.clone())
+		}
+
+		return saved, revocations
+	})
+
+	return nil
+}
+
+// List returns copies of all keys in the set, including revoked keys
+func (s *CopyOnWriteSet) List() ([]Key, error) {
+	snapshot := s.load()
+	keys := make([]Key, 0, len(snapshot.keys))
+
+	for i := range snapshot.keys {
+		keys = append(keys, snapshot.keys[i].clone())
+	}
+
+	return keys, nil
+}
+
+// Delete removes every key with the given kid or returns a *KeyNotFoundError if there are none
+func (s *CopyOnWriteSet) Delete(kid string) error {
+	if !s.Remove(kid) {
+		return newKeyNotFoundError(kid)
+	}
+
+	return nil
+}
@@ -0,0 +1,189 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+func Test_CopyOnWriteSet(t *testing.T) {
+	t.Run("can convert to and from a Response", func(t *testing.T) {
+		req := require.New(t)
+
+		response := &Response{}
+		req.NoError(json.Unmarshal([]byte(testJwksRfc7517Examples), response))
+
+		set := NewCopyOnWriteSet(response.Keys...)
+		req.Equal(3, set.Len())
+		req.Equal(response, set.Response())
+
+		data, err := json.Marshal(set)
+		req.NoError(err)
+
+		parsed, err := ParseResponse(data)
+		req.NoError(err)
+		req.Len(parsed.Keys, 3)
+	})
+
+	t.Run("can add, get and remove keys", func(t *testing.T) {
+		req := require.New(t)
+
+		set := NewCopyOnWriteSet()
+		set.Add(Key{KeyType: KeyTypeOct, KeyId: "a", K: "AQAB"})
+		set.Add(Key{KeyType: KeyTypeOct, KeyId: "b", K: "AQAB"})
+		set.Add(Key{KeyType: KeyTypeOct, KeyId: "a", K: "AQAC"})
+		req.Equal(3, set.Len())
+
+		key, ok := set.Get("a")
+		req.True(ok)
+		req.Equal("AQAB", key.K)
+
+		req.False(set.AddUnique(Key{KeyType: KeyTypeOct, KeyId: "b", K: "AQAC"}))
+		req.True(set.AddUnique(Key{KeyType: KeyTypeOct, KeyId: "c", K: "AQAC"}))
+
+		req.True(set.Remove("a"))
+		req.False(set.Remove("a"))
+		req.Equal(2, set.Len())
+
+		_, ok = set.Get("a")
+		req.False(ok)
+	})
+
+	t.Run("does not change snapshots held by readers", func(t *testing.T) {
+		req := require.New(t)
+
+		set := NewCopyOnWriteSet(Key{KeyType: KeyTypeOct, KeyId: "a", K: "AQAB"})
+
+		snapshot := set.load()
+		set.Replace([]Key{{KeyType: KeyTypeOct, KeyId: "b", K: "AQAB"}})
+
+		req.Len(snapshot.keys, 1)
+		req.Equal("a", snapshot.keys[0].KeyId)
+		req.Equal("b", set.load().keys[0].KeyId)
+	})
+
+	t.Run("isolates callers from the set's keys", func(t *testing.T) {
+		req := require.New(t)
+
+		key := Key{KeyType: KeyTypeOct, KeyId: "a", K: "AQAB", KeyOperations: []string{KeyOperationSign}}
+		set := NewCopyOnWriteSet(key)
+
+		key.KeyOperations[0] = KeyOperationVerify
+
+		stored, ok := set.Get("a")
+		req.True(ok)
+		req.Equal([]string{KeyOperationSign}, stored.KeyOperations)
+
+		stored.KeyOperations[0] = KeyOperationVerify
+
+		stored, _ = set.Get("a")
+		req.Equal([]string{KeyOperationSign}, stored.KeyOperations)
+	})
+
+	t.Run("excludes revoked keys", func(t *testing.T) {
+		req := require.New(t)
+
+		set := NewCopyOnWriteSet(
+			Key{KeyType: KeyTypeOct, KeyId: "a", K: "AQAB"},
+			Key{KeyType: KeyTypeOct, KeyId: "a", K: "AQAC"},
+		)
+		set.SetRevocations(&RevocationList{Revoked: []Revocation{{KeyId: "a"}}})
+
+		_, ok := set.Get("a")
+		req.False(ok)
+		req.Empty(set.Response().Keys)
+		req.Equal(2, set.Len())
+
+		key, err := set.Load("a")
+		req.NoError(err)
+		req.Equal("AQAB", key.K)
+	})
+
+	t.Run("implements KeyStore", func(t *testing.T) {
+		req := require.New(t)
+
+		var store KeyStore = NewCopyOnWriteSet()
+
+		req.NoError(store.Save(Key{KeyType: KeyTypeOct, KeyId: "a", K: "AQAB"}))
+		req.NoError(store.Save(Key{KeyType: KeyTypeOct, KeyId: "a", K: "AQAC"}))
+
+		keys, err := store.List()
+		req.NoError(err)
+		req.Len(keys, 1)
+		req.Equal("AQAC", keys[0].K)
+
+		req.NoError(store.Delete("a"))
+		req.IsType(&KeyNotFoundError{}, store.Delete("a"))
+
+		_, err = store.Load("a")
+		req.IsType(&KeyNotFoundError{}, err)
+	})
+
+	t.Run("can be used concurrently", func(t *testing.T) {
+		req := require.New(t)
+
+		set := NewCopyOnWriteSet()
+		wg := sync.WaitGroup{}
+
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+
+				for j := 0; j < 100; j++ {
+					kid := fmt.Sprintf("%d-%d", i, j)
+					set.Add(Key{KeyType: KeyTypeOct, KeyId: kid, K: "AQAB"})
+					_, _ = set.Get(kid)
+					_ = set.Response()
+					set.Remove(kid)
+				}
+			}(i)
+		}
+
+		wg.Wait()
+		req.Equal(0, set.Len())
+	})
+}
+
+func BenchmarkSetGet(b *testing.B) {
+	keys := make([]Key, 0, 100)
+
+	for i := 0; i < 100; i++ {
+		keys = append(keys, Key{KeyType: KeyTypeOct, KeyId: fmt.Sprintf("kid-%d", i), K: "AQAB"})
+	}
+
+	for name, get := range map[string]func(string) (Key, bool){
+		"Set":            NewSet(keys...).Get,
+		"CopyOnWriteSet": NewCopyOnWriteSet(keys...).Get,
+	} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, ok := get("kid-99"); !ok {
+						b.Fatal("key not found")
+					}
+				}
+			})
+		})
+	}
+}
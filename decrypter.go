@@ -0,0 +1,116 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"io"
+)
+
+// KeyDecrypter implements crypto.Decrypter for a private RSA Key. When Decrypt is called with nil opts the OAEP
+// parameters required by the key's alg are used.
+type KeyDecrypter struct {
+	decrypter crypto.Decrypter
+	opts      *rsa.OAEPOptions
+	algorithm string
+}
+
+// KeyToDecrypter converts a private RSA Key to a crypto.Decrypter for use with RSA-OAEP (SHA-1) or RSA-OAEP-256. Keys
+// without an alg default to RSA-OAEP-256. The returned decrypter is a *KeyDecrypter.
+func KeyToDecrypter(key Key) (crypto.Decrypter, error) {
+	if key.KeyType != KeyTypeRsa {
+		return nil, fmt.Errorf("decryption is only supported for RSA keys, got: %s", key.KeyType)
+	}
+
+	algorithm := key.Algorithm
+
+	if algorithm == "" {
+		algorithm = AlgorithmRsaOaep256
+	}
+
+	var opts *rsa.OAEPOptions
+
+	switch algorithm {
+	case AlgorithmRsaOaep:
+		opts = &rsa.OAEPOptions{Hash: crypto.SHA1}
+	case AlgorithmRsaOaep256:
+		opts = &rsa.OAEPOptions{Hash: crypto.SHA256}
+	default:
+		return nil, fmt.Errorf("unsupported decryption alg: %s", algorithm)
+	}
+
+	privKey, err := KeyToPrivateKey(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyDecrypter{
+		decrypter: privKey.(*rsa.PrivateKey),
+		opts:      opts,
+		algorithm: algorithm,
+	}, nil
+}
+
+// Public returns the public key corresponding to the private key
+func (d *KeyDecrypter) Public() crypto.PublicKey {
+	return d.decrypter.Public()
+}
+
+// Decrypt decrypts ciphertext with the private key. If opts is nil the OAEP options required by the decrypter's alg
+// are used.
+func (d *KeyDecrypter) Decrypt(rand io.Reader, ciphertext []byte, opts crypto.DecrypterOpts) ([]byte, error) {
+	if opts == nil {
+		opts = d.opts
+	}
+
+	return d.decrypter.Decrypt(rand, ciphertext, opts)
+}
+
+// Algorithm returns the JWE alg the decrypter handles
+func (d *KeyDecrypter) Algorithm() string {
+	return d.algorithm
+}
+
+// EncryptOaep encrypts plaintext to the public RSA key using the OAEP parameters of alg, RSA-OAEP or RSA-OAEP-256. It
+// is the counterpart of KeyToDecrypter for envelope-encryption scenarios.
+func EncryptOaep(rand io.Reader, key Key, algorithm string, plaintext []byte) ([]byte, error) {
+	pubKey, err := KeyToPublicKey(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rsaPubKey, ok := pubKey.(*rsa.PublicKey)
+
+	if !ok {
+		return nil, fmt.Errorf("encryption is only supported for RSA keys, got: %s", key.KeyType)
+	}
+
+	switch algorithm {
+	case AlgorithmRsaOaep:
+		return rsa.EncryptOAEP(sha1.New(), rand, rsaPubKey, plaintext, nil)
+	case AlgorithmRsaOaep256:
+		return rsa.EncryptOAEP(sha256.New(), rand, rsaPubKey, plaintext, nil)
+	}
+
+	return nil, fmt.Errorf("unsupported encryption alg: %s", algorithm)
+}
@@ -0,0 +1,82 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto/rand"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_KeyToDecrypter(t *testing.T) {
+	plaintext := []byte("content encryption key")
+
+	for _, algorithm := range []string{AlgorithmRsaOaep, AlgorithmRsaOaep256} {
+		t.Run("can decrypt "+algorithm, func(t *testing.T) {
+			req := require.New(t)
+
+			_, rsaPrivKey, err := newRsaCert()
+			req.NoError(err)
+
+			key, err := NewKeyFromPrivateKey("", rsaPrivKey, WithAlgorithm(algorithm))
+			req.NoError(err)
+
+			ciphertext, err := EncryptOaep(rand.Reader, *key, algorithm, plaintext)
+			req.NoError(err)
+
+			decrypter, err := KeyToDecrypter(*key)
+			req.NoError(err)
+			req.Equal(algorithm, decrypter.(*KeyDecrypter).Algorithm())
+
+			result, err := decrypter.Decrypt(rand.Reader, ciphertext, nil)
+			req.NoError(err)
+			req.Equal(plaintext, result)
+		})
+	}
+
+	t.Run("fails to decrypt with mismatched OAEP hash", func(t *testing.T) {
+		req := require.New(t)
+
+		_, rsaPrivKey, err := newRsaCert()
+		req.NoError(err)
+
+		key, err := NewKeyFromPrivateKey("", rsaPrivKey, WithAlgorithm(AlgorithmRsaOaep))
+		req.NoError(err)
+
+		ciphertext, err := EncryptOaep(rand.Reader, *key, AlgorithmRsaOaep256, plaintext)
+		req.NoError(err)
+
+		decrypter, err := KeyToDecrypter(*key)
+		req.NoError(err)
+
+		_, err = decrypter.Decrypt(rand.Reader, ciphertext, nil)
+		req.Error(err)
+	})
+
+	t.Run("errors for EC keys", func(t *testing.T) {
+		req := require.New(t)
+
+		_, ecPrivKey, err := newEcCert()
+		req.NoError(err)
+
+		key, err := NewKeyFromPrivateKey("", ecPrivKey)
+		req.NoError(err)
+
+		_, err = KeyToDecrypter(*key)
+		req.Error(err)
+	})
+}
@@ -0,0 +1,293 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// InsecureDeterministicGenerator derives key pairs from a seed so that test fixtures and golden JWKS files remain
+// stable across runs. Each key is derived from the seed together with its type, size or curve, and kid, so the same
+// request always yields the same key regardless of the order keys are generated in.
+//
+// The keys are NOT secure: anyone who knows the seed can recreate them. They must only be used in tests.
+type InsecureDeterministicGenerator struct {
+	seed []byte
+}
+
+// NewInsecureDeterministicGenerator returns a generator deriving all keys from seed. See
+// InsecureDeterministicGenerator.
+func NewInsecureDeterministicGenerator(seed []byte) *InsecureDeterministicGenerator {
+	return &InsecureDeterministicGenerator{
+		seed: append([]byte(nil), seed...),
+	}
+}
+
+// RSAKey derives an RSA key pair with a public exponent of 65537 as GenerateRSAKey would generate it. Sizes below
+// 1024 bits are refused.
+func (g *InsecureDeterministicGenerator) RSAKey(bits int, kid string, opts ...KeyOption) (*Key, *Key, error) {
+	if bits < 1024 {
+		return nil, nil, fmt.Errorf("invalid RSA key size: %d", bits)
+	}
+
+	if err := newKeyOptions(opts).checkGeneration(KeyTypeRsa, "", AlgorithmRs256, bits); err != nil {
+		return nil, nil, err
+	}
+
+	privateKey, err := deterministicRsaKey(g.reader(KeyTypeRsa, fmt.Sprint(bits), kid), bits)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not generate RSA key: %s", err)
+	}
+
+	return newGeneratedKeyPair(kid, privateKey, opts)
+}
+
+// ECKey derives an EC key pair on the named curve as GenerateECKey would generate it
+func (g *InsecureDeterministicGenerator) ECKey(curve string, kid string, opts ...KeyOption) (*Key, *Key, error) {
+	switch curve {
+	case "P-256", "P-384", "P-521":
+	default:
+		return nil, nil, fmt.Errorf("unsupported curve for EC key generation: %s", curve)
+	}
+
+	inferred := InferAlgorithm(&Key{KeyType: KeyTypeEc, Curve: curve})
+
+	if err := newKeyOptions(opts).checkGeneration(KeyTypeEc, curve, inferred, 0); err != nil {
+		return nil, nil, err
+	}
+
+	privateKey, err := deterministicEcKey(g.reader(KeyTypeEc, curve, kid), curve)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not generate EC key: %s", err)
+	}
+
+	return newGeneratedKeyPair(kid, privateKey, opts)
+}
+
+// OKPKey derives an Ed25519 or X25519 key pair as GenerateOKPKey would generate it
+func (g *InsecureDeterministicGenerator) OKPKey(curve string, kid string, opts ...KeyOption) (*Key, *Key, error) {
+	inferred := InferAlgorithm(&Key{KeyType: KeyTypeOkp, Curve: curve})
+
+	if err := newKeyOptions(opts).checkGeneration(KeyTypeOkp, curve, inferred, 0); err != nil {
+		return nil, nil, err
+	}
+
+	random := g.reader(KeyTypeOkp, curve, kid)
+
+	switch curve {
+	case CurveEd25519:
+		seed := make([]byte, ed25519.SeedSize)
+
+		if _, err := io.ReadFull(random, seed); err != nil {
+			return nil, nil, fmt.Errorf("could not generate Ed25519 key: %s", err)
+		}
+
+		return newGeneratedKeyPair(kid, ed25519.NewKeyFromSeed(seed), opts)
+	case CurveX25519:
+		return generateX25519Key(random, kid, opts)
+	}
+
+	return nil, nil, fmt.Errorf("unsupported curve for OKP key generation: %s", curve)
+}
+
+// OctKey derives a symmetric key as GenerateOctKey would generate it. As a random UUID would not be stable, an empty
+// kid is replaced with the key's thumbprint unless WithKidStrategy is supplied.
+func (g *InsecureDeterministicGenerator) OctKey(bytes int, kid string, opts ...KeyOption) (*Key, error) {
+	opts = append([]KeyOption{WithKidStrategy(ThumbprintKidStrategy)}, opts...)
+
+	return generateOctKey(g.reader(KeyTypeOct, fmt.Sprint(bytes), kid), bytes, kid, opts)
+}
+
+// reader returns the byte stream a single key is derived from. The stream is SHA-256 in counter mode over a digest of
+// the seed and the labels.
+func (g *InsecureDeterministicGenerator) reader(labels ...string) io.Reader {
+	hash := sha256.New()
+	_, _ = hash.Write(g.seed)
+
+	for _, label := range labels {
+		_ = binary.Write(hash, binary.BigEndian, uint32(len(label)))
+		_, _ = hash.Write([]byte(label))
+	}
+
+	return &deterministicReader{
+		key: hash.Sum(nil),
+	}
+}
+
+// deterministicReader is an endless io.Reader producing SHA-256(key || counter) blocks
+type deterministicReader struct {
+	key     []byte
+	counter uint64
+	buffer  []byte
+}
+
+func (r *deterministicReader) Read(p []byte) (int, error) {
+	read := 0
+
+	for read < len(p) {
+		if len(r.buffer) == 0 {
+			block := make([]byte, len(r.key)+8)
+			copy(block, r.key)
+			binary.BigEndian.PutUint64(block[len(r.key):], r.counter)
+			r.counter++
+
+			sum := sha256.Sum256(block)
+			r.buffer = sum[:]
+		}
+
+		n := copy(p[read:], r.buffer)
+		r.buffer = r.buffer[n:]
+		read += n
+	}
+
+	return read, nil
+}
+
+// deterministicRsaKey builds an RSA key from primes read from random. rsa.GenerateKey is not used as newer Go
+// versions ignore its random source.
+func deterministicRsaKey(random io.Reader, bits int) (*rsa.PrivateKey, error) {
+	e := big.NewInt(65537)
+	one := big.NewInt(1)
+
+	for {
+		p, err := deterministicPrime(random, (bits+1)/2)
+
+		if err != nil {
+			return nil, err
+		}
+
+		q, err := deterministicPrime(random, bits/2)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if p.Cmp(q) == 0 {
+			continue
+		}
+
+		pMinusOne := new(big.Int).Sub(p, one)
+		qMinusOne := new(big.Int).Sub(q, one)
+		phi := new(big.Int).Mul(pMinusOne, qMinusOne)
+
+		d := new(big.Int).ModInverse(e, phi)
+
+		if d == nil {
+			continue
+		}
+
+		n := new(big.Int).Mul(p, q)
+
+		if n.BitLen() != bits {
+			continue
+		}
+
+		privateKey := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{
+				N: n,
+				E: int(e.Int64()),
+			},
+			D:      d,
+			Primes: []*big.Int{p, q},
+		}
+
+		privateKey.Precompute()
+
+		if err := privateKey.Validate(); err != nil {
+			return nil, err
+		}
+
+		return privateKey, nil
+	}
+}
+
+// deterministicPrime reads candidates of the given size from random until one is prime. The top two bits are set so
+// the product of two such primes has exactly the combined size.
+func deterministicPrime(random io.Reader, bits int) (*big.Int, error) {
+	buffer := make([]byte, (bits+7)/8)
+
+	topBits := uint(bits % 8)
+
+	if topBits == 0 {
+		topBits = 8
+	}
+
+	for {
+		if _, err := io.ReadFull(random, buffer); err != nil {
+			return nil, err
+		}
+
+		buffer[0] &= uint8(int(1<<topBits) - 1)
+
+		if topBits >= 2 {
+			buffer[0] |= 3 << (topBits - 2)
+		} else {
+			buffer[0] |= 1
+
+			if len(buffer) > 1 {
+				buffer[1] |= 0x80
+			}
+		}
+
+		buffer[len(buffer)-1] |= 1
+
+		candidate := new(big.Int).SetBytes(buffer)
+
+		if candidate.ProbablyPrime(20) {
+			return candidate, nil
+		}
+	}
+}
+
+// deterministicEcKey derives a private scalar in [1, N-1] from random, reading 64 extra bits to make the modulo bias
+// negligible. ecdsa.GenerateKey is not used as newer Go versions ignore its random source.
+func deterministicEcKey(random io.Reader, curve string) (*ecdsa.PrivateKey, error) {
+	ellipticCurve := curveFromName(curve)
+	params := ellipticCurve.Params()
+	size := (params.BitSize + 7) / 8
+
+	buffer := make([]byte, size+8)
+
+	if _, err := io.ReadFull(random, buffer); err != nil {
+		return nil, err
+	}
+
+	one := big.NewInt(1)
+	d := new(big.Int).SetBytes(buffer)
+	d.Mod(d, new(big.Int).Sub(params.N, one))
+	d.Add(d, one)
+
+	x, y := ellipticCurve.ScalarBaseMult(d.FillBytes(make([]byte, size)))
+
+	return &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: ellipticCurve,
+			X:     x,
+			Y:     y,
+		},
+		D: d,
+	}, nil
+}
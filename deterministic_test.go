@@ -0,0 +1,145 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_InsecureDeterministicGenerator(t *testing.T) {
+	seed := []byte("jwks test fixtures")
+
+	t.Run("derives the same keys from the same seed", func(t *testing.T) {
+		req := require.New(t)
+
+		first := NewInsecureDeterministicGenerator(seed)
+		second := NewInsecureDeterministicGenerator(seed)
+
+		for _, generate := range []func(g *InsecureDeterministicGenerator) (*Key, *Key, error){
+			func(g *InsecureDeterministicGenerator) (*Key, *Key, error) { return g.RSAKey(2048, "rsa") },
+			func(g *InsecureDeterministicGenerator) (*Key, *Key, error) { return g.ECKey("P-256", "ec-256") },
+			func(g *InsecureDeterministicGenerator) (*Key, *Key, error) { return g.ECKey("P-521", "ec-521") },
+			func(g *InsecureDeterministicGenerator) (*Key, *Key, error) { return g.OKPKey(CurveEd25519, "ed") },
+			func(g *InsecureDeterministicGenerator) (*Key, *Key, error) { return g.OKPKey(CurveX25519, "x") },
+		} {
+			firstPrivate, firstPublic, err := generate(first)
+			req.NoError(err)
+			req.NoError(firstPrivate.Validate())
+
+			secondPrivate, secondPublic, err := generate(second)
+			req.NoError(err)
+
+			req.Equal(firstPrivate, secondPrivate)
+			req.Equal(firstPublic, secondPublic)
+		}
+
+		firstOct, err := first.OctKey(32, "")
+		req.NoError(err)
+
+		secondOct, err := second.OctKey(32, "")
+		req.NoError(err)
+
+		req.Equal(firstOct, secondOct)
+		req.NotEmpty(firstOct.KeyId)
+	})
+
+	t.Run("derives stable keys across runs", func(t *testing.T) {
+		req := require.New(t)
+
+		_, public, err := NewInsecureDeterministicGenerator(seed).OKPKey(CurveEd25519, "ed")
+		req.NoError(err)
+
+		raw, err := json.Marshal(public)
+		req.NoError(err)
+		req.JSONEq(`{"kty":"OKP","crv":"Ed25519","use":"sig","key_ops":["verify"],"alg":"EdDSA","kid":"ed","x":"ylT-f8NsRRrBrYRjKBQtvqn4KM6ooUI8UIg5rf2VuZg"}`, string(raw))
+	})
+
+	t.Run("derives keys independently of generation order", func(t *testing.T) {
+		req := require.New(t)
+
+		first := NewInsecureDeterministicGenerator(seed)
+		second := NewInsecureDeterministicGenerator(seed)
+
+		_, _, err := first.ECKey("P-256", "other")
+		req.NoError(err)
+
+		firstPrivate, _, err := first.ECKey("P-256", "ec")
+		req.NoError(err)
+
+		secondPrivate, _, err := second.ECKey("P-256", "ec")
+		req.NoError(err)
+
+		req.Equal(firstPrivate, secondPrivate)
+	})
+
+	t.Run("derives different keys for different seeds and kids", func(t *testing.T) {
+		req := require.New(t)
+
+		first, _, err := NewInsecureDeterministicGenerator(seed).ECKey("P-256", "ec")
+		req.NoError(err)
+
+		otherSeed, _, err := NewInsecureDeterministicGenerator([]byte("other")).ECKey("P-256", "ec")
+		req.NoError(err)
+
+		otherKid, _, err := NewInsecureDeterministicGenerator(seed).ECKey("P-256", "ec-2")
+		req.NoError(err)
+
+		req.NotEqual(first.D, otherSeed.D)
+		req.NotEqual(first.D, otherKid.D)
+	})
+
+	t.Run("can sign and verify with derived RSA keys", func(t *testing.T) {
+		req := require.New(t)
+
+		private, public, err := NewInsecureDeterministicGenerator(seed).RSAKey(2048, "rsa")
+		req.NoError(err)
+
+		privateKey, err := KeyToPrivateKey(*private)
+		req.NoError(err)
+
+		publicKey, err := KeyToPublicKey(*public)
+		req.NoError(err)
+
+		digest := crypto.SHA256.New()
+		digest.Write([]byte("payload"))
+		hashed := digest.Sum(nil)
+
+		signature, err := rsa.SignPKCS1v15(nil, privateKey.(*rsa.PrivateKey), crypto.SHA256, hashed)
+		req.NoError(err)
+		req.NoError(rsa.VerifyPKCS1v15(publicKey.(*rsa.PublicKey), crypto.SHA256, hashed, signature))
+		req.Equal(2048, publicKey.(*rsa.PublicKey).N.BitLen())
+	})
+
+	t.Run("refuses small RSA keys and unsupported curves", func(t *testing.T) {
+		req := require.New(t)
+
+		generator := NewInsecureDeterministicGenerator(seed)
+
+		_, _, err := generator.RSAKey(512, "rsa")
+		req.Error(err)
+
+		_, _, err = generator.ECKey("P-224", "ec")
+		req.Error(err)
+
+		_, _, err = generator.OKPKey("X448", "x")
+		req.Error(err)
+	})
+}
@@ -0,0 +1,185 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/json"
+	"sort"
+)
+
+// ResponseDiff lists how the keys of two Responses differ, see DiffResponses
+type ResponseDiff struct {
+	// Added are the keys only in the new Response
+	Added []Key `json:"added"`
+
+	// Removed are the keys only in the old Response
+	Removed []Key `json:"removed"`
+
+	// Changed are the keys in both Responses whose members differ
+	Changed []KeyChange `json:"changed"`
+
+	// Unchanged counts the keys that are identical in both Responses
+	Unchanged int `json:"unchanged"`
+}
+
+// KeyChange describes a key present in both Responses of a ResponseDiff with different members
+type KeyChange struct {
+	KeyId string `json:"kid"`
+	Old   Key    `json:"old"`
+	New   Key    `json:"new"`
+
+	// Members are the names of the added, removed, and changed members in lexicographic order
+	Members []string `json:"members"`
+}
+
+// IsEmpty returns true if the Responses have the same keys
+func (d *ResponseDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffResponses compares the keys of old and updated, e.g. a provider's set before and after a rotation. Keys are
+// matched by kid, keys without a kid by their RFC 7638 SHA-256 thumbprint, and keys sharing a kid in the order they
+// appear. Members are compared in canonical form, see Key.MarshalCanonical, so member order and formatting do not
+// matter. Either Response may be nil.
+func DiffResponses(old, updated *Response) (*ResponseDiff, error) {
+	diff := &ResponseDiff{}
+
+	var oldKeys, updatedKeys []Key
+
+	if old != nil {
+		oldKeys = old.Keys
+	}
+
+	if updated != nil {
+		updatedKeys = updated.Keys
+	}
+
+	remaining := map[string][]int{}
+
+	for i := range oldKeys {
+		identity := diffIdentity(&oldKeys[i])
+		remaining[identity] = append(remaining[identity], i)
+	}
+
+	matched := make([]bool, len(oldKeys))
+
+	for i := range updatedKeys {
+		identity := diffIdentity(&updatedKeys[i])
+		candidates := remaining[identity]
+
+		if len(candidates) == 0 {
+			diff.Added = append(diff.Added, updatedKeys[i].clone())
+			continue
+		}
+
+		remaining[identity] = candidates[1:]
+		matched[candidates[0]] = true
+
+		members, err := changedMembers(&oldKeys[candidates[0]], &updatedKeys[i])
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(members) == 0 {
+			diff.Unchanged++
+			continue
+		}
+
+		diff.Changed = append(diff.Changed, KeyChange{
+			KeyId:   updatedKeys[i].KeyId,
+			Old:     oldKeys[candidates[0]].clone(),
+			New:     updatedKeys[i].clone(),
+			Members: members,
+		})
+	}
+
+	for i := range oldKeys {
+		if !matched[i] {
+			diff.Removed = append(diff.Removed, oldKeys[i].clone())
+		}
+	}
+
+	return diff, nil
+}
+
+// diffIdentity returns the identity DiffResponses matches keys by
+func diffIdentity(key *Key) string {
+	if key.KeyId != "" {
+		return "kid:" + key.KeyId
+	}
+
+	if thumbprint, err := key.Thumbprint(crypto.SHA256); err == nil {
+		return "thumbprint:" + thumbprint
+	}
+
+	data, _ := key.MarshalCanonical()
+
+	return "json:" + string(data)
+}
+
+// changedMembers returns the names of the members that differ between the canonical forms of old and updated
+func changedMembers(old, updated *Key) ([]string, error) {
+	oldMembers, err := canonicalMembers(old)
+
+	if err != nil {
+		return nil, err
+	}
+
+	updatedMembers, err := canonicalMembers(updated)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var members []string
+
+	for name, value := range oldMembers {
+		if updatedValue, ok := updatedMembers[name]; !ok || !bytes.Equal(value, updatedValue) {
+			members = append(members, name)
+		}
+	}
+
+	for name := range updatedMembers {
+		if _, ok := oldMembers[name]; !ok {
+			members = append(members, name)
+		}
+	}
+
+	sort.Strings(members)
+
+	return members, nil
+}
+
+// canonicalMembers returns the members of the canonical form of key
+func canonicalMembers(key *Key) (map[string]json.RawMessage, error) {
+	data, err := key.MarshalCanonical()
+
+	if err != nil {
+		return nil, err
+	}
+
+	members := map[string]json.RawMessage{}
+
+	if err = json.Unmarshal(data, &members); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
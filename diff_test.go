@@ -0,0 +1,117 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_DiffResponses(t *testing.T) {
+	_, one, err := GenerateECKey("P-256", "one")
+	require.NoError(t, err)
+
+	_, two, err := GenerateECKey("P-256", "two")
+	require.NoError(t, err)
+
+	_, three, err := GenerateOKPKey(CurveEd25519, "three")
+	require.NoError(t, err)
+
+	t.Run("reports identical sets as empty", func(t *testing.T) {
+		req := require.New(t)
+
+		diff, err := DiffResponses(&Response{Keys: []Key{*one, *two}}, &Response{Keys: []Key{*two, *one}})
+		req.NoError(err)
+		req.True(diff.IsEmpty())
+		req.Equal(2, diff.Unchanged)
+	})
+
+	t.Run("reports added, removed, and changed keys", func(t *testing.T) {
+		req := require.New(t)
+
+		changed := one.clone()
+		changed.Use = UseEncryption
+		changed.Algorithm = ""
+
+		diff, err := DiffResponses(&Response{Keys: []Key{*one, *two}}, &Response{Keys: []Key{changed, *three}})
+		req.NoError(err)
+		req.False(diff.IsEmpty())
+		req.Equal(0, diff.Unchanged)
+
+		req.Len(diff.Added, 1)
+		req.Equal("three", diff.Added[0].KeyId)
+
+		req.Len(diff.Removed, 1)
+		req.Equal("two", diff.Removed[0].KeyId)
+
+		req.Len(diff.Changed, 1)
+		req.Equal("one", diff.Changed[0].KeyId)
+		req.Equal([]string{"alg", "use"}, diff.Changed[0].Members)
+		req.Equal(one.Use, diff.Changed[0].Old.Use)
+		req.Equal(UseEncryption, diff.Changed[0].New.Use)
+	})
+
+	t.Run("reports replaced key material under the same kid", func(t *testing.T) {
+		req := require.New(t)
+
+		replaced := two.clone()
+		replaced.KeyId = "one"
+
+		diff, err := DiffResponses(&Response{Keys: []Key{*one}}, &Response{Keys: []Key{replaced}})
+		req.NoError(err)
+		req.Len(diff.Changed, 1)
+		req.Equal([]string{"x", "y"}, diff.Changed[0].Members)
+	})
+
+	t.Run("matches keys without a kid by thumbprint", func(t *testing.T) {
+		req := require.New(t)
+
+		anonymous, other := one.clone(), two.clone()
+		anonymous.KeyId, other.KeyId = "", ""
+
+		diff, err := DiffResponses(&Response{Keys: []Key{anonymous}}, &Response{Keys: []Key{other, anonymous}})
+		req.NoError(err)
+		req.Equal(1, diff.Unchanged)
+		req.Len(diff.Added, 1)
+		req.Equal(other.X, diff.Added[0].X)
+	})
+
+	t.Run("matches duplicate kids in order", func(t *testing.T) {
+		req := require.New(t)
+
+		duplicate := two.clone()
+		duplicate.KeyId = "one"
+
+		diff, err := DiffResponses(&Response{Keys: []Key{*one, duplicate}}, &Response{Keys: []Key{*one}})
+		req.NoError(err)
+		req.Equal(1, diff.Unchanged)
+		req.Len(diff.Removed, 1)
+		req.Equal(two.X, diff.Removed[0].X)
+	})
+
+	t.Run("accepts nil responses", func(t *testing.T) {
+		req := require.New(t)
+
+		diff, err := DiffResponses(nil, &Response{Keys: []Key{*one}})
+		req.NoError(err)
+		req.Len(diff.Added, 1)
+
+		diff, err = DiffResponses(&Response{Keys: []Key{*one}}, nil)
+		req.NoError(err)
+		req.Len(diff.Removed, 1)
+	})
+}
@@ -0,0 +1,105 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"net/http"
+	"strings"
+)
+
+const (
+	// DiscoveryPath is the well known path of OpenID Provider configuration documents, relative to the issuer
+	DiscoveryPath = "/.well-known/openid-configuration"
+
+	ErrorDiscoveryJwksUriMissingMsg = "provider configuration does not contain a jwks_uri"
+	ErrorDiscoveryIssuerMismatchMsg = "provider configuration issuer does not match the requested issuer"
+)
+
+// providerConfiguration is the subset of an OpenID Provider configuration document used for discovery
+type providerConfiguration struct {
+	Issuer  string `json:"issuer"`
+	JwksUri string `json:"jwks_uri"`
+}
+
+// DiscoveryResolver implements Resolver for issuers. The OpenID Provider configuration at the issuer's DiscoveryPath
+// is fetched, its issuer checked against the requested one, and the key set is resolved from its jwks_uri.
+type DiscoveryResolver struct {
+	// Resolver resolves the jwks_uri, if nil an HttpResolver with Client, TlsPolicy and MaxBodySize is used
+	Resolver Resolver
+
+	// Client is used to fetch provider configurations, if nil http.DefaultClient is used
+	Client *http.Client
+
+	// TlsPolicy, if set, is enforced for every fetch and rejects plain HTTP
+	TlsPolicy *TlsPolicy
+
+	// MaxBodySize rejects responses larger than the given number of bytes, zero disables the limit
+	MaxBodySize int64
+}
+
+func (r *DiscoveryResolver) Get(issuer string) (*Response, []byte, error) {
+	jwksUri, err := r.JwksUri(issuer)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resolver := r.Resolver
+
+	if resolver == nil {
+		resolver = &HttpResolver{
+			Client:      r.Client,
+			TlsPolicy:   r.TlsPolicy,
+			MaxBodySize: r.MaxBodySize,
+		}
+	}
+
+	return resolver.Get(jwksUri)
+}
+
+// JwksUri returns the jwks_uri of the issuer's OpenID Provider configuration
+func (r *DiscoveryResolver) JwksUri(issuer string) (string, error) {
+	issuer = strings.TrimSuffix(issuer, "/")
+
+	resp, body, err := httpGet(r.Client, r.TlsPolicy, r.MaxBodySize, issuer+DiscoveryPath, "application/json")
+
+	if err != nil {
+		return "", err
+	}
+
+	config := &providerConfiguration{}
+
+	if err = json.Unmarshal(body, config); err != nil {
+		err = fmt.Errorf("could not parse provider configuration: %s", err)
+	} else if strings.TrimSuffix(config.Issuer, "/") != issuer {
+		err = errors.New(ErrorDiscoveryIssuerMismatchMsg)
+	} else if config.JwksUri == "" {
+		err = errors.New(ErrorDiscoveryJwksUriMissingMsg)
+	}
+
+	if err != nil {
+		return "", &HttpResolverError{
+			Resp:  resp,
+			error: err,
+		}
+	}
+
+	return config.JwksUri, nil
+}
@@ -0,0 +1,108 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_DiscoveryResolver(t *testing.T) {
+	var issuer string
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case DiscoveryPath:
+			rw.Header().Set("content-type", "application/json")
+			_, _ = fmt.Fprintf(rw, `{"issuer": %q, "jwks_uri": %q}`, issuer, issuer+"/keys")
+		case "/other" + DiscoveryPath:
+			rw.Header().Set("content-type", "application/json")
+			_, _ = fmt.Fprintf(rw, `{"issuer": %q, "jwks_uri": %q}`, issuer, issuer+"/keys")
+		case "/empty" + DiscoveryPath:
+			rw.Header().Set("content-type", "application/json")
+			_, _ = fmt.Fprintf(rw, `{"issuer": %q}`, issuer+"/empty")
+		case "/keys":
+			rw.Header().Set("content-type", "application/json")
+			_, _ = rw.Write([]byte(testPublicJwksAuth0))
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	issuer = server.URL
+
+	expected, err := ParseResponse([]byte(testPublicJwksAuth0))
+	require.NoError(t, err)
+
+	t.Run("can resolve the key set of an issuer", func(t *testing.T) {
+		req := require.New(t)
+
+		resolver := &DiscoveryResolver{}
+
+		for _, url := range []string{issuer, issuer + "/"} {
+			response, raw, err := resolver.Get(url)
+			req.NoError(err)
+			req.Equal(testPublicJwksAuth0, string(raw))
+			req.Equal(expected.Keys, response.Keys)
+		}
+	})
+
+	t.Run("can resolve the jwks_uri with another resolver", func(t *testing.T) {
+		req := require.New(t)
+
+		resolver := &DiscoveryResolver{Resolver: &HttpResolver{Stream: true}}
+
+		response, raw, err := resolver.Get(issuer)
+		req.NoError(err)
+		req.Nil(raw)
+		req.Equal(expected.Keys, response.Keys)
+	})
+
+	t.Run("rejects mismatched issuers", func(t *testing.T) {
+		req := require.New(t)
+
+		resolver := &DiscoveryResolver{}
+
+		_, _, err := resolver.Get(issuer + "/other")
+		req.IsType(&HttpResolverError{}, err)
+		req.True(strings.Contains(err.Error(), ErrorDiscoveryIssuerMismatchMsg), err.Error())
+	})
+
+	t.Run("rejects configurations without a jwks_uri", func(t *testing.T) {
+		req := require.New(t)
+
+		resolver := &DiscoveryResolver{}
+
+		_, err := resolver.JwksUri(issuer + "/empty")
+		req.IsType(&HttpResolverError{}, err)
+		req.True(strings.Contains(err.Error(), ErrorDiscoveryJwksUriMissingMsg), err.Error())
+	})
+
+	t.Run("can not discover missing configurations", func(t *testing.T) {
+		req := require.New(t)
+
+		resolver := &DiscoveryResolver{}
+
+		_, _, err := resolver.Get(issuer + "/missing")
+		req.Error(err)
+	})
+}
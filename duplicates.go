@@ -0,0 +1,146 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto"
+	"fmt"
+	"github.com/pkg/errors"
+	"strconv"
+)
+
+const (
+	ErrorDuplicateKidMsg = "duplicate kid"
+)
+
+// DuplicateKidMode selects how Response.ResolveDuplicateKids handles keys sharing a kid
+type DuplicateKidMode int
+
+const (
+	// DuplicateKidsAllow leaves duplicate kids in place
+	DuplicateKidsAllow DuplicateKidMode = iota
+
+	// DuplicateKidsReject returns a *DuplicateKidError if any kid is duplicated
+	DuplicateKidsReject
+
+	// DuplicateKidsKeepFirst drops every key but the first with a given kid
+	DuplicateKidsKeepFirst
+
+	// DuplicateKidsKeepLast drops every key but the last with a given kid
+	DuplicateKidsKeepLast
+
+	// DuplicateKidsDisambiguate keeps every key and renames all but the first with a given kid to
+	// "<kid>#<RFC 7638 SHA-256 thumbprint>"
+	DuplicateKidsDisambiguate
+)
+
+// DuplicateKidError is returned for a kid shared by the keys at Indexes
+type DuplicateKidError struct {
+	error
+	KeyId   string
+	Indexes []int
+}
+
+func (e *DuplicateKidError) Error() string {
+	return fmt.Sprintf("%s %q at keys %v", e.error.Error(), e.KeyId, e.Indexes)
+}
+
+// DuplicateKids returns the indexes of every key sharing a kid, keyed by kid. Keys without a kid are ignored.
+func (r *Response) DuplicateKids() map[string][]int {
+	indexes := map[string][]int{}
+
+	for i, key := range r.Keys {
+		if key.KeyId != "" {
+			indexes[key.KeyId] = append(indexes[key.KeyId], i)
+		}
+	}
+
+	for kid, kidIndexes := range indexes {
+		if len(kidIndexes) < 2 {
+			delete(indexes, kid)
+		}
+	}
+
+	return indexes
+}
+
+// ResolveDuplicateKids applies mode to keys sharing a kid, modifying r.Keys in place. With DuplicateKidsReject the
+// error names the first duplicated kid in document order.
+func (r *Response) ResolveDuplicateKids(mode DuplicateKidMode) error {
+	duplicates := r.DuplicateKids()
+
+	if len(duplicates) == 0 {
+		return nil
+	}
+
+	switch mode {
+	case DuplicateKidsAllow:
+		return nil
+	case DuplicateKidsReject:
+		for _, key := range r.Keys {
+			if indexes, ok := duplicates[key.KeyId]; ok {
+				return &DuplicateKidError{
+					error:   errors.New(ErrorDuplicateKidMsg),
+					KeyId:   key.KeyId,
+					Indexes: indexes,
+				}
+			}
+		}
+	case DuplicateKidsKeepFirst, DuplicateKidsKeepLast:
+		drop := map[int]bool{}
+
+		for _, indexes := range duplicates {
+			keep := indexes[0]
+
+			if mode == DuplicateKidsKeepLast {
+				keep = indexes[len(indexes)-1]
+			}
+
+			for _, index := range indexes {
+				if index != keep {
+					drop[index] = true
+				}
+			}
+		}
+
+		keys := make([]Key, 0, len(r.Keys)-len(drop))
+
+		for i, key := range r.Keys {
+			if !drop[i] {
+				keys = append(keys, key)
+			}
+		}
+
+		r.Keys = keys
+	case DuplicateKidsDisambiguate:
+		for kid, indexes := range duplicates {
+			for _, index := range indexes[1:] {
+				suffix, err := r.Keys[index].Thumbprint(crypto.SHA256)
+
+				if err != nil {
+					suffix = strconv.Itoa(index)
+				}
+
+				r.Keys[index].KeyId = kid + "#" + suffix
+			}
+		}
+	default:
+		return fmt.Errorf("unknown duplicate kid mode %d", mode)
+	}
+
+	return nil
+}
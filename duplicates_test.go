@@ -0,0 +1,119 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func newDuplicateKidResponse(t *testing.T) *Response {
+	response := &Response{}
+	require.NoError(t, json.Unmarshal([]byte(testJwksRfc7517Examples), response))
+
+	// keys: 1, dup, 2011-04-29, dup
+	ecKey := response.Keys[0]
+	rsaKey := response.Keys[1]
+	ecKey.KeyId = "dup"
+	rsaKey.KeyId = "dup"
+	response.Keys = []Key{response.Keys[0], ecKey, response.Keys[1], rsaKey}
+
+	return response
+}
+
+func Test_DuplicateKids(t *testing.T) {
+	t.Run("can detect duplicate kids", func(t *testing.T) {
+		req := require.New(t)
+
+		response := newDuplicateKidResponse(t)
+		req.Equal(map[string][]int{"dup": {1, 3}}, response.DuplicateKids())
+	})
+
+	t.Run("can allow duplicate kids", func(t *testing.T) {
+		req := require.New(t)
+
+		response := newDuplicateKidResponse(t)
+		req.NoError(response.ResolveDuplicateKids(DuplicateKidsAllow))
+		req.Len(response.Keys, 4)
+	})
+
+	t.Run("can reject duplicate kids", func(t *testing.T) {
+		req := require.New(t)
+
+		response := newDuplicateKidResponse(t)
+		err := response.ResolveDuplicateKids(DuplicateKidsReject)
+		req.Error(err)
+
+		var dupErr *DuplicateKidError
+		req.ErrorAs(err, &dupErr)
+		req.Equal("dup", dupErr.KeyId)
+		req.Equal([]int{1, 3}, dupErr.Indexes)
+	})
+
+	t.Run("can keep the first key", func(t *testing.T) {
+		req := require.New(t)
+
+		response := newDuplicateKidResponse(t)
+		req.NoError(response.ResolveDuplicateKids(DuplicateKidsKeepFirst))
+		req.Len(response.Keys, 3)
+		req.Equal("dup", response.Keys[1].KeyId)
+		req.Equal(KeyTypeEc, response.Keys[1].KeyType)
+		req.Equal("2011-04-29", response.Keys[2].KeyId)
+	})
+
+	t.Run("can keep the last key", func(t *testing.T) {
+		req := require.New(t)
+
+		response := newDuplicateKidResponse(t)
+		req.NoError(response.ResolveDuplicateKids(DuplicateKidsKeepLast))
+		req.Len(response.Keys, 3)
+		req.Equal("2011-04-29", response.Keys[1].KeyId)
+		req.Equal("dup", response.Keys[2].KeyId)
+		req.Equal(KeyTypeRsa, response.Keys[2].KeyType)
+	})
+
+	t.Run("can disambiguate duplicate kids", func(t *testing.T) {
+		req := require.New(t)
+
+		response := newDuplicateKidResponse(t)
+		req.NoError(response.ResolveDuplicateKids(DuplicateKidsDisambiguate))
+		req.Len(response.Keys, 4)
+		req.Empty(response.DuplicateKids())
+
+		thumbprint, err := response.Keys[3].Thumbprint(crypto.SHA256)
+		req.NoError(err)
+		req.Equal("dup", response.Keys[1].KeyId)
+		req.Equal("dup#"+thumbprint, response.Keys[3].KeyId)
+	})
+
+	t.Run("parser applies the duplicate kid mode", func(t *testing.T) {
+		req := require.New(t)
+
+		data, err := json.Marshal(newDuplicateKidResponse(t))
+		req.NoError(err)
+
+		_, err = (&Parser{DuplicateKids: DuplicateKidsReject}).Parse(data)
+		req.Error(err)
+
+		response, report, err := (&Parser{DuplicateKids: DuplicateKidsKeepLast}).ParseWithReport(data)
+		req.NoError(err)
+		req.Len(response.Keys, 3)
+		req.Equal(map[string][]int{"dup": {1, 3}}, report.DuplicateKids)
+	})
+}
@@ -0,0 +1,387 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package jwks
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pbkdf2"
+	"io"
+)
+
+const (
+	// JwePbes2Iterations is the PBES2 iteration count (p2c) used when encrypting sets with a passphrase
+	JwePbes2Iterations = 600000
+
+	// maxJwePbes2Iterations bounds the p2c accepted on decryption, as it is chosen by the sender
+	maxJwePbes2Iterations = 10 * JwePbes2Iterations
+
+	// encryptedSetContentType is the JWE cty of encrypted sets
+	encryptedSetContentType = "jwk-set+json"
+
+	ErrorJweMalformedMsg = "malformed JWE, expected compact serialization"
+	ErrorJweDecryptMsg   = "could not decrypt JWE"
+)
+
+// jweHeader is the protected header of the JWEs produced by this package
+type jweHeader struct {
+	Algorithm   string `json:"alg"`
+	Encryption  string `json:"enc"`
+	ContentType string `json:"cty,omitempty"`
+	KeyId       string `json:"kid,omitempty"`
+	Pbes2Salt   string `json:"p2s,omitempty"`
+	Pbes2Count  int    `json:"p2c,omitempty"`
+}
+
+// EncryptSet exports the unrevoked keys of a private Set as a compact JWE encrypted to the recipient's public RSA
+// key with the recipient's alg, RSA-OAEP or RSA-OAEP-256 (the default), and A256GCM content encryption, for transfer
+// to backup systems. It is the counterpart of DecryptSet.
+func EncryptSet(set *Set, recipient Key) ([]byte, error) {
+	algorithm := recipient.Algorithm
+
+	if algorithm == "" {
+		algorithm = AlgorithmRsaOaep256
+	}
+
+	cek, err := newContentEncryptionKey()
+
+	if err != nil {
+		return nil, err
+	}
+
+	encryptedKey, err := EncryptOaep(rand.Reader, recipient, algorithm, cek)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return sealSet(set, &jweHeader{Algorithm: algorithm, KeyId: recipient.KeyId}, cek, encryptedKey)
+}
+
+// DecryptSet decrypts a compact JWE produced by EncryptSet with the recipient's private RSA key
+func DecryptSet(data []byte, recipient Key) (*Set, error) {
+	jwe, err := parseJwe(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if recipient.Algorithm == "" {
+		recipient.Algorithm = jwe.header.Algorithm
+	} else if recipient.Algorithm != jwe.header.Algorithm {
+		return nil, fmt.Errorf("JWE alg %s does not match key alg %s", jwe.header.Algorithm, recipient.Algorithm)
+	}
+
+	decrypter, err := KeyToDecrypter(recipient)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cek, err := decrypter.Decrypt(nil, jwe.encryptedKey, nil)
+
+	if err != nil {
+		return nil, errors.New(ErrorJweDecryptMsg)
+	}
+
+	return jwe.openSet(cek)
+}
+
+// EncryptSetWithPassphrase exports the unrevoked keys of a private Set as a compact JWE encrypted with a key derived
+// from passphrase using PBES2-HS512+A256KW with JwePbes2Iterations iterations and A256GCM content encryption. It is
+// the counterpart of DecryptSetWithPassphrase.
+func EncryptSetWithPassphrase(set *Set, passphrase []byte) ([]byte, error) {
+	salt := make([]byte, 16)
+
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+
+	cek, err := newContentEncryptionKey()
+
+	if err != nil {
+		return nil, err
+	}
+
+	header := &jweHeader{
+		Algorithm:  AlgorithmPbes2Hs512A256Kw,
+		Pbes2Salt:  base64.RawURLEncoding.EncodeToString(salt),
+		Pbes2Count: JwePbes2Iterations,
+	}
+
+	encryptedKey, err := aesKeyWrap(pbes2Key(passphrase, salt, header.Pbes2Count), cek)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return sealSet(set, header, cek, encryptedKey)
+}
+
+// DecryptSetWithPassphrase decrypts a compact JWE produced by EncryptSetWithPassphrase. Iteration counts above ten
+// times JwePbes2Iterations are refused.
+func DecryptSetWithPassphrase(data []byte, passphrase []byte) (*Set, error) {
+	jwe, err := parseJwe(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if jwe.header.Algorithm != AlgorithmPbes2Hs512A256Kw {
+		return nil, fmt.Errorf("unsupported JWE alg for passphrase decryption: %s", jwe.header.Algorithm)
+	}
+
+	if jwe.header.Pbes2Count <= 0 || jwe.header.Pbes2Count > maxJwePbes2Iterations {
+		return nil, fmt.Errorf("invalid JWE p2c: %d", jwe.header.Pbes2Count)
+	}
+
+	salt, err := base64.RawURLEncoding.DecodeString(jwe.header.Pbes2Salt)
+
+	if err != nil || len(salt) < 8 {
+		return nil, errors.New(ErrorJweMalformedMsg)
+	}
+
+	cek, err := aesKeyUnwrap(pbes2Key(passphrase, salt, jwe.header.Pbes2Count), jwe.encryptedKey)
+
+	if err != nil {
+		return nil, errors.New(ErrorJweDecryptMsg)
+	}
+
+	return jwe.openSet(cek)
+}
+
+// compactJwe is a parsed compact JWE, https://www.rfc-editor.org/rfc/rfc7516#section-7.1
+type compactJwe struct {
+	header        *jweHeader
+	encodedHeader string
+	encryptedKey  []byte
+	iv            []byte
+	ciphertext    []byte
+	tag           []byte
+}
+
+func parseJwe(data []byte) (*compactJwe, error) {
+	parts := bytes.Split(bytes.TrimSpace(data), []byte("."))
+
+	if len(parts) != 5 {
+		return nil, errors.New(ErrorJweMalformedMsg)
+	}
+
+	decoded := make([][]byte, len(parts))
+
+	for i, part := range parts {
+		var err error
+
+		if decoded[i], err = base64.RawURLEncoding.DecodeString(string(part)); err != nil {
+			return nil, errors.New(ErrorJweMalformedMsg)
+		}
+	}
+
+	header := &jweHeader{}
+
+	if err := json.Unmarshal(decoded[0], header); err != nil {
+		return nil, errors.New(ErrorJweMalformedMsg)
+	}
+
+	if header.Encryption != AlgorithmA256Gcm {
+		return nil, fmt.Errorf("unsupported JWE enc: %s", header.Encryption)
+	}
+
+	return &compactJwe{
+		header:        header,
+		encodedHeader: string(parts[0]),
+		encryptedKey:  decoded[1],
+		iv:            decoded[2],
+		ciphertext:    decoded[3],
+		tag:           decoded[4],
+	}, nil
+}
+
+// openSet decrypts the content with cek and parses it as a JWK Set
+func (j *compactJwe) openSet(cek []byte) (*Set, error) {
+	gcm, err := newGcm(cek)
+
+	if err != nil {
+		return nil, errors.New(ErrorJweDecryptMsg)
+	}
+
+	if len(j.iv) != gcm.NonceSize() || len(j.tag) != gcm.Overhead() {
+		return nil, errors.New(ErrorJweMalformedMsg)
+	}
+
+	plaintext, err := gcm.Open(nil, j.iv, append(append([]byte(nil), j.ciphertext...), j.tag...), []byte(j.encodedHeader))
+
+	if err != nil {
+		return nil, errors.New(ErrorJweDecryptMsg)
+	}
+
+	response, err := ParseResponse(plaintext)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSetFromResponse(response), nil
+}
+
+// sealSet encrypts the unrevoked keys of set with cek using A256GCM and returns the compact JWE
+func sealSet(set *Set, header *jweHeader, cek []byte, encryptedKey []byte) ([]byte, error) {
+	header.Encryption = AlgorithmA256Gcm
+	header.ContentType = encryptedSetContentType
+
+	rawHeader, err := json.Marshal(header)
+
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := json.Marshal(set.Response())
+
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGcm(cek)
+
+	if err != nil {
+		return nil, err
+	}
+
+	iv := make([]byte, gcm.NonceSize())
+
+	if _, err := io.ReadFull(rand.Reader, iv); err != nil {
+		return nil, err
+	}
+
+	encodedHeader := base64.RawURLEncoding.EncodeToString(rawHeader)
+	sealed := gcm.Seal(nil, iv, plaintext, []byte(encodedHeader))
+	tagStart := len(sealed) - gcm.Overhead()
+
+	return []byte(encodedHeader + "." +
+		base64.RawURLEncoding.EncodeToString(encryptedKey) + "." +
+		base64.RawURLEncoding.EncodeToString(iv) + "." +
+		base64.RawURLEncoding.EncodeToString(sealed[:tagStart]) + "." +
+		base64.RawURLEncoding.EncodeToString(sealed[tagStart:])), nil
+}
+
+// newContentEncryptionKey returns a random A256GCM key
+func newContentEncryptionKey() ([]byte, error) {
+	cek := make([]byte, 32)
+
+	if _, err := io.ReadFull(rand.Reader, cek); err != nil {
+		return nil, err
+	}
+
+	return cek, nil
+}
+
+func newGcm(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// pbes2Key derives the PBES2-HS512+A256KW key wrapping key, https://www.rfc-editor.org/rfc/rfc7518#section-4.8.1.1
+func pbes2Key(passphrase []byte, salt []byte, count int) []byte {
+	saltValue := append(append([]byte(AlgorithmPbes2Hs512A256Kw), 0), salt...)
+
+	return pbkdf2.Key(passphrase, saltValue, count, 32, sha512.New)
+}
+
+// aesKeyWrapIv is the default initial value of RFC 3394 AES key wrap
+var aesKeyWrapIv = []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+
+// aesKeyWrap wraps key with kek as defined in https://www.rfc-editor.org/rfc/rfc3394#section-2.2.1
+func aesKeyWrap(kek []byte, key []byte) ([]byte, error) {
+	if len(key)%8 != 0 || len(key) < 16 {
+		return nil, fmt.Errorf("invalid key size for AES key wrap: %d", len(key))
+	}
+
+	block, err := aes.NewCipher(kek)
+
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(key) / 8
+	ret := make([]byte, 8+len(key))
+	copy(ret, aesKeyWrapIv)
+	copy(ret[8:], key)
+
+	buffer := make([]byte, 16)
+
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			copy(buffer, ret[:8])
+			copy(buffer[8:], ret[8*i:8*i+8])
+			block.Encrypt(buffer, buffer)
+
+			binary.BigEndian.PutUint64(ret[:8], binary.BigEndian.Uint64(buffer[:8])^uint64(n*j+i))
+			copy(ret[8*i:8*i+8], buffer[8:])
+		}
+	}
+
+	return ret, nil
+}
+
+// aesKeyUnwrap unwraps a key wrapped with aesKeyWrap, https://www.rfc-editor.org/rfc/rfc3394#section-2.2.2
+func aesKeyUnwrap(kek []byte, wrapped []byte) ([]byte, error) {
+	if len(wrapped)%8 != 0 || len(wrapped) < 24 {
+		return nil, fmt.Errorf("invalid wrapped key size for AES key wrap: %d", len(wrapped))
+	}
+
+	block, err := aes.NewCipher(kek)
+
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(wrapped)/8 - 1
+	ret := make([]byte, len(wrapped))
+	copy(ret, wrapped)
+
+	buffer := make([]byte, 16)
+
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			binary.BigEndian.PutUint64(buffer[:8], binary.BigEndian.Uint64(ret[:8])^uint64(n*j+i))
+			copy(buffer[8:], ret[8*i:8*i+8])
+			block.Decrypt(buffer, buffer)
+
+			copy(ret[:8], buffer[:8])
+			copy(ret[8*i:8*i+8], buffer[8:])
+		}
+	}
+
+	if subtle.ConstantTimeCompare(ret[:8], aesKeyWrapIv) != 1 {
+		return nil, errors.New("AES key unwrap integrity check failed")
+	}
+
+	return ret[8:], nil
+}
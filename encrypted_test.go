@@ -0,0 +1,144 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_EncryptSet(t *testing.T) {
+	signing, _, err := GenerateECKey("P-256", "signing")
+	require.NoError(t, err)
+
+	set := NewSet(*signing)
+
+	recipient, recipientPublic, err := GenerateRSAKey(2048, "backup", WithAlgorithm(AlgorithmRsaOaep256))
+	require.NoError(t, err)
+
+	t.Run("can export and import a set to a recipient key", func(t *testing.T) {
+		req := require.New(t)
+
+		for _, algorithm := range []string{"", AlgorithmRsaOaep, AlgorithmRsaOaep256} {
+			public := *recipientPublic
+			public.Algorithm = algorithm
+
+			encrypted, err := EncryptSet(set, public)
+			req.NoError(err)
+			req.Len(bytes.Split(encrypted, []byte(".")), 5)
+
+			private := *recipient
+			private.Algorithm = ""
+
+			decrypted, err := DecryptSet(encrypted, private)
+			req.NoError(err)
+			req.Equal(set.Response(), decrypted.Response())
+		}
+	})
+
+	t.Run("rejects other keys and tampered content", func(t *testing.T) {
+		req := require.New(t)
+
+		other, _, err := GenerateRSAKey(2048, "other", WithAlgorithm(AlgorithmRsaOaep256))
+		req.NoError(err)
+
+		private := *recipient
+
+		encrypted, err := EncryptSet(set, *recipientPublic)
+		req.NoError(err)
+
+		_, err = DecryptSet(encrypted, *other)
+		req.EqualError(err, ErrorJweDecryptMsg)
+
+		parts := bytes.Split(encrypted, []byte("."))
+		parts[3][0] ^= 1
+
+		_, err = DecryptSet(bytes.Join(parts, []byte(".")), private)
+		req.Error(err)
+
+		_, err = DecryptSet([]byte("a.b.c"), private)
+		req.EqualError(err, ErrorJweMalformedMsg)
+	})
+
+	t.Run("rejects non RSA recipients", func(t *testing.T) {
+		req := require.New(t)
+
+		_, public, err := GenerateECKey("P-256", "")
+		req.NoError(err)
+
+		_, err = EncryptSet(set, *public)
+		req.Error(err)
+	})
+}
+
+func Test_EncryptSetWithPassphrase(t *testing.T) {
+	signing, _, err := GenerateOKPKey(CurveEd25519, "signing")
+	require.NoError(t, err)
+
+	set := NewSet(*signing)
+
+	t.Run("can export and import a set with a passphrase", func(t *testing.T) {
+		req := require.New(t)
+
+		encrypted, err := EncryptSetWithPassphrase(set, []byte("correct horse"))
+		req.NoError(err)
+
+		decrypted, err := DecryptSetWithPassphrase(encrypted, []byte("correct horse"))
+		req.NoError(err)
+		req.Equal(set.Response(), decrypted.Response())
+
+		_, err = DecryptSetWithPassphrase(encrypted, []byte("wrong horse"))
+		req.EqualError(err, ErrorJweDecryptMsg)
+	})
+
+	t.Run("rejects recipient key JWEs", func(t *testing.T) {
+		req := require.New(t)
+
+		_, public, err := GenerateRSAKey(2048, "backup", WithAlgorithm(AlgorithmRsaOaep256))
+		req.NoError(err)
+
+		encrypted, err := EncryptSet(set, *public)
+		req.NoError(err)
+
+		_, err = DecryptSetWithPassphrase(encrypted, []byte("correct horse"))
+		req.Error(err)
+	})
+}
+
+func Test_AesKeyWrap(t *testing.T) {
+	t.Run("matches the RFC 3394 test vector", func(t *testing.T) {
+		req := require.New(t)
+
+		kek, _ := hex.DecodeString("000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F")
+		key, _ := hex.DecodeString("00112233445566778899AABBCCDDEEFF000102030405060708090A0B0C0D0E0F")
+		expected, _ := hex.DecodeString("28C9F404C4B810F4CBCCB35CFB87F8263F5786E2D80ED326CBC7F0E71A99F43BFB988B9B7A02DD21")
+
+		wrapped, err := aesKeyWrap(kek, key)
+		req.NoError(err)
+		req.Equal(expected, wrapped)
+
+		unwrapped, err := aesKeyUnwrap(kek, wrapped)
+		req.NoError(err)
+		req.Equal(key, unwrapped)
+
+		wrapped[0] ^= 1
+		_, err = aesKeyUnwrap(kek, wrapped)
+		req.Error(err)
+	})
+}
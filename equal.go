@@ -0,0 +1,49 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+)
+
+// ConstantTimeEqual returns true if a and b are both oct keys with the same secret. The decoded secrets are compared
+// in constant time, only their length may leak through timing. Other members are not compared. Integrators comparing
+// symmetric key material should use it rather than comparing the k members as strings.
+func ConstantTimeEqual(a, b Key) bool {
+	if a.KeyType != KeyTypeOct || b.KeyType != KeyTypeOct || a.K == "" || b.K == "" {
+		return false
+	}
+
+	aSecret, err := base64.RawURLEncoding.DecodeString(a.K)
+
+	if err != nil {
+		return false
+	}
+
+	defer wipe(aSecret)
+
+	bSecret, err := base64.RawURLEncoding.DecodeString(b.K)
+
+	if err != nil {
+		return false
+	}
+
+	defer wipe(bSecret)
+
+	return subtle.ConstantTimeCompare(aSecret, bSecret) == 1
+}
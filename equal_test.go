@@ -0,0 +1,51 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_ConstantTimeEqual(t *testing.T) {
+	t.Run("can compare oct keys by secret", func(t *testing.T) {
+		req := require.New(t)
+
+		key, err := GenerateOctKey(32, "a")
+		req.NoError(err)
+
+		other, err := GenerateOctKey(32, "b")
+		req.NoError(err)
+
+		same := *other
+		same.K = key.K
+
+		req.True(ConstantTimeEqual(*key, same))
+		req.False(ConstantTimeEqual(*key, *other))
+	})
+
+	t.Run("can not compare keys that are not oct keys", func(t *testing.T) {
+		req := require.New(t)
+
+		private, _, err := GenerateOKPKey(CurveEd25519, "ed")
+		req.NoError(err)
+
+		req.False(ConstantTimeEqual(*private, *private))
+		req.False(ConstantTimeEqual(Key{KeyType: KeyTypeOct}, Key{KeyType: KeyTypeOct}))
+		req.False(ConstantTimeEqual(Key{KeyType: KeyTypeOct, K: "!"}, Key{KeyType: KeyTypeOct, K: "!"}))
+	})
+}
@@ -0,0 +1,124 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package jwks
+
+import (
+	"sync"
+	"time"
+)
+
+// RotationEventType identifies a key lifecycle transition made by a RotationManager
+type RotationEventType string
+
+const (
+	// KeyPrePublished is emitted when a new next key is generated and published
+	KeyPrePublished RotationEventType = "key-pre-published"
+
+	// KeyActivated is emitted when a key becomes the active signing key
+	KeyActivated RotationEventType = "key-activated"
+
+	// KeyRetired is emitted when the active key is retired and only kept for verification
+	KeyRetired RotationEventType = "key-retired"
+
+	// KeyDestroyed is emitted when an expired retired key is deleted from the key store
+	KeyDestroyed RotationEventType = "key-destroyed"
+)
+
+// RotationEvent describes a key lifecycle transition. Key is the public part of the key after the transition.
+type RotationEvent struct {
+	Type  RotationEventType
+	KeyId string
+	Key   Key
+	Time  time.Time
+}
+
+func newRotationEvent(eventType RotationEventType, key Key, t time.Time) RotationEvent {
+	public := key.withoutRotationState()
+
+	return RotationEvent{
+		Type:  eventType,
+		KeyId: key.KeyId,
+		Key:   public.Public(),
+		Time:  t,
+	}
+}
+
+// RotationSubscriber receives the events of a RotationManager, for example to re-sign long-lived artifacts, audit, or
+// alert on each transition. Events are delivered synchronously in the order they happened, after the manager has been
+// unlocked, so subscribers may call the manager.
+type RotationSubscriber interface {
+	OnRotationEvent(event RotationEvent)
+}
+
+// RotationSubscriberFunc adapts a function to a RotationSubscriber
+type RotationSubscriberFunc func(event RotationEvent)
+
+func (f RotationSubscriberFunc) OnRotationEvent(event RotationEvent) {
+	f(event)
+}
+
+// Subscribe registers subscriber for the events of the manager and returns a function that unregisters it
+func (m *RotationManager) Subscribe(subscriber RotationSubscriber) func() {
+	return m.subscribers.add(subscriber)
+}
+
+// rotationSubscribers is a registry of subscribers that is safe for concurrent use
+type rotationSubscribers struct {
+	lock    sync.RWMutex
+	entries []*rotationSubscriberEntry
+}
+
+type rotationSubscriberEntry struct {
+	subscriber RotationSubscriber
+}
+
+func (s *rotationSubscribers) add(subscriber RotationSubscriber) func() {
+	entry := &rotationSubscriberEntry{subscriber: subscriber}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.entries = append(s.entries, entry)
+
+	return func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		for i, existing := range s.entries {
+			if existing == entry {
+				s.entries = append(s.entries[:i:i], s.entries[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// publish delivers events to all subscribers in order of registration
+func (s *rotationSubscribers) publish(events []RotationEvent) {
+	if len(events) == 0 {
+		return
+	}
+
+	s.lock.RLock()
+	entries := s.entries
+	s.lock.RUnlock()
+
+	for _, event := range events {
+		for _, entry := range entries {
+			entry.subscriber.OnRotationEvent(event)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_RotationEvents(t *testing.T) {
+	t.Run("emits an event for every transition", func(t *testing.T) {
+		req := require.New(t)
+
+		manager, err := NewRotationManager(NewMemoryKeyStore(), ECKeyPairGenerator("P-256"), WithRetention(-time.Second))
+		req.NoError(err)
+
+		var events []RotationEvent
+		manager.Subscribe(RotationSubscriberFunc(func(event RotationEvent) {
+			events = append(events, event)
+		}))
+
+		first, err := manager.ActiveKey()
+		req.NoError(err)
+
+		before, err := manager.PublicSet()
+		req.NoError(err)
+
+		req.NoError(manager.Rotate())
+
+		req.Len(events, 3)
+		req.Equal(KeyPrePublished, events[0].Type)
+		req.Equal(KeyActivated, events[1].Type)
+		req.Equal(before.Keys[1].KeyId, events[1].KeyId)
+		req.Equal(KeyRetired, events[2].Type)
+		req.Equal(first.KeyId, events[2].KeyId)
+		req.True(events[2].Key.ExpiresAt.IsSet())
+
+		for _, event := range events {
+			req.True(event.Key.IsPublic())
+			req.Nil(event.Key.AdditionalMembers)
+			req.False(event.Time.IsZero())
+		}
+
+		events = nil
+		req.NoError(manager.Rotate())
+
+		req.Len(events, 4)
+		req.Equal(KeyDestroyed, events[3].Type)
+		req.Equal(first.KeyId, events[3].KeyId)
+	})
+
+	t.Run("allows subscribers to call the manager and to unsubscribe", func(t *testing.T) {
+		req := require.New(t)
+
+		manager, err := NewRotationManager(NewMemoryKeyStore(), ECKeyPairGenerator("P-256"))
+		req.NoError(err)
+
+		var activated []string
+		unsubscribe := manager.Subscribe(RotationSubscriberFunc(func(event RotationEvent) {
+			if event.Type == KeyActivated {
+				active, err := manager.ActiveKey()
+				req.NoError(err)
+				activated = append(activated, active.KeyId)
+			}
+		}))
+
+		req.NoError(manager.Rotate())
+		req.Len(activated, 1)
+
+		unsubscribe()
+
+		req.NoError(manager.Rotate())
+		req.Len(activated, 1)
+	})
+}
@@ -0,0 +1,69 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// GetExtra decodes the additional member name into value, which must be a pointer, and returns true if the member is
+// present. Modeled members, such as "t", are accessed through their fields instead.
+func (k *Key) GetExtra(name string, value interface{}) (bool, error) {
+	raw, ok := k.AdditionalMembers[name]
+
+	if !ok {
+		return false, nil
+	}
+
+	if err := json.Unmarshal(raw, value); err != nil {
+		return true, fmt.Errorf("could not decode member %s: %s", name, err)
+	}
+
+	return true, nil
+}
+
+// SetExtra encodes value as JSON and stores it as the additional member name, replacing any existing value. Names of
+// modeled members are rejected.
+func (k *Key) SetExtra(name string, value interface{}) error {
+	if keyMemberNames[name] {
+		return fmt.Errorf("member %s is modeled by Key and can not be set as an additional member", name)
+	}
+
+	raw, err := json.Marshal(value)
+
+	if err != nil {
+		return fmt.Errorf("could not encode member %s: %s", name, err)
+	}
+
+	if k.AdditionalMembers == nil {
+		k.AdditionalMembers = map[string]json.RawMessage{}
+	}
+
+	k.AdditionalMembers[name] = raw
+
+	return nil
+}
+
+// DeleteExtra removes the additional member name if present
+func (k *Key) DeleteExtra(name string) {
+	delete(k.AdditionalMembers, name)
+
+	if len(k.AdditionalMembers) == 0 {
+		k.AdditionalMembers = nil
+	}
+}
@@ -0,0 +1,89 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_KeyExtra(t *testing.T) {
+	type deployment struct {
+		Tier    string   `json:"tier"`
+		Regions []string `json:"regions"`
+	}
+
+	t.Run("can get parsed additional members", func(t *testing.T) {
+		req := require.New(t)
+
+		response := &Response{}
+		req.NoError(json.Unmarshal([]byte(testJwksWithExtensions), response))
+		key := &response.Keys[0]
+
+		vendor := &deployment{}
+		ok, err := key.GetExtra("x-vendor", vendor)
+		req.NoError(err)
+		req.True(ok)
+		req.Equal(&deployment{Tier: "gold", Regions: []string{"us", "eu"}}, vendor)
+
+		var created int64
+		ok, err = key.GetExtra("x-created", &created)
+		req.NoError(err)
+		req.True(ok)
+		req.Equal(int64(1700000000), created)
+
+		ok, err = key.GetExtra("missing", &created)
+		req.NoError(err)
+		req.False(ok)
+	})
+
+	t.Run("can not get a member into the wrong type", func(t *testing.T) {
+		req := require.New(t)
+
+		key := &Key{}
+		req.NoError(key.SetExtra("x-vendor", "text"))
+
+		var number int
+		ok, err := key.GetExtra("x-vendor", &number)
+		req.True(ok)
+		req.Error(err)
+	})
+
+	t.Run("can set and delete additional members", func(t *testing.T) {
+		req := require.New(t)
+
+		key := &Key{KeyType: KeyTypeOct, K: "AQAB"}
+		req.NoError(key.SetExtra("x-deployment", &deployment{Tier: "silver"}))
+
+		data, err := json.Marshal(key)
+		req.NoError(err)
+		req.JSONEq(`{"kty":"oct","k":"AQAB","x-deployment":{"tier":"silver","regions":null}}`, string(data))
+
+		key.DeleteExtra("x-deployment")
+		req.Nil(key.AdditionalMembers)
+	})
+
+	t.Run("can not set modeled members", func(t *testing.T) {
+		req := require.New(t)
+
+		key := &Key{}
+		req.Error(key.SetExtra("t", "byok"))
+		req.Error(key.SetExtra("exp", 1))
+		req.Nil(key.AdditionalMembers)
+	})
+}
@@ -0,0 +1,196 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package jwks
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileKeyStore is a KeyStore persisting keys to a single file as a JWK Set, so issuers keep their signing keys across
+// restarts without a database. Writes go to a temporary file in the same directory that is renamed over the store, so
+// the file is never left partially written. With a passphrase the set is stored as a JWE, see
+// EncryptSetWithPassphrase.
+//
+// The file is read on first use and kept in memory, the store assumes it is the only writer.
+type FileKeyStore struct {
+	path       string
+	passphrase []byte
+
+	lock sync.Mutex
+	keys *Set
+}
+
+// NewFileKeyStore returns a FileKeyStore for the file at path, which is created on the first Save. If passphrase is
+// not empty the file is encrypted with it.
+func NewFileKeyStore(path string, passphrase []byte) *FileKeyStore {
+	return &FileKeyStore{
+		path:       path,
+		passphrase: passphrase,
+	}
+}
+
+func (s *FileKeyStore) Load(kid string) (Key, error) {
+	keys, err := s.load()
+
+	if err != nil {
+		return Key{}, err
+	}
+
+	return keys.Load(kid)
+}
+
+func (s *FileKeyStore) Save(key Key) error {
+	return s.update(func(keys *Set) error {
+		return keys.Save(key)
+	})
+}
+
+func (s *FileKeyStore) List() ([]Key, error) {
+	keys, err := s.load()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return keys.List()
+}
+
+func (s *FileKeyStore) Delete(kid string) error {
+	return s.update(func(keys *Set) error {
+		return keys.Delete(kid)
+	})
+}
+
+// load returns the cached keys, reading the file on first use
+func (s *FileKeyStore) load() (*Set, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.loadLocked()
+}
+
+func (s *FileKeyStore) loadLocked() (*Set, error) {
+	if s.keys != nil {
+		return s.keys, nil
+	}
+
+	data, err := os.ReadFile(s.path)
+
+	if os.IsNotExist(err) {
+		s.keys = NewSet()
+		return s.keys, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(s.passphrase) > 0 {
+		if s.keys, err = DecryptSetWithPassphrase(data, s.passphrase); err != nil {
+			return nil, err
+		}
+
+		return s.keys, nil
+	}
+
+	response, err := ParseResponse(data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.keys = NewSetFromResponse(response)
+
+	return s.keys, nil
+}
+
+// update applies change to a copy of the keys and persists it, the cached keys are only replaced if writing succeeds
+func (s *FileKeyStore) update(change func(keys *Set) error) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	current, err := s.loadLocked()
+
+	if err != nil {
+		return err
+	}
+
+	keys, _ := current.List()
+	updated := NewSet(keys...)
+
+	if err := change(updated); err != nil {
+		return err
+	}
+
+	if err := s.write(updated); err != nil {
+		return err
+	}
+
+	s.keys = updated
+
+	return nil
+}
+
+// write atomically replaces the file with keys
+func (s *FileKeyStore) write(keys *Set) error {
+	var data []byte
+	var err error
+
+	if len(s.passphrase) > 0 {
+		data, err = EncryptSetWithPassphrase(keys, s.passphrase)
+	} else {
+		data, err = json.Marshal(keys)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), "."+filepath.Base(s.path)+".tmp*")
+
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		// no-op once renamed
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if err := tmp.Chmod(0600); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
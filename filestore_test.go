@@ -0,0 +1,103 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_FileKeyStore(t *testing.T) {
+	private, _, err := GenerateECKey("P-256", "signing")
+	require.NoError(t, err)
+
+	t.Run("persists keys across instances", func(t *testing.T) {
+		req := require.New(t)
+
+		path := filepath.Join(t.TempDir(), "keys.json")
+
+		keys, err := NewFileKeyStore(path, nil).List()
+		req.NoError(err)
+		req.Empty(keys)
+
+		store := NewFileKeyStore(path, nil)
+		req.NoError(store.Save(*private))
+		req.NoError(store.Save(Key{KeyType: KeyTypeOct, KeyId: "secret", K: "AAAA"}))
+		req.NoError(store.Delete("secret"))
+
+		reopened := NewFileKeyStore(path, nil)
+		loaded, err := reopened.Load("signing")
+		req.NoError(err)
+		req.Equal(private.D, loaded.D)
+
+		keys, err = reopened.List()
+		req.NoError(err)
+		req.Len(keys, 1)
+
+		_, err = ParseResponse(mustReadFile(req, path))
+		req.NoError(err)
+
+		info, err := os.Stat(path)
+		req.NoError(err)
+		req.Equal(os.FileMode(0600), info.Mode().Perm())
+
+		entries, err := os.ReadDir(filepath.Dir(path))
+		req.NoError(err)
+		req.Len(entries, 1)
+	})
+
+	t.Run("can encrypt the file with a passphrase", func(t *testing.T) {
+		req := require.New(t)
+
+		path := filepath.Join(t.TempDir(), "keys.jwe")
+
+		req.NoError(NewFileKeyStore(path, []byte("passphrase")).Save(*private))
+
+		_, err := ParseResponse(mustReadFile(req, path))
+		req.Error(err)
+
+		loaded, err := NewFileKeyStore(path, []byte("passphrase")).Load("signing")
+		req.NoError(err)
+		req.Equal(private.D, loaded.D)
+
+		_, err = NewFileKeyStore(path, []byte("wrong")).List()
+		req.EqualError(err, ErrorJweDecryptMsg)
+	})
+
+	t.Run("keeps the file unchanged if a change fails", func(t *testing.T) {
+		req := require.New(t)
+
+		path := filepath.Join(t.TempDir(), "keys.json")
+		store := NewFileKeyStore(path, nil)
+		req.NoError(store.Save(*private))
+
+		before := mustReadFile(req, path)
+
+		err := store.Delete("missing")
+		req.IsType(&KeyNotFoundError{}, err)
+		req.Equal(before, mustReadFile(req, path))
+	})
+}
+
+func mustReadFile(req *require.Assertions, path string) []byte {
+	data, err := os.ReadFile(path)
+	req.NoError(err)
+
+	return data
+}
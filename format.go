@@ -0,0 +1,112 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Redacted replaces the values of secret key members in String and Format output
+const Redacted = "[REDACTED]"
+
+// String returns a summary of the key with its kid, kty, alg, and use. Secret members that are present are listed
+// with their values replaced by Redacted.
+func (k Key) String() string {
+	builder := &strings.Builder{}
+	builder.WriteString("Key{kid: ")
+	builder.WriteString(strconv.Quote(k.KeyId))
+	builder.WriteString(", kty: ")
+	builder.WriteString(k.KeyType)
+
+	if k.Algorithm != "" {
+		builder.WriteString(", alg: ")
+		builder.WriteString(k.Algorithm)
+	}
+
+	if k.Use != "" {
+		builder.WriteString(", use: ")
+		builder.WriteString(k.Use)
+	}
+
+	secrets := []struct {
+		name  string
+		value string
+	}{
+		{"k", k.K},
+		{"d", k.D},
+		{"p", k.P},
+		{"q", k.Q},
+		{"dp", k.Dp},
+		{"dq", k.Dq},
+		{"qi", k.Qi},
+	}
+
+	for _, secret := range secrets {
+		if secret.value != "" {
+			builder.WriteString(", ")
+			builder.WriteString(secret.name)
+			builder.WriteString(": ")
+			builder.WriteString(Redacted)
+		}
+	}
+
+	if _, ok := k.AdditionalMembers["oth"]; ok {
+		builder.WriteString(", oth: ")
+		builder.WriteString(Redacted)
+	}
+
+	builder.WriteString("}")
+
+	return builder.String()
+}
+
+// Format implements fmt.Formatter so that every verb, including %v, %+v, and %#v, prints String rather than the
+// struct fields. %q prints String quoted.
+func (k Key) Format(f fmt.State, verb rune) {
+	formatRedacted(f, verb, k.String())
+}
+
+// String returns a summary of the keys in the response as for Key.String
+func (r *Response) String() string {
+	if r == nil {
+		return "Response(nil)"
+	}
+
+	keys := make([]string, len(r.Keys))
+
+	for i, key := range r.Keys {
+		keys[i] = key.String()
+	}
+
+	return "Response{keys: [" + strings.Join(keys, ", ") + "]}"
+}
+
+// Format implements fmt.Formatter as for Key.Format
+func (r *Response) Format(f fmt.State, verb rune) {
+	formatRedacted(f, verb, r.String())
+}
+
+// formatRedacted writes s to f, quoted for %q
+func formatRedacted(f fmt.State, verb rune, s string) {
+	if verb == 'q' {
+		s = strconv.Quote(s)
+	}
+
+	_, _ = f.Write([]byte(s))
+}
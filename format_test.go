@@ -0,0 +1,78 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_KeyString(t *testing.T) {
+	t.Run("can print a private key without its secrets", func(t *testing.T) {
+		req := require.New(t)
+
+		private, _, err := GenerateRSAKey(2048, "rsa")
+		req.NoError(err)
+
+		expected := `Key{kid: "rsa", kty: RSA, alg: RS256, use: sig, d: [REDACTED], p: [REDACTED], q: [REDACTED], dp: [REDACTED], dq: [REDACTED], qi: [REDACTED]}`
+
+		for _, format := range []string{"%v", "%+v", "%#v", "%s"} {
+			req.Equal(expected, fmt.Sprintf(format, *private))
+			req.Equal(expected, fmt.Sprintf(format, private))
+		}
+
+		req.Equal(`"`+`Key{kid: \"rsa\", kty: RSA, alg: RS256, use: sig, d: [REDACTED], p: [REDACTED], q: [REDACTED], dp: [REDACTED], dq: [REDACTED], qi: [REDACTED]}`+`"`, fmt.Sprintf("%q", private))
+		req.NotContains(fmt.Sprintf("%v", private), private.D)
+	})
+
+	t.Run("can print an oct key without its secret", func(t *testing.T) {
+		req := require.New(t)
+
+		key, err := GenerateOctKey(32, "oct")
+		req.NoError(err)
+
+		req.Equal(`Key{kid: "oct", kty: oct, alg: HS256, use: sig, k: [REDACTED]}`, key.String())
+	})
+
+	t.Run("can print a public key", func(t *testing.T) {
+		req := require.New(t)
+
+		req.Equal(`Key{kid: "", kty: EC}`, fmt.Sprint(Key{KeyType: KeyTypeEc, X: "x", Y: "y"}))
+	})
+}
+
+func Test_ResponseString(t *testing.T) {
+	t.Run("can print a response without secrets", func(t *testing.T) {
+		req := require.New(t)
+
+		key, err := GenerateOctKey(32, "oct")
+		req.NoError(err)
+
+		response := &Response{Keys: []Key{*key, {KeyType: KeyTypeEc, KeyId: "ec"}}}
+
+		req.Equal(`Response{keys: [Key{kid: "oct", kty: oct, alg: HS256, use: sig, k: [REDACTED]}, Key{kid: "ec", kty: EC}]}`, fmt.Sprintf("%v", response))
+		req.NotContains(fmt.Sprintf("%+v", response), key.K)
+	})
+
+	t.Run("can print a nil response", func(t *testing.T) {
+		req := require.New(t)
+
+		var response *Response
+		req.Equal("Response(nil)", fmt.Sprint(response))
+	})
+}
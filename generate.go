@@ -0,0 +1,239 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/crypto/curve25519"
+	"io"
+)
+
+// GenerateRSAKey generates an RSA key pair of the given size and returns it as a private Key and its public
+// counterpart. If kid is empty string it is generated as for NewKeyFromPrivateKey. The alg is inferred unless
+// overridden with WithAlgorithm. With WithPolicy, keys the policy does not allow are refused before generation, as for
+// all generators.
+func GenerateRSAKey(bits int, kid string, opts ...KeyOption) (*Key, *Key, error) {
+	if err := newKeyOptions(opts).checkGeneration(KeyTypeRsa, "", AlgorithmRs256, bits); err != nil {
+		return nil, nil, err
+	}
+
+	privateKey, err := rsa.GenerateKey(rand.Reader, bits)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not generate RSA key: %s", err)
+	}
+
+	return newGeneratedKeyPair(kid, privateKey, opts)
+}
+
+// GenerateECKey generates an EC key pair on the named curve, one of "P-256", "P-384", or "P-521", and returns it as a
+// private Key and its public counterpart. Coordinates and the private scalar are padded to the curve size. If kid is
+// empty string it is generated as for NewKeyFromPrivateKey. The alg is inferred from the curve unless overridden with
+// WithAlgorithm.
+func GenerateECKey(curve string, kid string, opts ...KeyOption) (*Key, *Key, error) {
+	var ellipticCurve elliptic.Curve
+
+	switch curve {
+	case "P-256", "P-384", "P-521":
+		ellipticCurve = curveFromName(curve)
+	default:
+		return nil, nil, fmt.Errorf("unsupported curve for EC key generation: %s", curve)
+	}
+
+	inferred := InferAlgorithm(&Key{KeyType: KeyTypeEc, Curve: curve})
+
+	if err := newKeyOptions(opts).checkGeneration(KeyTypeEc, curve, inferred, 0); err != nil {
+		return nil, nil, err
+	}
+
+	privateKey, err := ecdsa.GenerateKey(ellipticCurve, rand.Reader)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not generate EC key: %s", err)
+	}
+
+	return newGeneratedKeyPair(kid, privateKey, opts)
+}
+
+// GenerateOKPKey generates an OKP key pair on the named curve and returns it as a private Key and its public
+// counterpart. Ed25519 keys are signing keys with an inferred alg of EdDSA. X25519 keys are ECDH key agreement keys
+// with an inferred alg of ECDH-ES, use "enc", and deriveKey and deriveBits key_ops on the private key. If kid is empty
+// string it is generated as for NewKeyFromPrivateKey.
+func GenerateOKPKey(curve string, kid string, opts ...KeyOption) (*Key, *Key, error) {
+	inferred := InferAlgorithm(&Key{KeyType: KeyTypeOkp, Curve: curve})
+
+	if err := newKeyOptions(opts).checkGeneration(KeyTypeOkp, curve, inferred, 0); err != nil {
+		return nil, nil, err
+	}
+
+	switch curve {
+	case CurveEd25519:
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not generate Ed25519 key: %s", err)
+		}
+
+		return newGeneratedKeyPair(kid, privateKey, opts)
+	case CurveX25519:
+		return generateX25519Key(rand.Reader, kid, opts)
+	}
+
+	return nil, nil, fmt.Errorf("unsupported curve for OKP key generation: %s", curve)
+}
+
+// generateX25519Key generates an X25519 key pair with a scalar read from random. As the crypto packages have no X25519
+// private key type usable with NewKeyFromPrivateKey, the members are populated directly.
+func generateX25519Key(random io.Reader, kid string, opts []KeyOption) (*Key, *Key, error) {
+	options := newKeyOptions(opts)
+
+	scalar := make([]byte, curve25519.ScalarSize)
+
+	if _, err := io.ReadFull(random, scalar); err != nil {
+		return nil, nil, fmt.Errorf("could not generate X25519 key: %s", err)
+	}
+
+	point, err := curve25519.X25519(scalar, curve25519.Basepoint)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not generate X25519 key: %s", err)
+	}
+
+	private := &Key{
+		KeyType:       KeyTypeOkp,
+		Curve:         CurveX25519,
+		Use:           UseEncryption,
+		KeyOperations: []string{KeyOperationDeriveKey, KeyOperationDeriveBits},
+		KeyId:         kid,
+		X:             base64.RawURLEncoding.EncodeToString(point),
+		D:             base64.RawURLEncoding.EncodeToString(scalar),
+	}
+
+	if err := options.assignKid(private, nil); err != nil {
+		return nil, nil, err
+	}
+
+	if err := options.apply(private); err != nil {
+		return nil, nil, err
+	}
+
+	public := private.Public()
+	public.KeyOperations = nil
+
+	return private, &public, nil
+}
+
+// octAlgorithmSizes maps the algorithms GenerateOctKey supports to the minimum key size in bytes and whether the size
+// must be exact
+var octAlgorithmSizes = map[string]struct {
+	size  int
+	exact bool
+}{
+	AlgorithmHs256:   {32, false},
+	AlgorithmHs384:   {48, false},
+	AlgorithmHs512:   {64, false},
+	AlgorithmA128Gcm: {16, true},
+	AlgorithmA192Gcm: {24, true},
+	AlgorithmA256Gcm: {32, true},
+}
+
+// GenerateOctKey generates a random symmetric key of the given size in bytes. The alg is inferred as the strongest
+// HMAC algorithm the size allows unless given with WithAlgorithm, which also accepts A128GCM, A192GCM, and A256GCM.
+// The key is restricted to signing for HMAC algorithms and to encryption for AES-GCM. If kid is empty string a random
+// UUID is used, as thumbprints of symmetric keys are derived from the secret, unless WithKidStrategy is supplied.
+func GenerateOctKey(bytes int, kid string, opts ...KeyOption) (*Key, error) {
+	return generateOctKey(rand.Reader, bytes, kid, opts)
+}
+
+// generateOctKey implements GenerateOctKey, reading the secret from random
+func generateOctKey(random io.Reader, bytes int, kid string, opts []KeyOption) (*Key, error) {
+	if bytes <= 0 {
+		return nil, fmt.Errorf("invalid oct key size: %d", bytes)
+	}
+
+	options := newKeyOptions(append([]KeyOption{WithKidStrategy(UuidKidStrategy)}, opts...))
+
+	if options.algorithm != "" {
+		required, ok := octAlgorithmSizes[options.algorithm]
+
+		if !ok {
+			return nil, fmt.Errorf("unsupported alg for oct key generation: %s", options.algorithm)
+		}
+
+		if bytes < required.size || (required.exact && bytes != required.size) {
+			return nil, fmt.Errorf("alg %s requires a %d byte key, got %d", options.algorithm, required.size, bytes)
+		}
+	}
+
+	if err := options.checkGeneration(KeyTypeOct, "", inferOctAlgorithm(bytes), 0); err != nil {
+		return nil, err
+	}
+
+	secret := make([]byte, bytes)
+
+	if _, err := io.ReadFull(random, secret); err != nil {
+		return nil, fmt.Errorf("could not generate oct key: %s", err)
+	}
+
+	key := &Key{
+		KeyType: KeyTypeOct,
+		KeyId:   kid,
+		K:       base64.RawURLEncoding.EncodeToString(secret),
+	}
+
+	if err := options.assignKid(key, nil); err != nil {
+		return nil, err
+	}
+
+	if err := options.apply(key); err != nil {
+		return nil, err
+	}
+
+	if isSigningAlgorithm(key.Algorithm) {
+		key.Use = UseSignature
+		key.KeyOperations = []string{KeyOperationSign, KeyOperationVerify}
+	} else if key.Algorithm != "" {
+		key.Use = UseEncryption
+		key.KeyOperations = []string{KeyOperationEncrypt, KeyOperationDecrypt}
+	}
+
+	return key, nil
+}
+
+// newGeneratedKeyPair converts a freshly generated private key to a private Key restricted to signing and a public
+// Key restricted to verification
+func newGeneratedKeyPair(kid string, privateKey crypto.PrivateKey, opts []KeyOption) (*Key, *Key, error) {
+	private, err := NewKeyFromPrivateKey(kid, privateKey, opts...)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	private.KeyOperations = []string{KeyOperationSign}
+
+	public := private.Public()
+	public.KeyOperations = []string{KeyOperationVerify}
+
+	return private, &public, nil
+}
@@ -0,0 +1,327 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/curve25519"
+	"testing"
+)
+
+func Test_GenerateRSAKey(t *testing.T) {
+	t.Run("can generate an RSA key pair", func(t *testing.T) {
+		req := require.New(t)
+
+		private, public, err := GenerateRSAKey(2048, "rsa-key")
+		req.NoError(err)
+
+		req.Equal("rsa-key", private.KeyId)
+		req.Equal("rsa-key", public.KeyId)
+		req.Equal(KeyTypeRsa, public.KeyType)
+		req.Equal(AlgorithmRs256, public.Algorithm)
+		req.Equal([]string{KeyOperationSign}, private.KeyOperations)
+		req.Equal([]string{KeyOperationVerify}, public.KeyOperations)
+
+		req.True(private.IsPrivate())
+		req.True(public.IsPublic())
+		req.NoError(private.Validate())
+		req.NoError(public.Validate())
+
+		privateKey, err := KeyToPrivateKey(*private)
+		req.NoError(err)
+		req.Equal(2048, privateKey.(*rsa.PrivateKey).N.BitLen())
+
+		publicKey, err := KeyToPublicKey(*public)
+		req.NoError(err)
+		req.True(publicKey.(*rsa.PublicKey).Equal(privateKey.(*rsa.PrivateKey).Public()))
+	})
+
+	t.Run("derives the kid and honors options", func(t *testing.T) {
+		req := require.New(t)
+
+		private, public, err := GenerateRSAKey(2048, "", WithAlgorithm(AlgorithmPs256))
+		req.NoError(err)
+		req.NotEmpty(private.KeyId)
+		req.Equal(private.KeyId, public.KeyId)
+		req.Equal(AlgorithmPs256, public.Algorithm)
+	})
+}
+
+func Test_GenerateECKey(t *testing.T) {
+	t.Run("can generate EC key pairs on every supported curve", func(t *testing.T) {
+		req := require.New(t)
+
+		for _, test := range []struct {
+			curve     string
+			algorithm string
+			size      int
+		}{
+			{"P-256", AlgorithmEs256, 32},
+			{"P-384", AlgorithmEs384, 48},
+			{"P-521", AlgorithmEs512, 66},
+		} {
+			for i := 0; i < 8; i++ {
+				private, public, err := GenerateECKey(test.curve, "")
+				req.NoError(err)
+
+				req.Equal(test.curve, public.Curve)
+				req.Equal(test.algorithm, public.Algorithm)
+				req.Equal(private.KeyId, public.KeyId)
+
+				for _, member := range []string{public.X, public.Y, private.D} {
+					decoded, err := base64.RawURLEncoding.DecodeString(member)
+					req.NoError(err)
+					req.Len(decoded, test.size)
+				}
+
+				req.NoError(private.Validate())
+				req.NoError(public.Validate())
+
+				privateKey, err := KeyToPrivateKey(*private)
+				req.NoError(err)
+
+				publicKey, err := KeyToPublicKey(*public)
+				req.NoError(err)
+				req.True(publicKey.(*ecdsa.PublicKey).Equal(privateKey.(*ecdsa.PrivateKey).Public()))
+			}
+		}
+	})
+
+	t.Run("can not generate on unsupported curves", func(t *testing.T) {
+		req := require.New(t)
+
+		for _, curve := range []string{"P-224", "secp256k1", ""} {
+			_, _, err := GenerateECKey(curve, "")
+			req.Error(err)
+		}
+	})
+}
+
+func Test_GenerateOKPKey(t *testing.T) {
+	t.Run("can generate an Ed25519 key pair", func(t *testing.T) {
+		req := require.New(t)
+
+		private, public, err := GenerateOKPKey(CurveEd25519, "ed")
+		req.NoError(err)
+
+		req.Equal(KeyTypeOkp, public.KeyType)
+		req.Equal(CurveEd25519, public.Curve)
+		req.Equal(AlgorithmEdDsa, public.Algorithm)
+		req.Equal("ed", public.KeyId)
+		req.NoError(private.Validate())
+		req.NoError(public.Validate())
+
+		signer, err := KeyToSigner(*private)
+		req.NoError(err)
+
+		signature, err := signer.Sign(nil, []byte("message"), nil)
+		req.NoError(err)
+
+		publicKey, err := KeyToPublicKey(*public)
+		req.NoError(err)
+		req.True(ed25519.Verify(publicKey.(ed25519.PublicKey), []byte("message"), signature))
+	})
+
+	t.Run("can generate an X25519 key pair", func(t *testing.T) {
+		req := require.New(t)
+
+		private, public, err := GenerateOKPKey(CurveX25519, "")
+		req.NoError(err)
+
+		req.Equal(KeyTypeOkp, public.KeyType)
+		req.Equal(CurveX25519, public.Curve)
+		req.Equal(AlgorithmEcdhEs, public.Algorithm)
+		req.Equal(UseEncryption, public.Use)
+		req.Equal([]string{KeyOperationDeriveKey, KeyOperationDeriveBits}, private.KeyOperations)
+		req.Empty(public.KeyOperations)
+		req.NotEmpty(public.KeyId)
+		req.Equal(private.KeyId, public.KeyId)
+		req.True(public.IsForEncryption())
+		req.NoError(private.Validate())
+		req.NoError(public.Validate())
+
+		// agree on a shared secret with a second pair
+		otherPrivate, otherPublic, err := GenerateOKPKey(CurveX25519, "")
+		req.NoError(err)
+
+		decode := func(value string) []byte {
+			decoded, err := base64.RawURLEncoding.DecodeString(value)
+			req.NoError(err)
+			return decoded
+		}
+
+		first, err := curve25519.X25519(decode(private.D), decode(otherPublic.X))
+		req.NoError(err)
+		second, err := curve25519.X25519(decode(otherPrivate.D), decode(public.X))
+		req.NoError(err)
+		req.Equal(first, second)
+	})
+
+	t.Run("validates X25519 keys", func(t *testing.T) {
+		req := require.New(t)
+
+		private, _, err := GenerateOKPKey(CurveX25519, "")
+		req.NoError(err)
+
+		_, other, err := GenerateOKPKey(CurveX25519, "")
+		req.NoError(err)
+
+		private.X = other.X
+		req.Error(private.Validate())
+	})
+
+	t.Run("can not generate on unsupported curves", func(t *testing.T) {
+		req := require.New(t)
+
+		_, _, err := GenerateOKPKey("Ed448", "")
+		req.Error(err)
+	})
+}
+
+func Test_GenerateOctKey(t *testing.T) {
+	t.Run("can generate HMAC keys", func(t *testing.T) {
+		req := require.New(t)
+
+		for bytes, algorithm := range map[int]string{32: AlgorithmHs256, 48: AlgorithmHs384, 64: AlgorithmHs512, 100: AlgorithmHs512} {
+			key, err := GenerateOctKey(bytes, "hmac")
+			req.NoError(err)
+
+			k, err := base64.RawURLEncoding.DecodeString(key.K)
+			req.NoError(err)
+			req.Len(k, bytes)
+
+			req.Equal(KeyTypeOct, key.KeyType)
+			req.Equal(algorithm, key.Algorithm)
+			req.Equal("hmac", key.KeyId)
+			req.Equal(UseSignature, key.Use)
+			req.True(key.IsSymmetric())
+			req.NoError(key.Validate())
+		}
+	})
+
+	t.Run("can generate AES-GCM keys", func(t *testing.T) {
+		req := require.New(t)
+
+		key, err := GenerateOctKey(32, "", WithAlgorithm(AlgorithmA256Gcm))
+		req.NoError(err)
+		req.Equal(AlgorithmA256Gcm, key.Algorithm)
+		req.Equal(UseEncryption, key.Use)
+		req.Equal([]string{KeyOperationEncrypt, KeyOperationDecrypt}, key.KeyOperations)
+		req.NoError(key.Validate())
+	})
+
+	t.Run("uses a random kid by default", func(t *testing.T) {
+		req := require.New(t)
+
+		key, err := GenerateOctKey(32, "")
+		req.NoError(err)
+
+		thumbprint, err := key.Thumbprint(crypto.SHA256)
+		req.NoError(err)
+		req.NotEqual(thumbprint, key.KeyId)
+		req.Len(key.KeyId, 36)
+
+		key, err = GenerateOctKey(32, "", WithKidStrategy(NewSequentialKidStrategy("secret-")))
+		req.NoError(err)
+		req.Equal("secret-1", key.KeyId)
+	})
+
+	t.Run("does not infer an alg for short keys", func(t *testing.T) {
+		req := require.New(t)
+
+		key, err := GenerateOctKey(16, "")
+		req.NoError(err)
+		req.Empty(key.Algorithm)
+		req.Empty(key.Use)
+	})
+
+	t.Run("can not generate keys of the wrong size for the alg", func(t *testing.T) {
+		req := require.New(t)
+
+		for _, test := range []struct {
+			bytes     int
+			algorithm string
+		}{
+			{16, AlgorithmHs256},
+			{32, AlgorithmA128Gcm},
+			{16, AlgorithmA256Gcm},
+			{32, AlgorithmRs256},
+			{0, ""},
+		} {
+			_, err := GenerateOctKey(test.bytes, "", WithAlgorithm(test.algorithm))
+			req.Error(err, test.algorithm)
+		}
+	})
+}
+
+func Test_GeneratePolicy(t *testing.T) {
+	t.Run("refuses RSA keys below the minimum size", func(t *testing.T) {
+		req := require.New(t)
+
+		_, _, err := GenerateRSAKey(1024, "", WithPolicy(DefaultPolicy()))
+
+		var validationErr *KeyValidationError
+		req.ErrorAs(err, &validationErr)
+		req.Len(validationErr.Errors, 1)
+
+		_, _, err = GenerateRSAKey(2048, "", WithPolicy(&Policy{MinRsaBits: 3072}))
+		req.ErrorAs(err, &validationErr)
+	})
+
+	t.Run("refuses disallowed curves and key types", func(t *testing.T) {
+		req := require.New(t)
+
+		_, _, err := GenerateECKey("P-521", "", WithPolicy(&Policy{AllowedCurves: []string{"P-256"}}))
+		req.Error(err)
+
+		_, _, err = GenerateOKPKey(CurveEd25519, "", WithPolicy(FipsPolicy()))
+		req.Error(err)
+
+		_, err = GenerateOctKey(32, "", WithPolicy(FipsPolicy()))
+		req.Error(err)
+	})
+
+	t.Run("refuses disallowed algorithms", func(t *testing.T) {
+		req := require.New(t)
+
+		policy := &Policy{AllowedAlgorithms: []string{AlgorithmEs384}}
+
+		_, _, err := GenerateECKey("P-256", "", WithPolicy(policy))
+		req.Error(err)
+
+		_, _, err = GenerateECKey("P-256", "", WithPolicy(policy), WithAlgorithm(AlgorithmEs384))
+		req.NoError(err)
+	})
+
+	t.Run("generates compliant keys", func(t *testing.T) {
+		req := require.New(t)
+
+		private, public, err := GenerateECKey("P-384", "", WithPolicy(FipsPolicy()))
+		req.NoError(err)
+		req.NoError(private.ValidateWithPolicy(FipsPolicy()))
+		req.NoError(public.ValidateWithPolicy(FipsPolicy()))
+
+		private, _, err = GenerateRSAKey(2048, "", WithPolicy(FipsPolicy()))
+		req.NoError(err)
+		req.NoError(private.ValidateWithPolicy(FipsPolicy()))
+	})
+}
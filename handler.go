@@ -0,0 +1,325 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package jwks
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultHandlerMaxAge is the Cache-Control max-age a Handler emits unless configured with WithMaxAge
+const DefaultHandlerMaxAge = 5 * time.Minute
+
+// ResponseSource provides the Response a Handler serves. It is consulted on every request so that rotations are
+// published immediately. Set implements ResponseSource.
+type ResponseSource interface {
+	Response() *Response
+}
+
+// ResponseSourceFunc adapts a function to a ResponseSource
+type ResponseSourceFunc func() *Response
+
+func (f ResponseSourceFunc) Response() *Response {
+	return f()
+}
+
+// StaticResponseSource returns a ResponseSource that always provides response
+func StaticResponseSource(response *Response) ResponseSource {
+	return ResponseSourceFunc(func() *Response {
+		return response
+	})
+}
+
+// HandlerOption configures a Handler
+type HandlerOption func(*handlerOptions)
+
+type handlerOptions struct {
+	maxAge     time.Duration
+	cors       *CorsConfig
+	signingKey *Key
+	metrics    *HandlerMetrics
+	filters    bool
+}
+
+// CorsConfig configures the CORS headers of a Handler so that browser applications can fetch the keys directly
+type CorsConfig struct {
+	// AllowedOrigins lists the origins allowed to fetch the keys. "*" allows any origin.
+	AllowedOrigins []string
+
+	// AllowedMethods lists the methods announced in preflight responses, defaulting to GET and HEAD
+	AllowedMethods []string
+
+	// MaxAge is how long browsers may cache preflight responses. Zero omits Access-Control-Max-Age.
+	MaxAge time.Duration
+}
+
+// allowOrigin returns the Access-Control-Allow-Origin value for origin or empty string if it is not allowed
+func (c *CorsConfig) allowOrigin(origin string) string {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			return "*"
+		}
+
+		if allowed == origin {
+			return origin
+		}
+	}
+
+	return ""
+}
+
+func (c *CorsConfig) allowedMethods() string {
+	if len(c.AllowedMethods) == 0 {
+		return "GET, HEAD"
+	}
+
+	return strings.Join(c.AllowedMethods, ", ")
+}
+
+// WithMaxAge sets the max-age of the Cache-Control header, rounded down to whole seconds. A max-age of zero or less
+// emits "no-cache", so verifiers revalidate with the ETag on every use.
+func WithMaxAge(maxAge time.Duration) HandlerOption {
+	return func(o *handlerOptions) {
+		o.maxAge = maxAge
+	}
+}
+
+// WithCors adds CORS headers to the responses of a Handler for requests from the configured origins and answers
+// preflight (OPTIONS) requests
+func WithCors(config CorsConfig) HandlerOption {
+	return func(o *handlerOptions) {
+		o.cors = &config
+	}
+}
+
+// WithSigningKey serves the key set as a compact JWS signed by the private key, see SignResponse, with the media type
+// application/jwk-set+jwt instead of application/json, so clients can verify the integrity of the published keys
+func WithSigningKey(key Key) HandlerOption {
+	return func(o *handlerOptions) {
+		o.signingKey = &key
+	}
+}
+
+// WithMetrics records the requests served by the handler in metrics
+func WithMetrics(metrics *HandlerMetrics) HandlerOption {
+	return func(o *handlerOptions) {
+		o.metrics = metrics
+	}
+}
+
+// WithQueryFilters lets clients restrict the served keys with the query parameters kid, use, and alg, each matching
+// the member of the same name exactly, so constrained clients can fetch only the keys they need from large sets.
+// Unknown parameters are ignored.
+func WithQueryFilters() HandlerOption {
+	return func(o *handlerOptions) {
+		o.filters = true
+	}
+}
+
+// Handler returns an http.Handler serving the Response provided by source as a JWKS endpoint. Only the public parts
+// of the keys are served, see Response.PublicOnly, so a Set of private keys may be used as the source directly. GET
+// and HEAD requests are answered with application/json, other methods with 405 Method Not Allowed. If source provides
+// nil the handler answers with 503 Service Unavailable.
+//
+// Responses carry an ETag derived from the SHA-256 hash of the canonical form of the served set, see
+// Response.MarshalCanonical, so it only changes when the keys do. Requests with a matching If-None-Match header are
+// answered with 304 Not Modified. The Cache-Control header defaults to a max-age of DefaultHandlerMaxAge.
+func Handler(source ResponseSource, opts ...HandlerOption) http.Handler {
+	options := handlerOptions{
+		maxAge: DefaultHandlerMaxAge,
+	}
+
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return &handler{
+		source:  source,
+		options: options,
+	}
+}
+
+type handler struct {
+	source  ResponseSource
+	options handlerOptions
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.options.cors != nil {
+		h.writeCorsHeaders(w, r)
+
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Allow", "GET, HEAD, OPTIONS")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	metrics := h.options.metrics
+	metrics.countRequest()
+
+	response := h.source.Response()
+
+	if response == nil {
+		metrics.countError()
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
+	public := response.PublicOnly()
+
+	if h.options.filters {
+		public = filterByQuery(public, r.URL.Query())
+	}
+
+	metrics.setServedKeys(len(public.Keys))
+
+	body, err := json.Marshal(public)
+
+	if err != nil {
+		metrics.countError()
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	canonical, err := public.MarshalCanonical()
+
+	if err != nil {
+		metrics.countError()
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	hash := sha256.Sum256(canonical)
+	etag := `"` + base64.RawURLEncoding.EncodeToString(hash[:]) + `"`
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", h.cacheControl())
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		metrics.countNotModified()
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	contentType := "application/json"
+
+	if h.options.signingKey != nil {
+		if body, err = SignResponse(public, *h.options.signingKey); err != nil {
+			metrics.countError()
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		contentType = "application/" + SignedResponseType
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	_, _ = w.Write(body)
+}
+
+// writeCorsHeaders adds the CORS headers for the request origin, including the preflight headers for OPTIONS requests
+func (h *handler) writeCorsHeaders(w http.ResponseWriter, r *http.Request) {
+	cors := h.options.cors
+
+	if cors.allowOrigin("") != "*" {
+		// the allowed origin depends on the request, caches must not share responses across origins
+		w.Header().Add("Vary", "Origin")
+	}
+
+	origin := r.Header.Get("Origin")
+
+	if origin == "" {
+		return
+	}
+
+	allowOrigin := cors.allowOrigin(origin)
+
+	if allowOrigin == "" {
+		return
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+	w.Header().Set("Access-Control-Expose-Headers", "ETag")
+
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Access-Control-Allow-Methods", cors.allowedMethods())
+		w.Header().Set("Access-Control-Allow-Headers", "If-None-Match")
+
+		if seconds := int64(cors.MaxAge / time.Second); seconds > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.FormatInt(seconds, 10))
+		}
+	}
+}
+
+func (h *handler) cacheControl() string {
+	seconds := int64(h.options.maxAge / time.Second)
+
+	if seconds <= 0 {
+		return "no-cache"
+	}
+
+	return fmt.Sprintf("public, max-age=%d", seconds)
+}
+
+// filterByQuery returns the keys of response matching the kid, use, and alg query parameters present in query
+func filterByQuery(response *Response, query url.Values) *Response {
+	kid, use, alg := query.Get("kid"), query.Get("use"), query.Get("alg")
+
+	if kid == "" && use == "" && alg == "" {
+		return response
+	}
+
+	return &Response{
+		Keys: response.Filter(func(key *Key) bool {
+			return (kid == "" || key.KeyId == kid) && (use == "" || key.Use == use) && (alg == "" || key.Algorithm == alg)
+		}),
+	}
+}
+
+// etagMatches returns true if the If-None-Match header value ifNoneMatch matches etag using the weak comparison of
+// RFC 9110 section 13.1.2
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+
+	return false
+}
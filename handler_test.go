@@ -0,0 +1,342 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_Handler(t *testing.T) {
+	t.Run("can serve the public keys of a set", func(t *testing.T) {
+		req := require.New(t)
+
+		private, _, err := GenerateECKey("P-256", "ec")
+		req.NoError(err)
+
+		secret, err := GenerateOctKey(32, "secret")
+		req.NoError(err)
+
+		recorder := httptest.NewRecorder()
+		Handler(NewSet(*private, *secret)).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+
+		req.Equal(http.StatusOK, recorder.Code)
+		req.Equal("application/json", recorder.Header().Get("Content-Type"))
+
+		response, err := ParseResponse(recorder.Body.Bytes())
+		req.NoError(err)
+		req.Len(response.Keys, 1)
+		req.Equal("ec", response.Keys[0].KeyId)
+		req.True(response.Keys[0].IsPublic())
+	})
+
+	t.Run("can serve the current response of a source", func(t *testing.T) {
+		req := require.New(t)
+
+		_, public, err := GenerateECKey("P-256", "first")
+		req.NoError(err)
+
+		current := &Response{Keys: []Key{*public}}
+		handler := Handler(ResponseSourceFunc(func() *Response {
+			return current
+		}))
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		body := &Response{}
+		req.NoError(json.Unmarshal(recorder.Body.Bytes(), body))
+		req.Equal("first", body.Keys[0].KeyId)
+
+		_, public, err = GenerateECKey("P-256", "second")
+		req.NoError(err)
+		current = &Response{Keys: []Key{*public}}
+
+		recorder = httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		body = &Response{}
+		req.NoError(json.Unmarshal(recorder.Body.Bytes(), body))
+		req.Equal("second", body.Keys[0].KeyId)
+	})
+
+	t.Run("answers HEAD requests without a body", func(t *testing.T) {
+		req := require.New(t)
+
+		recorder := httptest.NewRecorder()
+		Handler(StaticResponseSource(&Response{})).ServeHTTP(recorder, httptest.NewRequest(http.MethodHead, "/", nil))
+
+		req.Equal(http.StatusOK, recorder.Code)
+		req.Equal("application/json", recorder.Header().Get("Content-Type"))
+		req.Empty(recorder.Body.Bytes())
+	})
+
+	t.Run("rejects other methods", func(t *testing.T) {
+		req := require.New(t)
+
+		recorder := httptest.NewRecorder()
+		Handler(StaticResponseSource(&Response{})).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/", nil))
+
+		req.Equal(http.StatusMethodNotAllowed, recorder.Code)
+		req.Equal("GET, HEAD", recorder.Header().Get("Allow"))
+	})
+
+	t.Run("answers 503 if the source has no response", func(t *testing.T) {
+		req := require.New(t)
+
+		recorder := httptest.NewRecorder()
+		Handler(StaticResponseSource(nil)).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		req.Equal(http.StatusServiceUnavailable, recorder.Code)
+	})
+}
+
+func Test_HandlerCaching(t *testing.T) {
+	newResponse := func(req *require.Assertions, kids ...string) *Response {
+		response := &Response{}
+
+		for _, kid := range kids {
+			_, public, err := GenerateECKey("P-256", kid)
+			req.NoError(err)
+			response.Keys = append(response.Keys, *public)
+		}
+
+		return response
+	}
+
+	serve := func(handler http.Handler, ifNoneMatch string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+
+		if ifNoneMatch != "" {
+			request.Header.Set("If-None-Match", ifNoneMatch)
+		}
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	t.Run("emits a stable ETag independent of key order", func(t *testing.T) {
+		req := require.New(t)
+
+		response := newResponse(req, "a", "b")
+		reversed := &Response{Keys: []Key{response.Keys[1], response.Keys[0]}}
+
+		etag := serve(Handler(StaticResponseSource(response)), "").Header().Get("ETag")
+		req.NotEmpty(etag)
+		req.Equal(etag, serve(Handler(StaticResponseSource(response)), "").Header().Get("ETag"))
+		req.Equal(etag, serve(Handler(StaticResponseSource(reversed)), "").Header().Get("ETag"))
+
+		other := serve(Handler(StaticResponseSource(newResponse(req, "a"))), "").Header().Get("ETag")
+		req.NotEqual(etag, other)
+	})
+
+	t.Run("answers 304 for a matching If-None-Match", func(t *testing.T) {
+		req := require.New(t)
+
+		handler := Handler(StaticResponseSource(newResponse(req, "a")))
+		etag := serve(handler, "").Header().Get("ETag")
+
+		for _, ifNoneMatch := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+			recorder := serve(handler, ifNoneMatch)
+			req.Equal(http.StatusNotModified, recorder.Code, ifNoneMatch)
+			req.Equal(etag, recorder.Header().Get("ETag"))
+			req.Empty(recorder.Body.Bytes())
+		}
+
+		req.Equal(http.StatusOK, serve(handler, `"other"`).Code)
+	})
+
+	t.Run("emits a configurable Cache-Control", func(t *testing.T) {
+		req := require.New(t)
+
+		source := StaticResponseSource(&Response{})
+
+		req.Equal("public, max-age=300", serve(Handler(source), "").Header().Get("Cache-Control"))
+		req.Equal("public, max-age=3600", serve(Handler(source, WithMaxAge(time.Hour)), "").Header().Get("Cache-Control"))
+		req.Equal("no-cache", serve(Handler(source, WithMaxAge(0)), "").Header().Get("Cache-Control"))
+	})
+}
+
+func Test_HandlerCors(t *testing.T) {
+	serve := func(handler http.Handler, method string, origin string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, "/", nil)
+
+		if origin != "" {
+			request.Header.Set("Origin", origin)
+		}
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	t.Run("allows configured origins", func(t *testing.T) {
+		req := require.New(t)
+
+		handler := Handler(StaticResponseSource(&Response{}), WithCors(CorsConfig{
+			AllowedOrigins: []string{"https://app.example.com"},
+		}))
+
+		recorder := serve(handler, http.MethodGet, "https://app.example.com")
+		req.Equal(http.StatusOK, recorder.Code)
+		req.Equal("https://app.example.com", recorder.Header().Get("Access-Control-Allow-Origin"))
+		req.Equal("ETag", recorder.Header().Get("Access-Control-Expose-Headers"))
+		req.Equal("Origin", recorder.Header().Get("Vary"))
+
+		recorder = serve(handler, http.MethodGet, "https://evil.example.com")
+		req.Equal(http.StatusOK, recorder.Code)
+		req.Empty(recorder.Header().Get("Access-Control-Allow-Origin"))
+		req.Equal("Origin", recorder.Header().Get("Vary"))
+	})
+
+	t.Run("allows any origin with a wildcard", func(t *testing.T) {
+		req := require.New(t)
+
+		handler := Handler(StaticResponseSource(&Response{}), WithCors(CorsConfig{
+			AllowedOrigins: []string{"*"},
+		}))
+
+		recorder := serve(handler, http.MethodGet, "https://app.example.com")
+		req.Equal("*", recorder.Header().Get("Access-Control-Allow-Origin"))
+		req.Empty(recorder.Header().Get("Vary"))
+	})
+
+	t.Run("answers preflight requests", func(t *testing.T) {
+		req := require.New(t)
+
+		handler := Handler(StaticResponseSource(&Response{}), WithCors(CorsConfig{
+			AllowedOrigins: []string{"https://app.example.com"},
+			AllowedMethods: []string{http.MethodGet},
+			MaxAge:         time.Hour,
+		}))
+
+		recorder := serve(handler, http.MethodOptions, "https://app.example.com")
+		req.Equal(http.StatusNoContent, recorder.Code)
+		req.Equal("https://app.example.com", recorder.Header().Get("Access-Control-Allow-Origin"))
+		req.Equal("GET", recorder.Header().Get("Access-Control-Allow-Methods"))
+		req.Equal("If-None-Match", recorder.Header().Get("Access-Control-Allow-Headers"))
+		req.Equal("3600", recorder.Header().Get("Access-Control-Max-Age"))
+	})
+
+	t.Run("does not add CORS headers unless configured", func(t *testing.T) {
+		req := require.New(t)
+
+		handler := Handler(StaticResponseSource(&Response{}))
+
+		recorder := serve(handler, http.MethodGet, "https://app.example.com")
+		req.Empty(recorder.Header().Get("Access-Control-Allow-Origin"))
+
+		recorder = serve(handler, http.MethodOptions, "https://app.example.com")
+		req.Equal(http.StatusMethodNotAllowed, recorder.Code)
+	})
+}
+
+func Test_HandlerSigningKey(t *testing.T) {
+	t.Run("can serve a signed key set", func(t *testing.T) {
+		req := require.New(t)
+
+		private, _, err := GenerateECKey("P-256", "published")
+		req.NoError(err)
+
+		signingPrivate, signingPublic, err := GenerateOKPKey(CurveEd25519, "signing")
+		req.NoError(err)
+
+		recorder := httptest.NewRecorder()
+		Handler(NewSet(*private), WithSigningKey(*signingPrivate)).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		req.Equal(http.StatusOK, recorder.Code)
+		req.Equal("application/jwk-set+jwt", recorder.Header().Get("Content-Type"))
+
+		verified, err := VerifySignedResponse(recorder.Body.Bytes(), *signingPublic)
+		req.NoError(err)
+		req.Len(verified.Keys, 1)
+		req.Equal("published", verified.Keys[0].KeyId)
+		req.True(verified.Keys[0].IsPublic())
+	})
+}
+
+func Test_HandlerQueryFilters(t *testing.T) {
+	signing, _, err := GenerateECKey("P-256", "signing")
+	require.NoError(t, err)
+
+	signingRsa, _, err := GenerateRSAKey(2048, "signing-rsa")
+	require.NoError(t, err)
+
+	encryption, _, err := GenerateOKPKey(CurveX25519, "encryption")
+	require.NoError(t, err)
+
+	set := NewSet(*signing, *signingRsa, *encryption)
+
+	serve := func(req *require.Assertions, handler http.Handler, target string) []string {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, target, nil))
+		req.Equal(http.StatusOK, recorder.Code)
+
+		response, err := ParseResponse(recorder.Body.Bytes())
+		req.NoError(err)
+
+		var kids []string
+
+		for _, key := range response.Keys {
+			kids = append(kids, key.KeyId)
+		}
+
+		return kids
+	}
+
+	t.Run("can filter by kid, use and alg", func(t *testing.T) {
+		req := require.New(t)
+
+		handler := Handler(set, WithQueryFilters())
+
+		req.Equal([]string{"signing", "signing-rsa", "encryption"}, serve(req, handler, "/"))
+		req.Equal([]string{"signing"}, serve(req, handler, "/?kid=signing"))
+		req.Equal([]string{"signing", "signing-rsa"}, serve(req, handler, "/?use=sig"))
+		req.Equal([]string{"encryption"}, serve(req, handler, "/?use=enc"))
+		req.Equal([]string{"signing-rsa"}, serve(req, handler, "/?use=sig&alg=RS256"))
+		req.Empty(serve(req, handler, "/?kid=missing"))
+	})
+
+	t.Run("reports the number of filtered keys as served", func(t *testing.T) {
+		req := require.New(t)
+
+		metrics := &HandlerMetrics{}
+		handler := Handler(set, WithQueryFilters(), WithMetrics(metrics))
+
+		serve(req, handler, "/")
+		req.Equal(3, metrics.ServedKeys())
+
+		serve(req, handler, "/?use=sig")
+		req.Equal(2, metrics.ServedKeys())
+
+		serve(req, handler, "/?kid=signing")
+		req.Equal(1, metrics.ServedKeys())
+	})
+
+	t.Run("ignores query parameters unless enabled", func(t *testing.T) {
+		req := require.New(t)
+
+		req.Len(serve(req, Handler(set), "/?kid=signing"), 3)
+	})
+}
@@ -0,0 +1,259 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+)
+
+const (
+	ErrorJwsMalformedMsg   = "malformed JWS, expected compact serialization"
+	ErrorJwsSignatureMsg   = "signature of JWS is invalid"
+	ErrorJwsCriticalMsg    = "JWS has critical header parameters, which are not supported"
+	ErrorJwsNoKeyMsg       = "no key in the set can verify the JWS"
+	ErrorJwsKeyNotFoundMsg = "no key in the set has the JWS kid"
+)
+
+// JwsHeader holds the registered parameters of a JWS protected header used to select and check the verifying key
+type JwsHeader struct {
+	Algorithm   string `json:"alg"`
+	KeyId       string `json:"kid,omitempty"`
+	Type        string `json:"typ,omitempty"`
+	ContentType string `json:"cty,omitempty"`
+}
+
+// VerifiedJws is a compact JWS whose signature has been verified
+type VerifiedJws struct {
+	Header JwsHeader
+
+	// RawHeader is the decoded JSON protected header, including parameters not in Header
+	RawHeader json.RawMessage
+
+	Payload []byte
+
+	// Key is the key the signature was verified with
+	Key Key
+}
+
+// jwsMessages are the error messages of verifyCompactJws, so callers verifying specific kinds of JWS can name them
+type jwsMessages struct {
+	subject   string
+	malformed string
+	signature string
+}
+
+var compactJwsMessages = jwsMessages{
+	subject:   "JWS",
+	malformed: ErrorJwsMalformedMsg,
+	signature: ErrorJwsSignatureMsg,
+}
+
+// compactJws is a compact JWS split into its decoded parts
+type compactJws struct {
+	header       JwsHeader
+	rawHeader    []byte
+	payload      []byte
+	signature    []byte
+	signingInput []byte
+}
+
+// SignCompactJws signs payload with the private key and returns it as a compact JWS with the given typ, which may be
+// empty. The JWS alg is the key's alg or the inferred one and the kid is the key's, see KeyToSigner.
+func SignCompactJws(payload []byte, key Key, typ string) ([]byte, error) {
+	signer, err := KeyToSigner(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	keySigner := signer.(*KeySigner)
+
+	header, err := json.Marshal(&JwsHeader{
+		Algorithm: keySigner.Algorithm(),
+		KeyId:     key.KeyId,
+		Type:      typ,
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	signature, err := keySigner.Sign(rand.Reader, jwsDigest(keySigner.HashFunc(), []byte(signingInput)), nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if ecPublicKey, ok := keySigner.Public().(*ecdsa.PublicKey); ok {
+		if signature, err = ecdsaSignatureToJws(signature, ecPublicKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return []byte(signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)), nil
+}
+
+// VerifyCompactJws verifies the signature of a compact JWS, such as a JWT, with the public key and returns its
+// header and payload. The JWS alg must be usable with the key and, if the key has an alg, equal to it. If both the JWS
+// and the key have a kid they must match. Claims of JWTs, such as exp, are not checked.
+func VerifyCompactJws(data []byte, key Key) (*VerifiedJws, error) {
+	jws, err := parseCompactJws(data, compactJwsMessages)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return jws.verify(key, compactJwsMessages)
+}
+
+// VerifyCompactJws verifies a compact JWS with the key of the set identified by its kid. Without a kid every key for
+// signing whose kty suits the JWS alg is tried. See VerifyCompactJws for the checks applied.
+func (r *Response) VerifyCompactJws(data []byte) (*VerifiedJws, error) {
+	jws, err := parseCompactJws(data, compactJwsMessages)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, keyType, err := algorithmSignerOpts(jws.header.Algorithm)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+
+	for i := range r.Keys {
+		key := &r.Keys[i]
+
+		if jws.header.KeyId != "" && key.KeyId != jws.header.KeyId {
+			continue
+		}
+
+		if key.KeyType != keyType || !key.IsForSigning() {
+			continue
+		}
+
+		verified, err := jws.verify(*key, compactJwsMessages)
+
+		if err == nil {
+			return verified, nil
+		}
+
+		lastErr = err
+	}
+
+	if lastErr != nil {
+		return nil, lastErr
+	}
+
+	if jws.header.KeyId != "" {
+		return nil, errors.New(ErrorJwsKeyNotFoundMsg)
+	}
+
+	return nil, errors.New(ErrorJwsNoKeyMsg)
+}
+
+// parseCompactJws splits and decodes a compact JWS, rejecting critical header parameters
+func parseCompactJws(data []byte, messages jwsMessages) (*compactJws, error) {
+	data = bytes.TrimSpace(data)
+	parts := bytes.Split(data, []byte("."))
+
+	if len(parts) != 3 {
+		return nil, errors.New(messages.malformed)
+	}
+
+	jws := &compactJws{
+		signingInput: data[:len(parts[0])+1+len(parts[1])],
+	}
+
+	var err error
+
+	if jws.rawHeader, err = base64.RawURLEncoding.DecodeString(string(parts[0])); err != nil {
+		return nil, errors.New(messages.malformed)
+	}
+
+	if jws.payload, err = base64.RawURLEncoding.DecodeString(string(parts[1])); err != nil {
+		return nil, errors.New(messages.malformed)
+	}
+
+	if jws.signature, err = base64.RawURLEncoding.DecodeString(string(parts[2])); err != nil {
+		return nil, errors.New(messages.malformed)
+	}
+
+	members := map[string]json.RawMessage{}
+
+	if err = json.Unmarshal(jws.rawHeader, &members); err != nil {
+		return nil, errors.New(messages.malformed)
+	}
+
+	if err = json.Unmarshal(jws.rawHeader, &jws.header); err != nil {
+		return nil, errors.New(messages.malformed)
+	}
+
+	if _, ok := members["crit"]; ok {
+		return nil, errors.New(ErrorJwsCriticalMsg)
+	}
+
+	return jws, nil
+}
+
+// verify checks the signature of the JWS with the public key
+func (jws *compactJws) verify(key Key, messages jwsMessages) (*VerifiedJws, error) {
+	if key.Algorithm != "" && key.Algorithm != jws.header.Algorithm {
+		return nil, fmt.Errorf("%s alg %s does not match key alg %s", messages.subject, jws.header.Algorithm, key.Algorithm)
+	}
+
+	if key.KeyId != "" && jws.header.KeyId != "" && key.KeyId != jws.header.KeyId {
+		return nil, fmt.Errorf("%s kid %s does not match key kid %s", messages.subject, jws.header.KeyId, key.KeyId)
+	}
+
+	opts, keyType, err := algorithmSignerOpts(jws.header.Algorithm)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if keyType != key.KeyType {
+		return nil, fmt.Errorf("alg %s can not be used with key type %s", jws.header.Algorithm, key.KeyType)
+	}
+
+	publicKey, err := KeyToPublicKey(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !verifyJwsSignature(publicKey, opts, jwsDigest(opts.HashFunc(), jws.signingInput), jws.signature) {
+		return nil, errors.New(messages.signature)
+	}
+
+	return &VerifiedJws{
+		Header:    jws.header,
+		RawHeader: jws.rawHeader,
+		Payload:   jws.payload,
+		Key:       key.clone(),
+	}, nil
+}
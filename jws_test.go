@@ -0,0 +1,136 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"encoding/base64"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func Test_CompactJws(t *testing.T) {
+	ecPrivate, ecPublic, err := GenerateECKey("P-256", "ec")
+	require.NoError(t, err)
+
+	rsaPrivate, rsaPublic, err := GenerateRSAKey(2048, "rsa", WithAlgorithm(AlgorithmPs256))
+	require.NoError(t, err)
+
+	edPrivate, edPublic, err := GenerateOKPKey(CurveEd25519, "ed")
+	require.NoError(t, err)
+
+	payload := []byte(`{"sub":"someone"}`)
+
+	t.Run("can sign and verify with every key type", func(t *testing.T) {
+		req := require.New(t)
+
+		for _, pair := range [][2]*Key{{ecPrivate, ecPublic}, {rsaPrivate, rsaPublic}, {edPrivate, edPublic}} {
+			signed, err := SignCompactJws(payload, *pair[0], "JWT")
+			req.NoError(err)
+
+			verified, err := VerifyCompactJws(signed, *pair[1])
+			req.NoError(err, pair[0].KeyId)
+			req.Equal(payload, verified.Payload)
+			req.Equal(pair[0].KeyId, verified.Header.KeyId)
+			req.Equal(pair[0].Algorithm, verified.Header.Algorithm)
+			req.Equal("JWT", verified.Header.Type)
+			req.JSONEq(`{"alg":"`+pair[0].Algorithm+`","kid":"`+pair[0].KeyId+`","typ":"JWT"}`, string(verified.RawHeader))
+			req.Equal(pair[1].KeyId, verified.Key.KeyId)
+		}
+	})
+
+	t.Run("rejects tampered payloads and other keys", func(t *testing.T) {
+		req := require.New(t)
+
+		signed, err := SignCompactJws(payload, *ecPrivate, "")
+		req.NoError(err)
+
+		parts := strings.Split(string(signed), ".")
+		tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"admin"}`)) + "." + parts[2]
+
+		_, err = VerifyCompactJws([]byte(tampered), *ecPublic)
+		req.EqualError(err, ErrorJwsSignatureMsg)
+
+		_, err = VerifyCompactJws(signed, *edPublic)
+		req.Error(err)
+
+		_, err = VerifyCompactJws([]byte("a.b"), *ecPublic)
+		req.EqualError(err, ErrorJwsMalformedMsg)
+	})
+
+	t.Run("rejects critical header parameters", func(t *testing.T) {
+		req := require.New(t)
+
+		header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256","crit":["exp"],"exp":1}`))
+
+		_, err := VerifyCompactJws([]byte(header+".e30.AA"), *ecPublic)
+		req.EqualError(err, ErrorJwsCriticalMsg)
+	})
+
+	t.Run("can verify with the key of a set", func(t *testing.T) {
+		req := require.New(t)
+
+		set := &Response{Keys: []Key{*rsaPublic, *ecPublic, *edPublic}}
+
+		signed, err := SignCompactJws(payload, *edPrivate, "")
+		req.NoError(err)
+
+		verified, err := set.VerifyCompactJws(signed)
+		req.NoError(err)
+		req.Equal("ed", verified.Key.KeyId)
+
+		anonymous := *ecPrivate
+		anonymous.KeyId = ""
+
+		signed, err = SignCompactJws(payload, anonymous, "")
+		req.NoError(err)
+
+		verified, err = set.VerifyCompactJws(signed)
+		req.NoError(err)
+		req.Equal("ec", verified.Key.KeyId)
+	})
+
+	t.Run("reports sets without a suitable key", func(t *testing.T) {
+		req := require.New(t)
+
+		set := &Response{Keys: []Key{*rsaPublic}}
+
+		signed, err := SignCompactJws(payload, *ecPrivate, "")
+		req.NoError(err)
+
+		_, err = set.VerifyCompactJws(signed)
+		req.EqualError(err, ErrorJwsKeyNotFoundMsg)
+
+		anonymous := *ecPrivate
+		anonymous.KeyId = ""
+
+		signed, err = SignCompactJws(payload, anonymous, "")
+		req.NoError(err)
+
+		_, err = set.VerifyCompactJws(signed)
+		req.EqualError(err, ErrorJwsNoKeyMsg)
+
+		_, otherPublic, err := GenerateECKey("P-256", "ec")
+		req.NoError(err)
+
+		signed, err = SignCompactJws(payload, *ecPrivate, "")
+		req.NoError(err)
+
+		_, err = (&Response{Keys: []Key{*otherPublic}}).VerifyCompactJws(signed)
+		req.EqualError(err, ErrorJwsSignatureMsg)
+	})
+}
@@ -0,0 +1,131 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package jwks
+
+import "github.com/pkg/errors"
+
+const ErrorKeyNotFoundMsg = "key not found"
+
+// KeyNotFoundError is returned by KeyStore implementations when no key has the requested kid
+type KeyNotFoundError struct {
+	error
+	KeyId string
+}
+
+func newKeyNotFoundError(kid string) *KeyNotFoundError {
+	return &KeyNotFoundError{
+		error: errors.New(ErrorKeyNotFoundMsg),
+		KeyId: kid,
+	}
+}
+
+// KeyStore persists private and public keys by kid so that storage backends can be swapped. Implementations must be
+// safe for concurrent use and must return copies, so callers can not modify stored keys. Set and MemoryKeyStore are
+// in-memory implementations, FileKeyStore persists keys to disk.
+type KeyStore interface {
+	// Load returns the key with the given kid or a *KeyNotFoundError
+	Load(kid string) (Key, error)
+
+	// Save stores key, replacing any key with the same kid
+	Save(key Key) error
+
+	// List returns all stored keys
+	List() ([]Key, error)
+
+	// Delete removes the key with the given kid or returns a *KeyNotFoundError
+	Delete(kid string) error
+}
+
+// KeyStoreSource returns a ResponseSource providing the keys listed by store, so a Handler can serve it. If the store
+// can not be listed the source provides nil.
+func KeyStoreSource(store KeyStore) ResponseSource {
+	return ResponseSourceFunc(func() *Response {
+		keys, err := store.List()
+
+		if err != nil {
+			return nil
+		}
+
+		return &Response{Keys: keys}
+	})
+}
+
+// Load returns a copy of the first key with the given kid, including revoked keys, or a *KeyNotFoundError
+func (s *Set) Load(kid string) (Key, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	for i := range s.keys {
+		if s.keys[i].KeyId == kid {
+			return s.keys[i].clone(), nil
+		}
+	}
+
+	return Key{}, newKeyNotFoundError(kid)
+}
+
+// Save replaces the keys with the same kid as key with a copy of key, or appends it if there are none
+func (s *Set) Save(key Key) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	keys := s.keys[:0]
+	saved := false
+
+	for _, existing := range s.keys {
+		if existing.KeyId != key.KeyId {
+			keys = append(keys, existing)
+		} else if !saved {
+			keys = append(keys, key.clone())
+			saved = true
+		}
+	}
+
+	for i := len(keys); i < len(s.keys); i++ {
+		s.keys[i] = Key{}
+	}
+
+	if !saved {
+		keys = append(keys, key.clone())
+	}
+
+	s.keys = keys
+
+	return nil
+}
+
+// List returns copies of all keys in the set, including revoked keys
+func (s *Set) List() ([]Key, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	keys := make([]Key, 0, len(s.keys))
+
+	for i := range s.keys {
+		keys = append(keys, s.keys[i].clone())
+	}
+
+	return keys, nil
+}
+
+// Delete removes every key with the given kid or returns a *KeyNotFoundError if there are none
+func (s *Set) Delete(kid string) error {
+	if !s.Remove(kid) {
+		return newKeyNotFoundError(kid)
+	}
+
+	return nil
+}
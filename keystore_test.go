@@ -0,0 +1,96 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_SetKeyStore(t *testing.T) {
+	t.Run("implements KeyStore", func(t *testing.T) {
+		req := require.New(t)
+
+		var store KeyStore = NewSet()
+
+		req.NoError(store.Save(Key{KeyType: KeyTypeOct, KeyId: "a", K: "AAAA"}))
+		req.NoError(store.Save(Key{KeyType: KeyTypeOct, KeyId: "b", K: "BBBB"}))
+		req.NoError(store.Save(Key{KeyType: KeyTypeOct, KeyId: "a", K: "CCCC"}))
+
+		key, err := store.Load("a")
+		req.NoError(err)
+		req.Equal("CCCC", key.K)
+
+		keys, err := store.List()
+		req.NoError(err)
+		req.Len(keys, 2)
+		req.Equal("a", keys[0].KeyId)
+		req.Equal("b", keys[1].KeyId)
+
+		req.NoError(store.Delete("a"))
+
+		_, err = store.Load("a")
+		req.Error(err)
+		req.IsType(&KeyNotFoundError{}, err)
+		req.Equal("a", err.(*KeyNotFoundError).KeyId)
+
+		err = store.Delete("a")
+		req.EqualError(err, ErrorKeyNotFoundMsg)
+	})
+
+	t.Run("returns copies", func(t *testing.T) {
+		req := require.New(t)
+
+		set := NewSet()
+		key := Key{KeyType: KeyTypeOct, KeyId: "a", K: "AAAA", KeyOperations: []string{KeyOperationSign}}
+		req.NoError(set.Save(key))
+
+		key.KeyOperations[0] = KeyOperationVerify
+
+		loaded, err := set.Load("a")
+		req.NoError(err)
+		req.Equal([]string{KeyOperationSign}, loaded.KeyOperations)
+
+		loaded.KeyOperations[0] = KeyOperationVerify
+
+		keys, err := set.List()
+		req.NoError(err)
+		req.Equal([]string{KeyOperationSign}, keys[0].KeyOperations)
+	})
+}
+
+func Test_KeyStoreSource(t *testing.T) {
+	t.Run("can serve a key store", func(t *testing.T) {
+		req := require.New(t)
+
+		private, _, err := GenerateECKey("P-256", "ec")
+		req.NoError(err)
+
+		store := NewSet()
+		req.NoError(store.Save(*private))
+
+		recorder := httptest.NewRecorder()
+		Handler(KeyStoreSource(store)).ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		response, err := ParseResponse(recorder.Body.Bytes())
+		req.NoError(err)
+		req.Len(response.Keys, 1)
+		req.True(response.Keys[0].IsPublic())
+	})
+}
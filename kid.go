@@ -0,0 +1,103 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"github.com/pkg/errors"
+	"strconv"
+	"sync/atomic"
+)
+
+const (
+	ErrorKidStrategyNoCertMsg = "kid strategy requires a certificate"
+)
+
+// KidStrategy generates the kid of a Key whose key material has been populated. cert is the certificate the key was
+// constructed from and is nil for keys without one.
+type KidStrategy interface {
+	Kid(key *Key, cert *x509.Certificate) (string, error)
+}
+
+// KidStrategyFunc adapts a function to a KidStrategy
+type KidStrategyFunc func(key *Key, cert *x509.Certificate) (string, error)
+
+func (f KidStrategyFunc) Kid(key *Key, cert *x509.Certificate) (string, error) {
+	return f(key, cert)
+}
+
+// CertSha1KidStrategy uses the hex SHA-1 fingerprint of the certificate, the default of NewKey
+var CertSha1KidStrategy KidStrategy = KidStrategyFunc(func(_ *Key, cert *x509.Certificate) (string, error) {
+	if cert == nil {
+		return "", errors.New(ErrorKidStrategyNoCertMsg)
+	}
+
+	sum := sha1.Sum(cert.Raw)
+
+	return fmt.Sprintf("%x", sum), nil
+})
+
+// CertSha256KidStrategy uses the hex SHA-256 fingerprint of the certificate
+var CertSha256KidStrategy KidStrategy = KidStrategyFunc(func(_ *Key, cert *x509.Certificate) (string, error) {
+	if cert == nil {
+		return "", errors.New(ErrorKidStrategyNoCertMsg)
+	}
+
+	sum := sha256.Sum256(cert.Raw)
+
+	return fmt.Sprintf("%x", sum), nil
+})
+
+// ThumbprintKidStrategy uses the RFC 7638 SHA-256 thumbprint of the key, the default for keys without a certificate
+var ThumbprintKidStrategy KidStrategy = KidStrategyFunc(func(key *Key, _ *x509.Certificate) (string, error) {
+	return key.Thumbprint(crypto.SHA256)
+})
+
+// UuidKidStrategy uses a random RFC 4122 version 4 UUID
+var UuidKidStrategy KidStrategy = KidStrategyFunc(func(_ *Key, _ *x509.Certificate) (string, error) {
+	uuid := make([]byte, 16)
+
+	if _, err := rand.Read(uuid); err != nil {
+		return "", err
+	}
+
+	uuid[6] = (uuid[6] & 0x0f) | 0x40
+	uuid[8] = (uuid[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:]), nil
+})
+
+// SequentialKidStrategy generates kids of the form Prefix followed by a counter starting at 1. It is safe for
+// concurrent use and must not be copied after first use.
+type SequentialKidStrategy struct {
+	Prefix  string
+	counter uint64
+}
+
+// NewSequentialKidStrategy returns a SequentialKidStrategy with the given prefix
+func NewSequentialKidStrategy(prefix string) *SequentialKidStrategy {
+	return &SequentialKidStrategy{Prefix: prefix}
+}
+
+func (s *SequentialKidStrategy) Kid(_ *Key, _ *x509.Certificate) (string, error) {
+	return s.Prefix + strconv.FormatUint(atomic.AddUint64(&s.counter, 1), 10), nil
+}
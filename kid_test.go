@@ -0,0 +1,140 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"regexp"
+	"sync"
+	"testing"
+)
+
+func Test_KidStrategy(t *testing.T) {
+	cert, privateKey, err := newEcCert()
+	require.NoError(t, err)
+
+	t.Run("defaults to the certificate SHA-1 fingerprint", func(t *testing.T) {
+		req := require.New(t)
+
+		key, err := NewKey("", cert, nil)
+		req.NoError(err)
+
+		expected, err := CertSha1KidStrategy.Kid(key, cert)
+		req.NoError(err)
+		req.Equal(expected, key.KeyId)
+		req.Len(key.KeyId, 40)
+	})
+
+	t.Run("can use the certificate SHA-256 fingerprint", func(t *testing.T) {
+		req := require.New(t)
+
+		key, err := NewKey("", cert, nil, WithKidStrategy(CertSha256KidStrategy))
+		req.NoError(err)
+		req.Equal(fmt.Sprintf("%x", sha256.Sum256(cert.Raw)), key.KeyId)
+	})
+
+	t.Run("can not use a certificate strategy without a certificate", func(t *testing.T) {
+		req := require.New(t)
+
+		_, err := NewKeyFromPublicKey("", &privateKey.PublicKey, WithKidStrategy(CertSha256KidStrategy))
+		req.EqualError(err, ErrorKidStrategyNoCertMsg)
+	})
+
+	t.Run("can use the key thumbprint", func(t *testing.T) {
+		req := require.New(t)
+
+		key, err := NewKey("", cert, nil, WithKidStrategy(ThumbprintKidStrategy))
+		req.NoError(err)
+
+		thumbprint, err := key.Thumbprint(crypto.SHA256)
+		req.NoError(err)
+		req.Equal(thumbprint, key.KeyId)
+	})
+
+	t.Run("can use random UUIDs", func(t *testing.T) {
+		req := require.New(t)
+
+		first, err := NewKeyFromPublicKey("", &privateKey.PublicKey, WithKidStrategy(UuidKidStrategy))
+		req.NoError(err)
+		second, err := NewKeyFromPublicKey("", &privateKey.PublicKey, WithKidStrategy(UuidKidStrategy))
+		req.NoError(err)
+
+		uuidPattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+		req.Regexp(uuidPattern, first.KeyId)
+		req.Regexp(uuidPattern, second.KeyId)
+		req.NotEqual(first.KeyId, second.KeyId)
+	})
+
+	t.Run("can use sequential kids", func(t *testing.T) {
+		req := require.New(t)
+
+		strategy := NewSequentialKidStrategy("key-")
+
+		for i := 1; i <= 3; i++ {
+			key, err := NewKeyFromPublicKey("", &privateKey.PublicKey, WithKidStrategy(strategy))
+			req.NoError(err)
+			req.Equal(fmt.Sprintf("key-%d", i), key.KeyId)
+		}
+	})
+
+	t.Run("sequential kids are unique across goroutines", func(t *testing.T) {
+		req := require.New(t)
+
+		strategy := NewSequentialKidStrategy("")
+		kids := sync.Map{}
+		wg := sync.WaitGroup{}
+
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				for j := 0; j < 100; j++ {
+					kid, _ := strategy.Kid(nil, nil)
+					_, loaded := kids.LoadOrStore(kid, true)
+					req.False(loaded)
+				}
+			}()
+		}
+
+		wg.Wait()
+	})
+
+	t.Run("does not replace an explicit kid", func(t *testing.T) {
+		req := require.New(t)
+
+		key, err := NewKey("explicit", cert, nil, WithKidStrategy(UuidKidStrategy))
+		req.NoError(err)
+		req.Equal("explicit", key.KeyId)
+	})
+
+	t.Run("can use a custom strategy", func(t *testing.T) {
+		req := require.New(t)
+
+		strategy := KidStrategyFunc(func(key *Key, cert *x509.Certificate) (string, error) {
+			return key.KeyType + "-" + cert.Subject.CommonName, nil
+		})
+
+		key, err := NewKey("", cert, nil, WithKidStrategy(strategy))
+		req.NoError(err)
+		req.Equal(KeyTypeEc+"-"+cert.Subject.CommonName, key.KeyId)
+	})
+}
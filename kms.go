@@ -0,0 +1,332 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+)
+
+// KmsKeyIdMember is the additional member KmsKeyPairGenerator records the KMS key id of a key in. It is removed by
+// Key.Public, so it is not published.
+const KmsKeyIdMember = "x-kms-key-id"
+
+const (
+	ErrorKmsKeyNotPublicMsg      = "KMS key store only stores asymmetric keys without private key material"
+	ErrorKmsKeyIdMissingMsg      = "key has no " + KmsKeyIdMember + " member"
+	ErrorKmsPublicKeyMismatchMsg = "public key does not match the KMS key"
+)
+
+// KmsClient is the subset of the AWS KMS API used for signing keys, so this package does not depend on the AWS SDK. An
+// implementation wrapping the SDK's client maps the calls to CreateKey (KeyUsage SIGN_VERIFY), GetPublicKey, and Sign
+// (MessageType DIGEST) in a few lines. Key specs and signing algorithms use the KMS names, such as ECC_NIST_P256 and
+// ECDSA_SHA_256.
+type KmsClient interface {
+	// CreateKey creates an asymmetric signing key with the given key spec and returns its id
+	CreateKey(ctx context.Context, keySpec string) (string, error)
+
+	// GetPublicKey returns the DER encoded SubjectPublicKeyInfo of the key
+	GetPublicKey(ctx context.Context, keyId string) ([]byte, error)
+
+	// Sign signs digest with the key using the signing algorithm and returns the signature, ASN.1 DER encoded for
+	// ECDSA
+	Sign(ctx context.Context, keyId string, digest []byte, signingAlgorithm string) ([]byte, error)
+}
+
+// KmsKeyDeleter is implemented by KmsClients that can delete keys, mapping to ScheduleKeyDeletion in AWS KMS. It is
+// used by KmsKeyStore.Delete.
+type KmsKeyDeleter interface {
+	// ScheduleKeyDeletion schedules the deletion of the key, after which it can no longer sign
+	ScheduleKeyDeletion(ctx context.Context, keyId string) error
+}
+
+// SignerFactory returns a crypto.Signer for a key, for example one whose private key material is held externally
+type SignerFactory func(key Key) (crypto.Signer, error)
+
+// KmsSigner is a crypto.Signer for a key held in KMS. The private key never leaves KMS.
+type KmsSigner struct {
+	client    KmsClient
+	keyId     string
+	publicKey crypto.PublicKey
+}
+
+// NewKmsSigner returns a KmsSigner for the KMS key keyId, fetching its public key
+func NewKmsSigner(ctx context.Context, client KmsClient, keyId string) (*KmsSigner, error) {
+	der, err := client.GetPublicKey(ctx, keyId)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get public key of KMS key %s: %s", keyId, err)
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(der)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not parse public key of KMS key %s: %s", keyId, err)
+	}
+
+	return &KmsSigner{
+		client:    client,
+		keyId:     keyId,
+		publicKey: publicKey,
+	}, nil
+}
+
+// KeyId returns the KMS key id
+func (s *KmsSigner) KeyId() string {
+	return s.keyId
+}
+
+// Public returns the public key of the KMS key
+func (s *KmsSigner) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+// Sign signs digest in KMS. rand is ignored. RSA keys use PSS if opts is *rsa.PSSOptions, PKCS #1 v1.5 otherwise.
+func (s *KmsSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	algorithm, err := kmsSigningAlgorithm(s.publicKey, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return s.client.Sign(context.Background(), s.keyId, digest, algorithm)
+}
+
+// kmsSigningAlgorithm returns the KMS signing algorithm for a public key and signer options
+func kmsSigningAlgorithm(publicKey crypto.PublicKey, opts crypto.SignerOpts) (string, error) {
+	var size string
+
+	switch opts.HashFunc() {
+	case crypto.SHA256:
+		size = "256"
+	case crypto.SHA384:
+		size = "384"
+	case crypto.SHA512:
+		size = "512"
+	default:
+		return "", fmt.Errorf("unsupported hash for KMS signing: %s", opts.HashFunc())
+	}
+
+	switch publicKey.(type) {
+	case *rsa.PublicKey:
+		if _, ok := opts.(*rsa.PSSOptions); ok {
+			return "RSASSA_PSS_SHA_" + size, nil
+		}
+
+		return "RSASSA_PKCS1_V1_5_SHA_" + size, nil
+	case *ecdsa.PublicKey:
+		return "ECDSA_SHA_" + size, nil
+	}
+
+	return "", fmt.Errorf("unsupported key type for KMS signing: %T", publicKey)
+}
+
+// NewKeyFromSigner converts the public key of signer to a Key, for signers whose private key material is not
+// accessible such as KmsSigner. If keyId is empty string, the keyId will be populated with the RFC 7638 SHA-256
+// thumbprint of the key.
+func NewKeyFromSigner(keyId string, signer crypto.Signer, opts ...KeyOption) (*Key, error) {
+	return NewKeyFromPublicKey(keyId, signer.Public(), opts...)
+}
+
+// KmsKeyPairGenerator returns a KeyPairGenerator creating KMS keys with the given key spec, such as ECC_NIST_P256 or
+// RSA_2048. The first Key returned holds no private key material, it is the public key restricted to signing with
+// the KMS key id recorded in KmsKeyIdMember. Use it with a RotationManager configured with
+// WithSignerFactory(KmsSignerFactory(client)).
+func KmsKeyPairGenerator(client KmsClient, keySpec string) KeyPairGenerator {
+	return func(kid string, opts ...KeyOption) (*Key, *Key, error) {
+		ctx := context.Background()
+
+		keyId, err := client.CreateKey(ctx, keySpec)
+
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not create KMS key: %s", err)
+		}
+
+		signer, err := NewKmsSigner(ctx, client, keyId)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		key, err := NewKeyFromSigner(kid, signer, opts...)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		key.KeyOperations = []string{KeyOperationSign}
+
+		if err := key.SetExtra(KmsKeyIdMember, keyId); err != nil {
+			return nil, nil, err
+		}
+
+		public := key.Public()
+		public.KeyOperations = []string{KeyOperationVerify}
+
+		return key, &public, nil
+	}
+}
+
+// KmsSignerFactory returns a SignerFactory creating a KmsSigner for keys with a KmsKeyIdMember and falling back to
+// KeyToSigner for other keys. The signer signs with the hash of the key's alg when called with nil opts.
+func KmsSignerFactory(client KmsClient) SignerFactory {
+	return func(key Key) (crypto.Signer, error) {
+		var keyId string
+
+		found, err := key.GetExtra(KmsKeyIdMember, &keyId)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !found {
+			return KeyToSigner(key)
+		}
+
+		signer, err := NewKmsSigner(context.Background(), client, keyId)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return newKeySigner(key, signer)
+	}
+}
+
+// KmsKeyStore is a KeyStore for keys held in KMS, such as those created by KmsKeyPairGenerator. KMS only holds the
+// private key material, so the JWKs, carrying the KMS key id in KmsKeyIdMember, are kept in a metadata KeyStore such
+// as a FileKeyStore. Keys with private key material are never stored and every saved key is checked against the public
+// key KMS returns for its key id, so a published key always verifies signatures of the KMS key it names. Use it with a
+// RotationManager to keep the whole lifecycle of KMS keys, including deletion, in one place.
+type KmsKeyStore struct {
+	client   KmsClient
+	metadata KeyStore
+}
+
+// NewKmsKeyStore returns a KmsKeyStore for keys in client, keeping their JWKs in metadata
+func NewKmsKeyStore(client KmsClient, metadata KeyStore) *KmsKeyStore {
+	return &KmsKeyStore{
+		client:   client,
+		metadata: metadata,
+	}
+}
+
+func (s *KmsKeyStore) Load(kid string) (Key, error) {
+	return s.metadata.Load(kid)
+}
+
+// Save stores key after checking that it has no private key material and that its public key matches the KMS key
+// named by its KmsKeyIdMember
+func (s *KmsKeyStore) Save(key Key) error {
+	if !key.IsPublic() {
+		return errors.New(ErrorKmsKeyNotPublicMsg)
+	}
+
+	keyId, err := kmsKeyId(key)
+
+	if err != nil {
+		return err
+	}
+
+	signer, err := NewKmsSigner(context.Background(), s.client, keyId)
+
+	if err != nil {
+		return err
+	}
+
+	publicKey, err := KeyToPublicKey(key)
+
+	if err != nil {
+		return err
+	}
+
+	if comparable, ok := publicKey.(interface{ Equal(crypto.PublicKey) bool }); !ok || !comparable.Equal(signer.Public()) {
+		return errors.New(ErrorKmsPublicKeyMismatchMsg)
+	}
+
+	return s.metadata.Save(key)
+}
+
+func (s *KmsKeyStore) List() ([]Key, error) {
+	return s.metadata.List()
+}
+
+// Delete removes the key with the given kid. If the client implements KmsKeyDeleter the deletion of the KMS key is
+// scheduled after the key was removed from the metadata store, so a failed metadata deletion can be retried without
+// scheduling the deletion of the KMS key twice. If scheduling fails the returned error names the KMS key id.
+func (s *KmsKeyStore) Delete(kid string) error {
+	key, err := s.metadata.Load(kid)
+
+	if err != nil {
+		return err
+	}
+
+	if err = s.metadata.Delete(kid); err != nil {
+		return err
+	}
+
+	if deleter, ok := s.client.(KmsKeyDeleter); ok {
+		keyId, err := kmsKeyId(key)
+
+		if err != nil {
+			return err
+		}
+
+		if err = deleter.ScheduleKeyDeletion(context.Background(), keyId); err != nil {
+			return fmt.Errorf("could not schedule deletion of KMS key %s: %s", keyId, err)
+		}
+	}
+
+	return nil
+}
+
+// Signer returns a *KeySigner signing with the KMS key of the stored key with the given kid, see KmsSignerFactory
+func (s *KmsKeyStore) Signer(kid string) (crypto.Signer, error) {
+	key, err := s.metadata.Load(kid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = kmsKeyId(key); err != nil {
+		return nil, err
+	}
+
+	return KmsSignerFactory(s.client)(key)
+}
+
+// kmsKeyId returns the KMS key id recorded in the KmsKeyIdMember of key
+func kmsKeyId(key Key) (string, error) {
+	var keyId string
+
+	found, err := key.GetExtra(KmsKeyIdMember, &keyId)
+
+	if err != nil {
+		return "", err
+	}
+
+	if !found || keyId == "" {
+		return "", errors.New(ErrorKmsKeyIdMissingMsg)
+	}
+
+	return keyId, nil
+}
@@ -0,0 +1,332 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// testKmsClient is an in-memory KmsClient
+type testKmsClient struct {
+	keys       map[string]crypto.Signer
+	algorithms []string
+	created    int
+}
+
+func newTestKmsClient() *testKmsClient {
+	return &testKmsClient{keys: map[string]crypto.Signer{}}
+}
+
+func (c *testKmsClient) CreateKey(_ context.Context, keySpec string) (string, error) {
+	var signer crypto.Signer
+	var err error
+
+	switch keySpec {
+	case "ECC_NIST_P256":
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "RSA_2048":
+		signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	default:
+		return "", fmt.Errorf("unsupported key spec %s", keySpec)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	c.created++
+	keyId := fmt.Sprintf("key-%d", c.created)
+	c.keys[keyId] = signer
+
+	return keyId, nil
+}
+
+func (c *testKmsClient) GetPublicKey(_ context.Context, keyId string) ([]byte, error) {
+	signer, ok := c.keys[keyId]
+
+	if !ok {
+		return nil, fmt.Errorf("key %s not found", keyId)
+	}
+
+	return x509.MarshalPKIXPublicKey(signer.Public())
+}
+
+func (c *testKmsClient) Sign(_ context.Context, keyId string, digest []byte, signingAlgorithm string) ([]byte, error) {
+	c.algorithms = append(c.algorithms, signingAlgorithm)
+
+	signer, ok := c.keys[keyId]
+
+	if !ok {
+		return nil, fmt.Errorf("key %s not found", keyId)
+	}
+
+	var opts crypto.SignerOpts = crypto.SHA256
+
+	if signingAlgorithm == "RSASSA_PSS_SHA_256" {
+		opts = &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+	}
+
+	return signer.Sign(rand.Reader, digest, opts)
+}
+
+func Test_KmsSigner(t *testing.T) {
+	t.Run("can sign with a KMS key", func(t *testing.T) {
+		req := require.New(t)
+
+		client := newTestKmsClient()
+		keyId, err := client.CreateKey(context.Background(), "ECC_NIST_P256")
+		req.NoError(err)
+
+		signer, err := NewKmsSigner(context.Background(), client, keyId)
+		req.NoError(err)
+		req.Equal(keyId, signer.KeyId())
+
+		digest := sha256.Sum256([]byte("payload"))
+		signature, err := signer.Sign(nil, digest[:], crypto.SHA256)
+		req.NoError(err)
+		req.True(ecdsa.VerifyASN1(signer.Public().(*ecdsa.PublicKey), digest[:], signature))
+		req.Equal([]string{"ECDSA_SHA_256"}, client.algorithms)
+
+		_, err = signer.Sign(nil, digest[:], crypto.SHA1)
+		req.Error(err)
+	})
+
+	t.Run("selects the RSA padding from the options", func(t *testing.T) {
+		req := require.New(t)
+
+		client := newTestKmsClient()
+		keyId, err := client.CreateKey(context.Background(), "RSA_2048")
+		req.NoError(err)
+
+		signer, err := NewKmsSigner(context.Background(), client, keyId)
+		req.NoError(err)
+
+		digest := sha256.Sum256([]byte("payload"))
+		pss := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+
+		signature, err := signer.Sign(nil, digest[:], pss)
+		req.NoError(err)
+		req.NoError(rsa.VerifyPSS(signer.Public().(*rsa.PublicKey), crypto.SHA256, digest[:], signature, pss))
+
+		signature, err = signer.Sign(nil, digest[:], crypto.SHA256)
+		req.NoError(err)
+		req.NoError(rsa.VerifyPKCS1v15(signer.Public().(*rsa.PublicKey), crypto.SHA256, digest[:], signature))
+
+		req.Equal([]string{"RSASSA_PSS_SHA_256", "RSASSA_PKCS1_V1_5_SHA_256"}, client.algorithms)
+	})
+
+	t.Run("returns an error for unknown keys", func(t *testing.T) {
+		req := require.New(t)
+
+		_, err := NewKmsSigner(context.Background(), newTestKmsClient(), "missing")
+		req.Error(err)
+	})
+}
+
+func (c *testKmsClient) ScheduleKeyDeletion(_ context.Context, keyId string) error {
+	if _, ok := c.keys[keyId]; !ok {
+		return fmt.Errorf("key %s not found", keyId)
+	}
+
+	delete(c.keys, keyId)
+
+	return nil
+}
+
+// failingDeleteKeyStore fails Delete until it failed the given number of times
+type failingDeleteKeyStore struct {
+	KeyStore
+	fails int
+}
+
+func (s *failingDeleteKeyStore) Delete(kid string) error {
+	if s.fails > 0 {
+		s.fails--
+		return fmt.Errorf("could not delete %s", kid)
+	}
+
+	return s.KeyStore.Delete(kid)
+}
+
+func Test_KmsKeyStore(t *testing.T) {
+	t.Run("can store, sign with and delete KMS keys", func(t *testing.T) {
+		req := require.New(t)
+
+		client := newTestKmsClient()
+		store := NewKmsKeyStore(client, NewMemoryKeyStore())
+
+		key, _, err := KmsKeyPairGenerator(client, "ECC_NIST_P256")("kms")
+		req.NoError(err)
+		req.NoError(store.Save(*key))
+
+		loaded, err := store.Load("kms")
+		req.NoError(err)
+		req.Equal(*key, loaded)
+
+		signer, err := store.Signer("kms")
+		req.NoError(err)
+
+		digest := sha256.Sum256([]byte("payload"))
+		signature, err := signer.Sign(nil, digest[:], nil)
+		req.NoError(err)
+
+		publicKey, err := KeyToPublicKey(*key)
+		req.NoError(err)
+		req.True(ecdsa.VerifyASN1(publicKey.(*ecdsa.PublicKey), digest[:], signature))
+
+		req.NoError(store.Delete("kms"))
+		req.Empty(client.keys)
+
+		_, err = store.Load("kms")
+		req.IsType(&KeyNotFoundError{}, err)
+	})
+
+	t.Run("can retry a delete after the metadata store failed", func(t *testing.T) {
+		req := require.New(t)
+
+		client := newTestKmsClient()
+		metadata := &failingDeleteKeyStore{KeyStore: NewMemoryKeyStore(), fails: 1}
+		store := NewKmsKeyStore(client, metadata)
+
+		key, _, err := KmsKeyPairGenerator(client, "ECC_NIST_P256")("kms")
+		req.NoError(err)
+		req.NoError(store.Save(*key))
+
+		req.Error(store.Delete("kms"))
+		req.Len(client.keys, 1)
+
+		req.NoError(store.Delete("kms"))
+		req.Empty(client.keys)
+
+		_, err = store.Load("kms")
+		req.IsType(&KeyNotFoundError{}, err)
+	})
+
+	t.Run("rejects private keys, keys without a KMS key id and mismatched keys", func(t *testing.T) {
+		req := require.New(t)
+
+		client := newTestKmsClient()
+		store := NewKmsKeyStore(client, NewMemoryKeyStore())
+
+		private, public, err := GenerateECKey("P-256", "local")
+		req.NoError(err)
+
+		req.EqualError(store.Save(*private), ErrorKmsKeyNotPublicMsg)
+		req.EqualError(store.Save(*public), ErrorKmsKeyIdMissingMsg)
+
+		key, _, err := KmsKeyPairGenerator(client, "ECC_NIST_P256")("kms")
+		req.NoError(err)
+
+		mismatched := *public
+		req.NoError(mismatched.SetExtra(KmsKeyIdMember, key.AdditionalMembers[KmsKeyIdMember]))
+		req.EqualError(store.Save(mismatched), ErrorKmsPublicKeyMismatchMsg)
+
+		keys, err := store.List()
+		req.NoError(err)
+		req.Empty(keys)
+	})
+
+	t.Run("can back a rotation manager", func(t *testing.T) {
+		req := require.New(t)
+
+		client := newTestKmsClient()
+		store := NewKmsKeyStore(client, NewMemoryKeyStore())
+
+		manager, err := NewRotationManager(store, KmsKeyPairGenerator(client, "ECC_NIST_P256"), WithSignerFactory(KmsSignerFactory(client)), WithRetention(-time.Second))
+		req.NoError(err)
+
+		req.NoError(manager.Rotate())
+		req.NoError(manager.Rotate())
+
+		stored, err := store.List()
+		req.NoError(err)
+		req.Len(stored, 3)
+		req.Equal(4, client.created)
+		req.Len(client.keys, 3)
+	})
+}
+
+func Test_KmsRotationManager(t *testing.T) {
+	t.Run("can rotate KMS keys without private key material", func(t *testing.T) {
+		req := require.New(t)
+
+		client := newTestKmsClient()
+		store := NewMemoryKeyStore()
+
+		manager, err := NewRotationManager(store, KmsKeyPairGenerator(client, "ECC_NIST_P256"), WithSignerFactory(KmsSignerFactory(client)))
+		req.NoError(err)
+		req.NoError(manager.Rotate())
+
+		stored, err := store.List()
+		req.NoError(err)
+		req.Len(stored, 3)
+
+		for _, key := range stored {
+			req.False(key.IsPrivate())
+		}
+
+		signer, err := manager.CurrentSigner()
+		req.NoError(err)
+		req.Equal(AlgorithmEs256, signer.(*KeySigner).Algorithm())
+
+		public, err := manager.PublicSet()
+		req.NoError(err)
+
+		for _, key := range public.Keys {
+			req.Nil(key.AdditionalMembers)
+		}
+
+		active, err := manager.ActiveKey()
+		req.NoError(err)
+
+		digest := sha256.Sum256([]byte("payload"))
+		signature, err := signer.Sign(nil, digest[:], nil)
+		req.NoError(err)
+
+		publicKey, err := KeyToPublicKey(*public.ByKid(active.KeyId))
+		req.NoError(err)
+		req.True(ecdsa.VerifyASN1(publicKey.(*ecdsa.PublicKey), digest[:], signature))
+	})
+
+	t.Run("can convert a signer to a key", func(t *testing.T) {
+		req := require.New(t)
+
+		client := newTestKmsClient()
+		keyId, err := client.CreateKey(context.Background(), "ECC_NIST_P256")
+		req.NoError(err)
+
+		signer, err := NewKmsSigner(context.Background(), client, keyId)
+		req.NoError(err)
+
+		key, err := NewKeyFromSigner("", signer)
+		req.NoError(err)
+		req.True(key.IsPublic())
+		req.NotEmpty(key.KeyId)
+		req.Equal(AlgorithmEs256, key.Algorithm)
+	})
+}
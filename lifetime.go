@@ -0,0 +1,88 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// NumericDate is a JOSE NumericDate, the number of seconds since the Unix epoch. Fractional seconds are truncated
+// when parsed. The zero value means absent.
+type NumericDate int64
+
+// NewNumericDate returns the NumericDate of t
+func NewNumericDate(t time.Time) NumericDate {
+	return NumericDate(t.Unix())
+}
+
+// Time returns the NumericDate as a time.Time
+func (d NumericDate) Time() time.Time {
+	return time.Unix(int64(d), 0)
+}
+
+// IsSet returns true if the NumericDate is not absent
+func (d NumericDate) IsSet() bool {
+	return d != 0
+}
+
+func (d NumericDate) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.FormatInt(int64(d), 10)), nil
+}
+
+func (d *NumericDate) UnmarshalJSON(data []byte) error {
+	var seconds float64
+
+	if err := json.Unmarshal(data, &seconds); err != nil {
+		return fmt.Errorf("invalid NumericDate: %s", err)
+	}
+
+	if math.IsNaN(seconds) || seconds < math.MinInt64 || seconds >= math.MaxInt64 {
+		return fmt.Errorf("invalid NumericDate: %s", string(data))
+	}
+
+	*d = NumericDate(seconds)
+
+	return nil
+}
+
+// IsActiveAt returns true if the key may be used at t according to its optional nbf and exp members. Keys without
+// them are always active.
+func (k *Key) IsActiveAt(t time.Time) bool {
+	if k.NotBefore.IsSet() && t.Before(k.NotBefore.Time()) {
+		return false
+	}
+
+	if k.ExpiresAt.IsSet() && !t.Before(k.ExpiresAt.Time()) {
+		return false
+	}
+
+	return true
+}
+
+// ActiveAt returns a Response containing the keys that are active at t, see Key.IsActiveAt, so that expired and not
+// yet valid keys are excluded from verification
+func (r *Response) ActiveAt(t time.Time) *Response {
+	return &Response{
+		Keys: r.Filter(func(key *Key) bool {
+			return key.IsActiveAt(t)
+		}),
+	}
+}
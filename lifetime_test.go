@@ -0,0 +1,85 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_KeyLifetime(t *testing.T) {
+	t.Run("can parse and render lifetime members", func(t *testing.T) {
+		req := require.New(t)
+
+		key := &Key{}
+		req.NoError(json.Unmarshal([]byte(`{"kty":"oct","k":"AQAB","iat":1600000000,"nbf":1600000000.5,"exp":1700000000}`), key))
+		req.Equal(NumericDate(1600000000), key.IssuedAt)
+		req.Equal(NumericDate(1600000000), key.NotBefore)
+		req.Equal(NumericDate(1700000000), key.ExpiresAt)
+		req.Nil(key.AdditionalMembers)
+
+		data, err := json.Marshal(key)
+		req.NoError(err)
+		req.JSONEq(`{"kty":"oct","k":"AQAB","iat":1600000000,"nbf":1600000000,"exp":1700000000}`, string(data))
+	})
+
+	t.Run("omits absent lifetime members", func(t *testing.T) {
+		req := require.New(t)
+
+		data, err := json.Marshal(&Key{KeyType: KeyTypeOct, K: "AQAB"})
+		req.NoError(err)
+		req.JSONEq(`{"kty":"oct","k":"AQAB"}`, string(data))
+	})
+
+	t.Run("rejects invalid lifetime members", func(t *testing.T) {
+		req := require.New(t)
+
+		req.Error(json.Unmarshal([]byte(`{"kty":"oct","exp":"tomorrow"}`), &Key{}))
+		req.Error(json.Unmarshal([]byte(`{"kty":"oct","exp":1e300}`), &Key{}))
+	})
+
+	t.Run("can filter keys active at a time", func(t *testing.T) {
+		req := require.New(t)
+
+		now := time.Now()
+		response := &Response{Keys: []Key{
+			{KeyType: KeyTypeOct, KeyId: "unbounded", K: "AQAB"},
+			{KeyType: KeyTypeOct, KeyId: "expired", K: "AQAB", ExpiresAt: NewNumericDate(now.Add(-time.Minute))},
+			{KeyType: KeyTypeOct, KeyId: "future", K: "AQAB", NotBefore: NewNumericDate(now.Add(time.Minute))},
+			{KeyType: KeyTypeOct, KeyId: "current", K: "AQAB",
+				NotBefore: NewNumericDate(now.Add(-time.Minute)), ExpiresAt: NewNumericDate(now.Add(time.Minute))},
+		}}
+
+		active := response.ActiveAt(now)
+		req.Len(active.Keys, 2)
+		req.Equal("unbounded", active.Keys[0].KeyId)
+		req.Equal("current", active.Keys[1].KeyId)
+
+		req.Len(response.ActiveAt(now.Add(2*time.Minute)).Keys, 2)
+	})
+
+	t.Run("expires at exactly exp", func(t *testing.T) {
+		req := require.New(t)
+
+		exp := time.Unix(1700000000, 0)
+		key := &Key{ExpiresAt: NewNumericDate(exp)}
+		req.True(key.IsActiveAt(exp.Add(-time.Second)))
+		req.False(key.IsActiveAt(exp))
+	})
+}
@@ -0,0 +1,275 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"reflect"
+	"strings"
+)
+
+const (
+	ErrorTooManyKeysMsg       = "too many keys in JWK Set"
+	ErrorTooManyX5cEntriesMsg = "too many x5c entries"
+	ErrorMemberTooLongMsg     = "member too long"
+)
+
+// Limits bounds the size of documents accepted by Response.UnmarshalJSON and Key.UnmarshalJSON. Zero values disable
+// the corresponding limit.
+type Limits struct {
+	// MaxKeys is the maximum number of keys in a set
+	MaxKeys int
+
+	// MaxX5cEntries is the maximum number of certificates in a key's x5c chain
+	MaxX5cEntries int
+
+	// MaxMemberLength is the maximum length of any string member, x5c entry or additional member value of a key
+	MaxMemberLength int
+}
+
+// DefaultLimits are enforced by Response.UnmarshalJSON and Key.UnmarshalJSON, and by a Parser, KeyDecoder or
+// HttpResolver without Limits. They are generous enough for any legitimate JWKS document, programs with unusual
+// requirements should set the Limits of the decoder they use rather than change them.
+var DefaultLimits = Limits{
+	MaxKeys:         1000,
+	MaxX5cEntries:   16,
+	MaxMemberLength: 64 * 1024,
+}
+
+// LimitError is returned when a document exceeds one of the Limits. Member is empty for set level limits.
+type LimitError struct {
+	error
+	Member string
+	Length int
+	Limit  int
+}
+
+func (e *LimitError) Error() string {
+	if e.Member == "" {
+		return fmt.Sprintf("%s: %d exceeds limit of %d", e.error.Error(), e.Length, e.Limit)
+	}
+
+	return fmt.Sprintf("%s: %s is %d, limit is %d", e.error.Error(), e.Member, e.Length, e.Limit)
+}
+
+// limitsOrDefault returns limits, or DefaultLimits if nil
+func limitsOrDefault(limits *Limits) *Limits {
+	if limits == nil {
+		return &DefaultLimits
+	}
+
+	return limits
+}
+
+// checkKeyCount returns a *LimitError if count exceeds MaxKeys
+func (l *Limits) checkKeyCount(count int) error {
+	if l.MaxKeys > 0 && count > l.MaxKeys {
+		return &LimitError{
+			error:  errors.New(ErrorTooManyKeysMsg),
+			Length: count,
+			Limit:  l.MaxKeys,
+		}
+	}
+
+	return nil
+}
+
+// checkKey returns a *LimitError for the first member of key exceeding MaxX5cEntries or MaxMemberLength
+func (l *Limits) checkKey(key *Key) error {
+	if l.MaxX5cEntries > 0 && len(key.X509Chain) > l.MaxX5cEntries {
+		return &LimitError{
+			error:  errors.New(ErrorTooManyX5cEntriesMsg),
+			Member: "x5c",
+			Length: len(key.X509Chain),
+			Limit:  l.MaxX5cEntries,
+		}
+	}
+
+	if l.MaxMemberLength <= 0 {
+		return nil
+	}
+
+	tooLong := func(member string, length int) error {
+		return &LimitError{
+			error:  errors.New(ErrorMemberTooLongMsg),
+			Member: member,
+			Length: length,
+			Limit:  l.MaxMemberLength,
+		}
+	}
+
+	value := reflect.ValueOf(key).Elem()
+	keyType := value.Type()
+
+	for i := 0; i < keyType.NumField(); i++ {
+		name := strings.Split(keyType.Field(i).Tag.Get("json"), ",")[0]
+		field := value.Field(i)
+
+		switch field.Kind() {
+		case reflect.String:
+			if field.Len() > l.MaxMemberLength {
+				return tooLong(name, field.Len())
+			}
+		case reflect.Slice:
+			if field.Type().Elem().Kind() != reflect.String {
+				continue
+			}
+
+			for j := 0; j < field.Len(); j++ {
+				if field.Index(j).Len() > l.MaxMemberLength {
+					return tooLong(fmt.Sprintf("%s[%d]", name, j), field.Index(j).Len())
+				}
+			}
+		}
+	}
+
+	for name, raw := range key.AdditionalMembers {
+		if len(name) > l.MaxMemberLength {
+			return tooLong("member name", len(name))
+		}
+
+		if len(raw) > l.MaxMemberLength {
+			return tooLong(name, len(raw))
+		}
+	}
+
+	return nil
+}
+
+// UnmarshalJSON parses a JWK Set, enforcing DefaultLimits before decoding any key
+func (r *Response) UnmarshalJSON(data []byte) error {
+	return r.unmarshalJSON(data, &DefaultLimits)
+}
+
+// unmarshalJSON parses a JWK Set, enforcing limits before decoding any key
+func (r *Response) unmarshalJSON(data []byte, limits *Limits) error {
+	raw := struct {
+		Keys *keyList `json:"keys"`
+	}{
+		Keys: &keyList{limits: limits},
+	}
+
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	if raw.Keys == nil {
+		r.Keys = nil
+		return nil
+	}
+
+	r.Keys = raw.Keys.keys
+
+	return nil
+}
+
+// limitedResponse unmarshals into response enforcing limits instead of DefaultLimits
+type limitedResponse struct {
+	response *Response
+	limits   *Limits
+}
+
+func (r *limitedResponse) UnmarshalJSON(data []byte) error {
+	return r.response.unmarshalJSON(data, r.limits)
+}
+
+// keyList decodes the keys array of a JWK Set. The number of keys is counted and checked against limits on the raw
+// array before any key is decoded, then each key is decoded in place from the array.
+type keyList struct {
+	keys   []Key
+	limits *Limits
+}
+
+func (l *keyList) UnmarshalJSON(data []byte) error {
+	elements, ok := splitJsonArray(data)
+
+	if !ok {
+		// not an array or not splittable, let encoding/json report the error
+		var raw []json.RawMessage
+
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return err
+		}
+
+		elements = make([][]byte, 0, len(raw))
+
+		for _, element := range raw {
+			elements = append(elements, element)
+		}
+	}
+
+	if err := l.limits.checkKeyCount(len(elements)); err != nil {
+		return err
+	}
+
+	keys := make([]Key, len(elements))
+
+	for i, element := range elements {
+		if err := keys[i].unmarshalJSON(element, l.limits); err != nil {
+			return err
+		}
+	}
+
+	l.keys = keys
+
+	return nil
+}
+
+// splitJsonArray returns sub slices of data for the elements of the JSON array in data without copying them, see
+// skipJsonValue. ok is false if data is not an array.
+func splitJsonArray(data []byte) (elements [][]byte, ok bool) {
+	i := skipJsonWhitespace(data, 0)
+
+	if i >= len(data) || data[i] != '[' {
+		return nil, false
+	}
+
+	i = skipJsonWhitespace(data, i+1)
+	elements = [][]byte{}
+
+	if i < len(data) && data[i] == ']' {
+		return elements, true
+	}
+
+	for i < len(data) {
+		end := skipJsonValue(data, i)
+
+		if end < 0 {
+			return nil, false
+		}
+
+		elements = append(elements, data[i:end])
+		i = skipJsonWhitespace(data, end)
+
+		if i >= len(data) {
+			return nil, false
+		}
+
+		switch data[i] {
+		case ']':
+			return elements, true
+		case ',':
+			i = skipJsonWhitespace(data, i+1)
+		default:
+			return nil, false
+		}
+	}
+
+	return nil, false
+}
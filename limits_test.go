@@ -0,0 +1,163 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func withLimits(t *testing.T, limits Limits) {
+	previous := DefaultLimits
+	DefaultLimits = limits
+	t.Cleanup(func() {
+		DefaultLimits = previous
+	})
+}
+
+func Test_Limits(t *testing.T) {
+	t.Run("accepts documents within the default limits", func(t *testing.T) {
+		req := require.New(t)
+
+		response := &Response{}
+		req.NoError(json.Unmarshal([]byte(testJwksRfc7517Examples), response))
+		req.Len(response.Keys, 3)
+	})
+
+	t.Run("rejects too many keys", func(t *testing.T) {
+		req := require.New(t)
+		withLimits(t, Limits{MaxKeys: 2})
+
+		err := json.Unmarshal([]byte(testJwksRfc7517Examples), &Response{})
+
+		var limitErr *LimitError
+		req.ErrorAs(err, &limitErr)
+		req.Equal(3, limitErr.Length)
+		req.Equal(2, limitErr.Limit)
+
+		_, err = (&Parser{Lenient: true}).Parse([]byte(testJwksRfc7517Examples))
+		req.ErrorAs(err, &limitErr)
+	})
+
+	t.Run("rejects too many x5c entries", func(t *testing.T) {
+		req := require.New(t)
+		withLimits(t, Limits{MaxX5cEntries: 1})
+
+		err := json.Unmarshal([]byte(`{"kty":"oct","k":"AQAB","x5c":["AA==","AA=="]}`), &Key{})
+
+		var limitErr *LimitError
+		req.ErrorAs(err, &limitErr)
+		req.Equal("x5c", limitErr.Member)
+	})
+
+	t.Run("rejects long members", func(t *testing.T) {
+		req := require.New(t)
+		withLimits(t, Limits{MaxMemberLength: 16})
+
+		long := strings.Repeat("A", 17)
+
+		for _, doc := range []string{
+			fmt.Sprintf(`{"kty":"oct","k":"%s"}`, long),
+			fmt.Sprintf(`{"kty":"oct","k":"AQAB","x5c":["AA==","%s"]}`, long),
+			fmt.Sprintf(`{"kty":"oct","k":"AQAB","x-vendor":"%s"}`, long),
+		} {
+			err := json.Unmarshal([]byte(doc), &Key{})
+
+			var limitErr *LimitError
+			req.ErrorAs(err, &limitErr, doc)
+			req.Equal(16, limitErr.Limit)
+		}
+	})
+
+	t.Run("can set limits per parser without changing the defaults", func(t *testing.T) {
+		req := require.New(t)
+
+		for _, lenient := range []bool{false, true} {
+			parser := &Parser{Lenient: lenient, Limits: &Limits{MaxKeys: 2}}
+
+			_, err := parser.Parse([]byte(testJwksRfc7517Examples))
+
+			var limitErr *LimitError
+			req.ErrorAs(err, &limitErr)
+			req.Equal(2, limitErr.Limit)
+
+			parser.Limits = &Limits{MaxMemberLength: 16}
+			response, report, err := parser.ParseWithReport([]byte(testJwksRfc7517Examples))
+
+			if lenient {
+				req.NoError(err)
+				req.Empty(response.Keys)
+				req.Len(report.Skipped, 3)
+			} else {
+				req.ErrorAs(err, &limitErr)
+				req.Equal(16, limitErr.Limit)
+			}
+		}
+
+		response, err := (&Parser{}).Parse([]byte(testJwksRfc7517Examples))
+		req.NoError(err)
+		req.Len(response.Keys, 3)
+	})
+
+	t.Run("zero limits are unlimited", func(t *testing.T) {
+		req := require.New(t)
+		withLimits(t, Limits{})
+
+		doc := fmt.Sprintf(`{"kty":"oct","k":"%s"}`, strings.Repeat("A", 128*1024))
+		req.NoError(json.Unmarshal([]byte(doc), &Key{}))
+	})
+
+	t.Run("keeps a null key set nil", func(t *testing.T) {
+		req := require.New(t)
+
+		response := &Response{}
+		req.NoError(json.Unmarshal([]byte(`{"keys": null}`), response))
+		req.Nil(response.Keys)
+	})
+}
+
+func Test_splitJsonArray(t *testing.T) {
+	t.Run("can split arrays without copying", func(t *testing.T) {
+		req := require.New(t)
+
+		data := []byte(` [ {"a": [1, "]"]} , 2,"x" ] `)
+
+		elements, ok := splitJsonArray(data)
+		req.True(ok)
+		req.Len(elements, 3)
+		req.Equal(`{"a": [1, "]"]}`, string(elements[0]))
+		req.Equal(`2`, strings.TrimSpace(string(elements[1])))
+		req.Equal(`"x"`, string(elements[2]))
+
+		elements, ok = splitJsonArray([]byte(`[]`))
+		req.True(ok)
+		req.Empty(elements)
+	})
+
+	t.Run("rejects values that are not arrays", func(t *testing.T) {
+		req := require.New(t)
+
+		_, ok := splitJsonArray([]byte(`{"keys": []}`))
+		req.False(ok)
+
+		response := &Response{}
+		req.Error(json.Unmarshal([]byte(`{"keys": "none"}`), response))
+	})
+}
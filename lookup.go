@@ -0,0 +1,104 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+// ByKid returns the first key with the given kid or nil if there is none. It scans Keys, use KidIndex for constant
+// time lookups in a set that is not changed.
+func (r *Response) ByKid(kid string) *Key {
+	for i := range r.Keys {
+		if r.Keys[i].KeyId == kid {
+			return &r.Keys[i]
+		}
+	}
+
+	return nil
+}
+
+// GetKey returns a copy of the first key with the given kid, see ByKid
+func (r *Response) GetKey(kid string) (Key, bool) {
+	if key := r.ByKid(kid); key != nil {
+		return key.clone(), true
+	}
+
+	return Key{}, false
+}
+
+// KidIndex is an immutable snapshot of the keys of a Response indexed by kid, for constant time lookups in hot
+// verification paths over large sets. It holds its own copies of the keys, so later changes to the Response are not
+// reflected, build a new index after changing it. It is safe for concurrent use.
+type KidIndex struct {
+	keys    []Key
+	indexes map[string]int
+}
+
+// KidIndex returns a KidIndex of the current keys of the response
+func (r *Response) KidIndex() *KidIndex {
+	index := &KidIndex{
+		keys:    make([]Key, len(r.Keys)),
+		indexes: make(map[string]int, len(r.Keys)),
+	}
+
+	for i := len(r.Keys) - 1; i >= 0; i-- {
+		index.keys[i] = r.Keys[i].clone()
+		index.indexes[r.Keys[i].KeyId] = i
+	}
+
+	return index
+}
+
+// GetKey returns a copy of the first key with the given kid in constant time
+func (i *KidIndex) GetKey(kid string) (Key, bool) {
+	index, ok := i.indexes[kid]
+
+	if !ok {
+		return Key{}, false
+	}
+
+	return i.keys[index].clone(), true
+}
+
+// Len returns the number of indexed keys
+func (i *KidIndex) Len() int {
+	return len(i.keys)
+}
+
+// ByUse returns the keys whose use member equals use, see UseSignature and UseEncryption
+func (r *Response) ByUse(use string) []Key {
+	return r.Filter(func(key *Key) bool {
+		return key.Use == use
+	})
+}
+
+// ByAlg returns the keys whose alg member equals alg
+func (r *Response) ByAlg(alg string) []Key {
+	return r.Filter(func(key *Key) bool {
+		return key.Algorithm == alg
+	})
+}
+
+// Filter returns the keys for which predicate returns true in document order
+func (r *Response) Filter(predicate func(key *Key) bool) []Key {
+	var ret []Key
+
+	for i := range r.Keys {
+		if predicate(&r.Keys[i]) {
+			ret = append(ret, r.Keys[i])
+		}
+	}
+
+	return ret
+}
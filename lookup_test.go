@@ -0,0 +1,153 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_ResponseLookup(t *testing.T) {
+	response := &Response{}
+	require.NoError(t, json.Unmarshal([]byte(testJwksRfc7517Examples), response))
+
+	t.Run("can find a key by kid", func(t *testing.T) {
+		req := require.New(t)
+
+		key := response.ByKid("2011-04-29")
+		req.NotNil(key)
+		req.Equal(KeyTypeRsa, key.KeyType)
+
+		req.Nil(response.ByKid("missing"))
+	})
+
+	t.Run("can get a copy of a key by kid", func(t *testing.T) {
+		req := require.New(t)
+
+		key, ok := response.GetKey("1")
+		req.True(ok)
+		req.Equal(KeyTypeEc, key.KeyType)
+
+		key.KeyId = "changed"
+		req.Equal("1", response.Keys[0].KeyId)
+
+		_, ok = response.GetKey("missing")
+		req.False(ok)
+	})
+
+	t.Run("finds the first key for duplicate kids", func(t *testing.T) {
+		req := require.New(t)
+
+		duplicates := newDuplicateKidResponse(t)
+		req.Equal(KeyTypeEc, duplicates.ByKid("dup").KeyType)
+	})
+
+	t.Run("always reflects the current keys", func(t *testing.T) {
+		req := require.New(t)
+
+		response := &Response{}
+		req.NoError(json.Unmarshal([]byte(testJwksRfc7517Examples), response))
+		req.NotNil(response.ByKid("1"))
+
+		copied := *response
+
+		response.Keys = append(response.Keys, Key{KeyType: KeyTypeOct, KeyId: "appended", K: "AQAB"})
+		req.NotNil(response.ByKid("appended"))
+		req.Nil(copied.ByKid("appended"))
+
+		response.Keys[0].KeyId = "renamed"
+		req.Nil(response.ByKid("1"))
+		req.NotNil(response.ByKid("renamed"))
+	})
+
+	t.Run("can look up keys in a kid index snapshot", func(t *testing.T) {
+		req := require.New(t)
+
+		response := &Response{}
+		req.NoError(json.Unmarshal([]byte(testJwksRfc7517Examples), response))
+
+		index := response.KidIndex()
+		req.Equal(3, index.Len())
+
+		key, ok := index.GetKey("1")
+		req.True(ok)
+		req.Equal(*response.ByKid("1"), key)
+
+		response.Keys[0].KeyId = "renamed"
+		key.KeyId = "changed"
+
+		key, ok = index.GetKey("1")
+		req.True(ok)
+		req.Equal("1", key.KeyId)
+
+		_, ok = index.GetKey("renamed")
+		req.False(ok)
+
+		duplicates := newDuplicateKidResponse(t).KidIndex()
+		key, ok = duplicates.GetKey("dup")
+		req.True(ok)
+		req.Equal(KeyTypeEc, key.KeyType)
+	})
+
+	t.Run("can filter keys by use", func(t *testing.T) {
+		req := require.New(t)
+
+		keys := response.ByUse(UseEncryption)
+		req.Len(keys, 2)
+		req.Equal("1", keys[0].KeyId)
+		req.Equal("juliet@capulet.lit", keys[1].KeyId)
+
+		req.Empty(response.ByUse(UseSignature))
+	})
+
+	t.Run("can filter keys by alg", func(t *testing.T) {
+		req := require.New(t)
+
+		keys := response.ByAlg(AlgorithmRs256)
+		req.Len(keys, 1)
+		req.Equal("2011-04-29", keys[0].KeyId)
+	})
+
+	t.Run("can filter keys by predicate", func(t *testing.T) {
+		req := require.New(t)
+
+		keys := response.Filter(func(key *Key) bool {
+			return key.KeyType == KeyTypeRsa
+		})
+		req.Len(keys, 2)
+	})
+}
+
+func BenchmarkResponseByKid(b *testing.B) {
+	response, err := ParseResponse([]byte(testPublicJwksAuth0))
+
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	kid := response.Keys[len(response.Keys)-1].KeyId
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if response.ByKid(kid) == nil {
+			b.Fatal("key not found")
+		}
+	}
+}
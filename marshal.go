@@ -0,0 +1,288 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// jsonKey has the same fields as Key without its JSON methods
+type jsonKey Key
+
+// keyMemberNames is the set of JSON member names modeled by Key's fields
+var keyMemberNames = func() map[string]bool {
+	names := map[string]bool{}
+	keyType := reflect.TypeOf(Key{})
+
+	for i := 0; i < keyType.NumField(); i++ {
+		name := strings.Split(keyType.Field(i).Tag.Get("json"), ",")[0]
+
+		if name != "" && name != "-" {
+			names[name] = true
+		}
+	}
+
+	return names
+}()
+
+// UnmarshalJSON parses a JWK, storing members not modeled by Key in AdditionalMembers and enforcing DefaultLimits
+func (k *Key) UnmarshalJSON(data []byte) error {
+	return k.unmarshalJSON(data, &DefaultLimits)
+}
+
+// unmarshalJSON parses a JWK as UnmarshalJSON does, enforcing limits
+func (k *Key) unmarshalJSON(data []byte, limits *Limits) error {
+	parsed := jsonKey{}
+
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return err
+	}
+
+	parsed.AdditionalMembers = nil
+
+	// most keys only have modeled members, avoid decoding every member again to find additional ones
+	if onlyModeled, ok := hasOnlyModeledMembers(data); !ok || !onlyModeled {
+		members := map[string]json.RawMessage{}
+
+		if err := json.Unmarshal(data, &members); err != nil {
+			return err
+		}
+
+		for name := range members {
+			if keyMemberNames[name] {
+				delete(members, name)
+			}
+		}
+
+		if len(members) > 0 {
+			parsed.AdditionalMembers = members
+		}
+	}
+
+	if err := limits.checkKey((*Key)(&parsed)); err != nil {
+		return err
+	}
+
+	*k = Key(parsed)
+
+	return nil
+}
+
+// MarshalJSON renders a JWK, appending AdditionalMembers in lexicographic order after the modeled members. Empty
+// members are omitted, as are key material members that do not apply to a known kty. Additional members that collide
+// with modeled members are ignored.
+func (k Key) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(jsonKey(k.withoutForeignMembers()))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return appendAdditionalMembers(data, k.AdditionalMembers)
+}
+
+// withoutForeignMembers returns a copy of k with the key material members that do not apply to its kty cleared.
+// Keys with an unknown kty are returned unchanged.
+func (k Key) withoutForeignMembers() Key {
+	switch k.KeyType {
+	case KeyTypeRsa:
+		k.Curve, k.X, k.Y, k.K = "", "", "", ""
+	case KeyTypeEc:
+		k.N, k.E, k.K = "", "", ""
+		k.P, k.Q, k.Dp, k.Dq, k.Qi = "", "", "", "", ""
+	case KeyTypeOkp:
+		k.Y, k.N, k.E, k.K = "", "", "", ""
+		k.P, k.Q, k.Dp, k.Dq, k.Qi = "", "", "", "", ""
+	case KeyTypeOct:
+		k.Curve, k.X, k.Y, k.N, k.E = "", "", "", "", ""
+		k.D, k.P, k.Q, k.Dp, k.Dq, k.Qi = "", "", "", "", "", ""
+	}
+
+	return k
+}
+
+// appendAdditionalMembers appends members to the marshalled JSON object in data
+func appendAdditionalMembers(data []byte, members map[string]json.RawMessage) ([]byte, error) {
+	if len(members) == 0 {
+		return data, nil
+	}
+
+	names := make([]string, 0, len(members))
+
+	for name := range members {
+		if !keyMemberNames[name] {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+
+	buf := bytes.NewBuffer(bytes.TrimSuffix(data, []byte("}")))
+
+	for _, name := range names {
+		nameJson, err := json.Marshal(name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		valueJson, err := json.Marshal(members[name])
+
+		if err != nil {
+			return nil, err
+		}
+
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+
+		buf.Write(nameJson)
+		buf.WriteByte(':')
+		buf.Write(valueJson)
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// hasOnlyModeledMembers scans the member names of the JSON object in data without allocating and returns true if all
+// of them are modeled by Key. ok is false if the names could not be determined cheaply, such as for names with
+// escape sequences, and the caller must decode the members instead. data must be valid JSON.
+func hasOnlyModeledMembers(data []byte) (onlyModeled bool, ok bool) {
+	i := skipJsonWhitespace(data, 0)
+
+	if i >= len(data) || data[i] != '{' {
+		return false, false
+	}
+
+	i = skipJsonWhitespace(data, i+1)
+
+	if i < len(data) && data[i] == '}' {
+		return true, true
+	}
+
+	for i < len(data) {
+		if data[i] != '"' {
+			return false, false
+		}
+
+		end := i + 1
+
+		for end < len(data) && data[end] != '"' {
+			if data[end] == '\\' {
+				return false, false
+			}
+			end++
+		}
+
+		if end >= len(data) {
+			return false, false
+		}
+
+		if !keyMemberNames[string(data[i+1:end])] {
+			return false, true
+		}
+
+		i = skipJsonWhitespace(data, end+1)
+
+		if i >= len(data) || data[i] != ':' {
+			return false, false
+		}
+
+		if i = skipJsonValue(data, skipJsonWhitespace(data, i+1)); i < 0 {
+			return false, false
+		}
+
+		i = skipJsonWhitespace(data, i)
+
+		if i >= len(data) {
+			return false, false
+		}
+
+		switch data[i] {
+		case '}':
+			return true, true
+		case ',':
+			i = skipJsonWhitespace(data, i+1)
+		default:
+			return false, false
+		}
+	}
+
+	return false, false
+}
+
+// skipJsonWhitespace returns the index of the first non whitespace byte in data at or after i
+func skipJsonWhitespace(data []byte, i int) int {
+	for i < len(data) && (data[i] == ' ' || data[i] == '\t' || data[i] == '\n' || data[i] == '\r') {
+		i++
+	}
+
+	return i
+}
+
+// skipJsonValue returns the index after the JSON value starting at i, or -1 if data ends first
+func skipJsonValue(data []byte, i int) int {
+	depth := 0
+
+	for i < len(data) {
+		switch data[i] {
+		case '"':
+			i++
+
+			for i < len(data) && data[i] != '"' {
+				if data[i] == '\\' {
+					i++
+				}
+				i++
+			}
+
+			if i >= len(data) {
+				return -1
+			}
+		case '{', '[':
+			depth++
+		case '}', ']':
+			if depth == 0 {
+				return i
+			}
+
+			depth--
+		case ',':
+			if depth == 0 {
+				return i
+			}
+		}
+
+		i++
+
+		if depth == 0 && (data[i-1] == '"' || data[i-1] == '}' || data[i-1] == ']') {
+			return i
+		}
+	}
+
+	if depth == 0 {
+		return i
+	}
+
+	return -1
+}
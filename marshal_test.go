@@ -0,0 +1,259 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"encoding/json"
+	"github.com/Jeffail/gabs/v2"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+var testJwksWithExtensions = `{
+  "keys": [
+    {
+      "kty": "EC",
+      "crv": "P-256",
+      "x": "MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4",
+      "y": "4Etl6SRW2YiLUrN5vfvVHuhp7x8PxltmWWlbbM4IFyM",
+      "use": "sig",
+      "kid": "1",
+      "x-created": 1700000000,
+      "x-vendor": {"tier": "gold", "regions": ["us", "eu"]}
+    }
+  ]
+}`
+
+func Test_KeyAdditionalMembers(t *testing.T) {
+	t.Run("collects unknown members", func(t *testing.T) {
+		req := require.New(t)
+		response := &Response{}
+
+		err := json.Unmarshal([]byte(testJwksWithExtensions), response)
+		req.NoError(err)
+
+		key := response.Keys[0]
+		req.Equal("1", key.KeyId)
+		req.Len(key.AdditionalMembers, 2)
+		req.JSONEq(`1700000000`, string(key.AdditionalMembers["x-created"]))
+		req.JSONEq(`{"tier": "gold", "regions": ["us", "eu"]}`, string(key.AdditionalMembers["x-vendor"]))
+	})
+
+	t.Run("leaves AdditionalMembers nil when there are none", func(t *testing.T) {
+		req := require.New(t)
+		response := &Response{}
+
+		err := json.Unmarshal([]byte(testJwksRfc7517Examples), response)
+		req.NoError(err)
+
+		for _, key := range response.Keys {
+			req.Nil(key.AdditionalMembers)
+		}
+	})
+
+	t.Run("round trips unknown members", func(t *testing.T) {
+		req := require.New(t)
+		response := &Response{}
+
+		err := json.Unmarshal([]byte(testJwksWithExtensions), response)
+		req.NoError(err)
+
+		out, err := json.Marshal(response)
+		req.NoError(err)
+
+		container, err := gabs.ParseJSON(out)
+		req.NoError(err)
+
+		req.Equal(float64(1700000000), container.Path("keys.0.x-created").Data())
+		req.Equal("gold", container.Path("keys.0.x-vendor.tier").Data())
+		req.Equal("eu", container.Path("keys.0.x-vendor.regions.1").Data())
+		req.Equal("1", container.Path("keys.0.kid").Data())
+
+		reparsed := &Response{}
+		req.NoError(json.Unmarshal(out, reparsed))
+		req.Len(reparsed.Keys[0].AdditionalMembers, 2)
+
+		reOut, err := json.Marshal(reparsed)
+		req.NoError(err)
+		req.JSONEq(string(out), string(reOut))
+	})
+
+	t.Run("ignores additional members that collide with modeled members", func(t *testing.T) {
+		req := require.New(t)
+
+		key := Key{
+			KeyId:             "real",
+			AdditionalMembers: map[string]json.RawMessage{"kid": json.RawMessage(`"fake"`)},
+		}
+
+		out, err := json.Marshal(key)
+		req.NoError(err)
+
+		container, err := gabs.ParseJSON(out)
+		req.NoError(err)
+		req.Equal("real", container.Path("kid").Data())
+	})
+}
+
+func Test_KeyMarshalJSON(t *testing.T) {
+	t.Run("omits empty members", func(t *testing.T) {
+		req := require.New(t)
+
+		cert, _, err := newEcCert()
+		req.NoError(err)
+
+		key, err := NewKey("", cert, nil)
+		req.NoError(err)
+		key.X509Chain = nil
+
+		data, err := json.Marshal(key)
+		req.NoError(err)
+
+		members := map[string]interface{}{}
+		req.NoError(json.Unmarshal(data, &members))
+
+		for _, name := range []string{"crv", "x", "y", "kty", "kid", "alg", "use", "key_ops", "x5t", "x5t#S256"} {
+			req.Contains(members, name)
+		}
+
+		for _, name := range []string{"n", "e", "k", "d", "p", "q", "dp", "dq", "qi", "t", "x5c", "x5u"} {
+			req.NotContains(members, name)
+		}
+	})
+
+	t.Run("omits members foreign to the kty", func(t *testing.T) {
+		req := require.New(t)
+
+		key := Key{
+			KeyType: KeyTypeRsa,
+			KeyId:   "rsa",
+			N:       "AQAB",
+			E:       "AQAB",
+			Curve:   "P-256",
+			X:       "AQAB",
+			K:       "AQAB",
+		}
+
+		data, err := json.Marshal(key)
+		req.NoError(err)
+		req.JSONEq(`{"kty":"RSA","kid":"rsa","n":"AQAB","e":"AQAB"}`, string(data))
+	})
+
+	t.Run("keeps every member of an unknown kty", func(t *testing.T) {
+		req := require.New(t)
+
+		key := Key{
+			KeyType: "ML-DSA",
+			N:       "AQAB",
+			X:       "AQAB",
+		}
+
+		data, err := json.Marshal(key)
+		req.NoError(err)
+		req.JSONEq(`{"kty":"ML-DSA","n":"AQAB","x":"AQAB"}`, string(data))
+	})
+
+	t.Run("keeps the private members of the kty", func(t *testing.T) {
+		req := require.New(t)
+
+		response := &Response{}
+		req.NoError(json.Unmarshal([]byte(testJwksRfc7517Examples), response))
+
+		data, err := json.Marshal(response.Keys[2])
+		req.NoError(err)
+
+		parsed := Key{}
+		req.NoError(json.Unmarshal(data, &parsed))
+		req.Equal(response.Keys[2], parsed)
+	})
+}
+
+func Test_hasOnlyModeledMembers(t *testing.T) {
+	t.Run("can detect additional members", func(t *testing.T) {
+		req := require.New(t)
+
+		cases := map[string]bool{
+			`{}`:                                     true,
+			` { "kty" : "EC" , "x5c" : ["a", "b"] }`: true,
+			`{"kty": "EC", "exp": 1, "key_ops": []}`: true,
+			`{"kty": "EC", "ext": true}`:             false,
+			`{"kty": "EC", "x-vendor": {"kty": 1}}`:  false,
+			`{"x": "a\"}", "kid": "b"}`:              true,
+		}
+
+		for document, expected := range cases {
+			onlyModeled, ok := hasOnlyModeledMembers([]byte(document))
+			req.True(ok, document)
+			req.Equal(expected, onlyModeled, document)
+		}
+	})
+
+	t.Run("falls back for escaped names", func(t *testing.T) {
+		req := require.New(t)
+
+		_, ok := hasOnlyModeledMembers([]byte(`{"k\u0074y": "EC"}`))
+		req.False(ok)
+
+		key := &Key{}
+		req.NoError(json.Unmarshal([]byte(`{"kty": "EC", "x-vendor": 1}`), key))
+		req.Contains(key.AdditionalMembers, "x-vendor")
+	})
+}
+
+func BenchmarkResponseUnmarshal(b *testing.B) {
+	data := []byte(testPublicJwksAuth0)
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		response := &Response{}
+
+		if err := json.Unmarshal(data, response); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkKeyUnmarshal(b *testing.B) {
+	b.Run("modeled members", func(b *testing.B) {
+		data := []byte(testSingleJwk)
+
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			key := &Key{}
+
+			if err := json.Unmarshal(data, key); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("additional members", func(b *testing.B) {
+		data := []byte(`{"kty": "EC", "crv": "P-256", "x": "f83OJ3D2xF1Bg8vub9tLe1gHMzV76e8Tus9uPHvRVEU", "y": "x_FEzRu9m36HLN_tue659LNpXW6pCyStikYjKIWI5a0", "x-vendor": {"tier": 1}}`)
+
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			key := &Key{}
+
+			if err := json.Unmarshal(data, key); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,85 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package jwks
+
+// KeyStoreSnapshot is a point in time copy of the keys of a MemoryKeyStore. Private holds the keys as stored, Public
+// their public parts as served, see Response.PublicOnly.
+type KeyStoreSnapshot struct {
+	Private *Response `json:"private,omitempty"`
+	Public  *Response `json:"public,omitempty"`
+}
+
+// MemoryKeyStore is a concurrency-safe, in-memory KeyStore whose contents can be captured with Snapshot and reset
+// with Restore, for example to persist them elsewhere or to set up and reset state in tests. It is a ResponseSource
+// serving all stored keys.
+type MemoryKeyStore struct {
+	keys *Set
+}
+
+// NewMemoryKeyStore returns a MemoryKeyStore containing copies of keys
+func NewMemoryKeyStore(keys ...Key) *MemoryKeyStore {
+	return &MemoryKeyStore{
+		keys: NewSet(keys...),
+	}
+}
+
+func (s *MemoryKeyStore) Load(kid string) (Key, error) {
+	return s.keys.Load(kid)
+}
+
+func (s *MemoryKeyStore) Save(key Key) error {
+	return s.keys.Save(key)
+}
+
+func (s *MemoryKeyStore) List() ([]Key, error) {
+	return s.keys.List()
+}
+
+func (s *MemoryKeyStore) Delete(kid string) error {
+	return s.keys.Delete(kid)
+}
+
+// Response returns a Response containing copies of all stored keys
+func (s *MemoryKeyStore) Response() *Response {
+	keys, _ := s.keys.List()
+
+	return &Response{Keys: keys}
+}
+
+// Snapshot returns copies of the stored keys and of their public parts, taken atomically
+func (s *MemoryKeyStore) Snapshot() *KeyStoreSnapshot {
+	private := s.Response()
+
+	return &KeyStoreSnapshot{
+		Private: private,
+		Public:  private.PublicOnly(),
+	}
+}
+
+// Restore atomically replaces the stored keys with copies of the snapshot's Private keys, or of its Public keys if it
+// has no Private keys, so a verification only store can be restored from a published set
+func (s *MemoryKeyStore) Restore(snapshot *KeyStoreSnapshot) {
+	switch {
+	case snapshot == nil:
+		s.keys.Replace(nil)
+	case snapshot.Private != nil:
+		s.keys.Replace(snapshot.Private.Keys)
+	case snapshot.Public != nil:
+		s.keys.Replace(snapshot.Public.Keys)
+	default:
+		s.keys.Replace(nil)
+	}
+}
@@ -0,0 +1,110 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_MemoryKeyStore(t *testing.T) {
+	private, _, err := GenerateECKey("P-256", "signing")
+	require.NoError(t, err)
+
+	secret, err := GenerateOctKey(32, "secret")
+	require.NoError(t, err)
+
+	t.Run("implements KeyStore", func(t *testing.T) {
+		req := require.New(t)
+
+		var store KeyStore = NewMemoryKeyStore()
+
+		req.NoError(store.Save(*private))
+
+		loaded, err := store.Load("signing")
+		req.NoError(err)
+		req.Equal(private.D, loaded.D)
+
+		req.NoError(store.Delete("signing"))
+		req.IsType(&KeyNotFoundError{}, store.Delete("signing"))
+
+		keys, err := store.List()
+		req.NoError(err)
+		req.Empty(keys)
+	})
+
+	t.Run("can snapshot and restore", func(t *testing.T) {
+		req := require.New(t)
+
+		store := NewMemoryKeyStore(*private, *secret)
+		snapshot := store.Snapshot()
+
+		req.Len(snapshot.Private.Keys, 2)
+		req.True(snapshot.Private.Keys[0].IsPrivate())
+		req.Len(snapshot.Public.Keys, 1)
+		req.True(snapshot.Public.Keys[0].IsPublic())
+
+		req.NoError(store.Delete("signing"))
+		req.Len(store.Response().Keys, 1)
+
+		raw, err := json.Marshal(snapshot)
+		req.NoError(err)
+
+		restored := &KeyStoreSnapshot{}
+		req.NoError(json.Unmarshal(raw, restored))
+
+		store.Restore(restored)
+
+		keys, err := store.List()
+		req.NoError(err)
+		req.Len(keys, 2)
+
+		loaded, err := store.Load("signing")
+		req.NoError(err)
+		req.Equal(private.D, loaded.D)
+	})
+
+	t.Run("can restore public keys only", func(t *testing.T) {
+		req := require.New(t)
+
+		snapshot := NewMemoryKeyStore(*private).Snapshot()
+		snapshot.Private = nil
+
+		store := NewMemoryKeyStore(*secret)
+		store.Restore(snapshot)
+
+		keys, err := store.List()
+		req.NoError(err)
+		req.Len(keys, 1)
+		req.True(keys[0].IsPublic())
+
+		store.Restore(nil)
+		req.Empty(store.Response().Keys)
+	})
+
+	t.Run("snapshots are independent of the store", func(t *testing.T) {
+		req := require.New(t)
+
+		store := NewMemoryKeyStore(*private)
+		snapshot := store.Snapshot()
+		snapshot.Private.Keys[0].KeyId = "changed"
+
+		_, err := store.Load("signing")
+		req.NoError(err)
+	})
+}
@@ -0,0 +1,53 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto"
+)
+
+// MergeResponses combines the keys of responses in order into a new Response. Keys with the same RFC 7638 SHA-256
+// thumbprint are de-duplicated, keeping the first occurrence. Keys whose thumbprint can not be computed are kept as
+// is. Distinct keys sharing a kid are then resolved with mode, see Response.ResolveDuplicateKids.
+func MergeResponses(mode DuplicateKidMode, responses ...*Response) (*Response, error) {
+	merged := &Response{}
+	seen := map[string]bool{}
+
+	for _, response := range responses {
+		if response == nil {
+			continue
+		}
+
+		for i := range response.Keys {
+			if thumbprint, err := response.Keys[i].Thumbprint(crypto.SHA256); err == nil {
+				if seen[thumbprint] {
+					continue
+				}
+
+				seen[thumbprint] = true
+			}
+
+			merged.Keys = append(merged.Keys, response.Keys[i].clone())
+		}
+	}
+
+	if err := merged.ResolveDuplicateKids(mode); err != nil {
+		return nil, err
+	}
+
+	return merged, nil
+}
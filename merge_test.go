@@ -0,0 +1,80 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_MergeResponses(t *testing.T) {
+	newResponse := func(t *testing.T) *Response {
+		response := &Response{}
+		require.NoError(t, json.Unmarshal([]byte(testJwksRfc7517Examples), response))
+		return response
+	}
+
+	t.Run("de-duplicates keys by thumbprint", func(t *testing.T) {
+		req := require.New(t)
+
+		a := newResponse(t)
+		b := newResponse(t)
+		b.Keys[0].KeyId = "renamed"
+
+		merged, err := MergeResponses(DuplicateKidsReject, a, b)
+		req.NoError(err)
+		req.Len(merged.Keys, 3)
+		req.Equal("1", merged.Keys[0].KeyId)
+	})
+
+	t.Run("keeps keys without a thumbprint", func(t *testing.T) {
+		req := require.New(t)
+
+		unknown := &Response{Keys: []Key{{KeyType: "ML-DSA", KeyId: "pq"}}}
+
+		merged, err := MergeResponses(DuplicateKidsAllow, unknown, unknown)
+		req.NoError(err)
+		req.Len(merged.Keys, 2)
+	})
+
+	t.Run("resolves kid conflicts with the mode", func(t *testing.T) {
+		req := require.New(t)
+
+		a := &Response{Keys: []Key{{KeyType: KeyTypeOct, KeyId: "shared", K: "AQAB"}}}
+		b := &Response{Keys: []Key{{KeyType: KeyTypeOct, KeyId: "shared", K: "AQAC"}}}
+
+		_, err := MergeResponses(DuplicateKidsReject, a, b)
+		req.Error(err)
+
+		merged, err := MergeResponses(DuplicateKidsKeepLast, a, nil, b)
+		req.NoError(err)
+		req.Len(merged.Keys, 1)
+		req.Equal("AQAC", merged.Keys[0].K)
+	})
+
+	t.Run("does not share state with its inputs", func(t *testing.T) {
+		req := require.New(t)
+
+		a := newResponse(t)
+		merged, err := MergeResponses(DuplicateKidsAllow, a)
+		req.NoError(err)
+
+		merged.Keys[1].KeyId = "changed"
+		req.Equal("2011-04-29", a.Keys[1].KeyId)
+	})
+}
@@ -0,0 +1,100 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package jwks
+
+import "sync/atomic"
+
+// HandlerMetrics counts the requests served by a Handler configured with WithMetrics so issuers can observe how
+// verifiers fetch their keys, for example clients that poll too aggressively or ignore the ETag. It is safe for
+// concurrent use and its methods may be called on a nil *HandlerMetrics, which records nothing.
+type HandlerMetrics struct {
+	requests    uint64
+	notModified uint64
+	errors      uint64
+	servedKeys  int64
+}
+
+// Requests returns the number of GET and HEAD requests received
+func (m *HandlerMetrics) Requests() uint64 {
+	if m == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(&m.requests)
+}
+
+// NotModified returns the number of requests answered with 304 Not Modified
+func (m *HandlerMetrics) NotModified() uint64 {
+	if m == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(&m.notModified)
+}
+
+// Errors returns the number of requests that failed because no set could be served
+func (m *HandlerMetrics) Errors() uint64 {
+	if m == nil {
+		return 0
+	}
+
+	return atomic.LoadUint64(&m.errors)
+}
+
+// ServedKeys returns the number of keys in the most recently served set after query filters were applied
+func (m *HandlerMetrics) ServedKeys() int {
+	if m == nil {
+		return 0
+	}
+
+	return int(atomic.LoadInt64(&m.servedKeys))
+}
+
+// CacheHitRatio returns the share of requests answered with 304 Not Modified, 0 if there were no requests. A low ratio
+// with a high request rate indicates clients that do not send If-None-Match.
+func (m *HandlerMetrics) CacheHitRatio() float64 {
+	requests := m.Requests()
+
+	if requests == 0 {
+		return 0
+	}
+
+	return float64(m.NotModified()) / float64(requests)
+}
+
+func (m *HandlerMetrics) countRequest() {
+	if m != nil {
+		atomic.AddUint64(&m.requests, 1)
+	}
+}
+
+func (m *HandlerMetrics) countNotModified() {
+	if m != nil {
+		atomic.AddUint64(&m.notModified, 1)
+	}
+}
+
+func (m *HandlerMetrics) countError() {
+	if m != nil {
+		atomic.AddUint64(&m.errors, 1)
+	}
+}
+
+func (m *HandlerMetrics) setServedKeys(count int) {
+	if m != nil {
+		atomic.StoreInt64(&m.servedKeys, int64(count))
+	}
+}
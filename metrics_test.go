@@ -0,0 +1,76 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_HandlerMetrics(t *testing.T) {
+	t.Run("counts requests, cache hits and served keys", func(t *testing.T) {
+		req := require.New(t)
+
+		private, _, err := GenerateECKey("P-256", "ec")
+		req.NoError(err)
+
+		secret, err := GenerateOctKey(32, "secret")
+		req.NoError(err)
+
+		metrics := &HandlerMetrics{}
+		handler := Handler(NewSet(*private, *secret), WithMetrics(metrics))
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		etag := recorder.Header().Get("ETag")
+
+		for i := 0; i < 3; i++ {
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.Header.Set("If-None-Match", etag)
+			handler.ServeHTTP(httptest.NewRecorder(), request)
+		}
+
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", nil))
+
+		req.Equal(uint64(4), metrics.Requests())
+		req.Equal(uint64(3), metrics.NotModified())
+		req.Equal(uint64(0), metrics.Errors())
+		req.Equal(1, metrics.ServedKeys())
+		req.Equal(0.75, metrics.CacheHitRatio())
+	})
+
+	t.Run("counts errors", func(t *testing.T) {
+		req := require.New(t)
+
+		metrics := &HandlerMetrics{}
+		Handler(StaticResponseSource(nil), WithMetrics(metrics)).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+		req.Equal(uint64(1), metrics.Requests())
+		req.Equal(uint64(1), metrics.Errors())
+	})
+
+	t.Run("can be read without being configured", func(t *testing.T) {
+		req := require.New(t)
+
+		var metrics *HandlerMetrics
+
+		req.Equal(uint64(0), metrics.Requests())
+		req.Equal(0.0, metrics.CacheHitRatio())
+	})
+}
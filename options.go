@@ -0,0 +1,175 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto/x509"
+	"fmt"
+)
+
+// KeyOption alters how a Key is constructed by functions such as NewKey
+type KeyOption func(*keyOptions)
+
+type keyOptions struct {
+	algorithm     string
+	thumbprintKid bool
+	keyId         string
+	passphrase    []byte
+	policy        *Policy
+	kidStrategy   KidStrategy
+}
+
+func newKeyOptions(opts []KeyOption) *keyOptions {
+	options := &keyOptions{}
+
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	return options
+}
+
+// apply sets the properties of key that are controlled by options after its key material has been populated and
+// enforces the policy, if any, including the minimum RSA modulus size
+func (options *keyOptions) apply(key *Key) error {
+	if options.algorithm != "" {
+		key.Algorithm = options.algorithm
+	} else {
+		key.Algorithm = InferAlgorithm(key)
+	}
+
+	if options.policy != nil {
+		errs := options.policy.check(key)
+
+		if key.KeyType == KeyTypeRsa {
+			errs = append(errs, key.validateRsa(options.policy)...)
+		}
+
+		if len(errs) > 0 {
+			return &KeyValidationError{
+				KeyId:  key.KeyId,
+				Errors: errs,
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkGeneration enforces the policy, if any, on the parameters of a key before it is generated: kty, curve, the alg
+// that will be set, and for RSA the modulus size. inferredAlgorithm is used unless WithAlgorithm was supplied.
+func (options *keyOptions) checkGeneration(keyType, curve, inferredAlgorithm string, rsaBits int) error {
+	if options.policy == nil {
+		return nil
+	}
+
+	template := &Key{
+		KeyType:   keyType,
+		Curve:     curve,
+		Algorithm: inferredAlgorithm,
+	}
+
+	if options.algorithm != "" {
+		template.Algorithm = options.algorithm
+	}
+
+	errs := options.policy.check(template)
+
+	if keyType == KeyTypeRsa && options.policy.MinRsaBits > 0 && rsaBits < options.policy.MinRsaBits {
+		errs = append(errs, fmt.Errorf("RSA key size %d is below the policy minimum of %d", rsaBits, options.policy.MinRsaBits))
+	}
+
+	if len(errs) > 0 {
+		return &KeyValidationError{Errors: errs}
+	}
+
+	return nil
+}
+
+// assignKid populates an empty kid of key using the configured KidStrategy. Without one, keys constructed from a
+// certificate use CertSha1KidStrategy unless WithThumbprintKid is supplied or the policy forbids SHA-1 kids, and
+// all other keys use ThumbprintKidStrategy.
+func (options *keyOptions) assignKid(key *Key, cert *x509.Certificate) error {
+	if key.KeyId != "" {
+		return nil
+	}
+
+	strategy := options.kidStrategy
+
+	if strategy == nil {
+		if cert == nil || options.thumbprintKid || (options.policy != nil && options.policy.ForbidSha1Kid) {
+			strategy = ThumbprintKidStrategy
+		} else {
+			strategy = CertSha1KidStrategy
+		}
+	}
+
+	kid, err := strategy.Kid(key, cert)
+
+	if err != nil {
+		return err
+	}
+
+	key.KeyId = kid
+
+	return nil
+}
+
+// WithAlgorithm overrides the inferred alg property of a constructed Key
+func WithAlgorithm(algorithm string) KeyOption {
+	return func(options *keyOptions) {
+		options.algorithm = algorithm
+	}
+}
+
+// WithThumbprintKid derives an empty kid from the RFC 7638 SHA-256 thumbprint of the key instead of the SHA-1
+// fingerprint of its certificate
+func WithThumbprintKid() KeyOption {
+	return func(options *keyOptions) {
+		options.thumbprintKid = true
+	}
+}
+
+// WithKidStrategy generates empty kids of constructed Keys with strategy
+func WithKidStrategy(strategy KidStrategy) KeyOption {
+	return func(options *keyOptions) {
+		options.kidStrategy = strategy
+	}
+}
+
+// WithKeyId sets the kid of Keys constructed by functions that do not otherwise accept one, such as ParsePEMKey
+func WithKeyId(keyId string) KeyOption {
+	return func(options *keyOptions) {
+		options.keyId = keyId
+	}
+}
+
+// WithPassphrase supplies the passphrase used to decrypt encrypted private keys during parsing
+func WithPassphrase(passphrase []byte) KeyOption {
+	return func(options *keyOptions) {
+		options.passphrase = passphrase
+	}
+}
+
+// WithPolicy rejects constructed keys that are not allowed by policy. Generators such as GenerateRSAKey refuse to
+// generate keys the policy does not allow, including RSA keys below its MinRsaBits. If the policy forbids SHA-1 kids,
+// empty kids are derived from the RFC 7638 thumbprint.
+func WithPolicy(policy *Policy) KeyOption {
+	return func(options *keyOptions) {
+		options.policy = policy
+	}
+}
@@ -0,0 +1,506 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Parser parses JWKS documents with optional checks beyond plain json.Unmarshal. The zero value behaves like
+// json.Unmarshal into a Response.
+type Parser struct {
+	// Policy, if set, rejects documents containing any key that fails Response.Validate with the policy
+	Policy *Policy
+
+	// StrictBase64 rejects documents containing binary members that are not valid unpadded base64url, or x5c entries
+	// that are not valid padded base64, reporting every malformed member as a MemberErrors
+	StrictBase64 bool
+
+	// Lenient skips keys that can not be decoded, are structurally invalid, or fail the StrictBase64 or Policy checks
+	// instead of failing the whole document. Skipped keys are reported by ParseWithReport.
+	Lenient bool
+
+	// DisallowUnknownFields rejects documents with members other than "keys" at the top level or trailing data, and
+	// keys that fail a per-kty schema check: kty must be known, its required members present, and no foreign or
+	// unknown members may be set. Schema failures are reported as MemberErrors.
+	DisallowUnknownFields bool
+
+	// Tolerant accepts common real-world deviations, such as padded base64, curve aliases like "secp256r1" and
+	// lower case key types, and normalizes them into canonical form before any other check. Fixes are reported by
+	// ParseWithReport.
+	Tolerant bool
+
+	// DuplicateKids selects how keys sharing a kid are handled after parsing, see Response.ResolveDuplicateKids
+	DuplicateKids DuplicateKidMode
+
+	// Limits bounds the size of accepted documents, if nil DefaultLimits are enforced
+	Limits *Limits
+}
+
+// ParseReport describes how a document was handled by ParseWithReport
+type ParseReport struct {
+	// Skipped lists the keys dropped in Lenient mode in document order
+	Skipped []*SkippedKey
+
+	// DuplicateKids holds the indexes of keys sharing a kid before DuplicateKids was applied, keyed by kid. It is only
+	// populated if Parser.DuplicateKids is not DuplicateKidsAllow.
+	DuplicateKids map[string][]int
+
+	// Fixes lists the deviations normalized in Tolerant mode in document order
+	Fixes []*Fix
+}
+
+// SkippedKey describes a key dropped from a document in Lenient mode. Raw is the key's original JSON.
+type SkippedKey struct {
+	error
+	KeyIndex int
+	KeyId    string
+	Raw      json.RawMessage
+}
+
+func (e *SkippedKey) Error() string {
+	return fmt.Sprintf("skipped keys[%d] (kid %q): %s", e.KeyIndex, e.KeyId, e.error.Error())
+}
+
+func (e *SkippedKey) Unwrap() error {
+	return e.error
+}
+
+// MemberError identifies a malformed member of the key at KeyIndex
+type MemberError struct {
+	error
+	KeyIndex int
+	KeyId    string
+	Member   string
+}
+
+func (e *MemberError) Error() string {
+	return fmt.Sprintf("keys[%d] (kid %q) member %s: %s", e.KeyIndex, e.KeyId, e.Member, e.error.Error())
+}
+
+func (e *MemberError) Unwrap() error {
+	return e.error
+}
+
+// MemberErrors is returned when one or more key members are malformed
+type MemberErrors []*MemberError
+
+func (e MemberErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+const (
+	ErrorNotSingleKeyMsg = "document is a JWK Set, not a single JWK"
+	ErrorMissingKtyMsg   = "document is not a JWK, kty is missing"
+	ErrorTrailingDataMsg = "unexpected data after the JWKS document"
+)
+
+// ParseKey parses a single JWK document, such as one served as application/jwk+json
+func ParseKey(data []byte) (*Key, error) {
+	return parseKey(data, &DefaultLimits)
+}
+
+// parseKey implements ParseKey, enforcing limits
+func parseKey(data []byte, limits *Limits) (*Key, error) {
+	members := map[string]json.RawMessage{}
+
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, err
+	}
+
+	if _, ok := members["keys"]; ok {
+		return nil, errors.New(ErrorNotSingleKeyMsg)
+	}
+
+	if _, ok := members["kty"]; !ok {
+		return nil, errors.New(ErrorMissingKtyMsg)
+	}
+
+	key := &Key{}
+
+	if err := key.unmarshalJSON(data, limits); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// ParseResponse parses either a JWK Set or a single JWK document. A single JWK is wrapped into a Response containing
+// only that key.
+func ParseResponse(data []byte) (*Response, error) {
+	return parseResponse(data, &DefaultLimits)
+}
+
+// parseResponse implements ParseResponse, enforcing limits
+func parseResponse(data []byte, limits *Limits) (*Response, error) {
+	members := map[string]json.RawMessage{}
+
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, err
+	}
+
+	if _, ok := members["keys"]; !ok {
+		if _, ok := members["kty"]; ok {
+			key, err := parseKey(data, limits)
+
+			if err != nil {
+				return nil, err
+			}
+
+			return &Response{Keys: []Key{*key}}, nil
+		}
+	}
+
+	response := &Response{}
+
+	if err := json.Unmarshal(data, &limitedResponse{response: response, limits: limits}); err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// Parse parses a JWKS document, see ParseWithReport
+func (p *Parser) Parse(data []byte) (*Response, error) {
+	response, _, err := p.ParseWithReport(data)
+	return response, err
+}
+
+// ParseWithReport parses a JWKS document and reports keys skipped in Lenient mode, duplicate kids and Tolerant mode
+// fixes
+func (p *Parser) ParseWithReport(data []byte) (*Response, *ParseReport, error) {
+	report := &ParseReport{}
+
+	var response *Response
+	var err error
+
+	if p.Lenient {
+		response, err = p.parseLenient(data, report)
+	} else {
+		response, err = p.parseStrict(data, report)
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if p.DuplicateKids != DuplicateKidsAllow {
+		report.DuplicateKids = response.DuplicateKids()
+
+		if err := response.ResolveDuplicateKids(p.DuplicateKids); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return response, report, nil
+}
+
+// parseStrict decodes the whole document, failing on the first unusable key
+func (p *Parser) parseStrict(data []byte, report *ParseReport) (*Response, error) {
+	response := &Response{}
+
+	if err := p.decode(data, &limitedResponse{response: response, limits: limitsOrDefault(p.Limits)}); err != nil {
+		return nil, err
+	}
+
+	if p.Tolerant {
+		for i := range response.Keys {
+			report.Fixes = append(report.Fixes, normalizeKey(i, &response.Keys[i])...)
+		}
+	}
+
+	if p.DisallowUnknownFields {
+		var errs MemberErrors
+
+		for i := range response.Keys {
+			errs = append(errs, checkKeySchema(i, &response.Keys[i])...)
+		}
+
+		if len(errs) > 0 {
+			return nil, errs
+		}
+	}
+
+	if p.StrictBase64 {
+		var errs MemberErrors
+
+		for i := range response.Keys {
+			errs = append(errs, checkBase64Members(i, &response.Keys[i])...)
+		}
+
+		if len(errs) > 0 {
+			return nil, errs
+		}
+	}
+
+	if p.Policy != nil {
+		if err := response.Validate(p.Policy).Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return response, nil
+}
+
+// parseLenient decodes each key individually, dropping unusable keys into report
+func (p *Parser) parseLenient(data []byte, report *ParseReport) (*Response, error) {
+	rawResponse := struct {
+		Keys []json.RawMessage `json:"keys"`
+	}{}
+
+	if err := p.decode(data, &rawResponse); err != nil {
+		return nil, err
+	}
+
+	limits := limitsOrDefault(p.Limits)
+
+	if err := limits.checkKeyCount(len(rawResponse.Keys)); err != nil {
+		return nil, err
+	}
+
+	policy := p.Policy
+
+	if policy == nil {
+		// structural checks only
+		policy = &Policy{KeyOpsWarnOnly: true}
+	}
+
+	response := &Response{
+		Keys: make([]Key, 0, len(rawResponse.Keys)),
+	}
+
+	for i, raw := range rawResponse.Keys {
+		key := Key{}
+
+		skip := func(err error) {
+			report.Skipped = append(report.Skipped, &SkippedKey{
+				error:    err,
+				KeyIndex: i,
+				KeyId:    key.KeyId,
+				Raw:      raw,
+			})
+		}
+
+		if err := key.unmarshalJSON(raw, limits); err != nil {
+			// recover the kid for the report if possible
+			_ = json.Unmarshal(raw, &struct {
+				KeyId *string `json:"kid"`
+			}{&key.KeyId})
+
+			skip(err)
+			continue
+		}
+
+		if p.Tolerant {
+			report.Fixes = append(report.Fixes, normalizeKey(i, &key)...)
+		}
+
+		if p.DisallowUnknownFields {
+			if errs := checkKeySchema(i, &key); len(errs) > 0 {
+				skip(errs)
+				continue
+			}
+		}
+
+		if p.StrictBase64 {
+			if errs := checkBase64Members(i, &key); len(errs) > 0 {
+				skip(errs)
+				continue
+			}
+		}
+
+		if errs, _ := key.check(policy); len(errs) > 0 {
+			skip(&KeyValidationError{KeyId: key.KeyId, Errors: errs})
+			continue
+		}
+
+		response.Keys = append(response.Keys, key)
+	}
+
+	return response, nil
+}
+
+// decode unmarshals a document, honoring DisallowUnknownFields at the top level
+func (p *Parser) decode(data []byte, v interface{}) error {
+	if p.DisallowUnknownFields {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+
+		if err := decoder.Decode(&struct {
+			Keys json.RawMessage `json:"keys"`
+		}{}); err != nil {
+			return err
+		}
+
+		if _, err := decoder.Token(); err != io.EOF {
+			return errors.New(ErrorTrailingDataMsg)
+		}
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// keySchemaRequiredMembers lists the members each known kty requires
+var keySchemaRequiredMembers = map[string][]string{
+	KeyTypeRsa: {"n", "e"},
+	KeyTypeEc:  {"crv", "x", "y"},
+	KeyTypeOkp: {"crv", "x"},
+	KeyTypeOct: {"k"},
+}
+
+// checkKeySchema returns an error for every member of key that is missing, foreign to its kty or unknown
+func checkKeySchema(index int, key *Key) MemberErrors {
+	var errs MemberErrors
+
+	memberError := func(member, format string, args ...interface{}) {
+		errs = append(errs, &MemberError{
+			error:    fmt.Errorf(format, args...),
+			KeyIndex: index,
+			KeyId:    key.KeyId,
+			Member:   member,
+		})
+	}
+
+	required, ok := keySchemaRequiredMembers[key.KeyType]
+
+	if !ok {
+		memberError("kty", "unsupported key type %q", key.KeyType)
+		return errs
+	}
+
+	present, err := presentMembers(*key)
+
+	if err != nil {
+		memberError("", "%s", err)
+		return errs
+	}
+
+	for _, name := range required {
+		if !present[name] {
+			memberError(name, "required for kty %s", key.KeyType)
+		}
+	}
+
+	allowed, err := presentMembers(key.withoutForeignMembers())
+
+	if err != nil {
+		memberError("", "%s", err)
+		return errs
+	}
+
+	var foreign []string
+
+	for name := range present {
+		if !allowed[name] {
+			foreign = append(foreign, name)
+		}
+	}
+
+	for name := range key.AdditionalMembers {
+		foreign = append(foreign, name)
+	}
+
+	sort.Strings(foreign)
+
+	for _, name := range foreign {
+		if keyMemberNames[name] {
+			memberError(name, "not allowed for kty %s", key.KeyType)
+		} else {
+			memberError(name, "unknown member")
+		}
+	}
+
+	return errs
+}
+
+// presentMembers returns the names of the non-empty modeled members of key
+func presentMembers(key Key) (map[string]bool, error) {
+	data, err := json.Marshal(jsonKey(key))
+
+	if err != nil {
+		return nil, err
+	}
+
+	members := map[string]json.RawMessage{}
+
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, err
+	}
+
+	present := map[string]bool{}
+
+	for name := range members {
+		present[name] = true
+	}
+
+	return present, nil
+}
+
+// checkBase64Members returns an error for every binary member of key that is not strictly encoded
+func checkBase64Members(index int, key *Key) MemberErrors {
+	var errs MemberErrors
+
+	members := []struct {
+		name  string
+		value string
+	}{
+		{"n", key.N}, {"e", key.E}, {"x", key.X}, {"y", key.Y}, {"k", key.K},
+		{"d", key.D}, {"p", key.P}, {"q", key.Q}, {"dp", key.Dp}, {"dq", key.Dq}, {"qi", key.Qi},
+		{"x5t", key.X509Thumbprint}, {"x5t#S256", key.X509ThumbprintSha256},
+	}
+
+	for _, member := range members {
+		if member.value == "" {
+			continue
+		}
+
+		if _, err := base64.RawURLEncoding.Strict().DecodeString(member.value); err != nil {
+			errs = append(errs, &MemberError{
+				error:    fmt.Errorf("invalid unpadded base64url: %s", err),
+				KeyIndex: index,
+				KeyId:    key.KeyId,
+				Member:   member.name,
+			})
+		}
+	}
+
+	for i, encodedCert := range key.X509Chain {
+		if _, err := base64.StdEncoding.Strict().DecodeString(encodedCert); err != nil {
+			errs = append(errs, &MemberError{
+				error:    fmt.Errorf("invalid base64: %s", err),
+				KeyIndex: index,
+				KeyId:    key.KeyId,
+				Member:   fmt.Sprintf("x5c[%d]", i),
+			})
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,296 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+var testSingleJwk = `{"kty":"EC","crv":"P-256","kid":"single","x":"MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4","y":"4Etl6SRW2YiLUrN5vfvVHuhp7x8PxltmWWlbbM4IFyM"}`
+
+func Test_ParseKey(t *testing.T) {
+	t.Run("can parse a single JWK", func(t *testing.T) {
+		req := require.New(t)
+
+		key, err := ParseKey([]byte(testSingleJwk))
+		req.NoError(err)
+		req.Equal("single", key.KeyId)
+		req.Equal(KeyTypeEc, key.KeyType)
+	})
+
+	t.Run("can not parse a JWK Set", func(t *testing.T) {
+		req := require.New(t)
+
+		_, err := ParseKey([]byte(testJwksRfc7517Examples))
+		req.EqualError(err, ErrorNotSingleKeyMsg)
+	})
+
+	t.Run("can not parse an object without kty", func(t *testing.T) {
+		req := require.New(t)
+
+		_, err := ParseKey([]byte(`{"kid":"nope"}`))
+		req.EqualError(err, ErrorMissingKtyMsg)
+	})
+}
+
+func Test_ParseResponse(t *testing.T) {
+	t.Run("can parse a JWK Set", func(t *testing.T) {
+		req := require.New(t)
+
+		response, err := ParseResponse([]byte(testJwksRfc7517Examples))
+		req.NoError(err)
+		req.Len(response.Keys, 3)
+	})
+
+	t.Run("can wrap a single JWK", func(t *testing.T) {
+		req := require.New(t)
+
+		response, err := ParseResponse([]byte(testSingleJwk))
+		req.NoError(err)
+		req.Len(response.Keys, 1)
+		req.Equal("single", response.Keys[0].KeyId)
+	})
+
+	t.Run("can not parse invalid JSON", func(t *testing.T) {
+		req := require.New(t)
+
+		_, err := ParseResponse([]byte(`[]`))
+		req.Error(err)
+	})
+}
+
+func Test_Parser(t *testing.T) {
+	t.Run("the zero value parses like json.Unmarshal", func(t *testing.T) {
+		req := require.New(t)
+
+		parser := &Parser{}
+
+		response, err := parser.Parse([]byte(testJwksRfc7517Examples))
+		req.NoError(err)
+		req.Len(response.Keys, 3)
+
+		_, err = parser.Parse([]byte(`{"keys": invalid}`))
+		req.Error(err)
+	})
+
+	t.Run("applies the policy", func(t *testing.T) {
+		req := require.New(t)
+
+		parser := &Parser{Policy: DefaultPolicy()}
+
+		response, err := parser.Parse([]byte(testPublicJwksAuth0))
+		req.NoError(err)
+		req.Len(response.Keys, 2)
+
+		parser.Policy = &Policy{AllowedKeyTypes: []string{KeyTypeEc}}
+
+		response, err = parser.Parse([]byte(testPublicJwksAuth0))
+		req.Error(err)
+		req.Nil(response)
+	})
+}
+
+func Test_ParserStrictBase64(t *testing.T) {
+	t.Run("accepts well-formed documents", func(t *testing.T) {
+		req := require.New(t)
+
+		parser := &Parser{StrictBase64: true}
+
+		for _, doc := range []string{testPublicJwksAuth0, testJwksRfc7517Examples} {
+			_, err := parser.Parse([]byte(doc))
+			req.NoError(err)
+		}
+	})
+
+	t.Run("reports every malformed member", func(t *testing.T) {
+		req := require.New(t)
+
+		doc := strings.Replace(testJwksRfc7517Examples, `"e": "AQAB",
+      "alg"`, `"e": "AQAB==",
+      "alg"`, 1)
+		doc = strings.Replace(doc, `"x": "MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4"`, `"x": "MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4+/"`, 1)
+
+		_, err := (&Parser{}).Parse([]byte(doc))
+		req.NoError(err)
+
+		_, err = (&Parser{StrictBase64: true}).Parse([]byte(doc))
+		req.Error(err)
+
+		memberErrs, ok := err.(MemberErrors)
+		req.True(ok)
+		req.Len(memberErrs, 2)
+
+		req.Equal(0, memberErrs[0].KeyIndex)
+		req.Equal("1", memberErrs[0].KeyId)
+		req.Equal("x", memberErrs[0].Member)
+
+		req.Equal(1, memberErrs[1].KeyIndex)
+		req.Equal("2011-04-29", memberErrs[1].KeyId)
+		req.Equal("e", memberErrs[1].Member)
+	})
+
+	t.Run("rejects non-canonical trailing bits", func(t *testing.T) {
+		req := require.New(t)
+
+		doc := `{"keys": [{"kty": "oct", "k": "AB"}]}`
+
+		_, err := (&Parser{StrictBase64: true}).Parse([]byte(doc))
+		req.Error(err)
+	})
+
+	t.Run("rejects unpadded x5c entries", func(t *testing.T) {
+		req := require.New(t)
+
+		doc := strings.Replace(testPublicJwksAuth0, `gZpYTmc="`, `gZpYTmc"`, 1)
+
+		_, err := (&Parser{StrictBase64: true}).Parse([]byte(doc))
+		req.Error(err)
+		req.Equal("x5c[0]", err.(MemberErrors)[0].Member)
+	})
+}
+
+func Test_ParserLenient(t *testing.T) {
+	doc := `{
+  "keys": [
+    {"kty": "EC", "crv": "P-256", "kid": "good-ec",
+     "x": "MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4", "y": "4Etl6SRW2YiLUrN5vfvVHuhp7x8PxltmWWlbbM4IFyM"},
+    {"kty": "RSA", "kid": "wrong-type", "n": 12345, "e": "AQAB"},
+    {"kty": "EC", "crv": "P-256", "kid": "off-curve",
+     "x": "MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4", "y": "MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4"},
+    {"kty": "ML-DSA", "kid": "unsupported"},
+    {"kty": "OKP", "crv": "Ed25519", "kid": "good-ed", "x": "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"}
+  ]
+}`
+
+	t.Run("strict parsing fails the whole document", func(t *testing.T) {
+		req := require.New(t)
+
+		_, err := (&Parser{}).Parse([]byte(doc))
+		req.Error(err)
+	})
+
+	t.Run("lenient parsing skips unusable keys", func(t *testing.T) {
+		req := require.New(t)
+
+		response, report, err := (&Parser{Lenient: true}).ParseWithReport([]byte(doc))
+		req.NoError(err)
+
+		req.Len(response.Keys, 2)
+		req.Equal("good-ec", response.Keys[0].KeyId)
+		req.Equal("good-ed", response.Keys[1].KeyId)
+
+		req.Len(report.Skipped, 3)
+		req.Equal(1, report.Skipped[0].KeyIndex)
+		req.Equal("wrong-type", report.Skipped[0].KeyId)
+		req.Equal(2, report.Skipped[1].KeyIndex)
+		req.Equal("off-curve", report.Skipped[1].KeyId)
+		req.Equal(3, report.Skipped[2].KeyIndex)
+		req.Equal("unsupported", report.Skipped[2].KeyId)
+		req.Contains(string(report.Skipped[2].Raw), "ML-DSA")
+	})
+
+	t.Run("lenient parsing applies strict base64 and policy per key", func(t *testing.T) {
+		req := require.New(t)
+
+		parser := &Parser{
+			Lenient:      true,
+			StrictBase64: true,
+			Policy:       &Policy{AllowedKeyTypes: []string{KeyTypeRsa}},
+		}
+
+		response, report, err := parser.ParseWithReport([]byte(testJwksRfc7517Examples))
+		req.NoError(err)
+		req.Len(response.Keys, 2)
+		req.Len(report.Skipped, 1)
+		req.Equal("1", report.Skipped[0].KeyId)
+	})
+
+	t.Run("lenient parsing still fails on malformed documents", func(t *testing.T) {
+		req := require.New(t)
+
+		_, err := (&Parser{Lenient: true}).Parse([]byte(`{"keys": {}}`))
+		req.Error(err)
+	})
+}
+
+func Test_ParserDisallowUnknownFields(t *testing.T) {
+	t.Run("accepts well-formed documents", func(t *testing.T) {
+		req := require.New(t)
+
+		response, err := (&Parser{DisallowUnknownFields: true}).Parse([]byte(testJwksRfc7517Examples))
+		req.NoError(err)
+		req.Len(response.Keys, 3)
+	})
+
+	t.Run("rejects unknown top level members", func(t *testing.T) {
+		req := require.New(t)
+
+		_, err := (&Parser{DisallowUnknownFields: true}).Parse([]byte(`{"keys": [], "extra": true}`))
+		req.Error(err)
+
+		_, err = (&Parser{}).Parse([]byte(`{"keys": [], "extra": true}`))
+		req.NoError(err)
+	})
+
+	t.Run("rejects trailing data", func(t *testing.T) {
+		req := require.New(t)
+
+		_, err := (&Parser{DisallowUnknownFields: true}).Parse([]byte(`{"keys": []} {"keys": []}`))
+		req.EqualError(err, ErrorTrailingDataMsg)
+	})
+
+	t.Run("reports every schema violation", func(t *testing.T) {
+		req := require.New(t)
+
+		doc := `{"keys": [
+  {"kty": "EC", "crv": "P-256", "kid": "ec", "x": "MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4", "n": "AQAB", "x-vendor": 1},
+  {"kty": "ML-DSA", "kid": "unsupported"}
+]}`
+
+		_, err := (&Parser{DisallowUnknownFields: true}).Parse([]byte(doc))
+		req.Error(err)
+
+		var errs MemberErrors
+		req.ErrorAs(err, &errs)
+		req.Len(errs, 4)
+
+		req.Equal(0, errs[0].KeyIndex)
+		req.Equal("y", errs[0].Member)
+		req.Equal("n", errs[1].Member)
+		req.Equal("x-vendor", errs[2].Member)
+		req.Equal(1, errs[3].KeyIndex)
+		req.Equal("kty", errs[3].Member)
+	})
+
+	t.Run("lenient parsing skips keys failing the schema", func(t *testing.T) {
+		req := require.New(t)
+
+		doc := `{"keys": [
+  {"kty": "oct", "kid": "sym", "k": "AQAB", "e": "AQAB"},
+  {"kty": "oct", "kid": "ok", "k": "AQAB"}
+]}`
+
+		response, report, err := (&Parser{DisallowUnknownFields: true, Lenient: true}).ParseWithReport([]byte(doc))
+		req.NoError(err)
+		req.Len(response.Keys, 1)
+		req.Equal("ok", response.Keys[0].KeyId)
+		req.Len(report.Skipped, 1)
+		req.Equal("sym", report.Skipped[0].KeyId)
+	})
+}
@@ -17,15 +17,16 @@ limitations under the License.
 package jwks
 
 import (
-	"bytes"
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rsa"
 	"crypto/sha1"
 	sha2562 "crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
-	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"github.com/pkg/errors"
 	"math/big"
@@ -34,46 +35,110 @@ import (
 const (
 	KeyTypeRsa = "RSA"
 	KeyTypeEc  = "EC"
+	KeyTypeOkp = "OKP"
+	KeyTypeOct = "oct"
+
+	CurveEd25519 = "Ed25519"
+	CurveX25519  = "X25519"
+)
+
+// Key use and key_ops values, https://www.rfc-editor.org/rfc/rfc7517#section-4.2 and
+// https://www.rfc-editor.org/rfc/rfc7517#section-4.3
+const (
+	UseSignature  = "sig"
+	UseEncryption = "enc"
+
+	KeyOperationSign       = "sign"
+	KeyOperationVerify     = "verify"
+	KeyOperationEncrypt    = "encrypt"
+	KeyOperationDecrypt    = "decrypt"
+	KeyOperationWrapKey    = "wrapKey"
+	KeyOperationUnwrapKey  = "unwrapKey"
+	KeyOperationDeriveKey  = "deriveKey"
+	KeyOperationDeriveBits = "deriveBits"
+)
+
+// JWS algorithm names, https://www.rfc-editor.org/rfc/rfc7518#section-3.1 and
+// https://www.rfc-editor.org/rfc/rfc8037#section-3.1
+const (
+	AlgorithmRs256 = "RS256"
+	AlgorithmRs384 = "RS384"
+	AlgorithmRs512 = "RS512"
+	AlgorithmPs256 = "PS256"
+	AlgorithmPs384 = "PS384"
+	AlgorithmPs512 = "PS512"
+	AlgorithmEs256 = "ES256"
+	AlgorithmEs384 = "ES384"
+	AlgorithmEs512 = "ES512"
+	AlgorithmEdDsa = "EdDSA"
+	AlgorithmHs256 = "HS256"
+	AlgorithmHs384 = "HS384"
+	AlgorithmHs512 = "HS512"
+)
+
+// JWE key management algorithm names, https://www.rfc-editor.org/rfc/rfc7518#section-4.1
+const (
+	AlgorithmRsaOaep    = "RSA-OAEP"
+	AlgorithmRsaOaep256 = "RSA-OAEP-256"
+	AlgorithmEcdhEs     = "ECDH-ES"
+
+	AlgorithmPbes2Hs512A256Kw = "PBES2-HS512+A256KW"
+)
+
+// JWE content encryption algorithm names used with oct keys, https://www.rfc-editor.org/rfc/rfc7518#section-5.1
+const (
+	AlgorithmA128Gcm = "A128GCM"
+	AlgorithmA192Gcm = "A192GCM"
+	AlgorithmA256Gcm = "A256GCM"
 )
 
 // Key is used to parse the public keys ina JWKS endpoint.
 // All properties defined by https://www.rfc-editor.org/rfc/rfc7517#section-4.1 and
 // https://www.rfc-editor.org/rfc/rfc7518
 type Key struct {
-	Algorithm     string   `json:"alg"`     // https://www.rfc-editor.org/rfc/rfc7518#section-3.1
-	KeyType       string   `json:"kty"`     // RSA, EC
-	KeyOperations []string `json:"key_ops"` // sign, verify, encrypt, decrypt, wrapKey, unwrapKey, deriveKey, deriveBits
-	Use           string   `json:"use"`     // sig, enc
-	KeyId         string   `json:"kid"`     // a unique id for a key
+	Algorithm     string   `json:"alg,omitempty"`     // https://www.rfc-editor.org/rfc/rfc7518#section-3.1
+	KeyType       string   `json:"kty,omitempty"`     // RSA, EC, OKP
+	KeyOperations []string `json:"key_ops,omitempty"` // sign, verify, encrypt, decrypt, wrapKey, unwrapKey, deriveKey, deriveBits
+	Use           string   `json:"use,omitempty"`     // sig, enc
+	KeyId         string   `json:"kid,omitempty"`     // a unique id for a key
 
 	//x509
-	X509Thumbprint       string   `json:"x5t"`      //sha1 of der bytes
-	X509ThumbprintSha256 string   `json:"x5t#S256"` //sha256 of der bytes
-	X509Chain            []string `json:"x5c"`      // array of base64 certificate DER
-	X509Url              string   `json:"x5u"`      // URI pointing to an array of pem certs
+	X509Thumbprint       string   `json:"x5t,omitempty"`      //sha1 of der bytes
+	X509ThumbprintSha256 string   `json:"x5t#S256,omitempty"` //sha256 of der bytes
+	X509Chain            []string `json:"x5c,omitempty"`      // array of base64 certificate DER
+	X509Url              string   `json:"x5u,omitempty"`      // URI pointing to an array of pem certs
 
-	//public ec kty="ec"
-	Curve string `json:"crv"` //ec curve
-	X     string `json:"x"`   // ec x curve coordinate
-	Y     string `json:"y"`   // ec y curve coordinate
+	//public ec kty="ec", kty="okp"
+	Curve string `json:"crv,omitempty"` //ec curve, okp subtype
+	X     string `json:"x,omitempty"`   // ec x curve coordinate, okp public key
+	Y     string `json:"y,omitempty"`   // ec y curve coordinate
 
 	//public rsa kty="rsa"
-	N string `json:"n"` // rsa modulus
-	E string `json:"e"` // rsa public exponent
+	N string `json:"n,omitempty"` // rsa modulus
+	E string `json:"e,omitempty"` // rsa public exponent
 
 	//symmetric kty="oct"
-	K string `json:"k"` // symmetric key
+	K string `json:"k,omitempty"` // symmetric key
 
 	//private key properties
-	D  string `json:"d"`  // rsa private exponent / ec private key
-	P  string `json:"p"`  // rsa secret prime
-	Q  string `json:"q"`  // rsa secret prime
-	Dp string `json:"dp"` // rsa private key parameter
-	Dq string `json:"dq"` // rsa private key parameter
-	Qi string `json:"qi"` // rsa private key parameter
+	D  string `json:"d,omitempty"`  // rsa private exponent / ec private key
+	P  string `json:"p,omitempty"`  // rsa secret prime
+	Q  string `json:"q,omitempty"`  // rsa secret prime
+	Dp string `json:"dp,omitempty"` // rsa private key parameter
+	Dq string `json:"dq,omitempty"` // rsa private key parameter
+	Qi string `json:"qi,omitempty"` // rsa private key parameter
 
 	//byok
-	T string `json:"t"` //bring your own key property
+	T string `json:"t,omitempty"` //bring your own key property
+
+	//lifetime, optional and not registered by RFC 7517
+	ExpiresAt NumericDate `json:"exp,omitempty"` // key must not be used at or after this time
+	NotBefore NumericDate `json:"nbf,omitempty"` // key must not be used before this time
+	IssuedAt  NumericDate `json:"iat,omitempty"` // time the key was created
+
+	// AdditionalMembers holds any members not modeled above, such as vendor extensions, so that re-serialized keys
+	// are lossless
+	AdditionalMembers map[string]json.RawMessage `json:"-"`
 }
 
 // Response is used to parse a JWKS endpoint response, it contains zero or more Key instances
@@ -81,16 +146,23 @@ type Response struct {
 	Keys []Key `json:"keys"`
 }
 
-// NewKey will convert an *x509.Certificate to a Key. If keyId is empty string, the keyId will be populated
-// with the sha1 fingerprint/thumbprint of the certificate. Supports RSA and EC keys only.
-func NewKey(keyId string, cert *x509.Certificate, chain []*x509.Certificate) (*Key, error) {
+// NewKey will convert an *x509.Certificate to a Key. If keyId is empty string, the keyId will be populated with the
+// hex encoded sha1 fingerprint/thumbprint of the certificate, the RFC 7638 thumbprint of the key if WithThumbprintKid
+// is supplied, or the kid generated by WithKidStrategy. Supports RSA, EC, and Ed25519 keys only. The alg property is
+// inferred from the key type and size unless overridden with WithAlgorithm. The x5t and x5t#S256 members are the hex
+// encoded SHA-1 and SHA-256 thumbprints of the certificate.
+//
+// The public key members are encoded as RFC 7518 requires: e without leading zero octets (AQAB rather than the
+// AAEAAQ emitted by earlier versions) and EC x and y left padded to the full curve length. Keys emitted by earlier
+// versions still parse to the same public key, but their RFC 7638 thumbprints differ.
+func NewKey(keyId string, cert *x509.Certificate, chain []*x509.Certificate, opts ...KeyOption) (*Key, error) {
+	options := newKeyOptions(opts)
+
+	// x5t and x5t#S256 keep the hex encoding NewKey has always produced, so existing keys and their consumers are not
+	// affected. VerifyX5cThumbprints accepts both this and the RFC 7517 base64url encoding.
 	sha1print := fmt.Sprintf("%x", sha1.Sum(cert.Raw))
 	sha256print := fmt.Sprintf("%x", sha2562.Sum256(cert.Raw))
 
-	if keyId == "" {
-		keyId = sha1print
-	}
-
 	ret := Key{
 		Algorithm:            "",
 		KeyType:              "",
@@ -128,92 +200,258 @@ func NewKey(keyId string, cert *x509.Certificate, chain []*x509.Certificate) (*K
 		}
 	}
 
-	if rsaPubKey, ok := cert.PublicKey.(*rsa.PublicKey); ok {
-		ret.KeyType = KeyTypeRsa
-		ret.N = base64.RawURLEncoding.EncodeToString(rsaPubKey.N.Bytes())
+	if err := ret.setPublicKey(cert.PublicKey); err != nil {
+		return nil, err
+	}
+
+	if err := options.assignKid(&ret, cert); err != nil {
+		return nil, err
+	}
 
-		buf := new(bytes.Buffer)
-		err := binary.Write(buf, binary.BigEndian, int32(rsaPubKey.E))
+	if err := options.apply(&ret); err != nil {
+		return nil, err
+	}
 
-		if err != nil {
-			return nil, fmt.Errorf("error encoding RSA exponent: %s", err)
-		}
+	return &ret, nil
+}
 
-		ret.E = base64.RawURLEncoding.EncodeToString(buf.Bytes())
+// NewKeyFromPublicKey will convert an RSA, EC, or Ed25519 public key to a Key. As there is no certificate to
+// fingerprint, if keyId is empty string the keyId will be populated with the RFC 7638 SHA-256 thumbprint of the key.
+func NewKeyFromPublicKey(keyId string, publicKey crypto.PublicKey, opts ...KeyOption) (*Key, error) {
+	options := newKeyOptions(opts)
 
-	} else if ecPubKey, ok := cert.PublicKey.(*ecdsa.PublicKey); ok {
-		ret.KeyType = KeyTypeEc
+	ret := Key{
+		KeyOperations: []string{"sign", "verify"},
+		Use:           "sig",
+		KeyId:         keyId,
+	}
 
-		ret.Curve = ecPubKey.Curve.Params().Name
-		ret.X = base64.RawURLEncoding.EncodeToString(ecPubKey.X.Bytes())
-		ret.Y = base64.RawURLEncoding.EncodeToString(ecPubKey.Y.Bytes())
+	if err := ret.setPublicKey(publicKey); err != nil {
+		return nil, err
+	}
 
-	} else {
-		return nil, errors.New("invalid public key type, expected EC or RSA public key")
+	if err := options.assignKid(&ret, nil); err != nil {
+		return nil, err
+	}
+
+	if err := options.apply(&ret); err != nil {
+		return nil, err
 	}
 
 	return &ret, nil
 }
 
+// setPublicKey populates the kty and public key members of the key from an RSA, EC, or Ed25519 public key. Exponents
+// and coordinates are encoded as required by RFC 7518: minimal octets for e and full curve length for x and y.
+func (k *Key) setPublicKey(publicKey crypto.PublicKey) error {
+	if rsaPubKey, ok := publicKey.(*rsa.PublicKey); ok {
+		k.KeyType = KeyTypeRsa
+		k.N = encodeBigInt(rsaPubKey.N, 0)
+		k.E = encodeExponent(rsaPubKey.E)
+
+	} else if ecPubKey, ok := publicKey.(*ecdsa.PublicKey); ok {
+		k.KeyType = KeyTypeEc
+
+		byteLen := (ecPubKey.Curve.Params().BitSize + 7) / 8
+
+		k.Curve = ecPubKey.Curve.Params().Name
+		k.X = encodeBigInt(ecPubKey.X, byteLen)
+		k.Y = encodeBigInt(ecPubKey.Y, byteLen)
+
+	} else if edPubKey, ok := publicKey.(ed25519.PublicKey); ok {
+		k.KeyType = KeyTypeOkp
+
+		k.Curve = CurveEd25519
+		k.X = encodeBase64(edPubKey)
+
+	} else {
+		return errors.New("invalid public key type, expected EC, RSA, or Ed25519 public key")
+	}
+
+	return nil
+}
+
+// InferAlgorithm returns the JWS alg that is conventionally used with the supplied key based on its key type and
+// size: RS256 for RSA, ES256/ES384/ES512 for the P-256/P-384/P-521 curves, and EdDSA for Ed25519. An empty string
+// is returned if no algorithm can be inferred.
+func InferAlgorithm(key *Key) string {
+	switch key.KeyType {
+	case KeyTypeRsa:
+		return AlgorithmRs256
+	case KeyTypeEc:
+		switch key.Curve {
+		case elliptic.P256().Params().Name:
+			return AlgorithmEs256
+		case elliptic.P384().Params().Name:
+			return AlgorithmEs384
+		case elliptic.P521().Params().Name:
+			return AlgorithmEs512
+		}
+	case KeyTypeOkp:
+		switch key.Curve {
+		case CurveEd25519:
+			return AlgorithmEdDsa
+		case CurveX25519:
+			return AlgorithmEcdhEs
+		}
+	case KeyTypeOct:
+		// HMAC keys should be at least as long as the hash output, https://www.rfc-editor.org/rfc/rfc7518#section-3.2
+		if k, err := base64.RawURLEncoding.DecodeString(key.K); err == nil {
+			return inferOctAlgorithm(len(k))
+		}
+	}
+	return ""
+}
+
+// inferOctAlgorithm returns the strongest HMAC algorithm for a symmetric key of size bytes
+func inferOctAlgorithm(size int) string {
+	switch {
+	case size >= 64:
+		return AlgorithmHs512
+	case size >= 48:
+		return AlgorithmHs384
+	case size >= 32:
+		return AlgorithmHs256
+	}
+	return ""
+}
+
 // KeyToPublicKey converts the JSON marshalled Key to an interface{} object which represents a
 // public key that may be backed by rsa.PublicKey or ecdsa.Public key depending on the input
 // key's KeyType.
 func KeyToPublicKey(key Key) (interface{}, error) {
 	switch key.KeyType {
 	case KeyTypeRsa:
-		nBytes, err := base64.RawURLEncoding.DecodeString(key.N)
+		n, err := decodeBigInt("N", key.N)
 
 		if err != nil {
-			return nil, fmt.Errorf("error base64 decoding key's N: %s: %s", key.N, err)
+			return nil, err
 		}
-		n := &big.Int{}
-		n.SetBytes(nBytes)
 
-		eBytes, err := base64.RawURLEncoding.DecodeString(key.E)
+		e, err := decodeExponent(key.E)
 
 		if err != nil {
-			return nil, fmt.Errorf("error base64 decoding key's E: %s: %s", key.E, err)
+			return nil, err
 		}
-		e := &big.Int{}
-		e.SetBytes(eBytes)
 
 		rsaPubKey := &rsa.PublicKey{
 			N: n,
-			E: int(e.Int64()),
+			E: e,
 		}
 
 		return rsaPubKey, nil
 	case KeyTypeEc:
-		xBytes, err := base64.RawURLEncoding.DecodeString(key.X)
+		curve := curveFromName(key.Curve)
 
-		if err != nil {
-			return nil, fmt.Errorf("error base64 decoding key's X: %s: %s", key.X, err)
+		if curve == nil {
+			return nil, fmt.Errorf("unsupported EC curve: %s", key.Curve)
 		}
 
-		x := &big.Int{}
-		x.SetBytes(xBytes)
+		// coordinates are at most 66 bytes (P-521), decode them into scratch space on the stack
+		var scratch [maxEcCoordinateSize]byte
 
-		yBytes, err := base64.RawURLEncoding.DecodeString(key.Y)
+		x, err := decodeEcCoordinate("X", key.X, scratch[:])
 
 		if err != nil {
-			return nil, fmt.Errorf("error base64 decoding key's Y: %s: %s", key.Y, err)
+			return nil, err
 		}
 
-		y := &big.Int{}
-		y.SetBytes(yBytes)
+		y, err := decodeEcCoordinate("Y", key.Y, scratch[:])
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("EC point is not on curve %s", key.Curve)
+		}
 
 		ecPubKey := &ecdsa.PublicKey{
-			Curve: curveFromName(key.Curve),
+			Curve: curve,
 			X:     x,
 			Y:     y,
 		}
 
 		return ecPubKey, nil
+	case KeyTypeOkp:
+		if key.Curve != CurveEd25519 {
+			return nil, fmt.Errorf("unsupported OKP curve: %s", key.Curve)
+		}
+
+		xBytes, err := base64.RawURLEncoding.DecodeString(key.X)
+
+		if err != nil {
+			return nil, fmt.Errorf("error base64 decoding key's X: %s: %s", key.X, err)
+		}
+
+		if len(xBytes) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 public key length: %d", len(xBytes))
+		}
+
+		return ed25519.PublicKey(xBytes), nil
 	default:
 		return nil, fmt.Errorf("unsuportted key type: %s", key.KeyType)
 	}
 }
 
+// maxEcCoordinateSize is the size of a P-521 coordinate in bytes, the largest supported curve
+const maxEcCoordinateSize = 66
+
+// decodeEcCoordinate decodes the base64url EC coordinate named name using scratch, which must hold
+// maxEcCoordinateSize bytes, so only the big.Int is allocated
+func decodeEcCoordinate(name, value string, scratch []byte) (*big.Int, error) {
+	if base64.RawURLEncoding.DecodedLen(len(value)) > len(scratch) {
+		return nil, fmt.Errorf("key's %s is longer than %d bytes", name, len(scratch))
+	}
+
+	n, err := base64.RawURLEncoding.Decode(scratch, []byte(value))
+
+	if err != nil {
+		return nil, fmt.Errorf("error base64 decoding key's %s: %s: %s", name, value, err)
+	}
+
+	return new(big.Int).SetBytes(scratch[:n]), nil
+}
+
+// commonExponents holds the decoded values of the RSA exponents used in practice, so they are not decoded for every
+// conversion
+var commonExponents = map[string]int{
+	"AQAB": 65537,
+	"Aw":   3,
+	"EQ":   17,
+}
+
+// decodeExponent decodes a base64url RSA exponent, which must fit in 31 bits
+func decodeExponent(value string) (int, error) {
+	if e, ok := commonExponents[value]; ok {
+		return e, nil
+	}
+
+	var scratch [8]byte
+
+	if base64.RawURLEncoding.DecodedLen(len(value)) > len(scratch) {
+		return 0, fmt.Errorf("RSA exponent is too large: %s", value)
+	}
+
+	n, err := base64.RawURLEncoding.Decode(scratch[:], []byte(value))
+
+	if err != nil {
+		return 0, fmt.Errorf("error base64 decoding key's E: %s: %s", value, err)
+	}
+
+	e := uint64(0)
+
+	for _, b := range scratch[:n] {
+		e = e<<8 | uint64(b)
+	}
+
+	if e > 1<<31-1 {
+		return 0, fmt.Errorf("RSA exponent is too large: %s", value)
+	}
+
+	return int(e), nil
+}
+
 // curveFromName returns the elliptic.Curve implementation based on the input curve name. If the curve name is unknown
 // nil is returned.
 func curveFromName(curveName string) elliptic.Curve {
@@ -16,13 +16,18 @@ limitations under the License.
 package jwks
 
 import (
+	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"github.com/Jeffail/gabs/v2"
 	"github.com/stretchr/testify/require"
@@ -243,6 +248,11 @@ func Test_NewKey(t *testing.T) {
 
 		req.True(origPubKey.Equal(endKey), "expected the original public key and re-constituted key to be equal")
 
+		t.Run("infers RS256 as the alg", func(t *testing.T) {
+			req := require.New(t)
+			req.Equal(AlgorithmRs256, key.Algorithm)
+		})
+
 		t.Run("rsa x5c chains uses base64 standard encoding", func(t *testing.T) {
 			req := require.New(t)
 
@@ -260,6 +270,68 @@ func Test_NewKey(t *testing.T) {
 		})
 	})
 
+	t.Run("keeps hex x5t members and a hex SHA-1 kid", func(t *testing.T) {
+		req := require.New(t)
+
+		rsaCert, _, err := newRsaCert()
+		req.NoError(err)
+
+		key, err := NewKey("", rsaCert, []*x509.Certificate{rsaCert})
+		req.NoError(err)
+
+		sha1Sum := sha1.Sum(rsaCert.Raw)
+		sha256Sum := sha256.Sum256(rsaCert.Raw)
+
+		req.Equal(hex.EncodeToString(sha1Sum[:]), key.X509Thumbprint)
+		req.Equal(hex.EncodeToString(sha256Sum[:]), key.X509ThumbprintSha256)
+		req.Equal(hex.EncodeToString(sha1Sum[:]), key.KeyId)
+		req.True((&Response{Keys: []Key{*key}}).Validate(nil).IsValid())
+	})
+
+	t.Run("encodes e minimally and EC coordinates at the full curve length", func(t *testing.T) {
+		req := require.New(t)
+
+		rsaCert, _, err := newRsaCert()
+		req.NoError(err)
+
+		key, err := NewKey("", rsaCert, nil)
+		req.NoError(err)
+		req.Equal("AQAB", key.E)
+
+		legacy := *key
+		legacy.E = "AAEAAQ"
+
+		legacyPubKey, err := KeyToPublicKey(legacy)
+		req.NoError(err)
+		req.True(rsaCert.PublicKey.(*rsa.PublicKey).Equal(legacyPubKey))
+
+		thumbprint, err := key.Thumbprint(crypto.SHA256)
+		req.NoError(err)
+
+		legacyThumbprint, err := legacy.Thumbprint(crypto.SHA256)
+		req.NoError(err)
+		req.NotEqual(thumbprint, legacyThumbprint)
+
+		var ecKey *ecdsa.PrivateKey
+
+		for ecKey == nil || ecKey.X.BitLen() > 248 {
+			ecKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+			req.NoError(err)
+		}
+
+		key, err = NewKeyFromPublicKey("", &ecKey.PublicKey)
+		req.NoError(err)
+		req.Equal(base64.RawURLEncoding.EncodeToString(ecKey.X.FillBytes(make([]byte, 32))), key.X)
+
+		legacy = *key
+		legacy.X = base64.RawURLEncoding.EncodeToString(ecKey.X.Bytes())
+		req.NotEqual(key.X, legacy.X)
+
+		legacyPubKey, err = KeyToPublicKey(legacy)
+		req.NoError(err)
+		req.True(ecKey.PublicKey.Equal(legacyPubKey))
+	})
+
 	t.Run("can create a key from an EC certificate", func(t *testing.T) {
 		req := require.New(t)
 
@@ -280,6 +352,11 @@ func Test_NewKey(t *testing.T) {
 
 		req.True(origPubKey.Equal(endKey), "expected the original public key and re-constituted key to be equal")
 
+		t.Run("infers ES256 as the alg", func(t *testing.T) {
+			req := require.New(t)
+			req.Equal(AlgorithmEs256, key.Algorithm)
+		})
+
 		t.Run("ec x5c chains uses base64 standard encoding", func(t *testing.T) {
 			req := require.New(t)
 
@@ -297,6 +374,47 @@ func Test_NewKey(t *testing.T) {
 		})
 	})
 
+	t.Run("can create a key from an Ed25519 certificate", func(t *testing.T) {
+		req := require.New(t)
+
+		edCert, _, err := newEd25519Cert()
+		req.NoError(err)
+		req.NotNil(edCert)
+
+		key, err := NewKey("testEdKid", edCert, []*x509.Certificate{edCert})
+		req.NoError(err)
+		req.NotNil(key)
+		req.Equal(KeyTypeOkp, key.KeyType)
+		req.Equal(CurveEd25519, key.Curve)
+		req.Equal(AlgorithmEdDsa, key.Algorithm)
+
+		endKey, err := KeyToPublicKey(*key)
+		req.NoError(err)
+
+		origPubKey, ok := edCert.PublicKey.(ed25519.PublicKey)
+		req.True(ok)
+
+		req.True(origPubKey.Equal(endKey), "expected the original public key and re-constituted key to be equal")
+	})
+
+	t.Run("can override the inferred alg", func(t *testing.T) {
+		req := require.New(t)
+
+		rsaCert, _, err := newRsaCert()
+		req.NoError(err)
+
+		key, err := NewKey("testRsaKid", rsaCert, nil, WithAlgorithm("PS256"))
+		req.NoError(err)
+		req.Equal("PS256", key.Algorithm)
+	})
+
+	t.Run("infers ES384 and ES512 from the curve", func(t *testing.T) {
+		req := require.New(t)
+
+		req.Equal(AlgorithmEs384, InferAlgorithm(&Key{KeyType: KeyTypeEc, Curve: "P-384"}))
+		req.Equal(AlgorithmEs512, InferAlgorithm(&Key{KeyType: KeyTypeEc, Curve: "P-521"}))
+		req.Equal("", InferAlgorithm(&Key{KeyType: KeyTypeEc, Curve: "P-224"}))
+	})
 }
 
 func newRsaCert() (*x509.Certificate, *rsa.PrivateKey, error) {
@@ -370,3 +488,107 @@ func newEcCert() (*x509.Certificate, *ecdsa.PrivateKey, error) {
 
 	return cert, privateKey, nil
 }
+
+func newEd25519Cert() (*x509.Certificate, ed25519.PrivateKey, error) {
+	// Generate Ed25519 private key
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Certificate template
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			CommonName:   "TEST Ed25519 Certificate",
+			Organization: []string{"TEST"},
+		},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+
+	// Create a self-signed certificate
+	certBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, publicKey, privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Parse the certificate bytes into a x509.Certificate
+	cert, err := x509.ParseCertificate(certBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cert, privateKey, nil
+}
+
+func BenchmarkNewKeyFromPublicKey(b *testing.B) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for name, publicKey := range map[string]interface{}{"RSA": &rsaKey.PublicKey, "EC": &ecKey.PublicKey} {
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := NewKeyFromPublicKey("kid", publicKey); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func Test_decodeExponent(t *testing.T) {
+	t.Run("can decode common and uncommon exponents", func(t *testing.T) {
+		req := require.New(t)
+
+		for encoded, expected := range map[string]int{"AQAB": 65537, "Aw": 3, "EQ": 17, "AAEAAQ": 65537, "f____w": 1<<31 - 1} {
+			e, err := decodeExponent(encoded)
+			req.NoError(err, encoded)
+			req.Equal(expected, e, encoded)
+		}
+	})
+
+	t.Run("rejects exponents that are too large or malformed", func(t *testing.T) {
+		req := require.New(t)
+
+		for _, encoded := range []string{"gAAAAA", "AQAAAAAAAAAAAA", "!"} {
+			_, err := decodeExponent(encoded)
+			req.Error(err, encoded)
+		}
+	})
+}
+
+func Test_decodeEcCoordinate(t *testing.T) {
+	t.Run("can decode coordinates up to the P-521 size", func(t *testing.T) {
+		req := require.New(t)
+
+		var scratch [maxEcCoordinateSize]byte
+
+		raw := make([]byte, maxEcCoordinateSize)
+		raw[0], raw[maxEcCoordinateSize-1] = 1, 2
+
+		x, err := decodeEcCoordinate("X", base64.RawURLEncoding.EncodeToString(raw), scratch[:])
+		req.NoError(err)
+		req.Equal(new(big.Int).SetBytes(raw), x)
+
+		_, err = decodeEcCoordinate("X", base64.RawURLEncoding.EncodeToString(append(raw, 0)), scratch[:])
+		req.Error(err)
+
+		_, err = decodeEcCoordinate("X", "!", scratch[:])
+		req.Error(err)
+	})
+}
@@ -0,0 +1,277 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"github.com/pkg/errors"
+)
+
+const (
+	PemTypePublicKey           = "PUBLIC KEY"
+	PemTypePrivateKey          = "PRIVATE KEY"
+	PemTypeEncryptedPrivateKey = "ENCRYPTED PRIVATE KEY"
+	PemTypeRsaPublicKey        = "RSA PUBLIC KEY"
+	PemTypeRsaPrivateKey       = "RSA PRIVATE KEY"
+	PemTypeEcPrivateKey        = "EC PRIVATE KEY"
+	PemTypeEcParameters        = "EC PARAMETERS"
+)
+
+// PublicPEM returns the public key of the Key encoded as a PKIX SubjectPublicKeyInfo PEM block. Supports RSA, EC,
+// and Ed25519 keys.
+func (k *Key) PublicPEM() ([]byte, error) {
+	der, err := k.pkix()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  PemTypePublicKey,
+		Bytes: der,
+	}), nil
+}
+
+// PublicPEM returns the public keys of all Keys in the Response as concatenated PKIX SubjectPublicKeyInfo PEM blocks
+// in the order they appear. An error is returned if any key can not be encoded.
+func (r *Response) PublicPEM() ([]byte, error) {
+	var ret []byte
+
+	for i := range r.Keys {
+		keyPem, err := r.Keys[i].PublicPEM()
+
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, keyPem...)
+	}
+
+	return ret, nil
+}
+
+// SPKIHash returns the base64 SHA-256 hash of the key's PKIX SubjectPublicKeyInfo DER encoding, the pin-sha256 format
+// used for TLS public key pinning, https://www.rfc-editor.org/rfc/rfc7469#section-2.4
+func (k *Key) SPKIHash() (string, error) {
+	der, err := k.pkix()
+
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(der)
+
+	return base64.StdEncoding.EncodeToString(sum[:]), nil
+}
+
+// pkix returns the public key of the Key as PKIX SubjectPublicKeyInfo DER
+func (k *Key) pkix() ([]byte, error) {
+	pubKey, err := KeyToPublicKey(*k)
+
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(pubKey)
+
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling public key %s: %s", k.KeyId, err)
+	}
+
+	return der, nil
+}
+
+// PrivatePEM returns the private key of the Key encoded as an unencrypted PKCS#8 PEM block. Supports RSA, EC, and
+// Ed25519 keys.
+func (k *Key) PrivatePEM() ([]byte, error) {
+	der, err := k.pkcs8()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  PemTypePrivateKey,
+		Bytes: der,
+	}), nil
+}
+
+// EncryptedPrivatePEM returns the private key of the Key encoded as a PKCS#8 PEM block encrypted with passphrase
+// using PBES2 (PBKDF2-HMAC-SHA256, AES-256-CBC).
+func (k *Key) EncryptedPrivatePEM(passphrase []byte) ([]byte, error) {
+	if len(passphrase) == 0 {
+		return nil, errors.New("passphrase must not be empty")
+	}
+
+	der, err := k.pkcs8()
+
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := encryptPkcs8(der, passphrase)
+
+	if err != nil {
+		return nil, fmt.Errorf("error encrypting private key %s: %s", k.KeyId, err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  PemTypeEncryptedPrivateKey,
+		Bytes: encrypted,
+	}), nil
+}
+
+func (k *Key) pkcs8() ([]byte, error) {
+	privKey, err := KeyToPrivateKey(*k)
+
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(privKey)
+
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling private key %s: %s", k.KeyId, err)
+	}
+
+	return der, nil
+}
+
+// ParsePEMKey parses the first key PEM block in data and returns it as a Key. PKIX and PKCS#1 public keys as well as
+// PKCS#1, PKCS#8, encrypted PKCS#8 (see WithPassphrase), and SEC 1 EC private keys are supported. Private keys result
+// in a Key containing both public and private members. If no kid is supplied via WithKeyId the RFC 7638 thumbprint
+// is used.
+func ParsePEMKey(data []byte, opts ...KeyOption) (*Key, error) {
+	keys, err := parsePemKeys(data, true, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no key PEM block found")
+	}
+
+	return &keys[0], nil
+}
+
+// ParsePEMBundle parses every key PEM block in data, as ParsePEMKey does, and returns them in the order they appear.
+// EC PARAMETERS blocks are skipped, any other unsupported block type results in an error.
+func ParsePEMBundle(data []byte, opts ...KeyOption) ([]Key, error) {
+	return parsePemKeys(data, false, opts)
+}
+
+func parsePemKeys(data []byte, firstOnly bool, opts []KeyOption) ([]Key, error) {
+	options := newKeyOptions(opts)
+
+	var keys []Key
+
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type == PemTypeEcParameters {
+			continue
+		}
+
+		key, err := newKeyFromPemBlock(block, options, opts)
+
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, *key)
+
+		if firstOnly {
+			break
+		}
+	}
+
+	return keys, nil
+}
+
+func newKeyFromPemBlock(block *pem.Block, options *keyOptions, opts []KeyOption) (*Key, error) {
+	var pubKey interface{}
+	var privKey interface{}
+	var err error
+
+	switch block.Type {
+	case PemTypePublicKey:
+		pubKey, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case PemTypeRsaPublicKey:
+		pubKey, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	case PemTypeRsaPrivateKey:
+		privKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case PemTypeEcPrivateKey:
+		privKey, err = x509.ParseECPrivateKey(block.Bytes)
+	case PemTypePrivateKey:
+		privKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case PemTypeEncryptedPrivateKey:
+		if len(options.passphrase) == 0 {
+			return nil, errors.New("encrypted private key requires a passphrase")
+		}
+
+		var der []byte
+		der, err = decryptPkcs8(block.Bytes, options.passphrase)
+
+		if err == nil {
+			privKey, err = x509.ParsePKCS8PrivateKey(der)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type: %s", block.Type)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s PEM block: %s", block.Type, err)
+	}
+
+	if privKey != nil {
+		return NewKeyFromPrivateKey(options.keyId, privKey, opts...)
+	}
+
+	return NewKeyFromPublicKey(options.keyId, pubKey, opts...)
+}
+
+// NewKeyFromDER parses raw DER encoded key bytes, such as those received from HSMs or wire protocols, and returns them
+// as a Key. PKIX SubjectPublicKeyInfo and PKCS#8 PrivateKeyInfo are supported as well as PKCS#1 and SEC 1 keys. If no
+// kid is supplied via WithKeyId the RFC 7638 thumbprint is used.
+func NewKeyFromDER(der []byte, opts ...KeyOption) (*Key, error) {
+	options := newKeyOptions(opts)
+
+	if pubKey, err := x509.ParsePKIXPublicKey(der); err == nil {
+		return NewKeyFromPublicKey(options.keyId, pubKey, opts...)
+	}
+
+	if privKey, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return NewKeyFromPrivateKey(options.keyId, privKey, opts...)
+	}
+
+	if pubKey, err := x509.ParsePKCS1PublicKey(der); err == nil {
+		return NewKeyFromPublicKey(options.keyId, pubKey, opts...)
+	}
+
+	if privKey, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return NewKeyFromPrivateKey(options.keyId, privKey, opts...)
+	}
+
+	if privKey, err := x509.ParseECPrivateKey(der); err == nil {
+		return NewKeyFromPrivateKey(options.keyId, privKey, opts...)
+	}
+
+	return nil, errors.New("unsupported DER key encoding, expected PKIX, PKCS#8, PKCS#1, or SEC 1")
+}
@@ -0,0 +1,427 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_PublicPEM(t *testing.T) {
+	t.Run("can export RSA, EC, and Ed25519 keys", func(t *testing.T) {
+		req := require.New(t)
+
+		rsaCert, _, err := newRsaCert()
+		req.NoError(err)
+
+		ecCert, _, err := newEcCert()
+		req.NoError(err)
+
+		edCert, _, err := newEd25519Cert()
+		req.NoError(err)
+
+		for _, cert := range []*x509.Certificate{rsaCert, ecCert, edCert} {
+			key, err := NewKey("", cert, nil)
+			req.NoError(err)
+
+			keyPem, err := key.PublicPEM()
+			req.NoError(err)
+
+			block, rest := pem.Decode(keyPem)
+			req.NotNil(block)
+			req.Empty(rest)
+			req.Equal(PemTypePublicKey, block.Type)
+			req.Equal(cert.RawSubjectPublicKeyInfo, block.Bytes)
+		}
+	})
+
+	t.Run("can export an entire response", func(t *testing.T) {
+		req := require.New(t)
+		response := &Response{}
+
+		err := json.Unmarshal([]byte(testPublicJwksAuth0), response)
+		req.NoError(err)
+
+		setPem, err := response.PublicPEM()
+		req.NoError(err)
+
+		count := 0
+		for block, rest := pem.Decode(setPem); block != nil; block, rest = pem.Decode(rest) {
+			_, err := x509.ParsePKIXPublicKey(block.Bytes)
+			req.NoError(err)
+			count++
+		}
+
+		req.Equal(len(response.Keys), count)
+	})
+
+	t.Run("errors for unsupported key types", func(t *testing.T) {
+		req := require.New(t)
+
+		key := &Key{KeyType: KeyTypeOct, K: "AAAA"}
+
+		_, err := key.PublicPEM()
+		req.Error(err)
+	})
+}
+
+func Test_SPKIHash(t *testing.T) {
+	t.Run("matches the certificate SubjectPublicKeyInfo", func(t *testing.T) {
+		req := require.New(t)
+
+		rsaCert, _, err := newRsaCert()
+		req.NoError(err)
+
+		ecCert, _, err := newEcCert()
+		req.NoError(err)
+
+		ed25519Cert, _, err := newEd25519Cert()
+		req.NoError(err)
+
+		for _, cert := range []*x509.Certificate{rsaCert, ecCert, ed25519Cert} {
+			key, err := NewKey("", cert, nil)
+			req.NoError(err)
+
+			pin, err := key.SPKIHash()
+			req.NoError(err)
+
+			sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+			req.Equal(base64.StdEncoding.EncodeToString(sum[:]), pin)
+		}
+	})
+
+	t.Run("can not hash an oct key", func(t *testing.T) {
+		req := require.New(t)
+
+		_, err := (&Key{KeyType: KeyTypeOct, K: "AQAB"}).SPKIHash()
+		req.Error(err)
+	})
+}
+
+func Test_PrivatePEM(t *testing.T) {
+	t.Run("can export RSA, EC, and Ed25519 keys", func(t *testing.T) {
+		req := require.New(t)
+
+		_, rsaPrivKey, err := newRsaCert()
+		req.NoError(err)
+
+		_, ecPrivKey, err := newEcCert()
+		req.NoError(err)
+
+		_, edPrivKey, err := newEd25519Cert()
+		req.NoError(err)
+
+		for _, privKey := range []interface {
+			Equal(x crypto.PrivateKey) bool
+		}{rsaPrivKey, ecPrivKey, edPrivKey} {
+			key, err := NewKeyFromPrivateKey("", privKey)
+			req.NoError(err)
+
+			keyPem, err := key.PrivatePEM()
+			req.NoError(err)
+
+			block, _ := pem.Decode(keyPem)
+			req.NotNil(block)
+			req.Equal(PemTypePrivateKey, block.Type)
+
+			parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			req.NoError(err)
+			req.True(privKey.Equal(parsed))
+		}
+	})
+
+	t.Run("can export an encrypted key", func(t *testing.T) {
+		req := require.New(t)
+
+		_, ecPrivKey, err := newEcCert()
+		req.NoError(err)
+
+		key, err := NewKeyFromPrivateKey("", ecPrivKey)
+		req.NoError(err)
+
+		keyPem, err := key.EncryptedPrivatePEM([]byte("correct horse"))
+		req.NoError(err)
+
+		block, _ := pem.Decode(keyPem)
+		req.NotNil(block)
+		req.Equal(PemTypeEncryptedPrivateKey, block.Type)
+
+		t.Run("which decrypts with the passphrase", func(t *testing.T) {
+			req := require.New(t)
+
+			der, err := decryptPkcs8(block.Bytes, []byte("correct horse"))
+			req.NoError(err)
+
+			parsed, err := x509.ParsePKCS8PrivateKey(der)
+			req.NoError(err)
+			req.True(ecPrivKey.Equal(parsed))
+		})
+
+		t.Run("which does not decrypt with the wrong passphrase", func(t *testing.T) {
+			req := require.New(t)
+
+			der, err := decryptPkcs8(block.Bytes, []byte("battery staple"))
+
+			if err == nil {
+				_, err = x509.ParsePKCS8PrivateKey(der)
+			}
+
+			req.Error(err)
+		})
+
+		t.Run("which is rejected with an out of range iteration count", func(t *testing.T) {
+			req := require.New(t)
+
+			for _, iterations := range []int{0, -1, Pkcs8MaxPbkdf2Iterations + 1, 1<<31 - 1} {
+				der := withPbkdf2Iterations(req, block.Bytes, iterations)
+
+				_, err := decryptPkcs8(der, []byte("correct horse"))
+				req.EqualError(err, ErrorPkcs8IterationCountMsg, iterations)
+
+				_, err = ParsePEMKey(pem.EncodeToMemory(&pem.Block{Type: PemTypeEncryptedPrivateKey, Bytes: der}), WithPassphrase([]byte("correct horse")))
+				req.Error(err)
+			}
+		})
+	})
+
+	t.Run("errors for public keys", func(t *testing.T) {
+		req := require.New(t)
+
+		rsaCert, _, err := newRsaCert()
+		req.NoError(err)
+
+		key, err := NewKey("", rsaCert, nil)
+		req.NoError(err)
+
+		_, err = key.PrivatePEM()
+		req.Error(err)
+	})
+}
+
+func Test_ParsePEMKey(t *testing.T) {
+	_, rsaPrivKey, err := newRsaCert()
+	require.NoError(t, err)
+
+	_, ecPrivKey, err := newEcCert()
+	require.NoError(t, err)
+
+	_, edPrivKey, err := newEd25519Cert()
+	require.NoError(t, err)
+
+	t.Run("can parse PKIX public keys", func(t *testing.T) {
+		req := require.New(t)
+
+		for _, pubKey := range []crypto.PublicKey{rsaPrivKey.Public(), ecPrivKey.Public(), edPrivKey.Public()} {
+			der, err := x509.MarshalPKIXPublicKey(pubKey)
+			req.NoError(err)
+
+			key, err := ParsePEMKey(pem.EncodeToMemory(&pem.Block{Type: PemTypePublicKey, Bytes: der}))
+			req.NoError(err)
+			req.Empty(key.D)
+
+			endKey, err := KeyToPublicKey(*key)
+			req.NoError(err)
+			req.True(pubKey.(interface {
+				Equal(x crypto.PublicKey) bool
+			}).Equal(endKey))
+		}
+	})
+
+	t.Run("can parse PKCS#1 keys", func(t *testing.T) {
+		req := require.New(t)
+
+		key, err := ParsePEMKey(pem.EncodeToMemory(&pem.Block{Type: PemTypeRsaPublicKey, Bytes: x509.MarshalPKCS1PublicKey(&rsaPrivKey.PublicKey)}))
+		req.NoError(err)
+		req.Equal(KeyTypeRsa, key.KeyType)
+
+		key, err = ParsePEMKey(pem.EncodeToMemory(&pem.Block{Type: PemTypeRsaPrivateKey, Bytes: x509.MarshalPKCS1PrivateKey(rsaPrivKey)}), WithKeyId("rsa"))
+		req.NoError(err)
+		req.Equal("rsa", key.KeyId)
+
+		endKey, err := KeyToPrivateKey(*key)
+		req.NoError(err)
+		req.True(rsaPrivKey.Equal(endKey))
+	})
+
+	t.Run("can parse EC private keys", func(t *testing.T) {
+		req := require.New(t)
+
+		der, err := x509.MarshalECPrivateKey(ecPrivKey)
+		req.NoError(err)
+
+		key, err := ParsePEMKey(pem.EncodeToMemory(&pem.Block{Type: PemTypeEcPrivateKey, Bytes: der}))
+		req.NoError(err)
+
+		endKey, err := KeyToPrivateKey(*key)
+		req.NoError(err)
+		req.True(ecPrivKey.Equal(endKey))
+	})
+
+	t.Run("round trips PKCS#8 and encrypted PKCS#8 exports", func(t *testing.T) {
+		req := require.New(t)
+
+		orig, err := NewKeyFromPrivateKey("edKid", edPrivKey)
+		req.NoError(err)
+
+		keyPem, err := orig.PrivatePEM()
+		req.NoError(err)
+
+		key, err := ParsePEMKey(keyPem, WithKeyId("edKid"))
+		req.NoError(err)
+		req.Equal(orig, key)
+
+		encryptedPem, err := orig.EncryptedPrivatePEM([]byte("passphrase"))
+		req.NoError(err)
+
+		_, err = ParsePEMKey(encryptedPem)
+		req.Error(err)
+
+		key, err = ParsePEMKey(encryptedPem, WithKeyId("edKid"), WithPassphrase([]byte("passphrase")))
+		req.NoError(err)
+		req.Equal(orig, key)
+	})
+
+	t.Run("can parse a bundle", func(t *testing.T) {
+		req := require.New(t)
+
+		ecKey, err := NewKeyFromPrivateKey("", ecPrivKey)
+		req.NoError(err)
+
+		rsaKey, err := NewKeyFromPrivateKey("", rsaPrivKey)
+		req.NoError(err)
+
+		ecPem, err := ecKey.PrivatePEM()
+		req.NoError(err)
+
+		rsaPem, err := rsaKey.PublicPEM()
+		req.NoError(err)
+
+		bundle := append(append([]byte("leading text\n"), ecPem...), rsaPem...)
+
+		keys, err := ParsePEMBundle(bundle)
+		req.NoError(err)
+		req.Len(keys, 2)
+		req.Equal(ecKey.KeyId, keys[0].KeyId)
+		req.NotEmpty(keys[0].D)
+		req.Equal(rsaKey.KeyId, keys[1].KeyId)
+		req.Empty(keys[1].D)
+	})
+
+	t.Run("errors on unsupported blocks", func(t *testing.T) {
+		req := require.New(t)
+
+		_, err := ParsePEMKey(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: []byte{0}}))
+		req.Error(err)
+
+		_, err = ParsePEMKey([]byte("not pem"))
+		req.Error(err)
+	})
+}
+
+func Test_NewKeyFromDER(t *testing.T) {
+	_, ecPrivKey, err := newEcCert()
+	require.NoError(t, err)
+
+	_, rsaPrivKey, err := newRsaCert()
+	require.NoError(t, err)
+
+	t.Run("can parse SubjectPublicKeyInfo", func(t *testing.T) {
+		req := require.New(t)
+
+		der, err := x509.MarshalPKIXPublicKey(ecPrivKey.Public())
+		req.NoError(err)
+
+		key, err := NewKeyFromDER(der, WithKeyId("spki"))
+		req.NoError(err)
+		req.Equal("spki", key.KeyId)
+		req.Empty(key.D)
+
+		pubKey, err := KeyToPublicKey(*key)
+		req.NoError(err)
+		req.True(ecPrivKey.PublicKey.Equal(pubKey))
+	})
+
+	t.Run("can parse PKCS#8", func(t *testing.T) {
+		req := require.New(t)
+
+		der, err := x509.MarshalPKCS8PrivateKey(rsaPrivKey)
+		req.NoError(err)
+
+		key, err := NewKeyFromDER(der)
+		req.NoError(err)
+
+		privKey, err := KeyToPrivateKey(*key)
+		req.NoError(err)
+		req.True(rsaPrivKey.Equal(privKey))
+	})
+
+	t.Run("can parse PKCS#1 and SEC 1", func(t *testing.T) {
+		req := require.New(t)
+
+		key, err := NewKeyFromDER(x509.MarshalPKCS1PublicKey(&rsaPrivKey.PublicKey))
+		req.NoError(err)
+		req.Equal(KeyTypeRsa, key.KeyType)
+
+		der, err := x509.MarshalECPrivateKey(ecPrivKey)
+		req.NoError(err)
+
+		key, err = NewKeyFromDER(der)
+		req.NoError(err)
+		req.NotEmpty(key.D)
+	})
+
+	t.Run("errors on garbage", func(t *testing.T) {
+		req := require.New(t)
+
+		_, err := NewKeyFromDER([]byte{0x30, 0x03, 0x02, 0x01, 0x01})
+		req.Error(err)
+	})
+}
+
+// withPbkdf2Iterations returns the PBES2 EncryptedPrivateKeyInfo der with its PBKDF2 iteration count replaced
+func withPbkdf2Iterations(req *require.Assertions, der []byte, iterations int) []byte {
+	info := encryptedPrivateKeyInfo{}
+	_, err := asn1.Unmarshal(der, &info)
+	req.NoError(err)
+
+	scheme := pbes2Params{}
+	_, err = asn1.Unmarshal(info.EncryptionAlgorithm.Parameters.FullBytes, &scheme)
+	req.NoError(err)
+
+	kdf := pbkdf2Params{}
+	_, err = asn1.Unmarshal(scheme.KeyDerivationFunc.Parameters.FullBytes, &kdf)
+	req.NoError(err)
+
+	kdf.IterationCount = iterations
+	scheme.KeyDerivationFunc.Parameters.FullBytes, err = asn1.Marshal(kdf)
+	req.NoError(err)
+
+	info.EncryptionAlgorithm.Parameters.FullBytes, err = asn1.Marshal(scheme)
+	req.NoError(err)
+
+	der, err = asn1.Marshal(info)
+	req.NoError(err)
+
+	return der
+}
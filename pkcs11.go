@@ -0,0 +1,101 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package jwks
+
+import (
+	"crypto"
+	"encoding/hex"
+)
+
+// Pkcs11KeyPair is a key pair held in a PKCS#11 token. Signer signs with the private key object, which never leaves
+// the token, for example a crypto11.Signer.
+type Pkcs11KeyPair struct {
+	// Id is the CKA_ID of the key pair
+	Id []byte
+
+	// Label is the CKA_LABEL of the key pair
+	Label string
+
+	Signer crypto.Signer
+}
+
+// Pkcs11Token enumerates the key pairs of a PKCS#11 token or HSM, so this package does not depend on a PKCS#11
+// binding. Implementations typically wrap crypto11's FindAllKeyPairs and the attributes of the returned keys.
+type Pkcs11Token interface {
+	KeyPairs() ([]Pkcs11KeyPair, error)
+}
+
+// pkcs11Kid returns the kid of a token key pair: its label, or its hex encoded id if it has no label
+func pkcs11Kid(pair *Pkcs11KeyPair) string {
+	if pair.Label != "" {
+		return pair.Label
+	}
+
+	return hex.EncodeToString(pair.Id)
+}
+
+// Pkcs11Response returns the public keys of the token's key pairs as a Response. The kid of each key is the key
+// pair's label or, if it has none, its hex encoded id. opts are applied to every key.
+func Pkcs11Response(token Pkcs11Token, opts ...KeyOption) (*Response, error) {
+	pairs, err := token.KeyPairs()
+
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &Response{
+		Keys: make([]Key, 0, len(pairs)),
+	}
+
+	for i := range pairs {
+		key, err := NewKeyFromSigner(pkcs11Kid(&pairs[i]), pairs[i].Signer, opts...)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ret.Keys = append(ret.Keys, *key)
+	}
+
+	return ret, nil
+}
+
+// Pkcs11Signer returns a *KeySigner for the token key pair with the given kid, see Pkcs11Response, that signs with the
+// hash required by the key's alg when called with nil opts. A *KeyNotFoundError is returned if there is none. opts are
+// applied as for Pkcs11Response, for example to select the alg.
+func Pkcs11Signer(token Pkcs11Token, kid string, opts ...KeyOption) (crypto.Signer, error) {
+	pairs, err := token.KeyPairs()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range pairs {
+		if pkcs11Kid(&pairs[i]) != kid {
+			continue
+		}
+
+		key, err := NewKeyFromSigner(kid, pairs[i].Signer, opts...)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return newKeySigner(*key, pairs[i].Signer)
+	}
+
+	return nil, newKeyNotFoundError(kid)
+}
@@ -0,0 +1,93 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha512"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// testPkcs11Token is a Pkcs11Token holding software keys
+type testPkcs11Token struct {
+	pairs []Pkcs11KeyPair
+}
+
+func (t *testPkcs11Token) KeyPairs() ([]Pkcs11KeyPair, error) {
+	return t.pairs, nil
+}
+
+func Test_Pkcs11(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	token := &testPkcs11Token{pairs: []Pkcs11KeyPair{
+		{Id: []byte{0x01, 0x02}, Label: "signing", Signer: ecKey},
+		{Id: []byte{0x0a, 0x0b}, Signer: edKey},
+	}}
+
+	t.Run("can enumerate the public keys of a token", func(t *testing.T) {
+		req := require.New(t)
+
+		response, err := Pkcs11Response(token)
+		req.NoError(err)
+		req.Len(response.Keys, 2)
+
+		req.Equal("signing", response.Keys[0].KeyId)
+		req.Equal(KeyTypeEc, response.Keys[0].KeyType)
+		req.Equal(AlgorithmEs384, response.Keys[0].Algorithm)
+		req.True(response.Keys[0].IsPublic())
+
+		req.Equal("0a0b", response.Keys[1].KeyId)
+		req.Equal(KeyTypeOkp, response.Keys[1].KeyType)
+	})
+
+	t.Run("can sign with a token key", func(t *testing.T) {
+		req := require.New(t)
+
+		signer, err := Pkcs11Signer(token, "signing")
+		req.NoError(err)
+		req.Equal(AlgorithmEs384, signer.(*KeySigner).Algorithm())
+
+		digest := sha512.Sum384([]byte("payload"))
+		signature, err := signer.Sign(rand.Reader, digest[:], nil)
+		req.NoError(err)
+		req.True(ecdsa.VerifyASN1(&ecKey.PublicKey, digest[:], signature))
+
+		signer, err = Pkcs11Signer(token, "0a0b")
+		req.NoError(err)
+
+		signature, err = signer.Sign(rand.Reader, []byte("payload"), crypto.Hash(0))
+		req.NoError(err)
+		req.True(ed25519.Verify(edKey.Public().(ed25519.PublicKey), []byte("payload"), signature))
+	})
+
+	t.Run("returns an error for unknown kids", func(t *testing.T) {
+		req := require.New(t)
+
+		_, err := Pkcs11Signer(token, "missing")
+		req.IsType(&KeyNotFoundError{}, err)
+	})
+}
@@ -0,0 +1,229 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pbkdf2"
+	"hash"
+)
+
+const (
+	// Pkcs8Pbkdf2Iterations is the PBKDF2 iteration count used when encrypting PKCS#8 private keys
+	Pkcs8Pbkdf2Iterations = 600000
+
+	// Pkcs8MaxPbkdf2Iterations is the highest PBKDF2 iteration count accepted when decrypting PKCS#8 private keys, so
+	// crafted keys can not pin a CPU for minutes
+	Pkcs8MaxPbkdf2Iterations = 10000000
+
+	ErrorPkcs8IterationCountMsg = "PBKDF2 iteration count of encrypted private key is out of range"
+)
+
+var (
+	oidPbes2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPbkdf2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHmacWithSha1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHmacWithSha256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAes128Cbc      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAes192Cbc      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAes256Cbc      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// encryptedPrivateKeyInfo is defined in https://www.rfc-editor.org/rfc/rfc5958#section-3
+type encryptedPrivateKeyInfo struct {
+	EncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedData       []byte
+}
+
+// pbes2Params is defined in https://www.rfc-editor.org/rfc/rfc8018#appendix-A.4
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+// pbkdf2Params is defined in https://www.rfc-editor.org/rfc/rfc8018#appendix-A.2
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	Prf            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// encryptPkcs8 encrypts PKCS#8 PrivateKeyInfo DER bytes with PBES2 using PBKDF2-HMAC-SHA256 and AES-256-CBC and
+// returns the EncryptedPrivateKeyInfo DER bytes.
+func encryptPkcs8(der, passphrase []byte) ([]byte, error) {
+	salt := make([]byte, 16)
+	iv := make([]byte, aes.BlockSize)
+
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	encKey := pbkdf2.Key(passphrase, salt, Pkcs8Pbkdf2Iterations, 32, sha256.New)
+
+	block, err := aes.NewCipher(encKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	padLen := aes.BlockSize - len(der)%aes.BlockSize
+	plaintext := append(append([]byte{}, der...), bytes.Repeat([]byte{byte(padLen)}, padLen)...)
+	encrypted := make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, plaintext)
+
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:           salt,
+		IterationCount: Pkcs8Pbkdf2Iterations,
+		KeyLength:      len(encKey),
+		Prf: pkix.AlgorithmIdentifier{
+			Algorithm:  oidHmacWithSha256,
+			Parameters: asn1.NullRawValue,
+		},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	ivParams, err := asn1.Marshal(iv)
+
+	if err != nil {
+		return nil, err
+	}
+
+	schemeParams, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{
+			Algorithm:  oidPbkdf2,
+			Parameters: asn1.RawValue{FullBytes: kdfParams},
+		},
+		EncryptionScheme: pkix.AlgorithmIdentifier{
+			Algorithm:  oidAes256Cbc,
+			Parameters: asn1.RawValue{FullBytes: ivParams},
+		},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		EncryptionAlgorithm: pkix.AlgorithmIdentifier{
+			Algorithm:  oidPbes2,
+			Parameters: asn1.RawValue{FullBytes: schemeParams},
+		},
+		EncryptedData: encrypted,
+	})
+}
+
+// decryptPkcs8 decrypts PBES2 EncryptedPrivateKeyInfo DER bytes, supporting PBKDF2 with HMAC-SHA1 or HMAC-SHA256 and
+// AES-CBC, and returns the PKCS#8 PrivateKeyInfo DER bytes.
+func decryptPkcs8(der, passphrase []byte) ([]byte, error) {
+	info := encryptedPrivateKeyInfo{}
+
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, fmt.Errorf("error parsing encrypted private key info: %s", err)
+	}
+
+	if !info.EncryptionAlgorithm.Algorithm.Equal(oidPbes2) {
+		return nil, fmt.Errorf("unsupported private key encryption algorithm: %s", info.EncryptionAlgorithm.Algorithm)
+	}
+
+	scheme := pbes2Params{}
+
+	if _, err := asn1.Unmarshal(info.EncryptionAlgorithm.Parameters.FullBytes, &scheme); err != nil {
+		return nil, fmt.Errorf("error parsing PBES2 parameters: %s", err)
+	}
+
+	if !scheme.KeyDerivationFunc.Algorithm.Equal(oidPbkdf2) {
+		return nil, fmt.Errorf("unsupported key derivation function: %s", scheme.KeyDerivationFunc.Algorithm)
+	}
+
+	kdf := pbkdf2Params{}
+
+	if _, err := asn1.Unmarshal(scheme.KeyDerivationFunc.Parameters.FullBytes, &kdf); err != nil {
+		return nil, fmt.Errorf("error parsing PBKDF2 parameters: %s", err)
+	}
+
+	if kdf.IterationCount <= 0 || kdf.IterationCount > Pkcs8MaxPbkdf2Iterations {
+		return nil, errors.New(ErrorPkcs8IterationCountMsg)
+	}
+
+	var prf func() hash.Hash
+
+	switch {
+	case len(kdf.Prf.Algorithm) == 0 || kdf.Prf.Algorithm.Equal(oidHmacWithSha1):
+		prf = sha1.New
+	case kdf.Prf.Algorithm.Equal(oidHmacWithSha256):
+		prf = sha256.New
+	default:
+		return nil, fmt.Errorf("unsupported PBKDF2 pseudorandom function: %s", kdf.Prf.Algorithm)
+	}
+
+	var keyLen int
+
+	switch {
+	case scheme.EncryptionScheme.Algorithm.Equal(oidAes128Cbc):
+		keyLen = 16
+	case scheme.EncryptionScheme.Algorithm.Equal(oidAes192Cbc):
+		keyLen = 24
+	case scheme.EncryptionScheme.Algorithm.Equal(oidAes256Cbc):
+		keyLen = 32
+	default:
+		return nil, fmt.Errorf("unsupported encryption scheme: %s", scheme.EncryptionScheme.Algorithm)
+	}
+
+	var iv []byte
+
+	if _, err := asn1.Unmarshal(scheme.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, fmt.Errorf("error parsing encryption scheme IV: %s", err)
+	}
+
+	if len(iv) != aes.BlockSize || len(info.EncryptedData) == 0 || len(info.EncryptedData)%aes.BlockSize != 0 {
+		return nil, errors.New("invalid encrypted private key length")
+	}
+
+	block, err := aes.NewCipher(pbkdf2.Key(passphrase, kdf.Salt, kdf.IterationCount, keyLen, prf))
+
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext := make([]byte, len(info.EncryptedData))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, info.EncryptedData)
+
+	padLen := int(plaintext[len(plaintext)-1])
+
+	if padLen == 0 || padLen > aes.BlockSize || !bytes.Equal(plaintext[len(plaintext)-padLen:], bytes.Repeat([]byte{byte(padLen)}, padLen)) {
+		return nil, errors.New("error decrypting private key, incorrect passphrase")
+	}
+
+	return plaintext[:len(plaintext)-padLen], nil
+}
@@ -0,0 +1,132 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+)
+
+// DefaultMinRsaBits is the minimum RSA modulus size accepted by DefaultPolicy
+const DefaultMinRsaBits = 2048
+
+// Policy configures the checks applied when validating, parsing, and constructing keys. Empty allow lists permit any
+// value.
+type Policy struct {
+	// MinRsaBits is the minimum RSA modulus size in bits, zero disables the check
+	MinRsaBits int
+
+	// KeyOpsWarnOnly reports illegal or contradictory use and key_ops members as warnings instead of errors
+	KeyOpsWarnOnly bool
+
+	// AllowedKeyTypes restricts kty
+	AllowedKeyTypes []string
+
+	// AllowedAlgorithms restricts alg, keys without an alg are not checked
+	AllowedAlgorithms []string
+
+	// AllowedCurves restricts crv for EC and OKP keys
+	AllowedCurves []string
+
+	// ForbidSha1Kid rejects keys whose kid is the SHA-1 fingerprint of their x5c leaf certificate. Keys constructed
+	// under this policy without a kid use the RFC 7638 thumbprint instead.
+	ForbidSha1Kid bool
+}
+
+// DefaultPolicy returns the Policy used by Key.Validate
+func DefaultPolicy() *Policy {
+	return &Policy{
+		MinRsaBits: DefaultMinRsaBits,
+	}
+}
+
+// FipsPolicy returns a Policy restricted to FIPS 186-4 approved RSA and NIST curve EC keys and algorithms, 2048 bit
+// minimum RSA moduli, and no SHA-1 derived kids
+func FipsPolicy() *Policy {
+	return &Policy{
+		MinRsaBits:      DefaultMinRsaBits,
+		AllowedKeyTypes: []string{KeyTypeRsa, KeyTypeEc},
+		AllowedAlgorithms: []string{
+			AlgorithmRs256, AlgorithmRs384, AlgorithmRs512,
+			AlgorithmPs256, AlgorithmPs384, AlgorithmPs512,
+			AlgorithmEs256, AlgorithmEs384, AlgorithmEs512,
+			AlgorithmRsaOaep, AlgorithmRsaOaep256,
+		},
+		AllowedCurves: []string{"P-256", "P-384", "P-521"},
+		ForbidSha1Kid: true,
+	}
+}
+
+// check returns an error for every way the key's kty, alg, crv, and kid violate the policy
+func (p *Policy) check(key *Key) []error {
+	var errs []error
+
+	if !policyAllows(p.AllowedKeyTypes, key.KeyType) {
+		errs = append(errs, fmt.Errorf("key type %s is not allowed by policy", key.KeyType))
+	}
+
+	if key.Algorithm != "" && !policyAllows(p.AllowedAlgorithms, key.Algorithm) {
+		errs = append(errs, fmt.Errorf("alg %s is not allowed by policy", key.Algorithm))
+	}
+
+	if (key.KeyType == KeyTypeEc || key.KeyType == KeyTypeOkp) && !policyAllows(p.AllowedCurves, key.Curve) {
+		errs = append(errs, fmt.Errorf("curve %s is not allowed by policy", key.Curve))
+	}
+
+	if p.ForbidSha1Kid && key.KeyId != "" && isSha1Kid(key) {
+		errs = append(errs, fmt.Errorf("SHA-1 certificate fingerprint kids are not allowed by policy"))
+	}
+
+	return errs
+}
+
+func policyAllows(allowed []string, value string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	for _, candidate := range allowed {
+		if candidate == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isSha1Kid returns true if the key's kid is the hex or base64url SHA-1 fingerprint of its x5c leaf certificate or
+// equal to its x5t member
+func isSha1Kid(key *Key) bool {
+	if key.X509Thumbprint != "" && key.KeyId == key.X509Thumbprint {
+		return true
+	}
+
+	if len(key.X509Chain) == 0 {
+		return false
+	}
+
+	der, err := base64.StdEncoding.DecodeString(key.X509Chain[0])
+
+	if err != nil {
+		return false
+	}
+
+	sum := sha1.Sum(der)
+
+	return key.KeyId == fmt.Sprintf("%x", sum) || key.KeyId == base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,127 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_Policy(t *testing.T) {
+	t.Run("FipsPolicy rejects Ed25519 keys", func(t *testing.T) {
+		req := require.New(t)
+
+		_, edPrivKey, err := newEd25519Cert()
+		req.NoError(err)
+
+		key, err := NewKeyFromPublicKey("", edPrivKey.Public())
+		req.NoError(err)
+
+		req.NoError(key.Validate())
+		req.Error(key.ValidateWithPolicy(FipsPolicy()))
+
+		_, err = NewKeyFromPublicKey("", edPrivKey.Public(), WithPolicy(FipsPolicy()))
+		req.Error(err)
+	})
+
+	t.Run("FipsPolicy rejects imported 1024 bit RSA keys", func(t *testing.T) {
+		req := require.New(t)
+
+		rsaPrivKey, err := rsa.GenerateKey(rand.Reader, 1024)
+		req.NoError(err)
+
+		_, err = NewKeyFromPublicKey("", rsaPrivKey.Public())
+		req.NoError(err)
+
+		_, err = NewKeyFromPublicKey("", rsaPrivKey.Public(), WithPolicy(FipsPolicy()))
+		req.IsType(&KeyValidationError{}, err)
+		req.Contains(err.Error(), "RSA modulus must be at least 2048 bits, got 1024")
+
+		_, err = NewKeyFromPrivateKey("", rsaPrivKey, WithPolicy(FipsPolicy()))
+		req.Error(err)
+
+		der := x509.MarshalPKCS1PrivateKey(rsaPrivKey)
+
+		_, err = NewKeyFromDER(der, WithPolicy(FipsPolicy()))
+		req.Error(err)
+
+		_, err = ParsePEMKey(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: der}), WithPolicy(FipsPolicy()))
+		req.Error(err)
+
+		_, err = NewKeyFromDER(der)
+		req.NoError(err)
+	})
+
+	t.Run("rejects disallowed algorithms and curves", func(t *testing.T) {
+		req := require.New(t)
+
+		_, ecPrivKey, err := newEcCert()
+		req.NoError(err)
+
+		key, err := NewKeyFromPublicKey("", ecPrivKey.Public())
+		req.NoError(err)
+
+		req.Error(key.ValidateWithPolicy(&Policy{AllowedAlgorithms: []string{AlgorithmEs384}}))
+		req.Error(key.ValidateWithPolicy(&Policy{AllowedCurves: []string{"P-384"}}))
+		req.NoError(key.ValidateWithPolicy(&Policy{AllowedAlgorithms: []string{AlgorithmEs256}, AllowedCurves: []string{"P-256"}}))
+
+		_, err = NewKeyFromPublicKey("", ecPrivKey.Public(), WithPolicy(FipsPolicy()), WithAlgorithm(AlgorithmRs256))
+		req.NoError(err, "alg/kty mismatches are not a policy concern")
+
+		_, err = NewKeyFromPublicKey("", ecPrivKey.Public(), WithPolicy(FipsPolicy()), WithAlgorithm("ES256K"))
+		req.Error(err)
+	})
+
+	t.Run("ForbidSha1Kid rejects certificate fingerprint kids", func(t *testing.T) {
+		req := require.New(t)
+
+		ecCert, _, err := newEcCert()
+		req.NoError(err)
+
+		key, err := NewKey("", ecCert, []*x509.Certificate{ecCert})
+		req.NoError(err)
+
+		req.NoError(key.Validate())
+		req.Error(key.ValidateWithPolicy(FipsPolicy()))
+
+		key.KeyId = key.X509Thumbprint
+		req.Error(key.ValidateWithPolicy(FipsPolicy()))
+
+		key.KeyId = "custom"
+		req.NoError(key.ValidateWithPolicy(FipsPolicy()))
+	})
+
+	t.Run("NewKey derives thumbprint kids when SHA-1 kids are forbidden", func(t *testing.T) {
+		req := require.New(t)
+
+		ecCert, _, err := newEcCert()
+		req.NoError(err)
+
+		key, err := NewKey("", ecCert, []*x509.Certificate{ecCert}, WithPolicy(FipsPolicy()))
+		req.NoError(err)
+
+		thumbprint, err := key.Thumbprint(crypto.SHA256)
+		req.NoError(err)
+		req.Equal(thumbprint, key.KeyId)
+		req.NoError(key.ValidateWithPolicy(FipsPolicy()))
+	})
+}
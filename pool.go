@@ -0,0 +1,119 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"sync"
+)
+
+// encodingBuffers pools the scratch buffers used to encode key members, so issuers that regenerate or re-serialize
+// sets frequently do not allocate a raw and an encoded buffer for every member
+var encodingBuffers = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 2048)
+		return &buf
+	},
+}
+
+// encodeBase64 returns the unpadded base64url encoding of raw using a pooled scratch buffer, which is wiped as raw may
+// be private key material
+func encodeBase64(raw []byte) string {
+	bufPtr := encodingBuffers.Get().(*[]byte)
+	defer encodingBuffers.Put(bufPtr)
+
+	size := base64.RawURLEncoding.EncodedLen(len(raw))
+
+	if cap(*bufPtr) < size {
+		*bufPtr = make([]byte, 0, size)
+	}
+
+	encoded := (*bufPtr)[:size]
+	base64.RawURLEncoding.Encode(encoded, raw)
+
+	ret := string(encoded)
+	wipe(encoded)
+
+	return ret
+}
+
+// encodeBigInt returns the unpadded base64url encoding of the big-endian bytes of i, left padded with zeros to size
+// bytes if size is greater than the minimal length, using a pooled scratch buffer. The scratch buffer is wiped as i
+// may be private key material.
+func encodeBigInt(i *big.Int, size int) string {
+	length := (i.BitLen() + 7) / 8
+
+	if size > length {
+		length = size
+	}
+
+	bufPtr := encodingBuffers.Get().(*[]byte)
+	defer encodingBuffers.Put(bufPtr)
+
+	total := length + base64.RawURLEncoding.EncodedLen(length)
+
+	if cap(*bufPtr) < total {
+		*bufPtr = make([]byte, 0, total)
+	}
+
+	buf := (*bufPtr)[:total]
+	raw := i.FillBytes(buf[:length])
+	encoded := buf[length:]
+	base64.RawURLEncoding.Encode(encoded, raw)
+
+	ret := string(encoded)
+	wipe(buf)
+
+	return ret
+}
+
+// encodeExponent returns the unpadded base64url encoding of the minimal big-endian bytes of an RSA exponent
+func encodeExponent(e int) string {
+	var raw [8]byte
+
+	n := len(raw)
+
+	for value := uint64(e); value > 0; value >>= 8 {
+		n--
+		raw[n] = byte(value)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(raw[n:])
+}
+
+// decodeBigInt decodes the base64url member named name into a big.Int using a pooled scratch buffer
+func decodeBigInt(name, value string) (*big.Int, error) {
+	bufPtr := encodingBuffers.Get().(*[]byte)
+	defer encodingBuffers.Put(bufPtr)
+
+	size := base64.RawURLEncoding.DecodedLen(len(value))
+
+	if cap(*bufPtr) < size {
+		*bufPtr = make([]byte, 0, size)
+	}
+
+	buf := (*bufPtr)[:size]
+	n, err := base64.RawURLEncoding.Decode(buf, []byte(value))
+
+	if err != nil {
+		return nil, fmt.Errorf("error base64 decoding key's %s: %s: %s", name, value, err)
+	}
+
+	return new(big.Int).SetBytes(buf[:n]), nil
+}
@@ -0,0 +1,57 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"encoding/base64"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+)
+
+func Test_encodeBigInt(t *testing.T) {
+	t.Run("can encode minimal and padded big-endian bytes", func(t *testing.T) {
+		req := require.New(t)
+
+		value := big.NewInt(0x0102)
+
+		req.Equal(base64.RawURLEncoding.EncodeToString([]byte{1, 2}), encodeBigInt(value, 0))
+		req.Equal(base64.RawURLEncoding.EncodeToString([]byte{0, 0, 1, 2}), encodeBigInt(value, 4))
+		req.Equal(base64.RawURLEncoding.EncodeToString([]byte{1, 2}), encodeBigInt(value, 1))
+		req.Equal("", encodeBigInt(new(big.Int), 0))
+	})
+
+	t.Run("can encode values larger than the pooled buffers", func(t *testing.T) {
+		req := require.New(t)
+
+		value := new(big.Int).Lsh(big.NewInt(1), 8*4096)
+
+		req.Equal(base64.RawURLEncoding.EncodeToString(value.Bytes()), encodeBigInt(value, 0))
+		req.Equal(base64.RawURLEncoding.EncodeToString(value.Bytes()), encodeBase64(value.Bytes()))
+	})
+}
+
+func Test_encodeExponent(t *testing.T) {
+	t.Run("can encode minimal exponent bytes", func(t *testing.T) {
+		req := require.New(t)
+
+		req.Equal("AQAB", encodeExponent(65537))
+		req.Equal("Aw", encodeExponent(3))
+		req.Equal("", encodeExponent(0))
+		req.Equal(base64.RawURLEncoding.EncodeToString([]byte{0x7f, 0xff, 0xff, 0xff}), encodeExponent(1<<31-1))
+	})
+}
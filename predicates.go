@@ -0,0 +1,83 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+// hmacAlgorithms are the JWS HMAC algorithms used with oct keys, https://www.rfc-editor.org/rfc/rfc7518#section-3.2
+var hmacAlgorithms = map[string]bool{
+	AlgorithmHs256: true,
+	AlgorithmHs384: true,
+	AlgorithmHs512: true,
+}
+
+// IsPrivate returns true if the key contains private key material (d, p, q, dp, dq or qi)
+func (k *Key) IsPrivate() bool {
+	return k.D != "" || k.P != "" || k.Q != "" || k.Dp != "" || k.Dq != "" || k.Qi != ""
+}
+
+// IsSymmetric returns true if the key is a symmetric (oct) key
+func (k *Key) IsSymmetric() bool {
+	return k.KeyType == KeyTypeOct
+}
+
+// IsPublic returns true if the key is an asymmetric key without private key material and so safe to publish
+func (k *Key) IsPublic() bool {
+	return !k.IsPrivate() && !k.IsSymmetric()
+}
+
+// IsForSigning returns true if the key may be used for signatures. The use member is authoritative if present,
+// followed by key_ops and then alg. Keys without any of them are unrestricted.
+func (k *Key) IsForSigning() bool {
+	return k.isFor(UseSignature)
+}
+
+// IsForEncryption returns true if the key may be used for encryption, see IsForSigning for the precedence of members
+func (k *Key) IsForEncryption() bool {
+	return k.isFor(UseEncryption)
+}
+
+func (k *Key) isFor(use string) bool {
+	if k.Use != "" {
+		return k.Use == use
+	}
+
+	if len(k.KeyOperations) > 0 {
+		for _, op := range k.KeyOperations {
+			if keyOperationUses[op] == use {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if k.Algorithm != "" {
+		return isSigningAlgorithm(k.Algorithm) == (use == UseSignature)
+	}
+
+	return true
+}
+
+// isSigningAlgorithm returns true if alg is a JWS algorithm, as opposed to a JWE key management algorithm
+func isSigningAlgorithm(alg string) bool {
+	if hmacAlgorithms[alg] {
+		return true
+	}
+
+	_, _, err := algorithmSignerOpts(alg)
+
+	return err == nil
+}
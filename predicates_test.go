@@ -0,0 +1,89 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_KeyPredicates(t *testing.T) {
+	response := &Response{}
+	require.NoError(t, json.Unmarshal([]byte(testJwksRfc7517Examples), response))
+
+	t.Run("can classify key material", func(t *testing.T) {
+		req := require.New(t)
+
+		public := response.Keys[1]
+		req.True(public.IsPublic())
+		req.False(public.IsPrivate())
+		req.False(public.IsSymmetric())
+
+		private := response.Keys[2]
+		req.False(private.IsPublic())
+		req.True(private.IsPrivate())
+
+		symmetric := Key{KeyType: KeyTypeOct, K: "AQAB"}
+		req.False(symmetric.IsPublic())
+		req.False(symmetric.IsPrivate())
+		req.True(symmetric.IsSymmetric())
+	})
+
+	t.Run("can classify usage by use", func(t *testing.T) {
+		req := require.New(t)
+
+		enc := response.Keys[0]
+		req.True(enc.IsForEncryption())
+		req.False(enc.IsForSigning())
+	})
+
+	t.Run("can classify usage by key_ops", func(t *testing.T) {
+		req := require.New(t)
+
+		key := Key{KeyType: KeyTypeEc, KeyOperations: []string{KeyOperationVerify}}
+		req.True(key.IsForSigning())
+		req.False(key.IsForEncryption())
+
+		key.KeyOperations = []string{KeyOperationWrapKey}
+		req.False(key.IsForSigning())
+		req.True(key.IsForEncryption())
+	})
+
+	t.Run("can classify usage by alg", func(t *testing.T) {
+		req := require.New(t)
+
+		rs256 := response.Keys[1]
+		req.True(rs256.IsForSigning())
+		req.False(rs256.IsForEncryption())
+
+		hmac := Key{KeyType: KeyTypeOct, Algorithm: "HS256"}
+		req.True(hmac.IsForSigning())
+
+		oaep := Key{KeyType: KeyTypeRsa, Algorithm: AlgorithmRsaOaep256}
+		req.False(oaep.IsForSigning())
+		req.True(oaep.IsForEncryption())
+	})
+
+	t.Run("treats keys without usage members as unrestricted", func(t *testing.T) {
+		req := require.New(t)
+
+		key := Key{KeyType: KeyTypeEc}
+		req.True(key.IsForSigning())
+		req.True(key.IsForEncryption())
+	})
+}
@@ -0,0 +1,303 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"github.com/pkg/errors"
+	"math/big"
+)
+
+// KeyToPrivateKey converts the JSON marshalled Key to an interface{} object which represents a private key that may
+// be backed by rsa.PrivateKey, ecdsa.PrivateKey, or ed25519.PrivateKey depending on the input key's KeyType. RSA keys
+// that only carry n, e, and d have their primes derived on the fly, the input key is not modified.
+func KeyToPrivateKey(key Key) (interface{}, error) {
+	if key.D == "" {
+		return nil, errors.New("key does not contain private key material")
+	}
+
+	switch key.KeyType {
+	case KeyTypeRsa:
+		if key.P == "" || key.Q == "" {
+			if err := key.DeriveRsaCrtParameters(); err != nil {
+				return nil, err
+			}
+		}
+
+		pubKey, err := KeyToPublicKey(key)
+
+		if err != nil {
+			return nil, err
+		}
+
+		d, err := decodePrivateMember("d", key.D)
+
+		if err != nil {
+			return nil, err
+		}
+
+		p, err := decodePrivateMember("p", key.P)
+
+		if err != nil {
+			return nil, err
+		}
+
+		q, err := decodePrivateMember("q", key.Q)
+
+		if err != nil {
+			return nil, err
+		}
+
+		rsaPrivKey := &rsa.PrivateKey{
+			PublicKey: *pubKey.(*rsa.PublicKey),
+			D:         new(big.Int).SetBytes(d),
+			Primes:    []*big.Int{new(big.Int).SetBytes(p), new(big.Int).SetBytes(q)},
+		}
+
+		if err = rsaPrivKey.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid RSA private key: %s", err)
+		}
+
+		rsaPrivKey.Precompute()
+
+		return rsaPrivKey, nil
+	case KeyTypeEc:
+		pubKey, err := KeyToPublicKey(key)
+
+		if err != nil {
+			return nil, err
+		}
+
+		d, err := decodePrivateMember("d", key.D)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return newEcPrivateKey(pubKey.(*ecdsa.PublicKey), d)
+	case KeyTypeOkp:
+		pubKey, err := KeyToPublicKey(key)
+
+		if err != nil {
+			return nil, err
+		}
+
+		seed, err := decodePrivateMember("d", key.D)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("invalid Ed25519 private key length: %d", len(seed))
+		}
+
+		edPrivKey := ed25519.NewKeyFromSeed(seed)
+
+		if !edPrivKey.Public().(ed25519.PublicKey).Equal(pubKey) {
+			return nil, errors.New("Ed25519 private key does not match public key")
+		}
+
+		return edPrivKey, nil
+	default:
+		return nil, fmt.Errorf("unsuportted key type: %s", key.KeyType)
+	}
+}
+
+// newEcPrivateKey returns the EC private key with the scalar d, which must be in [1, N-1] and yield the public point
+// of pubKey
+func newEcPrivateKey(pubKey *ecdsa.PublicKey, d []byte) (*ecdsa.PrivateKey, error) {
+	privKey := &ecdsa.PrivateKey{
+		PublicKey: *pubKey,
+		D:         new(big.Int).SetBytes(d),
+	}
+
+	if privKey.D.Sign() <= 0 || privKey.D.Cmp(pubKey.Curve.Params().N) >= 0 {
+		return nil, errors.New("invalid EC private key, d is out of range")
+	}
+
+	x, y := pubKey.Curve.ScalarBaseMult(privKey.D.Bytes())
+
+	if x.Cmp(pubKey.X) != 0 || y.Cmp(pubKey.Y) != 0 {
+		return nil, errors.New("EC private key does not match public key")
+	}
+
+	return privKey, nil
+}
+
+// NewKeyFromPrivateKey will convert an RSA, EC, or Ed25519 private key to a Key containing both the public and private
+// members. If keyId is empty string, the keyId will be populated with the RFC 7638 SHA-256 thumbprint of the key.
+func NewKeyFromPrivateKey(keyId string, privateKey crypto.PrivateKey, opts ...KeyOption) (*Key, error) {
+	signer, ok := privateKey.(crypto.Signer)
+
+	if !ok {
+		return nil, errors.New("invalid private key type, expected EC, RSA, or Ed25519 private key")
+	}
+
+	ret, err := NewKeyFromPublicKey(keyId, signer.Public(), opts...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch privKey := privateKey.(type) {
+	case *rsa.PrivateKey:
+		if len(privKey.Primes) != 2 {
+			return nil, errors.New("multi-prime RSA private keys are not supported")
+		}
+
+		privKey.Precompute()
+
+		ret.D = encodeBigInt(privKey.D, 0)
+		ret.P = encodeBigInt(privKey.Primes[0], 0)
+		ret.Q = encodeBigInt(privKey.Primes[1], 0)
+		ret.Dp = encodeBigInt(privKey.Precomputed.Dp, 0)
+		ret.Dq = encodeBigInt(privKey.Precomputed.Dq, 0)
+		ret.Qi = encodeBigInt(privKey.Precomputed.Qinv, 0)
+	case *ecdsa.PrivateKey:
+		byteLen := (privKey.Curve.Params().BitSize + 7) / 8
+		ret.D = encodeBigInt(privKey.D, byteLen)
+	case ed25519.PrivateKey:
+		ret.D = encodeBase64(privKey.Seed())
+	default:
+		return nil, errors.New("invalid private key type, expected EC, RSA, or Ed25519 private key")
+	}
+
+	return ret, nil
+}
+
+// DeriveRsaCrtParameters populates the p, q, dp, dq, and qi members of a private RSA key that only carries n, e, and
+// d, as produced by some HSM exports. The primes are recovered from the private exponent using the probabilistic
+// method described in NIST SP 800-56B Appendix C.
+func (k *Key) DeriveRsaCrtParameters() error {
+	if k.KeyType != KeyTypeRsa {
+		return fmt.Errorf("can only derive CRT parameters for RSA keys, got: %s", k.KeyType)
+	}
+
+	pubKey, err := KeyToPublicKey(*k)
+
+	if err != nil {
+		return err
+	}
+
+	rsaPubKey := pubKey.(*rsa.PublicKey)
+
+	dBytes, err := decodePrivateMember("d", k.D)
+
+	if err != nil {
+		return err
+	}
+
+	n := rsaPubKey.N
+	e := big.NewInt(int64(rsaPubKey.E))
+	d := new(big.Int).SetBytes(dBytes)
+
+	p, q, err := factorRsaModulus(n, e, d)
+
+	if err != nil {
+		return err
+	}
+
+	one := big.NewInt(1)
+	dp := new(big.Int).Mod(d, new(big.Int).Sub(p, one))
+	dq := new(big.Int).Mod(d, new(big.Int).Sub(q, one))
+	qi := new(big.Int).ModInverse(q, p)
+
+	if qi == nil {
+		return errors.New("could not compute CRT coefficient, q is not invertible mod p")
+	}
+
+	k.P = encodeBigInt(p, 0)
+	k.Q = encodeBigInt(q, 0)
+	k.Dp = encodeBigInt(dp, 0)
+	k.Dq = encodeBigInt(dq, 0)
+	k.Qi = encodeBigInt(qi, 0)
+
+	return nil
+}
+
+// factorRsaModulus recovers the primes p and q (p > q) of n given the public and private exponents.
+func factorRsaModulus(n, e, d *big.Int) (*big.Int, *big.Int, error) {
+	one := big.NewInt(1)
+	nMinusOne := new(big.Int).Sub(n, one)
+
+	// k = de - 1 = 2^s * t with t odd
+	k := new(big.Int).Mul(d, e)
+	k.Sub(k, one)
+
+	if k.Bit(0) != 0 {
+		return nil, nil, errors.New("invalid RSA private exponent, de - 1 is odd")
+	}
+
+	t := new(big.Int).Set(k)
+	s := 0
+	for t.Bit(0) == 0 {
+		t.Rsh(t, 1)
+		s++
+	}
+
+	for g := int64(2); g < 1000; g++ {
+		x := new(big.Int).Exp(big.NewInt(g), t, n)
+
+		if x.Cmp(one) == 0 || x.Cmp(nMinusOne) == 0 {
+			continue
+		}
+
+		for i := 0; i < s; i++ {
+			y := new(big.Int).Exp(x, big.NewInt(2), n)
+
+			if y.Cmp(one) == 0 {
+				p := new(big.Int).GCD(nil, nil, new(big.Int).Sub(x, one), n)
+				q := new(big.Int).Div(n, p)
+
+				if p.Cmp(q) < 0 {
+					p, q = q, p
+				}
+
+				return p, q, nil
+			}
+
+			if y.Cmp(nMinusOne) == 0 {
+				break
+			}
+
+			x = y
+		}
+	}
+
+	return nil, nil, errors.New("could not factor RSA modulus from the private exponent")
+}
+
+// decodePrivateMember base64url decodes a private key member. The member value is intentionally omitted from errors.
+func decodePrivateMember(name, value string) ([]byte, error) {
+	if value == "" {
+		return nil, fmt.Errorf("key is missing private member: %s", name)
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+
+	if err != nil {
+		return nil, fmt.Errorf("error base64 decoding key's %s: %s", name, err)
+	}
+
+	return decoded, nil
+}
@@ -0,0 +1,193 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+)
+
+func Test_KeyToPrivateKey(t *testing.T) {
+	t.Run("can create rsa.PrivateKey from the rfc7517 example", func(t *testing.T) {
+		req := require.New(t)
+		response := &Response{}
+
+		err := json.Unmarshal([]byte(testJwksRfc7517Examples), response)
+		req.NoError(err)
+
+		privKey, err := KeyToPrivateKey(response.Keys[2])
+		req.NoError(err)
+
+		rsaPrivKey, ok := privKey.(*rsa.PrivateKey)
+		req.True(ok)
+		req.NoError(rsaPrivKey.Validate())
+	})
+
+	t.Run("errors for public keys", func(t *testing.T) {
+		req := require.New(t)
+		response := &Response{}
+
+		err := json.Unmarshal([]byte(testJwksRfc7517Examples), response)
+		req.NoError(err)
+
+		_, err = KeyToPrivateKey(response.Keys[1])
+		req.Error(err)
+	})
+
+	t.Run("can round trip RSA, EC, and Ed25519 private keys", func(t *testing.T) {
+		req := require.New(t)
+
+		_, rsaPrivKey, err := newRsaCert()
+		req.NoError(err)
+
+		_, ecPrivKey, err := newEcCert()
+		req.NoError(err)
+
+		_, edPrivKey, err := newEd25519Cert()
+		req.NoError(err)
+
+		for _, privKey := range []interface {
+			Equal(x crypto.PrivateKey) bool
+		}{rsaPrivKey, ecPrivKey, edPrivKey} {
+			key, err := NewKeyFromPrivateKey("", privKey)
+			req.NoError(err)
+			req.NotEmpty(key.D)
+
+			endKey, err := KeyToPrivateKey(*key)
+			req.NoError(err)
+			req.True(privKey.Equal(endKey), "expected the original private key and re-constituted key to be equal")
+		}
+	})
+
+	t.Run("rejects EC private keys with d out of range", func(t *testing.T) {
+		req := require.New(t)
+
+		private, _, err := GenerateECKey("P-256", "ec")
+		req.NoError(err)
+
+		n := elliptic.P256().Params().N
+
+		for _, d := range []*big.Int{big.NewInt(0), n, new(big.Int).Add(n, big.NewInt(1))} {
+			key := *private
+			key.D = encodeBigInt(d, 32)
+
+			_, err = KeyToPrivateKey(key)
+			req.EqualError(err, "invalid EC private key, d is out of range", d.String())
+		}
+	})
+
+	t.Run("rejects EC private keys that do not match the public key", func(t *testing.T) {
+		req := require.New(t)
+
+		private, _, err := GenerateECKey("P-256", "ec")
+		req.NoError(err)
+
+		other, _, err := GenerateECKey("P-256", "other")
+		req.NoError(err)
+
+		key := *private
+		key.D = other.D
+
+		_, err = KeyToPrivateKey(key)
+		req.EqualError(err, "EC private key does not match public key")
+
+		_, err = KeyToPrivateKey(*private)
+		req.NoError(err)
+	})
+}
+
+func Test_DeriveRsaCrtParameters(t *testing.T) {
+	t.Run("derives the rfc7517 example primes from n, e, and d", func(t *testing.T) {
+		req := require.New(t)
+		response := &Response{}
+
+		err := json.Unmarshal([]byte(testJwksRfc7517Examples), response)
+		req.NoError(err)
+
+		orig := response.Keys[2]
+
+		key := orig
+		key.P = ""
+		key.Q = ""
+		key.Dp = ""
+		key.Dq = ""
+		key.Qi = ""
+
+		err = key.DeriveRsaCrtParameters()
+		req.NoError(err)
+
+		req.Equal(orig.P, key.P)
+		req.Equal(orig.Q, key.Q)
+		req.Equal(orig.Dp, key.Dp)
+		req.Equal(orig.Dq, key.Dq)
+		req.Equal(orig.Qi, key.Qi)
+	})
+
+	t.Run("KeyToPrivateKey works with only n, e, and d", func(t *testing.T) {
+		req := require.New(t)
+
+		_, rsaPrivKey, err := newRsaCert()
+		req.NoError(err)
+
+		key, err := NewKeyFromPrivateKey("", rsaPrivKey)
+		req.NoError(err)
+
+		key.P = ""
+		key.Q = ""
+		key.Dp = ""
+		key.Dq = ""
+		key.Qi = ""
+
+		endKey, err := KeyToPrivateKey(*key)
+		req.NoError(err)
+
+		rsaEndKey := endKey.(*rsa.PrivateKey)
+		req.True(rsaPrivKey.PublicKey.Equal(&rsaEndKey.PublicKey))
+		req.Equal(0, rsaPrivKey.D.Cmp(rsaEndKey.D))
+		req.NoError(rsaEndKey.Validate())
+		req.Empty(key.P, "expected KeyToPrivateKey to not modify the input key")
+	})
+
+	t.Run("errors for non RSA keys", func(t *testing.T) {
+		req := require.New(t)
+
+		key := &Key{KeyType: KeyTypeEc}
+		req.Error(key.DeriveRsaCrtParameters())
+	})
+}
+
+func BenchmarkNewKeyFromPrivateKey(b *testing.B) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := NewKeyFromPrivateKey("kid", rsaKey); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+// privateAdditionalMembers lists registered private members not modeled by Key, such as the RSA "oth" other primes
+// info, https://www.rfc-editor.org/rfc/rfc7518#section-6.3.2.7, and the members this package uses to manage keys
+var privateAdditionalMembers = []string{"oth", KmsKeyIdMember, rotationStateMember}
+
+// Public returns a copy of the key with all private and symmetric key material (d, p, q, dp, dq, qi, oth and k) and
+// the internal x-kms-key-id and x-rotation-state members removed. The copy of an oct key has no key material left.
+func (k *Key) Public() Key {
+	ret := k.clone()
+
+	ret.D, ret.P, ret.Q, ret.Dp, ret.Dq, ret.Qi, ret.K = "", "", "", "", "", "", ""
+
+	for _, name := range privateAdditionalMembers {
+		delete(ret.AdditionalMembers, name)
+	}
+
+	if len(ret.AdditionalMembers) == 0 {
+		ret.AdditionalMembers = nil
+	}
+
+	return ret
+}
+
+// PublicOnly returns a copy of the set that is safe to publish: every key is replaced by Key.Public and symmetric
+// (oct) keys are dropped entirely
+func (r *Response) PublicOnly() *Response {
+	ret := &Response{
+		Keys: make([]Key, 0, len(r.Keys)),
+	}
+
+	for i := range r.Keys {
+		if r.Keys[i].KeyType == KeyTypeOct {
+			continue
+		}
+
+		ret.Keys = append(ret.Keys, r.Keys[i].Public())
+	}
+
+	return ret
+}
@@ -0,0 +1,77 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_Public(t *testing.T) {
+	t.Run("can strip private material from a key", func(t *testing.T) {
+		req := require.New(t)
+
+		response := &Response{}
+		req.NoError(json.Unmarshal([]byte(testJwksRfc7517Examples), response))
+
+		private := response.Keys[2]
+		private.AdditionalMembers = map[string]json.RawMessage{
+			"oth":      json.RawMessage(`[{"r":"AQAB","d":"AQAB","t":"AQAB"}]`),
+			"x-vendor": json.RawMessage(`true`),
+		}
+
+		public := private.Public()
+		req.Empty(public.D)
+		req.Empty(public.P)
+		req.Empty(public.Q)
+		req.Empty(public.Dp)
+		req.Empty(public.Dq)
+		req.Empty(public.Qi)
+		req.NotContains(public.AdditionalMembers, "oth")
+		req.Contains(public.AdditionalMembers, "x-vendor")
+		req.Equal(private.N, public.N)
+		req.Equal(private.E, public.E)
+		req.Equal(private.KeyId, public.KeyId)
+
+		req.NotEmpty(private.D)
+		req.Contains(private.AdditionalMembers, "oth")
+
+		_, err := KeyToPublicKey(public)
+		req.NoError(err)
+	})
+
+	t.Run("can strip private and symmetric keys from a set", func(t *testing.T) {
+		req := require.New(t)
+
+		response := &Response{}
+		req.NoError(json.Unmarshal([]byte(testJwksRfc7517Examples), response))
+		response.Keys = append(response.Keys, Key{KeyType: KeyTypeOct, KeyId: "hmac", K: "AQAB"})
+
+		public := response.PublicOnly()
+		req.Len(public.Keys, 3)
+		req.True(public.Validate(DefaultPolicy()).IsValid())
+
+		for _, key := range public.Keys {
+			req.Empty(key.D)
+			req.Empty(key.K)
+		}
+
+		req.Len(response.Keys, 4)
+		req.NotEmpty(response.Keys[2].D)
+	})
+}
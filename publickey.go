@@ -0,0 +1,147 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"container/list"
+	"crypto"
+	"sync"
+)
+
+// sharedPublicKeyCache backs Key.PublicKey. Keys are copied freely, so the cache is kept outside of them.
+var sharedPublicKeyCache = NewPublicKeyCache(DefaultPublicKeyCacheSize)
+
+// PublicKey returns the key converted by KeyToPublicKey. Converted keys are cached by RFC 7638 thumbprint in a process
+// wide PublicKeyCache of DefaultPublicKeyCacheSize keys, so hot verification paths do not decode the key for every
+// token and copies of a key, or the same key in a refetched Response, share the result. Changing kty, crv, n, e, x,
+// or y changes the thumbprint, so a changed key is converted again. Conversion errors are not cached. Safe for
+// concurrent use as long as the key is not modified concurrently.
+func (k *Key) PublicKey() (crypto.PublicKey, error) {
+	return sharedPublicKeyCache.PublicKey(*k)
+}
+
+// DefaultPublicKeyCacheSize is the capacity of a PublicKeyCache created with a capacity of zero or less
+const DefaultPublicKeyCacheSize = 1024
+
+// PublicKeyCache is a least recently used cache of crypto.PublicKey values converted by KeyToPublicKey, keyed by
+// RFC 7638 SHA-256 thumbprint, so keys that reappear in new Responses are not converted again. Key.PublicKey uses a
+// process wide instance, a separate cache bounds the memory and lifetime of the keys it holds independently. A single cache may be shared by any number of resolvers or managers and is safe for
+// concurrent use. The cached values are shared and must not be modified. A nil *PublicKeyCache converts without
+// caching.
+type PublicKeyCache struct {
+	lock     sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List
+}
+
+// publicKeyCacheEntry is an element of PublicKeyCache.order
+type publicKeyCacheEntry struct {
+	thumbprint string
+	publicKey  crypto.PublicKey
+}
+
+// NewPublicKeyCache returns a PublicKeyCache holding at most capacity keys, DefaultPublicKeyCacheSize if capacity is
+// zero or less
+func NewPublicKeyCache(capacity int) *PublicKeyCache {
+	if capacity <= 0 {
+		capacity = DefaultPublicKeyCacheSize
+	}
+
+	return &PublicKeyCache{
+		capacity: capacity,
+		entries:  map[string]*list.Element{},
+		order:    list.New(),
+	}
+}
+
+// PublicKey returns the public key of key from the cache, converting and adding it if it is not cached. Conversion
+// errors are not cached.
+func (c *PublicKeyCache) PublicKey(key Key) (crypto.PublicKey, error) {
+	if c == nil {
+		return KeyToPublicKey(key)
+	}
+
+	thumbprint, err := key.Thumbprint(crypto.SHA256)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c.lock.Lock()
+
+	if element, ok := c.entries[thumbprint]; ok {
+		c.order.MoveToFront(element)
+		c.lock.Unlock()
+
+		return element.Value.(*publicKeyCacheEntry).publicKey, nil
+	}
+
+	c.lock.Unlock()
+
+	publicKey, err := KeyToPublicKey(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if element, ok := c.entries[thumbprint]; ok {
+		c.order.MoveToFront(element)
+		return element.Value.(*publicKeyCacheEntry).publicKey, nil
+	}
+
+	c.entries[thumbprint] = c.order.PushFront(&publicKeyCacheEntry{
+		thumbprint: thumbprint,
+		publicKey:  publicKey,
+	})
+
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*publicKeyCacheEntry).thumbprint)
+	}
+
+	return publicKey, nil
+}
+
+// Len returns the number of cached keys
+func (c *PublicKeyCache) Len() int {
+	if c == nil {
+		return 0
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.order.Len()
+}
+
+// Purge removes every cached key
+func (c *PublicKeyCache) Purge() {
+	if c == nil {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.entries = map[string]*list.Element{}
+	c.order.Init()
+}
@@ -0,0 +1,279 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+func Test_KeyPublicKey(t *testing.T) {
+	t.Run("can convert and cache the public key", func(t *testing.T) {
+		req := require.New(t)
+
+		_, public, err := GenerateECKey("P-256", "ec")
+		req.NoError(err)
+
+		publicKey, err := public.PublicKey()
+		req.NoError(err)
+
+		expected, err := KeyToPublicKey(*public)
+		req.NoError(err)
+		req.True(expected.(*ecdsa.PublicKey).Equal(publicKey))
+
+		cached, err := public.PublicKey()
+		req.NoError(err)
+		req.Same(publicKey, cached)
+	})
+
+	t.Run("shares the cache between copies without affecting equality", func(t *testing.T) {
+		req := require.New(t)
+
+		_, public, err := GenerateECKey("P-256", "ec")
+		req.NoError(err)
+
+		copied := *public
+
+		publicKey, err := public.PublicKey()
+		req.NoError(err)
+
+		cached, err := copied.PublicKey()
+		req.NoError(err)
+		req.Same(publicKey, cached)
+		req.Equal(copied, *public)
+	})
+
+	t.Run("converts again after the key is changed", func(t *testing.T) {
+		req := require.New(t)
+
+		_, public, err := GenerateECKey("P-256", "ec")
+		req.NoError(err)
+
+		_, other, err := GenerateECKey("P-256", "other")
+		req.NoError(err)
+
+		first, err := public.PublicKey()
+		req.NoError(err)
+
+		public.X, public.Y = other.X, other.Y
+
+		second, err := public.PublicKey()
+		req.NoError(err)
+		req.NotSame(first, second)
+
+		expected, err := other.PublicKey()
+		req.NoError(err)
+		req.True(expected.(*ecdsa.PublicKey).Equal(second))
+	})
+
+	t.Run("returns conversion errors", func(t *testing.T) {
+		req := require.New(t)
+
+		key := &Key{KeyType: KeyTypeEc, Curve: "P-256", X: "!", Y: "!"}
+
+		_, err := key.PublicKey()
+		req.Error(err)
+
+		_, err = key.PublicKey()
+		req.Error(err)
+	})
+
+	t.Run("can be used concurrently with copies of the response", func(t *testing.T) {
+		req := require.New(t)
+
+		_, public, err := GenerateRSAKey(2048, "rsa")
+		req.NoError(err)
+
+		response := &Response{Keys: []Key{*public}}
+
+		wg := sync.WaitGroup{}
+		errs := make(chan error, 10)
+
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				_, err := response.ByKid("rsa").PublicKey()
+				errs <- err
+
+				_ = response.ByUse(UseSignature)
+			}()
+		}
+
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			req.NoError(err)
+		}
+	})
+}
+
+func Test_PublicKeyCache(t *testing.T) {
+	t.Run("can reuse public keys across responses", func(t *testing.T) {
+		req := require.New(t)
+
+		cache := NewPublicKeyCache(2)
+
+		_, public, err := GenerateECKey("P-256", "ec")
+		req.NoError(err)
+
+		first, err := cache.PublicKey(*public)
+		req.NoError(err)
+
+		refetched, err := ParseResponse([]byte(mustMarshalResponse(req, &Response{Keys: []Key{*public}})))
+		req.NoError(err)
+
+		second, err := cache.PublicKey(refetched.Keys[0])
+		req.NoError(err)
+		req.Same(first, second)
+		req.Equal(1, cache.Len())
+	})
+
+	t.Run("evicts the least recently used key", func(t *testing.T) {
+		req := require.New(t)
+
+		cache := NewPublicKeyCache(2)
+
+		var keys []*Key
+
+		for _, kid := range []string{"a", "b", "c"} {
+			_, public, err := GenerateOKPKey(CurveEd25519, kid)
+			req.NoError(err)
+			keys = append(keys, public)
+		}
+
+		a, err := cache.PublicKey(*keys[0])
+		req.NoError(err)
+
+		_, err = cache.PublicKey(*keys[1])
+		req.NoError(err)
+
+		_, err = cache.PublicKey(*keys[0])
+		req.NoError(err)
+
+		_, err = cache.PublicKey(*keys[2])
+		req.NoError(err)
+		req.Equal(2, cache.Len())
+
+		thumbprint, err := keys[1].Thumbprint(crypto.SHA256)
+		req.NoError(err)
+		req.NotContains(cache.entries, thumbprint)
+
+		cached, err := cache.PublicKey(*keys[0])
+		req.NoError(err)
+		req.Equal(a, cached)
+
+		cache.Purge()
+		req.Equal(0, cache.Len())
+	})
+
+	t.Run("does not cache conversion errors", func(t *testing.T) {
+		req := require.New(t)
+
+		cache := NewPublicKeyCache(0)
+		req.Equal(DefaultPublicKeyCacheSize, cache.capacity)
+
+		_, err := cache.PublicKey(Key{KeyType: KeyTypeOkp, Curve: CurveX25519, X: "eA"})
+		req.Error(err)
+		req.Equal(0, cache.Len())
+	})
+
+	t.Run("converts without caching when nil", func(t *testing.T) {
+		req := require.New(t)
+
+		var cache *PublicKeyCache
+
+		_, public, err := GenerateRSAKey(2048, "rsa")
+		req.NoError(err)
+
+		publicKey, err := cache.PublicKey(*public)
+		req.NoError(err)
+		req.NotNil(publicKey)
+		req.Equal(0, cache.Len())
+		cache.Purge()
+	})
+}
+
+func mustMarshalResponse(req *require.Assertions, response *Response) string {
+	data, err := json.Marshal(response)
+	req.NoError(err)
+	return string(data)
+}
+
+func BenchmarkPublicKey(b *testing.B) {
+	response, err := ParseResponse([]byte(testPublicJwksAuth0))
+
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("KeyToPublicKey", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			if _, err := KeyToPublicKey(response.Keys[0]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("KeyToPublicKey EC", func(b *testing.B) {
+		_, ecKey, err := GenerateECKey("P-384", "ec")
+
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+
+		for i := 0; i < b.N; i++ {
+			if _, err := KeyToPublicKey(*ecKey); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("Key.PublicKey", func(b *testing.B) {
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			if _, err := response.Keys[0].PublicKey(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("PublicKeyCache", func(b *testing.B) {
+		cache := NewPublicKeyCache(0)
+
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			if _, err := cache.PublicKey(response.Keys[0]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
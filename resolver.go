@@ -16,16 +16,18 @@ limitations under the License.
 package jwks
 
 import (
-	"encoding/json"
+	"bytes"
 	"github.com/pkg/errors"
+	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
-	"strings"
 )
 
 const (
 	ErrorInvalidStatusCodeMsg  = "could not fetch JWKS, status code was not 200 OK"
 	ErrorInvalidContentTypeMsg = "invalid content type, expected application/json"
+	ErrorResponseTooLargeMsg   = "JWKS response exceeds the maximum body size"
 )
 
 // Resolver takes in a string location and returns the Response and raw response (`[]byte`) JSON or an error
@@ -33,8 +35,30 @@ type Resolver interface {
 	Get(string) (*Response, []byte, error)
 }
 
-// HttpResolver implements Resolver and obtains JWKs responses via HTTP(S)
-type HttpResolver struct{}
+// HttpResolver implements Resolver and obtains JWKs responses via HTTP(S). Single JWK documents are wrapped into a
+// Response containing only that key.
+type HttpResolver struct {
+	// Client is used to fetch key sets, if nil http.DefaultClient is used
+	Client *http.Client
+
+	// TlsPolicy, if set, is enforced for every fetch and rejects plain HTTP
+	TlsPolicy *TlsPolicy
+
+	// MaxBodySize rejects responses larger than the given number of bytes, zero disables the limit
+	MaxBodySize int64
+
+	// Stream decodes JWK Sets directly from the response body one key at a time, see KeyDecoder, instead of reading
+	// the whole body first, which roughly halves peak memory for large sets. Members other than "keys" are ignored,
+	// single JWK documents are wrapped as for ParseResponse whatever their content type. The raw bytes returned by Get
+	// are nil unless RetainRaw is set.
+	Stream bool
+
+	// RetainRaw makes Get return the raw body when Stream is set
+	RetainRaw bool
+
+	// Limits bounds the size of fetched documents, if nil DefaultLimits are enforced
+	Limits *Limits
+}
 
 // HttpResolverError is a generic error type used to relay the the http.Response from a JWKS endpoint to external
 // code for inspection
@@ -44,30 +68,84 @@ type HttpResolverError struct {
 }
 
 func (j *HttpResolver) Get(url string) (*Response, []byte, error) {
+	if j.Stream {
+		return j.getStream(url)
+	}
 
-	resp, err := http.Get(url)
+	resp, body, err := httpGet(j.Client, j.TlsPolicy, j.MaxBodySize, url, "application/json", "application/jwk-set+json", "application/jwk+json")
 
 	if err != nil {
 		return nil, nil, err
 	}
 
-	if resp.StatusCode != http.StatusOK {
+	jwksResponse, err := parseResponse(body, limitsOrDefault(j.Limits))
+
+	if err != nil {
 		return nil, nil, &HttpResolverError{
 			Resp:  resp,
-			error: errors.New(ErrorInvalidStatusCodeMsg),
+			error: err,
 		}
 	}
 
-	contentType := strings.Split(resp.Header.Get("content-type"), ";")
+	return jwksResponse, body, nil
+}
+
+// getStream implements Get for Stream
+func (j *HttpResolver) getStream(url string) (*Response, []byte, error) {
+	resp, err := httpOpen(j.Client, j.TlsPolicy, url, "application/json", "application/jwk-set+json", "application/jwk+json")
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	body := limitBody(resp.Body, j.MaxBodySize)
+
+	var raw *bytes.Buffer
+
+	if j.RetainRaw {
+		raw = &bytes.Buffer{}
+		body = io.TeeReader(body, raw)
+	}
 
-	if contentType[0] != "application/json" && contentType[0] != "application/jwk-set+json" && contentType[0] != "application/jwk+json" {
+	response := &Response{Keys: []Key{}}
+
+	decoder := NewKeyDecoder(body)
+	decoder.Limits = j.Limits
+
+	err = decodeKeys(decoder, func(key *Key) error {
+		response.Keys = append(response.Keys, *key)
+		return nil
+	})
+
+	if err != nil {
 		return nil, nil, &HttpResolverError{
 			Resp:  resp,
-			error: errors.New(ErrorInvalidContentTypeMsg),
+			error: err,
 		}
 	}
 
-	body, err := ioutil.ReadAll(resp.Body)
+	if raw == nil {
+		return response, nil, nil
+	}
+
+	return response, raw.Bytes(), nil
+}
+
+// httpGet fetches url with client, http.DefaultClient if nil, and returns the response and its body if the status is
+// 200 OK and the content type is one of contentTypes. If policy is not nil it is applied to the client and checked
+// against the connection. If maxBodySize is greater than zero longer bodies are rejected. Errors after the request was
+// made are *HttpResolverError.
+func httpGet(client *http.Client, policy *TlsPolicy, maxBodySize int64, url string, contentTypes ...string) (*http.Response, []byte, error) {
+	resp, err := httpOpen(client, policy, url, contentTypes...)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	body, err := ioutil.ReadAll(limitBody(resp.Body, maxBodySize))
+	_ = resp.Body.Close()
 
 	if err != nil {
 		return nil, nil, &HttpResolverError{
@@ -76,15 +154,125 @@ func (j *HttpResolver) Get(url string) (*Response, []byte, error) {
 		}
 	}
 
-	jwksResponse := &Response{}
-	err = json.Unmarshal(body, jwksResponse)
+	return resp, body, nil
+}
+
+// httpOpen fetches url as httpGet does, but returns the response with its body unread. The caller must close the
+// body.
+func httpOpen(client *http.Client, policy *TlsPolicy, url string, contentTypes ...string) (*http.Response, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	if policy != nil {
+		var err error
+
+		if client, err = policy.client(client); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := client.Get(url)
 
 	if err != nil {
-		return nil, nil, &HttpResolverError{
+		if policy != nil {
+			client.CloseIdleConnections()
+		}
+
+		return nil, err
+	}
+
+	if policy != nil {
+		// the client has its own transport, release its connections once the body is closed
+		resp.Body = &idleClosingBody{ReadCloser: resp.Body, client: client}
+
+		if err = policy.check(resp.TLS); err != nil {
+			_ = resp.Body.Close()
+
+			return nil, &HttpResolverError{
+				Resp:  resp,
+				error: err,
+			}
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+
+		return nil, &HttpResolverError{
 			Resp:  resp,
-			error: err,
+			error: errors.New(ErrorInvalidStatusCodeMsg),
 		}
 	}
 
-	return jwksResponse, body, nil
+	// media types are case-insensitive, ParseMediaType lowercases them
+	contentType, _, _ := mime.ParseMediaType(resp.Header.Get("content-type"))
+
+	validContentType := false
+
+	for _, allowed := range contentTypes {
+		if contentType == allowed {
+			validContentType = true
+			break
+		}
+	}
+
+	if !validContentType {
+		_ = resp.Body.Close()
+
+		return nil, &HttpResolverError{
+			Resp:  resp,
+			error: errors.New(ErrorInvalidContentTypeMsg),
+		}
+	}
+
+	return resp, nil
+}
+
+// idleClosingBody closes the idle connections of client when the body is closed
+type idleClosingBody struct {
+	io.ReadCloser
+	client *http.Client
+}
+
+func (b *idleClosingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.client.CloseIdleConnections()
+
+	return err
+}
+
+// limitBody returns a reader failing with ErrorResponseTooLargeMsg once more than maxBodySize bytes are read from body,
+// or body itself if maxBodySize is zero or less
+func limitBody(body io.Reader, maxBodySize int64) io.Reader {
+	if maxBodySize <= 0 {
+		return body
+	}
+
+	return &limitedBody{reader: body, remaining: maxBodySize}
+}
+
+// limitedBody is returned by limitBody
+type limitedBody struct {
+	reader    io.Reader
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, errors.New(ErrorResponseTooLargeMsg)
+	}
+
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+
+	n, err := b.reader.Read(p)
+	b.remaining -= int64(n)
+
+	if b.remaining < 0 {
+		return 0, errors.New(ErrorResponseTooLargeMsg)
+	}
+
+	return n, err
 }
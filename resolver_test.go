@@ -19,7 +19,10 @@ import (
 	"context"
 	"github.com/stretchr/testify/require"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 func Test_HttpResolver(t *testing.T) {
@@ -31,6 +34,7 @@ func Test_HttpResolver(t *testing.T) {
 	urlWrongContentTypePath := "/invalid/content-type"
 	urlEmptyContentPath := "/invalid/no-content"
 	urlBadContentPath := "/invalid/mangled-json"
+	urlSingleKeyPath := "/single/jwk.json"
 
 	server := &http.Server{Addr: "0.0.0.0:" + port, Handler: http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
@@ -43,6 +47,9 @@ func Test_HttpResolver(t *testing.T) {
 		case urlEmptyContentPath:
 			rw.Header().Set("content-type", "application/json; charset=utf-8")
 			_, _ = rw.Write([]byte(""))
+		case urlSingleKeyPath:
+			rw.Header().Set("content-type", "application/jwk+json")
+			_, _ = rw.Write([]byte(testSingleJwk))
 		case urlBadContentPath:
 			rw.Header().Set("content-type", "application/json")
 			_, _ = rw.Write([]byte(`{"hello": invalid-json[]}`))
@@ -64,6 +71,18 @@ func Test_HttpResolver(t *testing.T) {
 		req.NoError(err)
 	}()
 
+	// wait for the server to accept connections, ListenAndServe may not have bound the port yet
+	req.Eventually(func() bool {
+		resp, err := http.Get(urlBase)
+
+		if err != nil {
+			return false
+		}
+
+		_ = resp.Body.Close()
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+
 	t.Run("can resolve and parse a valid JWKS response", func(t *testing.T) {
 		req := require.New(t)
 
@@ -75,6 +94,18 @@ func Test_HttpResolver(t *testing.T) {
 		req.Equal(testPublicJwksAuth0, string(rawPayload))
 	})
 
+	t.Run("can resolve and wrap a single JWK response", func(t *testing.T) {
+		req := require.New(t)
+
+		resolver := &HttpResolver{}
+
+		resp, rawPayload, err := resolver.Get(urlBase + urlSingleKeyPath)
+		req.NoError(err)
+		req.Len(resp.Keys, 1)
+		req.Equal("single", resp.Keys[0].KeyId)
+		req.Equal(testSingleJwk, string(rawPayload))
+	})
+
 	t.Run("can not resolve and parse a 404", func(t *testing.T) {
 		req := require.New(t)
 
@@ -119,3 +150,132 @@ func Test_HttpResolver(t *testing.T) {
 		req.Nil(rawPayload)
 	})
 }
+
+func Test_HttpResolverStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/single":
+			rw.Header().Set("content-type", "application/jwk+json")
+			_, _ = rw.Write([]byte(testSingleJwk))
+		case "/single-json":
+			rw.Header().Set("content-type", "application/json")
+			_, _ = rw.Write([]byte(testSingleJwk))
+		case "/mixed-case":
+			rw.Header().Set("content-type", "Application/JWK-Set+JSON; charset=utf-8")
+			_, _ = rw.Write([]byte(testPublicJwksAuth0))
+		case "/mangled":
+			rw.Header().Set("content-type", "application/json")
+			_, _ = rw.Write([]byte(`{"keys": [{"kty": 1}]}`))
+		default:
+			rw.Header().Set("content-type", "application/json")
+			_, _ = rw.Write([]byte(testPublicJwksAuth0))
+		}
+	}))
+	defer server.Close()
+
+	expected, err := ParseResponse([]byte(testPublicJwksAuth0))
+	require.NoError(t, err)
+
+	t.Run("can decode a set from the response body", func(t *testing.T) {
+		req := require.New(t)
+
+		resolver := &HttpResolver{Stream: true}
+
+		response, raw, err := resolver.Get(server.URL)
+		req.NoError(err)
+		req.Nil(raw)
+		req.Equal(expected.Keys, response.Keys)
+	})
+
+	t.Run("can retain the raw body", func(t *testing.T) {
+		req := require.New(t)
+
+		resolver := &HttpResolver{Stream: true, RetainRaw: true}
+
+		response, raw, err := resolver.Get(server.URL)
+		req.NoError(err)
+		req.Equal(testPublicJwksAuth0, string(raw))
+		req.Len(response.Keys, len(expected.Keys))
+	})
+
+	t.Run("can decode single JWK documents", func(t *testing.T) {
+		req := require.New(t)
+
+		resolver := &HttpResolver{Stream: true}
+
+		response, _, err := resolver.Get(server.URL + "/single")
+		req.NoError(err)
+		req.Len(response.Keys, 1)
+	})
+
+	t.Run("can decode single JWK documents served as JWK Sets", func(t *testing.T) {
+		req := require.New(t)
+
+		expected, err := ParseKey([]byte(testSingleJwk))
+		req.NoError(err)
+
+		for _, stream := range []bool{true, false} {
+			resolver := &HttpResolver{Stream: stream}
+
+			response, _, err := resolver.Get(server.URL + "/single-json")
+			req.NoError(err)
+			req.Len(response.Keys, 1)
+			req.Equal(*expected, response.Keys[0])
+		}
+	})
+
+	t.Run("accepts content types in any case", func(t *testing.T) {
+		req := require.New(t)
+
+		for _, stream := range []bool{true, false} {
+			resolver := &HttpResolver{Stream: stream}
+
+			response, _, err := resolver.Get(server.URL + "/mixed-case")
+			req.NoError(err)
+			req.Equal(expected.Keys, response.Keys)
+		}
+	})
+
+	t.Run("rejects malformed sets", func(t *testing.T) {
+		req := require.New(t)
+
+		resolver := &HttpResolver{Stream: true}
+
+		_, _, err := resolver.Get(server.URL + "/mangled")
+		req.IsType(&HttpResolverError{}, err)
+	})
+
+	t.Run("enforces its own limits", func(t *testing.T) {
+		req := require.New(t)
+
+		for _, stream := range []bool{true, false} {
+			resolver := &HttpResolver{Stream: stream, Limits: &Limits{MaxKeys: len(expected.Keys) - 1}}
+
+			_, _, err := resolver.Get(server.URL)
+			req.IsType(&HttpResolverError{}, err)
+			req.Contains(err.Error(), ErrorTooManyKeysMsg)
+
+			resolver.Limits = nil
+
+			_, _, err = resolver.Get(server.URL)
+			req.NoError(err)
+		}
+	})
+
+	t.Run("rejects bodies larger than the limit", func(t *testing.T) {
+		req := require.New(t)
+
+		for _, stream := range []bool{true, false} {
+			resolver := &HttpResolver{Stream: stream, MaxBodySize: int64(len(testPublicJwksAuth0)) - 1}
+
+			_, _, err := resolver.Get(server.URL)
+			req.IsType(&HttpResolverError{}, err)
+			req.True(strings.Contains(err.Error(), ErrorResponseTooLargeMsg), err.Error())
+
+			resolver.MaxBodySize = int64(len(testPublicJwksAuth0))
+
+			_, _, err = resolver.Get(server.URL)
+			req.NoError(err)
+		}
+	})
+}
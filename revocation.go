@@ -0,0 +1,135 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// RevocationList is a document listing revoked keys, for example:
+//
+//	{"revoked": [{"kid": "2011-04-29", "revoked_at": 1700000000, "reason": "compromised"}]}
+type RevocationList struct {
+	Revoked []Revocation `json:"revoked"`
+}
+
+// Revocation identifies a revoked key by kid, RFC 7638 SHA-256 thumbprint, or both. If both are set, a key must match
+// both. RevokedAt is the time from which the key is revoked; if absent the key is revoked at all times.
+type Revocation struct {
+	KeyId      string      `json:"kid,omitempty"`
+	Thumbprint string      `json:"thumbprint,omitempty"`
+	RevokedAt  NumericDate `json:"revoked_at,omitempty"`
+	Reason     string      `json:"reason,omitempty"`
+}
+
+// ParseRevocationList parses a revocation document
+func ParseRevocationList(data []byte) (*RevocationList, error) {
+	list := &RevocationList{}
+
+	if err := json.Unmarshal(data, list); err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// IsRevoked returns true if key is revoked at t. A nil list revokes nothing.
+func (l *RevocationList) IsRevoked(key *Key, t time.Time) bool {
+	if l == nil {
+		return false
+	}
+
+	thumbprint := ""
+
+	for _, revocation := range l.Revoked {
+		if revocation.KeyId == "" && revocation.Thumbprint == "" {
+			continue
+		}
+
+		if revocation.RevokedAt.IsSet() && t.Before(revocation.RevokedAt.Time()) {
+			continue
+		}
+
+		if revocation.KeyId != "" && revocation.KeyId != key.KeyId {
+			continue
+		}
+
+		if revocation.Thumbprint != "" {
+			if thumbprint == "" {
+				var err error
+
+				if thumbprint, err = key.Thumbprint(crypto.SHA256); err != nil {
+					continue
+				}
+			}
+
+			if revocation.Thumbprint != thumbprint {
+				continue
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// WithoutRevoked returns a Response containing the keys not revoked by list at t
+func (r *Response) WithoutRevoked(list *RevocationList, t time.Time) *Response {
+	return &Response{
+		Keys: r.Filter(func(key *Key) bool {
+			return !list.IsRevoked(key, t)
+		}),
+	}
+}
+
+// RevocationResolver takes in a string location and returns the RevocationList and raw response (`[]byte`) JSON or an
+// error
+type RevocationResolver interface {
+	Get(string) (*RevocationList, []byte, error)
+}
+
+// HttpRevocationResolver implements RevocationResolver and obtains revocation documents via HTTP(S)
+type HttpRevocationResolver struct {
+	// Client is used to fetch revocation lists, if nil http.DefaultClient is used
+	Client *http.Client
+
+	// TlsPolicy, if set, is enforced for every fetch and rejects plain HTTP
+	TlsPolicy *TlsPolicy
+}
+
+func (j *HttpRevocationResolver) Get(url string) (*RevocationList, []byte, error) {
+	resp, body, err := httpGet(j.Client, j.TlsPolicy, 0, url, "application/json")
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	list, err := ParseRevocationList(body)
+
+	if err != nil {
+		return nil, nil, &HttpResolverError{
+			Resp:  resp,
+			error: err,
+		}
+	}
+
+	return list, body, nil
+}
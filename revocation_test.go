@@ -0,0 +1,145 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_RevocationList(t *testing.T) {
+	response := &Response{}
+	require.NoError(t, json.Unmarshal([]byte(testJwksRfc7517Examples), response))
+
+	thumbprint, err := response.Keys[0].Thumbprint(crypto.SHA256)
+	require.NoError(t, err)
+
+	now := time.Now()
+
+	t.Run("can parse a revocation document", func(t *testing.T) {
+		req := require.New(t)
+
+		list, err := ParseRevocationList([]byte(`{"revoked":[{"kid":"2011-04-29","revoked_at":1700000000,"reason":"compromised"}]}`))
+		req.NoError(err)
+		req.Len(list.Revoked, 1)
+		req.Equal("2011-04-29", list.Revoked[0].KeyId)
+		req.Equal(NumericDate(1700000000), list.Revoked[0].RevokedAt)
+		req.Equal("compromised", list.Revoked[0].Reason)
+	})
+
+	t.Run("can revoke by kid and thumbprint", func(t *testing.T) {
+		req := require.New(t)
+
+		list := &RevocationList{Revoked: []Revocation{
+			{KeyId: "2011-04-29"},
+			{Thumbprint: thumbprint},
+		}}
+
+		req.True(list.IsRevoked(&response.Keys[0], now))
+		req.True(list.IsRevoked(&response.Keys[1], now))
+		req.False(list.IsRevoked(&response.Keys[2], now))
+
+		remaining := response.WithoutRevoked(list, now)
+		req.Len(remaining.Keys, 1)
+		req.Equal("juliet@capulet.lit", remaining.Keys[0].KeyId)
+	})
+
+	t.Run("requires both kid and thumbprint to match when both are set", func(t *testing.T) {
+		req := require.New(t)
+
+		list := &RevocationList{Revoked: []Revocation{{KeyId: "2011-04-29", Thumbprint: thumbprint}}}
+		req.False(list.IsRevoked(&response.Keys[0], now))
+		req.False(list.IsRevoked(&response.Keys[1], now))
+	})
+
+	t.Run("honors the revocation time", func(t *testing.T) {
+		req := require.New(t)
+
+		list := &RevocationList{Revoked: []Revocation{{KeyId: "1", RevokedAt: NewNumericDate(now)}}}
+		req.False(list.IsRevoked(&response.Keys[0], now.Add(-time.Second)))
+		req.True(list.IsRevoked(&response.Keys[0], now))
+	})
+
+	t.Run("a nil list revokes nothing", func(t *testing.T) {
+		req := require.New(t)
+
+		var list *RevocationList
+		req.False(list.IsRevoked(&response.Keys[0], now))
+		req.Len(response.WithoutRevoked(nil, now).Keys, 3)
+	})
+
+	t.Run("excludes revoked keys from a Set", func(t *testing.T) {
+		req := require.New(t)
+
+		set := NewSetFromResponse(response)
+		set.SetRevocations(&RevocationList{Revoked: []Revocation{{KeyId: "1"}}})
+
+		_, ok := set.Get("1")
+		req.False(ok)
+		req.Len(set.Response().Keys, 2)
+		req.Equal(3, set.Len())
+
+		set.SetRevocations(nil)
+		_, ok = set.Get("1")
+		req.True(ok)
+	})
+}
+
+func Test_HttpRevocationResolver(t *testing.T) {
+	doc := `{"revoked":[{"kid":"1"}]}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/revoked.json":
+			rw.Header().Set("content-type", "application/json")
+			_, _ = rw.Write([]byte(doc))
+		case "/mangled.json":
+			rw.Header().Set("content-type", "application/json")
+			_, _ = rw.Write([]byte(`{"revoked": {`))
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	t.Run("can resolve a revocation document", func(t *testing.T) {
+		req := require.New(t)
+
+		list, raw, err := (&HttpRevocationResolver{}).Get(server.URL + "/revoked.json")
+		req.NoError(err)
+		req.Equal(doc, string(raw))
+		req.Len(list.Revoked, 1)
+	})
+
+	t.Run("can not resolve a 404 or invalid document", func(t *testing.T) {
+		req := require.New(t)
+
+		for _, path := range []string{"/missing.json", "/mangled.json"} {
+			list, raw, err := (&HttpRevocationResolver{}).Get(server.URL + path)
+
+			var resolverErr *HttpResolverError
+			req.ErrorAs(err, &resolverErr)
+			req.Nil(list)
+			req.Nil(raw)
+		}
+	})
+}
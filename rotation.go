@@ -0,0 +1,367 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package jwks
+
+import (
+	"crypto"
+	"fmt"
+	"github.com/pkg/errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultRetention is how long a RotationManager keeps retired keys verifiable unless configured with WithRetention
+const DefaultRetention = 24 * time.Hour
+
+// RotationOption configures a RotationManager
+type RotationOption func(*RotationManager)
+
+// WithRetention sets how long retired keys remain in the published set after rotation. It should cover the lifetime
+// of tokens signed just before the rotation plus the time verifiers cache the set, see RotationManager.CheckOverlap.
+func WithRetention(retention time.Duration) RotationOption {
+	return func(m *RotationManager) {
+		m.retention = retention
+	}
+}
+
+// WithTokenLifetime sets the retention to tokenLifetime plus cacheTtl, the longest a verifier may need a retired key:
+// a token signed just before rotation stays valid for tokenLifetime and the verifier may only refetch the set after
+// cacheTtl
+func WithTokenLifetime(tokenLifetime time.Duration, cacheTtl time.Duration) RotationOption {
+	return WithRetention(tokenLifetime + cacheTtl)
+}
+
+// KeyState is the rotation state of a key managed by a RotationManager
+type KeyState string
+
+const (
+	// KeyStateNext keys are published but not yet used for signing, so verifier caches learn them before activation
+	KeyStateNext KeyState = "next"
+
+	// KeyStateActive is the state of the key used for signing
+	KeyStateActive KeyState = "active"
+
+	// KeyStateRetired keys are no longer used for signing but stay published until they expire, so tokens signed
+	// with them remain verifiable
+	KeyStateRetired KeyState = "retired"
+)
+
+// rotationStateMember is the additional member the RotationManager records key states in. It is kept in the KeyStore,
+// so the states survive restarts, and removed from published keys.
+const rotationStateMember = "x-rotation-state"
+
+// RotationManager manages the lifecycle of signing keys kept in a KeyStore. One key is active and used for signing,
+// one next key is published ahead of its activation, and retired keys stay published until their exp, the retention
+// period after retirement, after which they are deleted. It is safe for concurrent use and a ResponseSource serving
+// PublicSet.
+type RotationManager struct {
+	lock      sync.Mutex
+	store     KeyStore
+	generate  KeyPairGenerator
+	retention time.Duration
+	signers   SignerFactory
+
+	subscribers rotationSubscribers
+}
+
+// NewRotationManager returns a RotationManager for the keys in store, generating keys with generate. States recorded
+// in the store by a previous RotationManager are resumed; missing active and next keys are generated, promoting an
+// existing next key if there is no active key.
+func NewRotationManager(store KeyStore, generate KeyPairGenerator, opts ...RotationOption) (*RotationManager, error) {
+	manager := &RotationManager{
+		store:     store,
+		generate:  generate,
+		retention: DefaultRetention,
+		signers:   KeyToSigner,
+	}
+
+	for _, opt := range opts {
+		opt(manager)
+	}
+
+	manager.lock.Lock()
+	defer manager.lock.Unlock()
+
+	keys, err := manager.keysLocked()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keys[KeyStateActive]) == 0 {
+		if len(keys[KeyStateNext]) > 0 {
+			if err := manager.setStateLocked(keys[KeyStateNext][0], KeyStateActive); err != nil {
+				return nil, err
+			}
+		} else if _, err := manager.generateLocked(KeyStateActive); err != nil {
+			return nil, err
+		}
+
+		if keys, err = manager.keysLocked(); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(keys[KeyStateNext]) == 0 {
+		if _, err := manager.generateLocked(KeyStateNext); err != nil {
+			return nil, err
+		}
+	}
+
+	return manager, nil
+}
+
+// WithSignerFactory sets how CurrentSigner obtains signers for keys, by default KeyToSigner. Use it for keys whose
+// private key material is held externally, see KmsKeyPairGenerator.
+func WithSignerFactory(factory SignerFactory) RotationOption {
+	return func(m *RotationManager) {
+		m.signers = factory
+	}
+}
+
+// Retention returns how long retired keys remain in the published set, see WithRetention
+func (m *RotationManager) Retention() time.Duration {
+	return m.retention
+}
+
+// CheckOverlap returns warnings if the retention is too short for keys served with a Cache-Control max-age of
+// maxAge, see WithMaxAge. Verifiers that cached the set may not learn about a retirement before maxAge has passed, so
+// a shorter retention removes keys that cached copies and tokens in flight still refer to.
+func (m *RotationManager) CheckOverlap(maxAge time.Duration) []error {
+	var warnings []error
+
+	if m.retention <= 0 {
+		warnings = append(warnings, fmt.Errorf("retention %s removes retired keys immediately", m.retention))
+	} else if m.retention < maxAge {
+		warnings = append(warnings, fmt.Errorf("retention %s is shorter than the Cache-Control max-age %s", m.retention, maxAge))
+	}
+
+	return warnings
+}
+
+// ActiveKey returns a copy of the private key currently used for signing
+func (m *RotationManager) ActiveKey() (Key, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	keys, err := m.keysLocked()
+
+	if err != nil {
+		return Key{}, err
+	}
+
+	if len(keys[KeyStateActive]) == 0 {
+		return Key{}, errors.New("no active key")
+	}
+
+	return keys[KeyStateActive][0].withoutRotationState(), nil
+}
+
+// CurrentSigner returns a signer for the active key from the SignerFactory, by default a *KeySigner, see
+// KeyToSigner. Its kid is available from ActiveKey.
+func (m *RotationManager) CurrentSigner() (crypto.Signer, error) {
+	key, err := m.ActiveKey()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return m.signers(key)
+}
+
+// PublicSet returns the public keys to publish: the active key, the next key, and the retired keys that have not
+// expired yet, in that order
+func (m *RotationManager) PublicSet() (*Response, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	keys, err := m.keysLocked()
+
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &Response{
+		Keys: []Key{},
+	}
+
+	now := time.Now()
+
+	for _, state := range []KeyState{KeyStateActive, KeyStateNext, KeyStateRetired} {
+		for _, key := range keys[state] {
+			if key.IsActiveAt(now) {
+				ret.Keys = append(ret.Keys, key.withoutRotationState())
+			}
+		}
+	}
+
+	return ret.PublicOnly(), nil
+}
+
+// Response returns PublicSet or nil if the key store fails, so the manager can be served with Handler
+func (m *RotationManager) Response() *Response {
+	response, err := m.PublicSet()
+
+	if err != nil {
+		return nil
+	}
+
+	return response
+}
+
+// Rotate activates the next key, retires the active key, publishes a new next key, and deletes retired keys that have
+// expired. The retired key expires after the retention period. Subscribers are notified of every transition after
+// the manager is unlocked, including those made before an error.
+func (m *RotationManager) Rotate() error {
+	events, err := m.rotate()
+	m.subscribers.publish(events)
+
+	return err
+}
+
+func (m *RotationManager) rotate() ([]RotationEvent, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	keys, err := m.keysLocked()
+
+	if err != nil {
+		return nil, err
+	}
+
+	var events []RotationEvent
+	now := time.Now()
+
+	next, err := m.generateLocked(KeyStateNext)
+
+	if err != nil {
+		return events, err
+	}
+
+	events = append(events, newRotationEvent(KeyPrePublished, *next, now))
+
+	for _, key := range keys[KeyStateNext] {
+		if err := m.setStateLocked(key, KeyStateActive); err != nil {
+			return events, err
+		}
+
+		events = append(events, newRotationEvent(KeyActivated, key, now))
+	}
+
+	for _, key := range keys[KeyStateActive] {
+		key.ExpiresAt = NewNumericDate(now.Add(m.retention))
+
+		if err := m.setStateLocked(key, KeyStateRetired); err != nil {
+			return events, err
+		}
+
+		events = append(events, newRotationEvent(KeyRetired, key, now))
+	}
+
+	for _, key := range keys[KeyStateRetired] {
+		if !key.IsActiveAt(now) {
+			if err := m.store.Delete(key.KeyId); err != nil {
+				return events, err
+			}
+
+			events = append(events, newRotationEvent(KeyDestroyed, key, now))
+		}
+	}
+
+	return events, nil
+}
+
+// nextPublishedAt returns when the next key was generated and published
+func (m *RotationManager) nextPublishedAt() (time.Time, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	keys, err := m.keysLocked()
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if len(keys[KeyStateNext]) == 0 {
+		return time.Time{}, errors.New("no next key")
+	}
+
+	return keys[KeyStateNext][0].IssuedAt.Time(), nil
+}
+
+// keysLocked returns the stored keys by state, most recently issued first. Keys without a state are ignored.
+func (m *RotationManager) keysLocked() (map[KeyState][]Key, error) {
+	stored, err := m.store.List()
+
+	if err != nil {
+		return nil, err
+	}
+
+	ret := map[KeyState][]Key{}
+
+	for _, key := range stored {
+		var state KeyState
+
+		if found, err := key.GetExtra(rotationStateMember, &state); !found || err != nil {
+			continue
+		}
+
+		ret[state] = append(ret[state], key)
+	}
+
+	for _, keys := range ret {
+		sort.SliceStable(keys, func(i, j int) bool {
+			return keys[i].IssuedAt > keys[j].IssuedAt
+		})
+	}
+
+	return ret, nil
+}
+
+// generateLocked generates a key in the given state and saves it to the store
+func (m *RotationManager) generateLocked(state KeyState) (*Key, error) {
+	private, _, err := m.generate("")
+
+	if err != nil {
+		return nil, fmt.Errorf("could not generate key: %s", err)
+	}
+
+	private.IssuedAt = NewNumericDate(time.Now())
+
+	if err := m.setStateLocked(*private, state); err != nil {
+		return nil, err
+	}
+
+	return private, nil
+}
+
+// setStateLocked records state in key and saves it to the store
+func (m *RotationManager) setStateLocked(key Key, state KeyState) error {
+	if err := key.SetExtra(rotationStateMember, state); err != nil {
+		return err
+	}
+
+	return m.store.Save(key)
+}
+
+// withoutRotationState returns a copy of the key without the rotation state member
+func (k Key) withoutRotationState() Key {
+	ret := k.clone()
+	ret.DeleteExtra(rotationStateMember)
+
+	return ret
+}
@@ -0,0 +1,201 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_RotationManager(t *testing.T) {
+	kids := func(response *Response) []string {
+		var ret []string
+
+		for _, key := range response.Keys {
+			ret = append(ret, key.KeyId)
+		}
+
+		return ret
+	}
+
+	t.Run("generates an active and a next key", func(t *testing.T) {
+		req := require.New(t)
+
+		store := NewMemoryKeyStore()
+		manager, err := NewRotationManager(store, ECKeyPairGenerator("P-256"))
+		req.NoError(err)
+
+		active, err := manager.ActiveKey()
+		req.NoError(err)
+		req.True(active.IsPrivate())
+		req.Nil(active.AdditionalMembers)
+
+		public, err := manager.PublicSet()
+		req.NoError(err)
+		req.Len(public.Keys, 2)
+		req.Equal(active.KeyId, public.Keys[0].KeyId)
+
+		for _, key := range public.Keys {
+			req.True(key.IsPublic())
+			req.Nil(key.AdditionalMembers)
+		}
+
+		stored, err := store.List()
+		req.NoError(err)
+		req.Len(stored, 2)
+	})
+
+	t.Run("signs with the active key", func(t *testing.T) {
+		req := require.New(t)
+
+		manager, err := NewRotationManager(NewMemoryKeyStore(), ECKeyPairGenerator("P-256"))
+		req.NoError(err)
+
+		signer, err := manager.CurrentSigner()
+		req.NoError(err)
+
+		digest := sha256.Sum256([]byte("payload"))
+		signature, err := signer.Sign(nil, digest[:], crypto.SHA256)
+		req.NoError(err)
+
+		active, err := manager.ActiveKey()
+		req.NoError(err)
+
+		public, err := manager.PublicSet()
+		req.NoError(err)
+
+		publicKey, err := KeyToPublicKey(*public.ByKid(active.KeyId))
+		req.NoError(err)
+		req.True(ecdsa.VerifyASN1(publicKey.(*ecdsa.PublicKey), digest[:], signature))
+	})
+
+	t.Run("can rotate keys", func(t *testing.T) {
+		req := require.New(t)
+
+		manager, err := NewRotationManager(NewMemoryKeyStore(), ECKeyPairGenerator("P-256"))
+		req.NoError(err)
+
+		before, err := manager.PublicSet()
+		req.NoError(err)
+
+		req.NoError(manager.Rotate())
+
+		after, err := manager.PublicSet()
+		req.NoError(err)
+		req.Len(after.Keys, 3)
+
+		active, err := manager.ActiveKey()
+		req.NoError(err)
+
+		req.Equal(before.Keys[1].KeyId, active.KeyId)
+		req.Equal(active.KeyId, after.Keys[0].KeyId)
+		req.NotContains(kids(before), after.Keys[1].KeyId)
+		req.Equal(before.Keys[0].KeyId, after.Keys[2].KeyId)
+
+		retired := after.Keys[2]
+		req.True(retired.ExpiresAt.IsSet())
+		req.WithinDuration(time.Now().Add(DefaultRetention), retired.ExpiresAt.Time(), time.Minute)
+	})
+
+	t.Run("deletes expired retired keys", func(t *testing.T) {
+		req := require.New(t)
+
+		store := NewMemoryKeyStore()
+		manager, err := NewRotationManager(store, ECKeyPairGenerator("P-256"), WithRetention(-time.Second))
+		req.NoError(err)
+
+		first, err := manager.ActiveKey()
+		req.NoError(err)
+
+		req.NoError(manager.Rotate())
+
+		public, err := manager.PublicSet()
+		req.NoError(err)
+		req.NotContains(kids(public), first.KeyId)
+
+		_, err = store.Load(first.KeyId)
+		req.NoError(err)
+
+		req.NoError(manager.Rotate())
+
+		_, err = store.Load(first.KeyId)
+		req.IsType(&KeyNotFoundError{}, err)
+	})
+
+	t.Run("resumes the states recorded in the store", func(t *testing.T) {
+		req := require.New(t)
+
+		store := NewMemoryKeyStore()
+		manager, err := NewRotationManager(store, ECKeyPairGenerator("P-256"))
+		req.NoError(err)
+		req.NoError(manager.Rotate())
+
+		expected, err := manager.PublicSet()
+		req.NoError(err)
+
+		resumed, err := NewRotationManager(store, ECKeyPairGenerator("P-256"))
+		req.NoError(err)
+
+		actual, err := resumed.PublicSet()
+		req.NoError(err)
+		req.Equal(kids(expected), kids(actual))
+	})
+
+	t.Run("can be served by a Handler", func(t *testing.T) {
+		req := require.New(t)
+
+		manager, err := NewRotationManager(NewMemoryKeyStore(), OKPKeyPairGenerator(CurveEd25519))
+		req.NoError(err)
+
+		var source ResponseSource = manager
+		req.Len(source.Response().Keys, 2)
+	})
+}
+
+func Test_RotationRetention(t *testing.T) {
+	t.Run("can configure the retention", func(t *testing.T) {
+		req := require.New(t)
+
+		manager, err := NewRotationManager(NewMemoryKeyStore(), ECKeyPairGenerator("P-256"), WithTokenLifetime(time.Hour, 10*time.Minute))
+		req.NoError(err)
+		req.Equal(70*time.Minute, manager.Retention())
+
+		req.NoError(manager.Rotate())
+
+		public, err := manager.PublicSet()
+		req.NoError(err)
+		req.WithinDuration(time.Now().Add(70*time.Minute), public.Keys[2].ExpiresAt.Time(), time.Minute)
+	})
+
+	t.Run("warns if the retention is shorter than the max-age", func(t *testing.T) {
+		req := require.New(t)
+
+		manager, err := NewRotationManager(NewMemoryKeyStore(), ECKeyPairGenerator("P-256"), WithRetention(time.Minute))
+		req.NoError(err)
+
+		req.Empty(manager.CheckOverlap(time.Minute))
+		req.Len(manager.CheckOverlap(DefaultHandlerMaxAge), 1)
+
+		manager, err = NewRotationManager(NewMemoryKeyStore(), ECKeyPairGenerator("P-256"), WithRetention(0))
+		req.NoError(err)
+		req.Len(manager.CheckOverlap(0), 1)
+	})
+}
@@ -0,0 +1,310 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package jwks
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const ErrorRotationIntervalMsg = "rotation interval must be positive"
+
+// RotationSchedule decides when a RotationScheduler rotates keys
+type RotationSchedule interface {
+	// Next returns the first rotation time after the given time
+	Next(after time.Time) time.Time
+}
+
+// EverySchedule returns a RotationSchedule rotating every interval. NewRotationScheduler rejects intervals that are
+// not positive.
+func EverySchedule(interval time.Duration) RotationSchedule {
+	return everySchedule(interval)
+}
+
+type everySchedule time.Duration
+
+func (s everySchedule) Next(after time.Time) time.Time {
+	if s <= 0 {
+		return time.Time{}
+	}
+
+	return after.Add(time.Duration(s))
+}
+
+// CronSchedule is a RotationSchedule defined by a standard five field cron expression (minute, hour, day of month,
+// month, day of week) evaluated in the location of the time passed to Next. Fields accept "*", numbers, ranges
+// ("1-5"), steps ("*/15", "0-30/10"), and comma separated lists. As in cron, if both day fields are restricted a day
+// matches if either does. The macros @hourly, @daily, @weekly, @monthly, and @yearly are supported.
+type CronSchedule struct {
+	minutes     uint64
+	hours       uint64
+	daysOfMonth uint64
+	months      uint64
+	daysOfWeek  uint64
+	anyDay      bool
+}
+
+var cronMacros = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+}
+
+// ParseCronSchedule parses a cron expression, see CronSchedule
+func ParseCronSchedule(expr string) (*CronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+
+	if macro, ok := cronMacros[expr]; ok {
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields, got %d", expr, len(fields))
+	}
+
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	masks := [5]uint64{}
+
+	for i, field := range fields {
+		mask, err := parseCronField(field, bounds[i][0], bounds[i][1])
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %s", expr, err)
+		}
+
+		masks[i] = mask
+	}
+
+	// 7 is an alias for Sunday
+	if masks[4]&(1<<7) != 0 {
+		masks[4] |= 1
+	}
+
+	return &CronSchedule{
+		minutes:     masks[0],
+		hours:       masks[1],
+		daysOfMonth: masks[2],
+		months:      masks[3],
+		daysOfWeek:  masks[4],
+		anyDay:      fields[2] == "*" || fields[4] == "*",
+	}, nil
+}
+
+// parseCronField returns the bit mask of the values a cron field matches
+func parseCronField(field string, lowest int, highest int) (uint64, error) {
+	var mask uint64
+
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+
+			rangePart = part[:i]
+		}
+
+		low, high := lowest, highest
+
+		if rangePart != "*" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+
+			if low, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("invalid value in %q", part)
+			}
+
+			high = low
+
+			if len(bounds) == 2 {
+				if high, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("invalid value in %q", part)
+				}
+			} else if step > 1 {
+				high = highest
+			}
+		}
+
+		if low < lowest || high > highest || low > high {
+			return 0, fmt.Errorf("%q is out of range %d-%d", part, lowest, highest)
+		}
+
+		for value := low; value <= high; value += step {
+			mask |= 1 << uint(value)
+		}
+	}
+
+	return mask, nil
+}
+
+// Next returns the first time after the given time, truncated to the minute, that matches the schedule. It returns the
+// zero time if there is none within five years, for example for February 30th.
+func (s *CronSchedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.months&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !s.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if s.hours&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if s.minutes&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t
+	}
+
+	return time.Time{}
+}
+
+func (s *CronSchedule) matchesDay(t time.Time) bool {
+	dayOfMonth := s.daysOfMonth&(1<<uint(t.Day())) != 0
+	dayOfWeek := s.daysOfWeek&(1<<uint(t.Weekday())) != 0
+
+	if s.anyDay {
+		return dayOfMonth && dayOfWeek
+	}
+
+	return dayOfMonth || dayOfWeek
+}
+
+// RotationScheduler rotates the keys of a RotationManager according to a RotationSchedule. Rotation is postponed
+// until the next key has been published for at least the pre-publication period, so verifier caches have learned it
+// before it is used for signing.
+type RotationScheduler struct {
+	manager    *RotationManager
+	schedule   RotationSchedule
+	prePublish time.Duration
+
+	// OnError is called with errors from scheduled rotations if not nil. Failed rotations are retried at the next
+	// scheduled time.
+	OnError func(err error)
+
+	lock sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewRotationScheduler returns a stopped RotationScheduler for manager or an error if schedule is an EverySchedule
+// with an interval that is not positive
+func NewRotationScheduler(manager *RotationManager, schedule RotationSchedule, prePublish time.Duration) (*RotationScheduler, error) {
+	if every, ok := schedule.(everySchedule); ok && every <= 0 {
+		return nil, errors.New(ErrorRotationIntervalMsg)
+	}
+
+	return &RotationScheduler{
+		manager:    manager,
+		schedule:   schedule,
+		prePublish: prePublish,
+	}, nil
+}
+
+// CheckOverlap returns the warnings of RotationManager.CheckOverlap and a warning if the pre-publication period is
+// shorter than the Cache-Control max-age maxAge, as verifiers may then not know the next key when it is activated
+func (s *RotationScheduler) CheckOverlap(maxAge time.Duration) []error {
+	warnings := s.manager.CheckOverlap(maxAge)
+
+	if s.prePublish < maxAge {
+		warnings = append(warnings, fmt.Errorf("pre-publication %s is shorter than the Cache-Control max-age %s", s.prePublish, maxAge))
+	}
+
+	return warnings
+}
+
+// Start starts rotating keys in a background goroutine. Calling Start on a started scheduler does nothing.
+func (s *RotationScheduler) Start() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.stop != nil {
+		return
+	}
+
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+
+	go s.run(s.stop, s.done)
+}
+
+// Stop stops the scheduler and waits for a rotation in progress to finish
+func (s *RotationScheduler) Stop() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.stop == nil {
+		return
+	}
+
+	close(s.stop)
+	<-s.done
+
+	s.stop = nil
+	s.done = nil
+}
+
+func (s *RotationScheduler) run(stop chan struct{}, done chan struct{}) {
+	defer close(done)
+
+	next := s.schedule.Next(time.Now())
+
+	for !next.IsZero() {
+		if published, err := s.manager.nextPublishedAt(); err == nil && published.Add(s.prePublish).After(next) {
+			next = published.Add(s.prePublish)
+		}
+
+		timer := time.NewTimer(time.Until(next))
+
+		select {
+		case <-stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if err := s.manager.Rotate(); err != nil && s.OnError != nil {
+			s.OnError(err)
+		}
+
+		next = s.schedule.Next(time.Now())
+	}
+}
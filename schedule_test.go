@@ -0,0 +1,181 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"github.com/stretchr/testify/require"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_CronSchedule(t *testing.T) {
+	base := time.Date(2024, time.January, 31, 10, 17, 30, 0, time.UTC)
+
+	t.Run("can compute the next matching time", func(t *testing.T) {
+		req := require.New(t)
+
+		for _, test := range []struct {
+			expr     string
+			expected time.Time
+		}{
+			{"* * * * *", time.Date(2024, time.January, 31, 10, 18, 0, 0, time.UTC)},
+			{"*/15 * * * *", time.Date(2024, time.January, 31, 10, 30, 0, 0, time.UTC)},
+			{"0 3 * * *", time.Date(2024, time.February, 1, 3, 0, 0, 0, time.UTC)},
+			{"0 0 1 */3 *", time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC)},
+			{"30 9 * * 1-5", time.Date(2024, time.February, 1, 9, 30, 0, 0, time.UTC)},
+			{"0 0 * * 7", time.Date(2024, time.February, 4, 0, 0, 0, 0, time.UTC)},
+			{"0 0 15 * 0", time.Date(2024, time.February, 4, 0, 0, 0, 0, time.UTC)},
+			{"0 0 29 2 *", time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+			{"@monthly", time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)},
+			{"5,10 11 * * *", time.Date(2024, time.January, 31, 11, 5, 0, 0, time.UTC)},
+		} {
+			schedule, err := ParseCronSchedule(test.expr)
+			req.NoError(err, test.expr)
+			req.Equal(test.expected, schedule.Next(base), test.expr)
+		}
+	})
+
+	t.Run("returns the zero time for impossible dates", func(t *testing.T) {
+		req := require.New(t)
+
+		schedule, err := ParseCronSchedule("0 0 30 2 *")
+		req.NoError(err)
+		req.True(schedule.Next(base).IsZero())
+	})
+
+	t.Run("rejects invalid expressions", func(t *testing.T) {
+		req := require.New(t)
+
+		for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+			_, err := ParseCronSchedule(expr)
+			req.Error(err, expr)
+		}
+	})
+}
+
+func Test_RotationScheduler(t *testing.T) {
+	t.Run("rotates on schedule", func(t *testing.T) {
+		req := require.New(t)
+
+		manager, err := NewRotationManager(NewMemoryKeyStore(), ECKeyPairGenerator("P-256"))
+		req.NoError(err)
+
+		first, err := manager.ActiveKey()
+		req.NoError(err)
+
+		scheduler, err := NewRotationScheduler(manager, EverySchedule(10*time.Millisecond), 0)
+		req.NoError(err)
+
+		scheduler.Start()
+		scheduler.Start()
+
+		req.Eventually(func() bool {
+			active, err := manager.ActiveKey()
+			return err == nil && active.KeyId != first.KeyId
+		}, 5*time.Second, 5*time.Millisecond)
+
+		scheduler.Stop()
+		scheduler.Stop()
+	})
+
+	t.Run("postpones rotation until the next key was pre-published", func(t *testing.T) {
+		req := require.New(t)
+
+		manager, err := NewRotationManager(NewMemoryKeyStore(), ECKeyPairGenerator("P-256"))
+		req.NoError(err)
+
+		first, err := manager.ActiveKey()
+		req.NoError(err)
+
+		scheduler, err := NewRotationScheduler(manager, EverySchedule(time.Millisecond), time.Hour)
+		req.NoError(err)
+
+		scheduler.Start()
+		time.Sleep(50 * time.Millisecond)
+		scheduler.Stop()
+
+		active, err := manager.ActiveKey()
+		req.NoError(err)
+		req.Equal(first.KeyId, active.KeyId)
+	})
+
+	t.Run("reports rotation errors", func(t *testing.T) {
+		req := require.New(t)
+
+		generate := ECKeyPairGenerator("P-256")
+		var fail int32
+
+		manager, err := NewRotationManager(NewMemoryKeyStore(), func(kid string, opts ...KeyOption) (*Key, *Key, error) {
+			if atomic.LoadInt32(&fail) == 1 {
+				return GenerateECKey("P-224", kid, opts...)
+			}
+
+			return generate(kid, opts...)
+		})
+		req.NoError(err)
+
+		atomic.StoreInt32(&fail, 1)
+
+		var errs int32
+		scheduler, err := NewRotationScheduler(manager, EverySchedule(10*time.Millisecond), 0)
+		req.NoError(err)
+
+		scheduler.OnError = func(err error) {
+			atomic.AddInt32(&errs, 1)
+		}
+
+		scheduler.Start()
+		defer scheduler.Stop()
+
+		req.Eventually(func() bool {
+			return atomic.LoadInt32(&errs) > 0
+		}, 5*time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("rejects intervals that are not positive", func(t *testing.T) {
+		req := require.New(t)
+
+		manager, err := NewRotationManager(NewMemoryKeyStore(), ECKeyPairGenerator("P-256"))
+		req.NoError(err)
+
+		for _, interval := range []time.Duration{0, -time.Second} {
+			scheduler, err := NewRotationScheduler(manager, EverySchedule(interval), 0)
+			req.EqualError(err, ErrorRotationIntervalMsg)
+			req.Nil(scheduler)
+
+			req.True(EverySchedule(interval).Next(time.Now()).IsZero())
+		}
+	})
+}
+
+func Test_RotationSchedulerCheckOverlap(t *testing.T) {
+	t.Run("warns if the pre-publication is shorter than the max-age", func(t *testing.T) {
+		req := require.New(t)
+
+		manager, err := NewRotationManager(NewMemoryKeyStore(), ECKeyPairGenerator("P-256"))
+		req.NoError(err)
+
+		scheduler, err := NewRotationScheduler(manager, EverySchedule(time.Hour), time.Hour)
+		req.NoError(err)
+		req.Empty(scheduler.CheckOverlap(DefaultHandlerMaxAge))
+
+		scheduler, err = NewRotationScheduler(manager, EverySchedule(time.Hour), time.Minute)
+		req.NoError(err)
+		req.Len(scheduler.CheckOverlap(DefaultHandlerMaxAge), 1)
+	})
+}
@@ -0,0 +1,423 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package jwks
+
+import (
+	"crypto"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"math/big"
+	"runtime"
+	"sync"
+)
+
+const ErrorSecureBufferDestroyedMsg = "secure buffer has been destroyed"
+
+var (
+	enclaveOnce sync.Once
+	enclave     cipher.AEAD
+	enclaveErr  error
+)
+
+// enclaveAead returns the process wide AEAD SecureBuffers are encrypted with. Its key is generated on first use in
+// locked memory and wiped once the AEAD has expanded it.
+func enclaveAead() (cipher.AEAD, error) {
+	enclaveOnce.Do(func() {
+		key := make([]byte, 32)
+		lockMemory(key)
+
+		if _, enclaveErr = io.ReadFull(rand.Reader, key); enclaveErr != nil {
+			return
+		}
+
+		// the AES key schedule created by newGcm is ordinary heap memory, see SealedKey for the limits this implies
+		enclave, enclaveErr = newGcm(key)
+		wipe(key)
+		unlockMemory(key)
+	})
+
+	return enclave, enclaveErr
+}
+
+// wipe overwrites b with zeros
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+
+	runtime.KeepAlive(b)
+}
+
+// SecureBuffer keeps a secret encrypted in memory with a per-process key, so the plaintext is only present, in locked
+// memory, while Open runs. This reduces the exposure of secrets in heap dumps, core files, and
+// swap. It is safe for concurrent use.
+//
+// Memory locking is best effort: it is only supported on Linux, macOS, and the BSDs and may fail due to resource
+// limits such as RLIMIT_MEMLOCK, in which case the buffers are still encrypted and wiped.
+type SecureBuffer struct {
+	lock       sync.RWMutex
+	nonce      []byte
+	ciphertext []byte
+}
+
+// NewSecureBuffer returns a SecureBuffer holding secret. secret is wiped.
+func NewSecureBuffer(secret []byte) (*SecureBuffer, error) {
+	defer wipe(secret)
+
+	aead, err := enclaveAead()
+
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return &SecureBuffer{
+		nonce:      nonce,
+		ciphertext: aead.Seal(nil, nonce, secret, nil),
+	}, nil
+}
+
+// Open decrypts the secret into locked memory and calls f with it. The plaintext is wiped when f returns, f must not
+// retain it.
+func (b *SecureBuffer) Open(f func(secret []byte) error) error {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	if b.ciphertext == nil {
+		return errors.New(ErrorSecureBufferDestroyedMsg)
+	}
+
+	aead, err := enclaveAead()
+
+	if err != nil {
+		return err
+	}
+
+	plaintext := make([]byte, 0, len(b.ciphertext))
+	lockMemory(plaintext[:cap(plaintext)])
+
+	defer func() {
+		wipe(plaintext[:cap(plaintext)])
+		unlockMemory(plaintext[:cap(plaintext)])
+	}()
+
+	plaintext, err = aead.Open(plaintext, b.nonce, b.ciphertext, nil)
+
+	if err != nil {
+		return err
+	}
+
+	return f(plaintext)
+}
+
+// Destroy discards the secret, subsequent calls to Open fail
+func (b *SecureBuffer) Destroy() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	wipe(b.ciphertext)
+	b.ciphertext = nil
+}
+
+// sealedMemberNames are the private and symmetric members of a SealedKey in the order they are sealed
+var sealedMemberNames = []string{"d", "p", "q", "dp", "dq", "qi", "k"}
+
+// sealedMembers returns the private and symmetric members of key in the order of sealedMemberNames
+func sealedMembers(key *Key) []string {
+	return []string{key.D, key.P, key.Q, key.Dp, key.Dq, key.Qi, key.K}
+}
+
+// openedMembers holds the decoded members of a SealedKey. The slices alias the plaintext passed to the
+// SecureBuffer.Open callback and are only valid while it runs.
+type openedMembers struct {
+	D, P, Q, Dp, Dq, Qi, K []byte
+}
+
+// sealMembers base64url decodes the members of key into a single buffer in locked memory, each prefixed with its
+// 4 byte big endian length, so the members are never held as JSON or re-encoded strings. The caller must wipe and
+// unlock the returned buffer.
+func sealMembers(key *Key) ([]byte, error) {
+	members := sealedMembers(key)
+
+	size := 0
+	for _, member := range members {
+		size += 4 + base64.RawURLEncoding.DecodedLen(len(member))
+	}
+
+	buf := make([]byte, size)
+	lockMemory(buf)
+
+	offset := 0
+	for i, member := range members {
+		src := []byte(member)
+		n, err := base64.RawURLEncoding.Decode(buf[offset+4:], src)
+		wipe(src)
+
+		if err != nil {
+			wipe(buf)
+			unlockMemory(buf)
+			return nil, fmt.Errorf("error base64 decoding key's %s: %s", sealedMemberNames[i], err)
+		}
+
+		binary.BigEndian.PutUint32(buf[offset:], uint32(n))
+		offset += 4 + n
+	}
+
+	return buf[:offset], nil
+}
+
+// openMembers splits plaintext produced by sealMembers into its members without copying them
+func openMembers(plaintext []byte) (*openedMembers, error) {
+	members := make([][]byte, len(sealedMemberNames))
+
+	for i := range members {
+		if len(plaintext) < 4 {
+			return nil, errors.New("sealed key material is truncated")
+		}
+
+		n := binary.BigEndian.Uint32(plaintext)
+		plaintext = plaintext[4:]
+
+		if uint64(n) > uint64(len(plaintext)) {
+			return nil, errors.New("sealed key material is truncated")
+		}
+
+		members[i], plaintext = plaintext[:n:n], plaintext[n:]
+	}
+
+	return &openedMembers{
+		D:  members[0],
+		P:  members[1],
+		Q:  members[2],
+		Dp: members[3],
+		Dq: members[4],
+		Qi: members[5],
+		K:  members[6],
+	}, nil
+}
+
+// SealedKey is an opt-in representation of a private or symmetric Key whose key material is kept in a SecureBuffer
+// instead of ordinary heap strings. The members are stored base64url decoded, so opening them involves no JSON or
+// string copies. It implements crypto.Signer for asymmetric keys, decoding the private key only for the duration of
+// each Sign call.
+//
+// The protection has limits callers should be aware of:
+//   - the key material of the Key passed to SealKey can not be wiped as Go strings are immutable, callers should drop
+//     it as soon as possible
+//   - RSA and EC private keys are rebuilt as math/big values on each Sign call. Their words are zeroed afterwards, but
+//     intermediate values computed by the standard library while signing are ordinary, unlocked heap memory
+//   - the AES key schedule of the process wide enclave key lives in ordinary heap memory
+//
+// A SealedKey therefore narrows, but does not eliminate, the window in which key material can be recovered from
+// memory. Use a hardware backed crypto.Signer, see Pkcs11Signer, where that is required.
+type SealedKey struct {
+	public    Key
+	publicKey crypto.PublicKey
+	secret    *SecureBuffer
+}
+
+// SealKey moves the key material of key into a SecureBuffer. RSA keys that only carry n, e, and d have their CRT
+// parameters derived before sealing.
+func SealKey(key Key) (*SealedKey, error) {
+	if key.KeyType == KeyTypeRsa && key.D != "" && (key.P == "" || key.Q == "") {
+		if err := key.DeriveRsaCrtParameters(); err != nil {
+			return nil, err
+		}
+	}
+
+	members, err := sealMembers(&key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := NewSecureBuffer(members)
+	unlockMemory(members)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &SealedKey{
+		public: key.Public(),
+		secret: secret,
+	}
+
+	if key.KeyType != KeyTypeOct {
+		if ret.publicKey, err = KeyToPublicKey(ret.public); err != nil {
+			secret.Destroy()
+			return nil, err
+		}
+	}
+
+	return ret, nil
+}
+
+// Key returns the public part of the key, see Key.Public
+func (s *SealedKey) Key() Key {
+	return s.public.clone()
+}
+
+// Public returns the public key of an asymmetric key, nil for oct keys
+func (s *SealedKey) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+// Sign signs digest as the *KeySigner returned by KeyToSigner for the unsealed key would
+func (s *SealedKey) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	var signature []byte
+
+	err := s.secret.Open(func(plaintext []byte) error {
+		members, err := openMembers(plaintext)
+
+		if err != nil {
+			return err
+		}
+
+		privKey, wipeKey, err := s.privateKey(members)
+
+		if err != nil {
+			return err
+		}
+
+		defer wipeKey()
+
+		signer, err := newKeySigner(s.public, privKey)
+
+		if err != nil {
+			return err
+		}
+
+		signature, err = signer.Sign(rand, digest, opts)
+
+		return err
+	})
+
+	return signature, err
+}
+
+// privateKey builds the private key of an asymmetric SealedKey from its opened members. The returned function
+// zeroes the private values of the key and must be called once it is no longer used.
+func (s *SealedKey) privateKey(members *openedMembers) (crypto.Signer, func(), error) {
+	if len(members.D) == 0 {
+		return nil, nil, errors.New("key does not contain private key material")
+	}
+
+	switch pubKey := s.publicKey.(type) {
+	case *rsa.PublicKey:
+		if len(members.P) == 0 || len(members.Q) == 0 {
+			return nil, nil, errors.New("key is missing private member: p")
+		}
+
+		privKey := &rsa.PrivateKey{
+			PublicKey: *pubKey,
+			D:         new(big.Int).SetBytes(members.D),
+			Primes:    []*big.Int{new(big.Int).SetBytes(members.P), new(big.Int).SetBytes(members.Q)},
+		}
+
+		wipeKey := func() {
+			wipeBigInts(privKey.D, privKey.Primes[0], privKey.Primes[1], privKey.Precomputed.Dp, privKey.Precomputed.Dq, privKey.Precomputed.Qinv)
+
+			for _, value := range privKey.Precomputed.CRTValues {
+				wipeBigInts(value.Exp, value.Coeff, value.R)
+			}
+		}
+
+		if err := privKey.Validate(); err != nil {
+			wipeKey()
+			return nil, nil, fmt.Errorf("invalid RSA private key: %s", err)
+		}
+
+		privKey.Precompute()
+
+		return privKey, wipeKey, nil
+	case *ecdsa.PublicKey:
+		privKey, err := newEcPrivateKey(pubKey, members.D)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return privKey, func() { wipeBigInts(privKey.D) }, nil
+	case ed25519.PublicKey:
+		if len(members.D) != ed25519.SeedSize {
+			return nil, nil, fmt.Errorf("invalid Ed25519 private key length: %d", len(members.D))
+		}
+
+		privKey := ed25519.NewKeyFromSeed(members.D)
+		wipeKey := func() { wipe(privKey) }
+
+		if !privKey.Public().(ed25519.PublicKey).Equal(pubKey) {
+			wipeKey()
+			return nil, nil, errors.New("Ed25519 private key does not match public key")
+		}
+
+		return privKey, wipeKey, nil
+	default:
+		return nil, nil, fmt.Errorf("unsuportted key type: %s", s.public.KeyType)
+	}
+}
+
+// wipeBigInts zeroes the words backing each non-nil value
+func wipeBigInts(values ...*big.Int) {
+	for _, value := range values {
+		if value == nil {
+			continue
+		}
+
+		words := value.Bits()
+		for i := range words {
+			words[i] = 0
+		}
+
+		runtime.KeepAlive(words)
+	}
+}
+
+// WithSecret calls f with the decoded secret of an oct key in locked memory, wiped when f returns
+func (s *SealedKey) WithSecret(f func(secret []byte) error) error {
+	if s.public.KeyType != KeyTypeOct {
+		return fmt.Errorf("key type %s has no symmetric secret", s.public.KeyType)
+	}
+
+	return s.secret.Open(func(plaintext []byte) error {
+		members, err := openMembers(plaintext)
+
+		if err != nil {
+			return err
+		}
+
+		return f(members.K)
+	})
+}
+
+// Destroy discards the key material, subsequent calls to Sign and WithSecret fail
+func (s *SealedKey) Destroy() {
+	s.secret.Destroy()
+}
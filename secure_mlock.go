@@ -0,0 +1,35 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import "syscall"
+
+// lockMemory prevents b from being swapped out, best effort
+func lockMemory(b []byte) {
+	if len(b) > 0 {
+		_ = syscall.Mlock(b)
+	}
+}
+
+// unlockMemory reverts lockMemory
+func unlockMemory(b []byte) {
+	if len(b) > 0 {
+		_ = syscall.Munlock(b)
+	}
+}
@@ -0,0 +1,184 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_SecureBuffer(t *testing.T) {
+	t.Run("can open the sealed secret and wipes the input", func(t *testing.T) {
+		req := require.New(t)
+
+		secret := []byte("super secret")
+		buffer, err := NewSecureBuffer(secret)
+		req.NoError(err)
+		req.Equal(make([]byte, len(secret)), secret)
+		req.NotContains(string(buffer.ciphertext), "super secret")
+
+		var opened string
+		req.NoError(buffer.Open(func(plaintext []byte) error {
+			opened = string(plaintext)
+			return nil
+		}))
+		req.Equal("super secret", opened)
+	})
+
+	t.Run("can not be opened after destroy", func(t *testing.T) {
+		req := require.New(t)
+
+		buffer, err := NewSecureBuffer([]byte("secret"))
+		req.NoError(err)
+
+		buffer.Destroy()
+
+		err = buffer.Open(func([]byte) error { return nil })
+		req.EqualError(err, ErrorSecureBufferDestroyedMsg)
+	})
+}
+
+func Test_SealKey(t *testing.T) {
+	t.Run("can sign with a sealed EC key", func(t *testing.T) {
+		req := require.New(t)
+
+		private, public, err := GenerateECKey("P-256", "ec")
+		req.NoError(err)
+
+		sealed, err := SealKey(*private)
+		req.NoError(err)
+		req.Equal(public.KeyId, sealed.Key().KeyId)
+		req.Empty(sealed.Key().D)
+
+		digest := sha256.Sum256([]byte("hello world"))
+		signature, err := sealed.Sign(rand.Reader, digest[:], crypto.SHA256)
+		req.NoError(err)
+
+		publicKey, ok := sealed.Public().(*ecdsa.PublicKey)
+		req.True(ok)
+		req.True(ecdsa.VerifyASN1(publicKey, digest[:], signature))
+	})
+
+	t.Run("can sign with sealed RSA and Ed25519 keys", func(t *testing.T) {
+		req := require.New(t)
+
+		rsaKey, _, err := GenerateRSAKey(2048, "rsa")
+		req.NoError(err)
+
+		edKey, _, err := GenerateOKPKey(CurveEd25519, "ed")
+		req.NoError(err)
+
+		digest := sha256.Sum256([]byte("hello world"))
+
+		for _, key := range []*Key{rsaKey, edKey} {
+			sealed, err := SealKey(*key)
+			req.NoError(err)
+
+			signer, err := KeyToSigner(*key)
+			req.NoError(err)
+
+			message := digest[:]
+			var opts crypto.SignerOpts = crypto.SHA256
+
+			if key.KeyType == KeyTypeOkp {
+				message = []byte("hello world")
+				opts = crypto.Hash(0)
+			}
+
+			signature, err := sealed.Sign(rand.Reader, message, opts)
+			req.NoError(err)
+
+			expected, err := signer.Sign(rand.Reader, message, opts)
+			req.NoError(err)
+			req.Equal(expected, signature, key.KeyType)
+		}
+	})
+
+	t.Run("stores the decoded members instead of JSON", func(t *testing.T) {
+		req := require.New(t)
+
+		key, err := GenerateOctKey(32, "oct")
+		req.NoError(err)
+
+		sealed, err := SealKey(*key)
+		req.NoError(err)
+
+		raw, err := base64.RawURLEncoding.DecodeString(key.K)
+		req.NoError(err)
+
+		req.NoError(sealed.secret.Open(func(plaintext []byte) error {
+			req.NotContains(string(plaintext), key.K)
+			req.Equal(6*4+4+len(raw), len(plaintext))
+
+			members, err := openMembers(plaintext)
+			req.NoError(err)
+			req.Empty(members.D)
+			req.Equal(raw, members.K)
+			return nil
+		}))
+	})
+
+	t.Run("can access the secret of a sealed oct key", func(t *testing.T) {
+		req := require.New(t)
+
+		key, err := GenerateOctKey(32, "oct")
+		req.NoError(err)
+
+		sealed, err := SealKey(*key)
+		req.NoError(err)
+		req.Nil(sealed.Public())
+		req.Empty(sealed.Key().K)
+
+		var secret string
+		req.NoError(sealed.WithSecret(func(raw []byte) error {
+			secret = base64.RawURLEncoding.EncodeToString(raw)
+			return nil
+		}))
+		req.Equal(key.K, secret)
+	})
+
+	t.Run("can not access the secret of an asymmetric key", func(t *testing.T) {
+		req := require.New(t)
+
+		private, _, err := GenerateOKPKey(CurveEd25519, "ed")
+		req.NoError(err)
+
+		sealed, err := SealKey(*private)
+		req.NoError(err)
+
+		req.Error(sealed.WithSecret(func([]byte) error { return nil }))
+	})
+
+	t.Run("can not sign after destroy", func(t *testing.T) {
+		req := require.New(t)
+
+		private, _, err := GenerateOKPKey(CurveEd25519, "ed")
+		req.NoError(err)
+
+		sealed, err := SealKey(*private)
+		req.NoError(err)
+		sealed.Destroy()
+
+		_, err = sealed.Sign(rand.Reader, []byte("message"), crypto.Hash(0))
+		req.EqualError(err, ErrorSecureBufferDestroyedMsg)
+	})
+}
@@ -0,0 +1,218 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MaxKidAttempts is the number of times Set.AssignKid invokes a KidStrategy before extending a colliding kid
+const MaxKidAttempts = 8
+
+// Set is a mutable collection of keys that is safe for concurrent use. Keys are copied on the way in and out so that
+// callers can not modify the set without holding its lock. Keys revoked by the list supplied to SetRevocations are
+// excluded from Get and Response.
+type Set struct {
+	lock        sync.RWMutex
+	keys        []Key
+	revocations *RevocationList
+}
+
+// NewSet returns a Set containing copies of keys
+func NewSet(keys ...Key) *Set {
+	set := &Set{}
+	set.Replace(keys)
+
+	return set
+}
+
+// NewSetFromResponse returns a Set containing copies of the keys in response
+func NewSetFromResponse(response *Response) *Set {
+	return NewSet(response.Keys...)
+}
+
+// Add appends a copy of key to the set
+func (s *Set) Add(key Key) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.keys = append(s.keys, key.clone())
+}
+
+// AddUnique adds a copy of key to the set unless a key with the same kid is present, checking and adding in one step.
+// It returns true if the key was added.
+func (s *Set) AddUnique(key Key) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for i := range s.keys {
+		if s.keys[i].KeyId == key.KeyId {
+			return false
+		}
+	}
+
+	s.keys = append(s.keys, key.clone())
+
+	return true
+}
+
+// AssignKid generates a kid for key with strategy, assigns it and adds a copy of key to the set in one step, so that
+// the kid is unique among the set's keys. On collision the strategy is retried up to MaxKidAttempts times, which
+// suffices for random strategies, after which the last kid is extended with "-2", "-3", and so on. strategy must not
+// use the set. The assigned kid is returned.
+func (s *Set) AssignKid(key *Key, strategy KidStrategy) (string, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	taken := make(map[string]bool, len(s.keys))
+
+	for _, existing := range s.keys {
+		taken[existing.KeyId] = true
+	}
+
+	kid := ""
+
+	for attempt := 0; attempt < MaxKidAttempts; attempt++ {
+		next, err := strategy.Kid(key, nil)
+
+		if err != nil {
+			return "", err
+		}
+
+		if !taken[next] {
+			kid = next
+			break
+		}
+
+		if next == kid {
+			// deterministic strategy, retrying will not help
+			break
+		}
+
+		kid = next
+	}
+
+	if taken[kid] {
+		base := kid
+
+		for i := 2; taken[kid]; i++ {
+			kid = base + "-" + strconv.Itoa(i)
+		}
+	}
+
+	key.KeyId = kid
+	s.keys = append(s.keys, key.clone())
+
+	return kid, nil
+}
+
+// Remove removes every key with the given kid and returns true if any were removed
+func (s *Set) Remove(kid string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	keys := s.keys[:0]
+
+	for _, key := range s.keys {
+		if key.KeyId != kid {
+			keys = append(keys, key)
+		}
+	}
+
+	removed := len(keys) != len(s.keys)
+
+	// clear the tail so removed keys can be garbage collected
+	for i := len(keys); i < len(s.keys); i++ {
+		s.keys[i] = Key{}
+	}
+
+	s.keys = keys
+
+	return removed
+}
+
+// Replace atomically replaces the contents of the set with copies of keys
+func (s *Set) Replace(keys []Key) {
+	copied := make([]Key, 0, len(keys))
+
+	for _, key := range keys {
+		copied = append(copied, key.clone())
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.keys = copied
+}
+
+// Len returns the number of keys in the set
+func (s *Set) Len() int {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return len(s.keys)
+}
+
+// Get returns a copy of the first key with the given kid
+func (s *Set) Get(kid string) (Key, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	now := time.Now()
+
+	for i := range s.keys {
+		if s.keys[i].KeyId == kid && !s.revocations.IsRevoked(&s.keys[i], now) {
+			return s.keys[i].clone(), true
+		}
+	}
+
+	return Key{}, false
+}
+
+// SetRevocations replaces the revocation list of the set, nil clears it. Revoked keys remain in the set, and count
+// towards Len, but are no longer returned.
+func (s *Set) SetRevocations(list *RevocationList) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.revocations = list
+}
+
+// Response returns a Response containing copies of the keys in the set that are not revoked
+func (s *Set) Response() *Response {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	keys := make([]Key, 0, len(s.keys))
+	now := time.Now()
+
+	for i := range s.keys {
+		if !s.revocations.IsRevoked(&s.keys[i], now) {
+			keys = append(keys, s.keys[i].clone())
+		}
+	}
+
+	return &Response{Keys: keys}
+}
+
+// MarshalJSON renders the set as a JWK Set document
+func (s *Set) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.Response())
+}
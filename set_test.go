@@ -0,0 +1,177 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+func Test_Set(t *testing.T) {
+	newResponse := func(t *testing.T) *Response {
+		response := &Response{}
+		require.NoError(t, json.Unmarshal([]byte(testJwksRfc7517Examples), response))
+		return response
+	}
+
+	t.Run("can convert to and from a Response", func(t *testing.T) {
+		req := require.New(t)
+
+		response := newResponse(t)
+		set := NewSetFromResponse(response)
+		req.Equal(3, set.Len())
+		req.Equal(response, set.Response())
+	})
+
+	t.Run("can add, get and remove keys", func(t *testing.T) {
+		req := require.New(t)
+
+		set := NewSet()
+		set.Add(Key{KeyType: KeyTypeOct, KeyId: "a", K: "AQAB"})
+		set.Add(Key{KeyType: KeyTypeOct, KeyId: "b", K: "AQAB"})
+		set.Add(Key{KeyType: KeyTypeOct, KeyId: "a", K: "AQAC"})
+		req.Equal(3, set.Len())
+
+		key, ok := set.Get("a")
+		req.True(ok)
+		req.Equal("AQAB", key.K)
+
+		req.True(set.Remove("a"))
+		req.False(set.Remove("a"))
+		req.Equal(1, set.Len())
+
+		_, ok = set.Get("a")
+		req.False(ok)
+	})
+
+	t.Run("can assign unique kids", func(t *testing.T) {
+		req := require.New(t)
+
+		set := NewSet()
+
+		thumbprint, err := (&Key{KeyType: KeyTypeOct, K: "AQAB"}).Thumbprint(crypto.SHA256)
+		req.NoError(err)
+
+		for i := 0; i < 3; i++ {
+			kid, err := set.AssignKid(&Key{KeyType: KeyTypeOct, K: "AQAB"}, ThumbprintKidStrategy)
+			req.NoError(err)
+
+			if i == 0 {
+				req.Equal(thumbprint, kid)
+			} else {
+				req.Equal(fmt.Sprintf("%s-%d", thumbprint, i+1), kid)
+			}
+		}
+
+		req.Equal(3, set.Len())
+		req.Empty(set.Response().DuplicateKids())
+	})
+
+	t.Run("retries colliding strategies before extending", func(t *testing.T) {
+		req := require.New(t)
+
+		set := NewSet(Key{KeyType: KeyTypeOct, KeyId: "key-1", K: "AQAB"})
+		strategy := NewSequentialKidStrategy("key-")
+
+		key := &Key{KeyType: KeyTypeOct, K: "AQAC"}
+		kid, err := set.AssignKid(key, strategy)
+		req.NoError(err)
+		req.Equal("key-2", kid)
+		req.Equal("key-2", key.KeyId)
+	})
+
+	t.Run("returns strategy errors", func(t *testing.T) {
+		req := require.New(t)
+
+		set := NewSet()
+		_, err := set.AssignKid(&Key{KeyType: KeyTypeOct, K: "AQAB"}, CertSha1KidStrategy)
+		req.EqualError(err, ErrorKidStrategyNoCertMsg)
+		req.Equal(0, set.Len())
+	})
+
+	t.Run("can replace all keys", func(t *testing.T) {
+		req := require.New(t)
+
+		set := NewSetFromResponse(newResponse(t))
+		set.Replace([]Key{{KeyType: KeyTypeOct, KeyId: "only", K: "AQAB"}})
+		req.Equal(1, set.Len())
+
+		data, err := json.Marshal(set)
+		req.NoError(err)
+		req.JSONEq(`{"keys":[{"kty":"oct","kid":"only","k":"AQAB"}]}`, string(data))
+	})
+
+	t.Run("isolates callers from the set's keys", func(t *testing.T) {
+		req := require.New(t)
+
+		key := Key{KeyType: KeyTypeOct, KeyId: "a", K: "AQAB", KeyOperations: []string{KeyOperationSign}}
+		set := NewSet(key)
+		key.KeyOperations[0] = KeyOperationEncrypt
+
+		stored, _ := set.Get("a")
+		stored.KeyOperations[0] = KeyOperationDecrypt
+
+		stored, _ = set.Get("a")
+		req.Equal([]string{KeyOperationSign}, stored.KeyOperations)
+	})
+
+	t.Run("can be used concurrently", func(t *testing.T) {
+		req := require.New(t)
+
+		set := NewSet()
+		wg := sync.WaitGroup{}
+
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+
+				for j := 0; j < 100; j++ {
+					kid := fmt.Sprintf("%d-%d", i, j)
+					set.Add(Key{KeyType: KeyTypeOct, KeyId: kid, K: "AQAB"})
+					_, _ = set.Get(kid)
+					_ = set.Response()
+					set.Remove(kid)
+				}
+			}(i)
+		}
+
+		wg.Wait()
+		req.Equal(0, set.Len())
+	})
+}
+
+func Test_SetAddUnique(t *testing.T) {
+	t.Run("adds keys with new kids only", func(t *testing.T) {
+		req := require.New(t)
+
+		set := NewSet()
+
+		req.True(set.AddUnique(Key{KeyType: KeyTypeOct, KeyId: "a", K: "AAAA"}))
+		req.False(set.AddUnique(Key{KeyType: KeyTypeOct, KeyId: "a", K: "BBBB"}))
+		req.True(set.AddUnique(Key{KeyType: KeyTypeOct, KeyId: "b", K: "BBBB"}))
+
+		key, found := set.Get("a")
+		req.True(found)
+		req.Equal("AAAA", key.K)
+		req.Equal(2, set.Len())
+	})
+}
@@ -0,0 +1,184 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package jwks
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/asn1"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"math/big"
+	"net/http"
+	"strings"
+)
+
+const (
+	// SignedResponseType is the JWS typ header and, prefixed with "application/", the media type of signed JWK sets
+	SignedResponseType = "jwk-set+jwt"
+
+	ErrorSignedResponseMalformedMsg = "malformed signed JWK set, expected a compact JWS"
+	ErrorSignedResponseSignatureMsg = "signature of signed JWK set is invalid"
+	ErrorSignedResponseTypeMsg      = "signed JWK set has an invalid typ, expected " + SignedResponseType
+)
+
+// signedResponseMessages name signed JWK sets in the errors of VerifySignedResponse
+var signedResponseMessages = jwsMessages{
+	subject:   "signed JWK set",
+	malformed: ErrorSignedResponseMalformedMsg,
+	signature: ErrorSignedResponseSignatureMsg,
+}
+
+// SignResponse signs the JSON form of response with the private key and returns it as a compact JWS with the typ
+// SignedResponseType, as used for signed JWK sets by OpenID Federation. The JWS alg is the key's alg or the inferred
+// one, see KeyToSigner. The response is signed as is, use Response.PublicOnly to strip private key material first.
+func SignResponse(response *Response, key Key) ([]byte, error) {
+	payload, err := json.Marshal(response)
+
+	if err != nil {
+		return nil, err
+	}
+
+	signed, err := SignCompactJws(payload, key, SignedResponseType)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not sign JWK set: %s", err)
+	}
+
+	return signed, nil
+}
+
+// VerifySignedResponse verifies a compact JWS produced by SignResponse with the public key and returns the signed
+// Response. The JWS typ must be SignedResponseType, optionally prefixed with "application/" and compared case
+// insensitively, so other JWTs signed by the same key can not be substituted for a JWK set. The JWS alg must be
+// usable with the key and, if the key has an alg, equal to it. If both the JWS and the key have a kid they must match.
+func VerifySignedResponse(data []byte, key Key) (*Response, error) {
+	jws, err := parseCompactJws(data, signedResponseMessages)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimPrefix(strings.ToLower(jws.header.Type), "application/") != SignedResponseType {
+		return nil, errors.New(ErrorSignedResponseTypeMsg)
+	}
+
+	verified, err := jws.verify(key, signedResponseMessages)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseResponse(verified.Payload)
+}
+
+// jwsDigest hashes the JWS signing input with hash, or returns it unchanged for crypto.Hash(0) (EdDSA)
+func jwsDigest(hash crypto.Hash, signingInput []byte) []byte {
+	if hash == crypto.Hash(0) {
+		return signingInput
+	}
+
+	digest := hash.New()
+	_, _ = digest.Write(signingInput)
+
+	return digest.Sum(nil)
+}
+
+// verifyJwsSignature verifies a JWS signature, which for ECDSA is the fixed size concatenation of R and S
+func verifyJwsSignature(publicKey interface{}, opts crypto.SignerOpts, digest []byte, signature []byte) bool {
+	switch publicKey := publicKey.(type) {
+	case *rsa.PublicKey:
+		if pssOpts, ok := opts.(*rsa.PSSOptions); ok {
+			return rsa.VerifyPSS(publicKey, pssOpts.Hash, digest, signature, pssOpts) == nil
+		}
+
+		return rsa.VerifyPKCS1v15(publicKey, opts.HashFunc(), digest, signature) == nil
+	case *ecdsa.PublicKey:
+		size := (publicKey.Curve.Params().BitSize + 7) / 8
+
+		if len(signature) != 2*size {
+			return false
+		}
+
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+
+		return ecdsa.Verify(publicKey, digest, r, s)
+	case ed25519.PublicKey:
+		return ed25519.Verify(publicKey, digest, signature)
+	}
+
+	return false
+}
+
+// ecdsaSignatureToJws converts an ASN.1 DER ECDSA signature to the JWS form, R and S padded to the curve size
+func ecdsaSignatureToJws(signature []byte, publicKey *ecdsa.PublicKey) ([]byte, error) {
+	var parsed struct {
+		R, S *big.Int
+	}
+
+	if _, err := asn1.Unmarshal(signature, &parsed); err != nil {
+		return nil, fmt.Errorf("could not parse ECDSA signature: %s", err)
+	}
+
+	size := (publicKey.Curve.Params().BitSize + 7) / 8
+	ret := make([]byte, 2*size)
+
+	parsed.R.FillBytes(ret[:size])
+	parsed.S.FillBytes(ret[size:])
+
+	return ret, nil
+}
+
+// SignedHttpResolver implements Resolver for signed JWK sets served over HTTP(S) as application/jwk-set+jwt, e.g. by a
+// Handler with WithSigningKey. The signature is verified with TrustAnchor, see VerifySignedResponse, before the
+// Response is returned, so documents tampered with in transit or by a compromised host are rejected. The raw bytes
+// returned are the compact JWS.
+type SignedHttpResolver struct {
+	// TrustAnchor is the public key the set must be signed with
+	TrustAnchor Key
+
+	// Client is used to fetch key sets, if nil http.DefaultClient is used
+	Client *http.Client
+
+	// TlsPolicy, if set, is enforced for every fetch and rejects plain HTTP
+	TlsPolicy *TlsPolicy
+
+	// MaxBodySize rejects responses larger than the given number of bytes, zero disables the limit
+	MaxBodySize int64
+}
+
+func (r *SignedHttpResolver) Get(url string) (*Response, []byte, error) {
+	resp, body, err := httpGet(r.Client, r.TlsPolicy, r.MaxBodySize, url, "application/"+SignedResponseType, "application/jwt")
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	response, err := VerifySignedResponse(body, r.TrustAnchor)
+
+	if err != nil {
+		return nil, nil, &HttpResolverError{
+			Resp:  resp,
+			error: err,
+		}
+	}
+
+	return response, body, nil
+}
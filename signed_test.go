@@ -0,0 +1,246 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_SignResponse(t *testing.T) {
+	_, published, err := GenerateECKey("P-256", "published")
+	require.NoError(t, err)
+
+	response := &Response{Keys: []Key{*published}}
+
+	t.Run("can sign and verify with every key type", func(t *testing.T) {
+		req := require.New(t)
+
+		rsaPrivate, rsaPublic, err := GenerateRSAKey(2048, "rsa")
+		req.NoError(err)
+
+		psPrivate, psPublic, err := GenerateRSAKey(2048, "ps", WithAlgorithm(AlgorithmPs256))
+		req.NoError(err)
+
+		ecPrivate, ecPublic, err := GenerateECKey("P-521", "ec")
+		req.NoError(err)
+
+		edPrivate, edPublic, err := GenerateOKPKey(CurveEd25519, "ed")
+		req.NoError(err)
+
+		for _, pair := range [][2]*Key{{rsaPrivate, rsaPublic}, {psPrivate, psPublic}, {ecPrivate, ecPublic}, {edPrivate, edPublic}} {
+			signed, err := SignResponse(response, *pair[0])
+			req.NoError(err)
+
+			verified, err := VerifySignedResponse(signed, *pair[1])
+			req.NoError(err, pair[0].KeyId)
+			req.Equal(response, verified)
+		}
+	})
+
+	t.Run("sets the protected header", func(t *testing.T) {
+		req := require.New(t)
+
+		private, _, err := GenerateECKey("P-256", "signer")
+		req.NoError(err)
+
+		signed, err := SignResponse(response, *private)
+		req.NoError(err)
+
+		rawHeader, err := base64.RawURLEncoding.DecodeString(string(bytes.Split(signed, []byte("."))[0]))
+		req.NoError(err)
+
+		header := map[string]interface{}{}
+		req.NoError(json.Unmarshal(rawHeader, &header))
+		req.Equal(map[string]interface{}{"alg": AlgorithmEs256, "kid": "signer", "typ": SignedResponseType}, header)
+	})
+
+	t.Run("rejects tampered sets and other keys", func(t *testing.T) {
+		req := require.New(t)
+
+		private, public, err := GenerateECKey("P-256", "signer")
+		req.NoError(err)
+
+		_, other, err := GenerateECKey("P-256", "signer")
+		req.NoError(err)
+
+		signed, err := SignResponse(response, *private)
+		req.NoError(err)
+
+		_, err = VerifySignedResponse(signed, *other)
+		req.EqualError(err, ErrorSignedResponseSignatureMsg)
+
+		parts := bytes.Split(signed, []byte("."))
+		tampered, err := json.Marshal(&Response{})
+		req.NoError(err)
+		parts[1] = []byte(base64.RawURLEncoding.EncodeToString(tampered))
+
+		_, err = VerifySignedResponse(bytes.Join(parts, []byte(".")), *public)
+		req.EqualError(err, ErrorSignedResponseSignatureMsg)
+
+		_, err = VerifySignedResponse([]byte("not.a-jws"), *public)
+		req.EqualError(err, ErrorSignedResponseMalformedMsg)
+	})
+
+	t.Run("requires the signed JWK set typ", func(t *testing.T) {
+		req := require.New(t)
+
+		private, public, err := GenerateECKey("P-256", "signer")
+		req.NoError(err)
+
+		payload, err := json.Marshal(response)
+		req.NoError(err)
+
+		for _, typ := range []string{"application/jwk-set+jwt", "JWK-SET+JWT"} {
+			signed, err := SignCompactJws(payload, *private, typ)
+			req.NoError(err)
+
+			verified, err := VerifySignedResponse(signed, *public)
+			req.NoError(err, typ)
+			req.Equal(response, verified)
+		}
+
+		for _, typ := range []string{"", "JWT", "application/jwt", "text/jwk-set+jwt"} {
+			signed, err := SignCompactJws(payload, *private, typ)
+			req.NoError(err)
+
+			_, err = VerifySignedResponse(signed, *public)
+			req.EqualError(err, ErrorSignedResponseTypeMsg, typ)
+		}
+	})
+
+	t.Run("rejects mismatched algs and kids", func(t *testing.T) {
+		req := require.New(t)
+
+		private, public, err := GenerateECKey("P-256", "signer")
+		req.NoError(err)
+
+		signed, err := SignResponse(response, *private)
+		req.NoError(err)
+
+		wrongAlg := *public
+		wrongAlg.Algorithm = AlgorithmEs384
+		_, err = VerifySignedResponse(signed, wrongAlg)
+		req.Error(err)
+
+		wrongKid := *public
+		wrongKid.KeyId = "other"
+		_, err = VerifySignedResponse(signed, wrongKid)
+		req.Error(err)
+
+		rsaPrivate, _, err := GenerateRSAKey(2048, "rsa")
+		req.NoError(err)
+
+		_, err = VerifySignedResponse(signed, rsaPrivate.Public())
+		req.Error(err)
+	})
+}
+
+func Test_SignedHttpResolver(t *testing.T) {
+	_, published, err := GenerateECKey("P-256", "published")
+	require.NoError(t, err)
+
+	signing, anchor, err := GenerateECKey("P-256", "anchor")
+	require.NoError(t, err)
+
+	t.Run("can fetch and verify a signed set", func(t *testing.T) {
+		req := require.New(t)
+
+		server := httptest.NewServer(Handler(StaticResponseSource(&Response{Keys: []Key{*published}}), WithSigningKey(*signing)))
+		defer server.Close()
+
+		resolver := &SignedHttpResolver{TrustAnchor: *anchor}
+
+		response, raw, err := resolver.Get(server.URL)
+		req.NoError(err)
+		req.Len(response.Keys, 1)
+		req.Equal("published", response.Keys[0].KeyId)
+		req.Len(bytes.Split(raw, []byte(".")), 3)
+	})
+
+	t.Run("rejects sets signed by another key", func(t *testing.T) {
+		req := require.New(t)
+
+		other, _, err := GenerateECKey("P-256", "anchor")
+		req.NoError(err)
+
+		server := httptest.NewServer(Handler(StaticResponseSource(&Response{Keys: []Key{*published}}), WithSigningKey(*other)))
+		defer server.Close()
+
+		resolver := &SignedHttpResolver{TrustAnchor: *anchor}
+
+		_, _, err = resolver.Get(server.URL)
+		req.IsType(&HttpResolverError{}, err)
+		req.EqualError(err, ErrorSignedResponseSignatureMsg)
+	})
+
+	t.Run("rejects tampered sets", func(t *testing.T) {
+		req := require.New(t)
+
+		signed, err := SignResponse(&Response{Keys: []Key{*published}}, *signing)
+		req.NoError(err)
+
+		parts := bytes.Split(signed, []byte("."))
+		parts[1] = []byte(base64.RawURLEncoding.EncodeToString([]byte(`{"keys":[]}`)))
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/jwk-set+jwt")
+			_, _ = w.Write(bytes.Join(parts, []byte(".")))
+		}))
+		defer server.Close()
+
+		resolver := &SignedHttpResolver{TrustAnchor: *anchor}
+
+		_, _, err = resolver.Get(server.URL)
+		req.EqualError(err, ErrorSignedResponseSignatureMsg)
+	})
+
+	t.Run("rejects bodies larger than the limit", func(t *testing.T) {
+		req := require.New(t)
+
+		server := httptest.NewServer(Handler(StaticResponseSource(&Response{Keys: []Key{*published}}), WithSigningKey(*signing)))
+		defer server.Close()
+
+		resolver := &SignedHttpResolver{TrustAnchor: *anchor, MaxBodySize: 16}
+
+		_, _, err := resolver.Get(server.URL)
+		req.IsType(&HttpResolverError{}, err)
+		req.Contains(err.Error(), ErrorResponseTooLargeMsg)
+
+		resolver.MaxBodySize = 1024 * 1024
+
+		_, _, err = resolver.Get(server.URL)
+		req.NoError(err)
+	})
+
+	t.Run("rejects unsigned sets", func(t *testing.T) {
+		req := require.New(t)
+
+		server := httptest.NewServer(Handler(StaticResponseSource(&Response{Keys: []Key{*published}})))
+		defer server.Close()
+
+		resolver := &SignedHttpResolver{TrustAnchor: *anchor}
+
+		_, _, err := resolver.Get(server.URL)
+		req.EqualError(err, ErrorInvalidContentTypeMsg)
+	})
+}
@@ -0,0 +1,144 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"fmt"
+	"io"
+)
+
+// KeySigner implements crypto.Signer for a private Key. When Sign is called with nil opts the hash and padding
+// required by the key's alg are used. KeySigner also implements crypto.SignerOpts, reporting the alg's hash, so it
+// can be passed as its own opts.
+type KeySigner struct {
+	signer    crypto.Signer
+	opts      crypto.SignerOpts
+	algorithm string
+}
+
+// KeyToSigner converts a private Key to a crypto.Signer backed by the key returned from KeyToPrivateKey. The returned
+// signer is a *KeySigner that signs with the hash required by the key's alg, or the inferred alg if the key has none.
+func KeyToSigner(key Key) (crypto.Signer, error) {
+	algorithm, opts, err := keySignerOpts(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	privKey, err := KeyToPrivateKey(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeySigner{
+		signer:    privKey.(crypto.Signer),
+		opts:      opts,
+		algorithm: algorithm,
+	}, nil
+}
+
+// newKeySigner returns a *KeySigner signing with signer, which must hold the private key of key, using the options
+// required by the key's alg
+func newKeySigner(key Key, signer crypto.Signer) (*KeySigner, error) {
+	algorithm, opts, err := keySignerOpts(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeySigner{
+		signer:    signer,
+		opts:      opts,
+		algorithm: algorithm,
+	}, nil
+}
+
+// keySignerOpts returns the alg of the key, or the inferred alg if it has none, and the crypto.SignerOpts it requires
+func keySignerOpts(key Key) (string, crypto.SignerOpts, error) {
+	algorithm := key.Algorithm
+
+	if algorithm == "" {
+		algorithm = InferAlgorithm(&key)
+	}
+
+	opts, keyType, err := algorithmSignerOpts(algorithm)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	if keyType != key.KeyType {
+		return "", nil, fmt.Errorf("alg %s can not be used with key type %s", algorithm, key.KeyType)
+	}
+
+	return algorithm, opts, nil
+}
+
+// Public returns the public key corresponding to the private key
+func (s *KeySigner) Public() crypto.PublicKey {
+	return s.signer.Public()
+}
+
+// Sign signs digest with the private key. If opts is nil the options required by the signer's alg are used.
+func (s *KeySigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts == nil {
+		opts = s.opts
+	}
+
+	return s.signer.Sign(rand, digest, opts)
+}
+
+// HashFunc returns the hash required by the signer's alg, crypto.Hash(0) for EdDSA
+func (s *KeySigner) HashFunc() crypto.Hash {
+	return s.opts.HashFunc()
+}
+
+// Algorithm returns the JWS alg the signer produces signatures for
+func (s *KeySigner) Algorithm() string {
+	return s.algorithm
+}
+
+// algorithmSignerOpts returns the crypto.SignerOpts and key type required by a JWS alg
+func algorithmSignerOpts(algorithm string) (crypto.SignerOpts, string, error) {
+	switch algorithm {
+	case AlgorithmRs256:
+		return crypto.SHA256, KeyTypeRsa, nil
+	case AlgorithmRs384:
+		return crypto.SHA384, KeyTypeRsa, nil
+	case AlgorithmRs512:
+		return crypto.SHA512, KeyTypeRsa, nil
+	case AlgorithmPs256:
+		return &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}, KeyTypeRsa, nil
+	case AlgorithmPs384:
+		return &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA384}, KeyTypeRsa, nil
+	case AlgorithmPs512:
+		return &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA512}, KeyTypeRsa, nil
+	case AlgorithmEs256:
+		return crypto.SHA256, KeyTypeEc, nil
+	case AlgorithmEs384:
+		return crypto.SHA384, KeyTypeEc, nil
+	case AlgorithmEs512:
+		return crypto.SHA512, KeyTypeEc, nil
+	case AlgorithmEdDsa:
+		return crypto.Hash(0), KeyTypeOkp, nil
+	}
+
+	return nil, "", fmt.Errorf("unsupported signing alg: %s", algorithm)
+}
@@ -0,0 +1,156 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func Test_KeyToSigner(t *testing.T) {
+	message := []byte("hello world")
+
+	t.Run("RS256 signatures verify with PKCS1v15 SHA-256", func(t *testing.T) {
+		req := require.New(t)
+
+		_, rsaPrivKey, err := newRsaCert()
+		req.NoError(err)
+
+		key, err := NewKeyFromPrivateKey("", rsaPrivKey)
+		req.NoError(err)
+
+		signer, err := KeyToSigner(*key)
+		req.NoError(err)
+		req.Equal(crypto.SHA256, signer.(*KeySigner).HashFunc())
+
+		digest := sha256.Sum256(message)
+		sig, err := signer.Sign(rand.Reader, digest[:], nil)
+		req.NoError(err)
+
+		req.NoError(rsa.VerifyPKCS1v15(&rsaPrivKey.PublicKey, crypto.SHA256, digest[:], sig))
+	})
+
+	t.Run("PS384 signatures verify with PSS SHA-384", func(t *testing.T) {
+		req := require.New(t)
+
+		_, rsaPrivKey, err := newRsaCert()
+		req.NoError(err)
+
+		key, err := NewKeyFromPrivateKey("", rsaPrivKey, WithAlgorithm(AlgorithmPs384))
+		req.NoError(err)
+
+		signer, err := KeyToSigner(*key)
+		req.NoError(err)
+
+		digest := sha512.Sum384(message)
+		sig, err := signer.Sign(rand.Reader, digest[:], nil)
+		req.NoError(err)
+
+		req.NoError(rsa.VerifyPSS(&rsaPrivKey.PublicKey, crypto.SHA384, digest[:], sig, nil))
+	})
+
+	t.Run("ES256 signatures verify", func(t *testing.T) {
+		req := require.New(t)
+
+		_, ecPrivKey, err := newEcCert()
+		req.NoError(err)
+
+		key, err := NewKeyFromPrivateKey("", ecPrivKey)
+		req.NoError(err)
+
+		signer, err := KeyToSigner(*key)
+		req.NoError(err)
+
+		digest := sha256.Sum256(message)
+		sig, err := signer.Sign(rand.Reader, digest[:], nil)
+		req.NoError(err)
+
+		req.True(ecdsa.VerifyASN1(&ecPrivKey.PublicKey, digest[:], sig))
+	})
+
+	t.Run("EdDSA signatures verify", func(t *testing.T) {
+		req := require.New(t)
+
+		_, edPrivKey, err := newEd25519Cert()
+		req.NoError(err)
+
+		key, err := NewKeyFromPrivateKey("", edPrivKey)
+		req.NoError(err)
+
+		signer, err := KeyToSigner(*key)
+		req.NoError(err)
+
+		sig, err := signer.Sign(rand.Reader, message, nil)
+		req.NoError(err)
+
+		req.True(ed25519.Verify(edPrivKey.Public().(ed25519.PublicKey), message, sig))
+	})
+
+	t.Run("can sign x509 certificates", func(t *testing.T) {
+		req := require.New(t)
+
+		_, ecPrivKey, err := newEcCert()
+		req.NoError(err)
+
+		key, err := NewKeyFromPrivateKey("", ecPrivKey)
+		req.NoError(err)
+
+		signer, err := KeyToSigner(*key)
+		req.NoError(err)
+
+		template := &x509.Certificate{
+			SerialNumber:          big.NewInt(2),
+			Subject:               pkix.Name{CommonName: "TEST Signer Certificate"},
+			NotBefore:             time.Now(),
+			NotAfter:              time.Now().Add(time.Hour),
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+			BasicConstraintsValid: true,
+			IsCA:                  true,
+		}
+
+		der, err := x509.CreateCertificate(rand.Reader, template, template, signer.Public(), signer)
+		req.NoError(err)
+
+		cert, err := x509.ParseCertificate(der)
+		req.NoError(err)
+		req.NoError(cert.CheckSignatureFrom(cert))
+	})
+
+	t.Run("errors when alg does not match the key type", func(t *testing.T) {
+		req := require.New(t)
+
+		_, ecPrivKey, err := newEcCert()
+		req.NoError(err)
+
+		key, err := NewKeyFromPrivateKey("", ecPrivKey, WithAlgorithm(AlgorithmRs256))
+		req.NoError(err)
+
+		_, err = KeyToSigner(*key)
+		req.Error(err)
+	})
+}
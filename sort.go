@@ -0,0 +1,92 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto"
+	"sort"
+	"strings"
+)
+
+// KeyComparator orders two keys, returning a negative number if a sorts before b, a positive number if a sorts after
+// b and zero if they are equivalent
+type KeyComparator func(a, b *Key) int
+
+// CompareKid orders keys by kid
+func CompareKid(a, b *Key) int {
+	return strings.Compare(a.KeyId, b.KeyId)
+}
+
+// CompareThumbprint orders keys by their RFC 7638 SHA-256 thumbprint. Keys without a thumbprint sort last.
+func CompareThumbprint(a, b *Key) int {
+	aThumbprint, aErr := a.Thumbprint(crypto.SHA256)
+	bThumbprint, bErr := b.Thumbprint(crypto.SHA256)
+
+	switch {
+	case aErr != nil && bErr != nil:
+		return 0
+	case aErr != nil:
+		return 1
+	case bErr != nil:
+		return -1
+	}
+
+	return strings.Compare(aThumbprint, bThumbprint)
+}
+
+// CompareNotBefore orders keys by the NotBefore time of their x5c leaf certificate, oldest first. Keys without a
+// parsable x5c chain sort last.
+func CompareNotBefore(a, b *Key) int {
+	aChain, aErr := a.ParseX509Chain()
+	bChain, bErr := b.ParseX509Chain()
+
+	aOk := aErr == nil && len(aChain) > 0
+	bOk := bErr == nil && len(bChain) > 0
+
+	switch {
+	case !aOk && !bOk:
+		return 0
+	case !aOk:
+		return 1
+	case !bOk:
+		return -1
+	case aChain[0].NotBefore.Before(bChain[0].NotBefore):
+		return -1
+	case bChain[0].NotBefore.Before(aChain[0].NotBefore):
+		return 1
+	}
+
+	return 0
+}
+
+// Sort orders r.Keys in place using comparators in turn, each breaking ties left by the previous ones. With no
+// comparators keys are ordered by kid, then by thumbprint. The sort is stable.
+func (r *Response) Sort(comparators ...KeyComparator) {
+	if len(comparators) == 0 {
+		comparators = []KeyComparator{CompareKid, CompareThumbprint}
+	}
+
+	sort.SliceStable(r.Keys, func(i, j int) bool {
+		for _, compare := range comparators {
+			if result := compare(&r.Keys[i], &r.Keys[j]); result != 0 {
+				return result < 0
+			}
+		}
+
+		return false
+	})
+}
@@ -0,0 +1,107 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func newNotBeforeKey(t *testing.T, kid string, notBefore time.Time) Key {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: kid},
+		NotBefore:    notBefore,
+		NotAfter:     notBefore.Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	require.NoError(t, err)
+
+	key, err := NewKeyFromPublicKey(kid, &privateKey.PublicKey)
+	require.NoError(t, err)
+	key.X509Chain = []string{base64.StdEncoding.EncodeToString(der)}
+
+	return *key
+}
+
+func Test_ResponseSort(t *testing.T) {
+	t.Run("sorts by kid then thumbprint by default", func(t *testing.T) {
+		req := require.New(t)
+
+		response := &Response{}
+		req.NoError(json.Unmarshal([]byte(testJwksRfc7517Examples), response))
+
+		response.Keys = append(response.Keys, Key{KeyType: KeyTypeOct, KeyId: "1", K: "AQAB"})
+		response.Keys[0], response.Keys[2] = response.Keys[2], response.Keys[0]
+
+		expected := &Response{Keys: append([]Key{}, response.Keys...)}
+		expected.Sort()
+
+		response.Sort()
+		req.Equal(expected, response)
+
+		req.Equal("1", response.Keys[0].KeyId)
+		req.Equal("1", response.Keys[1].KeyId)
+		req.Equal("2011-04-29", response.Keys[2].KeyId)
+		req.Equal("juliet@capulet.lit", response.Keys[3].KeyId)
+		req.Negative(CompareThumbprint(&response.Keys[0], &response.Keys[1]))
+	})
+
+	t.Run("sorts by x5c not before", func(t *testing.T) {
+		req := require.New(t)
+
+		now := time.Now().Truncate(time.Second)
+		response := &Response{Keys: []Key{
+			{KeyType: KeyTypeOct, KeyId: "no-x5c", K: "AQAB"},
+			newNotBeforeKey(t, "newest", now),
+			newNotBeforeKey(t, "oldest", now.Add(-2*time.Hour)),
+			newNotBeforeKey(t, "older", now.Add(-time.Hour)),
+		}}
+
+		response.Sort(CompareNotBefore)
+
+		req.Equal("oldest", response.Keys[0].KeyId)
+		req.Equal("older", response.Keys[1].KeyId)
+		req.Equal("newest", response.Keys[2].KeyId)
+		req.Equal("no-x5c", response.Keys[3].KeyId)
+	})
+
+	t.Run("breaks ties with later comparators", func(t *testing.T) {
+		req := require.New(t)
+
+		response := &Response{Keys: []Key{
+			{KeyType: KeyTypeOct, KeyId: "b", K: "AQAB"},
+			{KeyType: KeyTypeOct, KeyId: "a", K: "AQAB"},
+		}}
+
+		response.Sort(CompareThumbprint, CompareKid)
+		req.Equal("a", response.Keys[0].KeyId)
+	})
+}
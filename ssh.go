@@ -0,0 +1,119 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/pem"
+	"fmt"
+	"golang.org/x/crypto/ssh"
+)
+
+// ParseSshAuthorizedKey parses a single public key line in the OpenSSH authorized_keys format and returns it as a
+// Key. RSA, ECDSA, and Ed25519 keys are supported. If no kid is supplied via WithKeyId the RFC 7638 thumbprint is used.
+func ParseSshAuthorizedKey(line []byte, opts ...KeyOption) (*Key, error) {
+	options := newKeyOptions(opts)
+
+	sshPubKey, _, _, _, err := ssh.ParseAuthorizedKey(line)
+
+	if err != nil {
+		return nil, fmt.Errorf("error parsing authorized key: %s", err)
+	}
+
+	cryptoPubKey, ok := sshPubKey.(ssh.CryptoPublicKey)
+
+	if !ok {
+		return nil, fmt.Errorf("unsupported ssh key type: %s", sshPubKey.Type())
+	}
+
+	return NewKeyFromPublicKey(options.keyId, cryptoPubKey.CryptoPublicKey(), opts...)
+}
+
+// SshAuthorizedKey returns the public key of the Key as an OpenSSH authorized_keys line with the kid as the comment
+func (k *Key) SshAuthorizedKey() ([]byte, error) {
+	pubKey, err := KeyToPublicKey(*k)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sshPubKey, err := ssh.NewPublicKey(pubKey)
+
+	if err != nil {
+		return nil, fmt.Errorf("error converting key %s to ssh public key: %s", k.KeyId, err)
+	}
+
+	line := bytes.TrimSuffix(ssh.MarshalAuthorizedKey(sshPubKey), []byte("\n"))
+
+	if k.KeyId != "" {
+		line = append(append(line, ' '), k.KeyId...)
+	}
+
+	return append(line, '\n'), nil
+}
+
+// ParseOpenSshPrivateKey parses an OpenSSH private key, as well as the PEM formats supported by
+// ssh.ParseRawPrivateKey, and returns it as a Key containing both public and private members. Encrypted keys require
+// WithPassphrase. If no kid is supplied via WithKeyId the RFC 7638 thumbprint is used.
+func ParseOpenSshPrivateKey(data []byte, opts ...KeyOption) (*Key, error) {
+	options := newKeyOptions(opts)
+
+	var privKey interface{}
+	var err error
+
+	if len(options.passphrase) > 0 {
+		privKey, err = ssh.ParseRawPrivateKeyWithPassphrase(data, options.passphrase)
+	} else {
+		privKey, err = ssh.ParseRawPrivateKey(data)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error parsing ssh private key: %s", err)
+	}
+
+	// ssh returns Ed25519 keys by reference
+	if edPrivKey, ok := privKey.(*ed25519.PrivateKey); ok {
+		privKey = *edPrivKey
+	}
+
+	return NewKeyFromPrivateKey(options.keyId, privKey, opts...)
+}
+
+// OpenSshPrivateKey returns the private key of the Key in the OpenSSH private key format with the kid as the comment.
+// If passphrase is non-empty the key is encrypted with it.
+func (k *Key) OpenSshPrivateKey(passphrase []byte) ([]byte, error) {
+	privKey, err := KeyToPrivateKey(*k)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var block *pem.Block
+
+	if len(passphrase) > 0 {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(privKey, k.KeyId, passphrase)
+	} else {
+		block, err = ssh.MarshalPrivateKey(privKey, k.KeyId)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling key %s to ssh private key: %s", k.KeyId, err)
+	}
+
+	return pem.EncodeToMemory(block), nil
+}
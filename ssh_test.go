@@ -0,0 +1,102 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"strings"
+	"testing"
+)
+
+func Test_Ssh(t *testing.T) {
+	_, rsaPrivKey, err := newRsaCert()
+	require.NoError(t, err)
+
+	_, ecPrivKey, err := newEcCert()
+	require.NoError(t, err)
+
+	_, edPrivKey, err := newEd25519Cert()
+	require.NoError(t, err)
+
+	privKeys := []interface {
+		Equal(x crypto.PrivateKey) bool
+	}{rsaPrivKey, ecPrivKey, edPrivKey}
+
+	t.Run("can round trip authorized keys", func(t *testing.T) {
+		req := require.New(t)
+
+		for _, privKey := range privKeys {
+			orig, err := NewKeyFromPrivateKey("", privKey)
+			req.NoError(err)
+
+			line, err := orig.SshAuthorizedKey()
+			req.NoError(err)
+			req.True(strings.HasSuffix(string(line), " "+orig.KeyId+"\n"))
+
+			sshPubKey, _, _, _, err := ssh.ParseAuthorizedKey(line)
+			req.NoError(err)
+			req.NotNil(sshPubKey)
+
+			key, err := ParseSshAuthorizedKey(line)
+			req.NoError(err)
+			req.Equal(orig.KeyId, key.KeyId)
+			req.Equal(orig.X, key.X)
+			req.Equal(orig.N, key.N)
+			req.Empty(key.D)
+		}
+	})
+
+	t.Run("can round trip OpenSSH private keys", func(t *testing.T) {
+		req := require.New(t)
+
+		for _, privKey := range privKeys {
+			orig, err := NewKeyFromPrivateKey("", privKey)
+			req.NoError(err)
+
+			data, err := orig.OpenSshPrivateKey(nil)
+			req.NoError(err)
+			req.Contains(string(data), "OPENSSH PRIVATE KEY")
+
+			key, err := ParseOpenSshPrivateKey(data)
+			req.NoError(err)
+			req.Equal(orig.KeyId, key.KeyId)
+
+			endKey, err := KeyToPrivateKey(*key)
+			req.NoError(err)
+			req.True(privKey.Equal(endKey))
+		}
+	})
+
+	t.Run("can round trip encrypted OpenSSH private keys", func(t *testing.T) {
+		req := require.New(t)
+
+		orig, err := NewKeyFromPrivateKey("", edPrivKey)
+		req.NoError(err)
+
+		data, err := orig.OpenSshPrivateKey([]byte("passphrase"))
+		req.NoError(err)
+
+		_, err = ParseOpenSshPrivateKey(data)
+		req.Error(err)
+
+		key, err := ParseOpenSshPrivateKey(data, WithPassphrase([]byte("passphrase")))
+		req.NoError(err)
+		req.Equal(orig.D, key.D)
+	})
+}
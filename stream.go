@@ -0,0 +1,265 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// KeyDecoder reads the keys of a JWK Set from a stream one at a time, so very large sets, such as those of
+// federations, do not need to be held in memory as both a document and a Response. Members other than "keys" are
+// skipped. Documents without a "keys" member but with a "kty" member are single JWKs and decoded as a set of that one
+// key, as ParseResponse does. Limits are enforced as for Response.UnmarshalJSON, with MaxKeys checked as keys are
+// read.
+type KeyDecoder struct {
+	// Limits bounds the size of the set and its keys, if nil DefaultLimits are enforced. It must be set before the
+	// first call to Next.
+	Limits *Limits
+
+	decoder *json.Decoder
+	state   keyDecoderState
+	count   int
+	err     error
+
+	// members holds the members read before "keys", so single JWK documents can be decoded once the object ends
+	members map[string]json.RawMessage
+	single  *Key
+}
+
+type keyDecoderState int
+
+const (
+	keyDecoderStart keyDecoderState = iota
+	keyDecoderInKeys
+	keyDecoderSingle
+	keyDecoderDone
+)
+
+// NewKeyDecoder returns a KeyDecoder reading a JWK Set from r
+func NewKeyDecoder(r io.Reader) *KeyDecoder {
+	return &KeyDecoder{
+		decoder: json.NewDecoder(r),
+	}
+}
+
+// Next returns the next key of the set or io.EOF once every key has been read. Any other error is returned by all
+// subsequent calls.
+func (d *KeyDecoder) Next() (*Key, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	key, err := d.next()
+
+	if err != nil {
+		d.err = err
+	}
+
+	return key, err
+}
+
+func (d *KeyDecoder) next() (*Key, error) {
+	switch d.state {
+	case keyDecoderStart:
+		if err := d.expectDelim('{'); err != nil {
+			return nil, err
+		}
+
+		if err := d.skipToKeys(); err != nil {
+			return nil, err
+		}
+
+		return d.next()
+	case keyDecoderInKeys:
+		if !d.decoder.More() {
+			if err := d.expectDelim(']'); err != nil {
+				return nil, err
+			}
+
+			d.state = keyDecoderDone
+
+			if err := d.skipToKeys(); err != nil {
+				return nil, err
+			}
+
+			return d.next()
+		}
+
+		d.count++
+
+		limits := limitsOrDefault(d.Limits)
+
+		if err := limits.checkKeyCount(d.count); err != nil {
+			return nil, err
+		}
+
+		raw := json.RawMessage{}
+		key := &Key{}
+
+		if err := d.decoder.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("could not decode keys[%d]: %s", d.count-1, err)
+		}
+
+		if err := key.unmarshalJSON(raw, limits); err != nil {
+			return nil, fmt.Errorf("could not decode keys[%d]: %s", d.count-1, err)
+		}
+
+		return key, nil
+	case keyDecoderSingle:
+		d.state = keyDecoderDone
+
+		return d.single, nil
+	}
+
+	return nil, io.EOF
+}
+
+// skipToKeys skips members of the set object until the start of the keys array, entering keyDecoderInKeys, or the
+// end of the object, entering keyDecoderSingle for single JWK documents and keyDecoderDone otherwise. Keys members
+// after the first are skipped.
+func (d *KeyDecoder) skipToKeys() error {
+	for d.decoder.More() {
+		token, err := d.decoder.Token()
+
+		if err != nil {
+			return err
+		}
+
+		if token == "keys" && d.state == keyDecoderStart {
+			token, err = d.decoder.Token()
+
+			if err != nil {
+				return err
+			}
+
+			if token == nil {
+				continue
+			}
+
+			if delim, ok := token.(json.Delim); !ok || delim != '[' {
+				return fmt.Errorf("keys must be an array, got %v", token)
+			}
+
+			d.members = nil
+			d.state = keyDecoderInKeys
+
+			return nil
+		}
+
+		skipped := json.RawMessage{}
+
+		if err = d.decoder.Decode(&skipped); err != nil {
+			return err
+		}
+
+		if name, ok := token.(string); ok && d.state == keyDecoderStart {
+			if d.members == nil {
+				d.members = map[string]json.RawMessage{}
+			}
+
+			d.members[name] = skipped
+		}
+	}
+
+	if err := d.expectDelim('}'); err != nil {
+		return err
+	}
+
+	if _, ok := d.members["kty"]; ok && d.state == keyDecoderStart {
+		return d.decodeSingle()
+	}
+
+	d.members = nil
+	d.state = keyDecoderDone
+
+	return nil
+}
+
+// decodeSingle decodes the members read by skipToKeys as a single JWK and enters keyDecoderSingle
+func (d *KeyDecoder) decodeSingle() error {
+	data, err := json.Marshal(d.members)
+	d.members = nil
+
+	if err != nil {
+		return err
+	}
+
+	d.count++
+
+	limits := limitsOrDefault(d.Limits)
+
+	if err = limits.checkKeyCount(d.count); err != nil {
+		return err
+	}
+
+	key := &Key{}
+
+	if err = key.unmarshalJSON(data, limits); err != nil {
+		return fmt.Errorf("could not decode JWK: %s", err)
+	}
+
+	d.single = key
+	d.state = keyDecoderSingle
+
+	return nil
+}
+
+// expectDelim reads the next token and returns an error if it is not delim
+func (d *KeyDecoder) expectDelim(delim json.Delim) error {
+	token, err := d.decoder.Token()
+
+	if err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+
+		return err
+	}
+
+	if actual, ok := token.(json.Delim); !ok || actual != delim {
+		return fmt.Errorf("expected %s in JWK Set, got %v", delim, token)
+	}
+
+	return nil
+}
+
+// DecodeKeys reads a JWK Set from r with a KeyDecoder and calls f with every key in document order. Decoding stops at
+// the first error returned by f, which is returned.
+func DecodeKeys(r io.Reader, f func(key *Key) error) error {
+	return decodeKeys(NewKeyDecoder(r), f)
+}
+
+// decodeKeys implements DecodeKeys for a configured decoder
+func decodeKeys(decoder *KeyDecoder, f func(key *Key) error) error {
+	for {
+		key, err := decoder.Next()
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err = f(key); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,158 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"io"
+	"strings"
+	"testing"
+)
+
+func Test_KeyDecoder(t *testing.T) {
+	t.Run("can stream the keys of a set", func(t *testing.T) {
+		req := require.New(t)
+
+		expected, err := ParseResponse([]byte(testPublicJwksAuth0))
+		req.NoError(err)
+
+		decoder := NewKeyDecoder(strings.NewReader(testPublicJwksAuth0))
+
+		for i := range expected.Keys {
+			key, err := decoder.Next()
+			req.NoError(err)
+			req.Equal(expected.Keys[i], *key)
+		}
+
+		_, err = decoder.Next()
+		req.Equal(io.EOF, err)
+
+		_, err = decoder.Next()
+		req.Equal(io.EOF, err)
+	})
+
+	t.Run("can skip other members", func(t *testing.T) {
+		req := require.New(t)
+
+		var kids []string
+
+		err := DecodeKeys(strings.NewReader(`{"iss": {"nested": [1, 2]}, "keys": [{"kty": "oct", "kid": "a"}, {"kty": "oct", "kid": "b"}], "keys": [{"kty": "oct", "kid": "c"}], "exp": 1}`), func(key *Key) error {
+			kids = append(kids, key.KeyId)
+			return nil
+		})
+		req.NoError(err)
+		req.Equal([]string{"a", "b"}, kids)
+	})
+
+	t.Run("can decode sets without keys", func(t *testing.T) {
+		req := require.New(t)
+
+		for _, document := range []string{`{}`, `{"keys": []}`, `{"keys": null}`} {
+			err := DecodeKeys(strings.NewReader(document), func(key *Key) error {
+				return fmt.Errorf("unexpected key %s", key.KeyId)
+			})
+			req.NoError(err, document)
+		}
+	})
+
+	t.Run("can decode single JWK documents", func(t *testing.T) {
+		req := require.New(t)
+
+		expected, err := ParseKey([]byte(testSingleJwk))
+		req.NoError(err)
+
+		decoder := NewKeyDecoder(strings.NewReader(testSingleJwk))
+
+		key, err := decoder.Next()
+		req.NoError(err)
+		req.Equal(*expected, *key)
+
+		_, err = decoder.Next()
+		req.Equal(io.EOF, err)
+
+		var kids []string
+
+		err = DecodeKeys(strings.NewReader(`{"x-other": 1, "kty": "oct", "kid": "a", "k": "AAAA"}`), func(key *Key) error {
+			kids = append(kids, key.KeyId)
+			return nil
+		})
+		req.NoError(err)
+		req.Equal([]string{"a"}, kids)
+	})
+
+	t.Run("prefers keys over top level key members", func(t *testing.T) {
+		req := require.New(t)
+
+		var kids []string
+
+		err := DecodeKeys(strings.NewReader(`{"kty": "oct", "kid": "top", "keys": [{"kty": "oct", "kid": "a"}]}`), func(key *Key) error {
+			kids = append(kids, key.KeyId)
+			return nil
+		})
+		req.NoError(err)
+		req.Equal([]string{"a"}, kids)
+	})
+
+	t.Run("stops at the first callback error", func(t *testing.T) {
+		req := require.New(t)
+
+		stop := errors.New("stop")
+		count := 0
+
+		err := DecodeKeys(strings.NewReader(testPublicJwksAuth0), func(key *Key) error {
+			count++
+			return stop
+		})
+		req.Equal(stop, err)
+		req.Equal(1, count)
+	})
+
+	t.Run("rejects malformed documents", func(t *testing.T) {
+		req := require.New(t)
+
+		for _, document := range []string{`[]`, `{"keys": {}}`, `{"keys": [{"kty": 1}]}`, `{"keys": [{"kty": "oct"}`, ``, `{"kty": 1}`} {
+			err := DecodeKeys(strings.NewReader(document), func(*Key) error { return nil })
+			req.Error(err, document)
+			req.NotEqual(io.EOF, err, document)
+		}
+	})
+
+	t.Run("enforces the key limit while streaming", func(t *testing.T) {
+		req := require.New(t)
+
+		decoder := NewKeyDecoder(strings.NewReader(`{"keys": [{"kty": "oct"}, {"kty": "oct"}]}`))
+		decoder.Limits = &Limits{MaxKeys: 1}
+
+		count := 0
+
+		err := decodeKeys(decoder, func(*Key) error {
+			count++
+			return nil
+		})
+		req.IsType(&LimitError{}, err)
+		req.Equal(1, count)
+
+		decoder = NewKeyDecoder(strings.NewReader(`{"kid": "a", "kty": "oct", "x-vendor": "too long"}`))
+		decoder.Limits = &Limits{MaxMemberLength: 4}
+
+		_, err = decoder.Next()
+		req.Error(err)
+		req.Contains(err.Error(), ErrorMemberTooLongMsg)
+	})
+}
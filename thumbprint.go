@@ -0,0 +1,150 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// Thumbprint computes the RFC 7638 JWK thumbprint of the key using the supplied hash and returns it base64url
+// encoded without padding. Only the required public members of the key's kty participate in the thumbprint, so a
+// private key and its public counterpart share the same thumbprint.
+func (k *Key) Thumbprint(hash crypto.Hash) (string, error) {
+	if !hash.Available() {
+		return "", fmt.Errorf("hash function %v is not available", hash)
+	}
+
+	input, err := k.thumbprintInput()
+
+	if err != nil {
+		return "", err
+	}
+
+	h := hash.New()
+	_, _ = h.Write(input)
+
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil)), nil
+}
+
+// ThumbprintUriPrefix prefixes JWK Thumbprint URIs, followed by the hash name and the thumbprint, RFC 9278
+const ThumbprintUriPrefix = "urn:ietf:params:oauth:jwk-thumbprint:"
+
+// thumbprintHashNames maps hashes to their names in the IANA Named Information Hash Algorithm Registry
+var thumbprintHashNames = map[crypto.Hash]string{
+	crypto.SHA256: "sha-256",
+	crypto.SHA384: "sha-384",
+	crypto.SHA512: "sha-512",
+}
+
+// ThumbprintURI returns the RFC 9278 JWK Thumbprint URI of the key, e.g.
+// urn:ietf:params:oauth:jwk-thumbprint:sha-256:<thumbprint>. SHA-256, SHA-384, and SHA-512 are supported.
+func (k *Key) ThumbprintURI(hash crypto.Hash) (string, error) {
+	name, ok := thumbprintHashNames[hash]
+
+	if !ok {
+		return "", fmt.Errorf("hash function %v has no JWK thumbprint URI name", hash)
+	}
+
+	thumbprint, err := k.Thumbprint(hash)
+
+	if err != nil {
+		return "", err
+	}
+
+	return ThumbprintUriPrefix + name + ":" + thumbprint, nil
+}
+
+// thumbprintInput returns the JSON object containing only the required members of the key in lexicographic order
+// with no whitespace as defined by https://www.rfc-editor.org/rfc/rfc7638#section-3
+func (k *Key) thumbprintInput() ([]byte, error) {
+	type member struct {
+		name  string
+		value string
+	}
+
+	var members []member
+
+	switch k.KeyType {
+	case KeyTypeRsa:
+		members = []member{{"e", k.E}, {"kty", k.KeyType}, {"n", k.N}}
+	case KeyTypeEc:
+		members = []member{{"crv", k.Curve}, {"kty", k.KeyType}, {"x", k.X}, {"y", k.Y}}
+	case KeyTypeOkp:
+		members = []member{{"crv", k.Curve}, {"kty", k.KeyType}, {"x", k.X}}
+	case KeyTypeOct:
+		members = []member{{"k", k.K}, {"kty", k.KeyType}}
+	default:
+		return nil, fmt.Errorf("unsupported key type for thumbprint: %s", k.KeyType)
+	}
+
+	size := 2
+
+	for _, m := range members {
+		size += len(m.name) + len(m.value) + 6
+	}
+
+	buf := make([]byte, 1, size)
+	buf[0] = '{'
+
+	for i, m := range members {
+		if m.value == "" {
+			return nil, fmt.Errorf("key is missing required member for thumbprint: %s", m.name)
+		}
+
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+
+		buf = append(buf, '"')
+		buf = append(buf, m.name...)
+		buf = append(buf, '"', ':')
+
+		if isPlainJsonString(m.value) {
+			// base64url values and key types never need escaping, avoid json.Marshal on the hot path
+			buf = append(buf, '"')
+			buf = append(buf, m.value...)
+			buf = append(buf, '"')
+			continue
+		}
+
+		value, err := json.Marshal(m.value)
+
+		if err != nil {
+			return nil, err
+		}
+
+		buf = append(buf, value...)
+	}
+
+	return append(buf, '}'), nil
+}
+
+// isPlainJsonString returns true if s is encoded by json.Marshal as itself in quotes
+func isPlainJsonString(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+
+		if c < 0x20 || c >= 0x80 || c == '"' || c == '\\' || c == '<' || c == '>' || c == '&' {
+			return false
+		}
+	}
+
+	return true
+}
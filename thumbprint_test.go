@@ -0,0 +1,150 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_Thumbprint(t *testing.T) {
+	t.Run("computes the rfc7638 example thumbprint", func(t *testing.T) {
+		req := require.New(t)
+		response := &Response{}
+
+		err := json.Unmarshal([]byte(testJwksRfc7517Examples), response)
+		req.NoError(err)
+
+		thumbprint, err := response.Keys[1].Thumbprint(crypto.SHA256)
+		req.NoError(err)
+		req.Equal("NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs", thumbprint)
+	})
+
+	t.Run("computes the rfc8037 example Ed25519 thumbprint", func(t *testing.T) {
+		req := require.New(t)
+
+		key := &Key{
+			KeyType: KeyTypeOkp,
+			Curve:   CurveEd25519,
+			X:       "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo",
+		}
+
+		thumbprint, err := key.Thumbprint(crypto.SHA256)
+		req.NoError(err)
+		req.Equal("kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k", thumbprint)
+	})
+
+	t.Run("ignores private and optional members", func(t *testing.T) {
+		req := require.New(t)
+		response := &Response{}
+
+		err := json.Unmarshal([]byte(testJwksRfc7517Examples), response)
+		req.NoError(err)
+
+		privateKey := response.Keys[2]
+
+		publicKey := Key{
+			KeyType: privateKey.KeyType,
+			N:       privateKey.N,
+			E:       privateKey.E,
+		}
+
+		privateThumbprint, err := privateKey.Thumbprint(crypto.SHA256)
+		req.NoError(err)
+
+		publicThumbprint, err := publicKey.Thumbprint(crypto.SHA256)
+		req.NoError(err)
+
+		req.Equal(publicThumbprint, privateThumbprint)
+	})
+
+	t.Run("errors on missing required members", func(t *testing.T) {
+		req := require.New(t)
+
+		key := &Key{KeyType: KeyTypeRsa, N: "AQAB"}
+
+		_, err := key.Thumbprint(crypto.SHA256)
+		req.Error(err)
+	})
+
+	t.Run("NewKey can derive kid from the thumbprint", func(t *testing.T) {
+		req := require.New(t)
+
+		rsaCert, _, err := newRsaCert()
+		req.NoError(err)
+
+		key, err := NewKey("", rsaCert, nil, WithThumbprintKid())
+		req.NoError(err)
+
+		thumbprint, err := key.Thumbprint(crypto.SHA256)
+		req.NoError(err)
+		req.Equal(thumbprint, key.KeyId)
+		req.Equal("AQAB", key.E)
+	})
+
+	t.Run("NewKeyFromPublicKey defaults kid to the thumbprint", func(t *testing.T) {
+		req := require.New(t)
+
+		_, privateKey, err := newRsaCert()
+		req.NoError(err)
+
+		key, err := NewKeyFromPublicKey("", privateKey.Public())
+		req.NoError(err)
+
+		thumbprint, err := key.Thumbprint(crypto.SHA256)
+		req.NoError(err)
+		req.Equal(thumbprint, key.KeyId)
+		req.Equal(AlgorithmRs256, key.Algorithm)
+
+		pubKey, err := KeyToPublicKey(*key)
+		req.NoError(err)
+		req.True(privateKey.Public().(*rsa.PublicKey).Equal(pubKey))
+	})
+}
+
+func Test_ThumbprintURI(t *testing.T) {
+	key := &Key{
+		KeyType: KeyTypeOkp,
+		Curve:   CurveEd25519,
+		X:       "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo",
+	}
+
+	t.Run("can compute the thumbprint URI", func(t *testing.T) {
+		req := require.New(t)
+
+		uri, err := key.ThumbprintURI(crypto.SHA256)
+		req.NoError(err)
+		req.Equal("urn:ietf:params:oauth:jwk-thumbprint:sha-256:kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k", uri)
+
+		uri, err = key.ThumbprintURI(crypto.SHA512)
+		req.NoError(err)
+
+		thumbprint, err := key.Thumbprint(crypto.SHA512)
+		req.NoError(err)
+		req.Equal(ThumbprintUriPrefix+"sha-512:"+thumbprint, uri)
+	})
+
+	t.Run("rejects hashes without a registered name", func(t *testing.T) {
+		req := require.New(t)
+
+		_, err := key.ThumbprintURI(crypto.SHA1)
+		req.Error(err)
+	})
+}
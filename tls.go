@@ -0,0 +1,188 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto"
+	"crypto/tls"
+	"fmt"
+	"github.com/pkg/errors"
+	"net/http"
+)
+
+const ErrorTlsPolicyMsg = "connection does not meet the TLS policy"
+
+// KeyToTlsCertificate combines the private key material of a Key with its x5c chain into a tls.Certificate suitable
+// for TLS server or client authentication. The first x5c certificate must be the leaf and match the private key.
+func KeyToTlsCertificate(key Key) (*tls.Certificate, error) {
+	certs, err := key.ParseX509Chain()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("key does not contain an x5c certificate chain")
+	}
+
+	privKey, err := KeyToPrivateKey(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	leaf := certs[0]
+
+	leafPubKey, ok := leaf.PublicKey.(interface {
+		Equal(x crypto.PublicKey) bool
+	})
+
+	if !ok || !leafPubKey.Equal(privKey.(crypto.Signer).Public()) {
+		return nil, errors.New("private key does not match the x5c leaf certificate")
+	}
+
+	ret := &tls.Certificate{
+		PrivateKey: privKey,
+		Leaf:       leaf,
+	}
+
+	for _, cert := range certs {
+		ret.Certificate = append(ret.Certificate, cert.Raw)
+	}
+
+	return ret, nil
+}
+
+// TlsPolicy restricts the TLS connections resolvers fetch key sets and revocation lists over, so the channel carrying
+// trust anchors meets deployment policy. Plain HTTP is rejected. CipherSuites only applies to TLS 1.2 and below, TLS
+// 1.3 suites are not configurable, see tls.Config.
+type TlsPolicy struct {
+	// MinVersion is the minimum TLS version, such as tls.VersionTLS12, zero uses tls.VersionTLS12
+	MinVersion uint16
+
+	// CipherSuites restricts the TLS 1.2 cipher suites, empty uses the Go defaults
+	CipherSuites []uint16
+}
+
+// Tls12Policy returns a TlsPolicy requiring TLS 1.2 or later and, for TLS 1.2, forward secret AEAD cipher suites
+func Tls12Policy() *TlsPolicy {
+	return &TlsPolicy{
+		MinVersion: tls.VersionTLS12,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+		},
+	}
+}
+
+// Tls13Policy returns a TlsPolicy requiring TLS 1.3
+func Tls13Policy() *TlsPolicy {
+	return &TlsPolicy{
+		MinVersion: tls.VersionTLS13,
+	}
+}
+
+// minVersion returns MinVersion or tls.VersionTLS12 if it is not set
+func (p *TlsPolicy) minVersion() uint16 {
+	if p.MinVersion == 0 {
+		return tls.VersionTLS12
+	}
+
+	return p.MinVersion
+}
+
+// client returns a copy of base, or http.DefaultClient if nil, whose transport is configured with the policy. The
+// transport of base must be nil or an *http.Transport.
+func (p *TlsPolicy) client(base *http.Client) (*http.Client, error) {
+	if base == nil {
+		base = http.DefaultClient
+	}
+
+	roundTripper := base.Transport
+
+	if roundTripper == nil {
+		roundTripper = http.DefaultTransport
+	}
+
+	transport, ok := roundTripper.(*http.Transport)
+
+	if !ok {
+		return nil, fmt.Errorf("TLS policy can not be applied to transport %T", roundTripper)
+	}
+
+	transport = transport.Clone()
+
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+
+	if transport.TLSClientConfig.MinVersion < p.minVersion() {
+		transport.TLSClientConfig.MinVersion = p.minVersion()
+	}
+
+	if len(p.CipherSuites) > 0 {
+		transport.TLSClientConfig.CipherSuites = p.CipherSuites
+	}
+
+	client := *base
+	client.Transport = transport
+
+	return &client, nil
+}
+
+// check returns an error if the connection state of a response does not meet the policy
+func (p *TlsPolicy) check(state *tls.ConnectionState) error {
+	if state == nil {
+		return fmt.Errorf("%s: TLS is required", ErrorTlsPolicyMsg)
+	}
+
+	if state.Version < p.minVersion() {
+		return fmt.Errorf("%s: %s is below the minimum version %s", ErrorTlsPolicyMsg, tlsVersionName(state.Version), tlsVersionName(p.minVersion()))
+	}
+
+	if state.Version < tls.VersionTLS13 && len(p.CipherSuites) > 0 {
+		for _, suite := range p.CipherSuites {
+			if suite == state.CipherSuite {
+				return nil
+			}
+		}
+
+		return fmt.Errorf("%s: cipher suite %s is not allowed", ErrorTlsPolicyMsg, tls.CipherSuiteName(state.CipherSuite))
+	}
+
+	return nil
+}
+
+// tlsVersionName returns the name of a TLS version
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	}
+
+	return fmt.Sprintf("0x%04X", version)
+}
@@ -0,0 +1,203 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_KeyToTlsCertificate(t *testing.T) {
+	t.Run("can build a certificate usable for a TLS handshake", func(t *testing.T) {
+		req := require.New(t)
+
+		ecCert, ecPrivKey, err := newEcCert()
+		req.NoError(err)
+
+		key, err := NewKey("", ecCert, []*x509.Certificate{ecCert})
+		req.NoError(err)
+
+		privKey, err := NewKeyFromPrivateKey(key.KeyId, ecPrivKey)
+		req.NoError(err)
+		privKey.X509Chain = key.X509Chain
+
+		tlsCert, err := KeyToTlsCertificate(*privKey)
+		req.NoError(err)
+		req.Len(tlsCert.Certificate, 1)
+		req.Equal(ecCert.Raw, tlsCert.Leaf.Raw)
+
+		roots := x509.NewCertPool()
+		roots.AddCert(ecCert)
+
+		serverConn, clientConn := net.Pipe()
+		defer func() { _ = serverConn.Close() }()
+		defer func() { _ = clientConn.Close() }()
+
+		server := tls.Server(serverConn, &tls.Config{Certificates: []tls.Certificate{*tlsCert}})
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- server.Handshake()
+		}()
+
+		// the test certificate has no SANs, so verify the chain manually
+		client := tls.Client(clientConn, &tls.Config{
+			InsecureSkipVerify: true,
+			VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+				cert, err := x509.ParseCertificate(rawCerts[0])
+				if err != nil {
+					return err
+				}
+				_, err = cert.Verify(x509.VerifyOptions{Roots: roots})
+				return err
+			},
+		})
+
+		req.NoError(client.Handshake())
+		req.NoError(<-errs)
+	})
+
+	t.Run("errors without an x5c chain", func(t *testing.T) {
+		req := require.New(t)
+
+		_, ecPrivKey, err := newEcCert()
+		req.NoError(err)
+
+		privKey, err := NewKeyFromPrivateKey("", ecPrivKey)
+		req.NoError(err)
+
+		_, err = KeyToTlsCertificate(*privKey)
+		req.Error(err)
+	})
+
+	t.Run("errors when the leaf does not match the private key", func(t *testing.T) {
+		req := require.New(t)
+
+		ecCert, _, err := newEcCert()
+		req.NoError(err)
+
+		_, otherPrivKey, err := newEcCert()
+		req.NoError(err)
+
+		key, err := NewKey("", ecCert, []*x509.Certificate{ecCert})
+		req.NoError(err)
+
+		privKey, err := NewKeyFromPrivateKey(key.KeyId, otherPrivKey)
+		req.NoError(err)
+		privKey.X509Chain = key.X509Chain
+
+		_, err = KeyToTlsCertificate(*privKey)
+		req.Error(err)
+	})
+}
+
+func Test_TlsPolicy(t *testing.T) {
+	_, published, err := GenerateECKey("P-256", "published")
+	require.NoError(t, err)
+
+	newServer := func(maxVersion uint16, cipherSuites ...uint16) *httptest.Server {
+		server := httptest.NewUnstartedServer(Handler(StaticResponseSource(&Response{Keys: []Key{*published}})))
+		server.TLS = &tls.Config{
+			MaxVersion:   maxVersion,
+			CipherSuites: cipherSuites,
+		}
+		server.StartTLS()
+
+		return server
+	}
+
+	t.Run("can fetch over a connection meeting the policy", func(t *testing.T) {
+		req := require.New(t)
+
+		server := newServer(tls.VersionTLS13)
+		defer server.Close()
+
+		resolver := &HttpResolver{Client: server.Client(), TlsPolicy: Tls13Policy()}
+
+		response, _, err := resolver.Get(server.URL)
+		req.NoError(err)
+		req.Len(response.Keys, 1)
+
+		resolver.TlsPolicy = Tls12Policy()
+
+		_, _, err = resolver.Get(server.URL)
+		req.NoError(err)
+	})
+
+	t.Run("rejects connections below the minimum version", func(t *testing.T) {
+		req := require.New(t)
+
+		server := newServer(tls.VersionTLS12)
+		defer server.Close()
+
+		resolver := &HttpResolver{Client: server.Client(), TlsPolicy: Tls13Policy()}
+
+		_, _, err := resolver.Get(server.URL)
+		req.Error(err)
+
+		resolver.TlsPolicy = Tls12Policy()
+
+		_, _, err = resolver.Get(server.URL)
+		req.NoError(err)
+	})
+
+	t.Run("rejects cipher suites outside the policy", func(t *testing.T) {
+		req := require.New(t)
+
+		server := newServer(tls.VersionTLS12, tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA)
+		defer server.Close()
+
+		resolver := &HttpResolver{Client: server.Client(), TlsPolicy: Tls12Policy()}
+
+		_, _, err := resolver.Get(server.URL)
+		req.Error(err)
+
+		resolver.TlsPolicy = nil
+
+		_, _, err = resolver.Get(server.URL)
+		req.NoError(err)
+	})
+
+	t.Run("rejects plain HTTP", func(t *testing.T) {
+		req := require.New(t)
+
+		server := httptest.NewServer(Handler(StaticResponseSource(&Response{Keys: []Key{*published}})))
+		defer server.Close()
+
+		resolver := &HttpRevocationResolver{TlsPolicy: Tls12Policy()}
+
+		_, _, err := resolver.Get(server.URL)
+		req.IsType(&HttpResolverError{}, err)
+		req.EqualError(err, ErrorTlsPolicyMsg+": TLS is required")
+	})
+
+	t.Run("rejects transports it can not configure", func(t *testing.T) {
+		req := require.New(t)
+
+		client := &http.Client{Transport: http.NewFileTransport(http.Dir("."))}
+
+		resolver := &SignedHttpResolver{Client: client, TlsPolicy: Tls12Policy()}
+
+		_, _, err := resolver.Get("https://example.com")
+		req.Error(err)
+	})
+}
@@ -0,0 +1,107 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Fix describes a nonstandard member normalized by a Parser in Tolerant mode. Descriptions never include key material.
+type Fix struct {
+	KeyIndex    int
+	KeyId       string
+	Member      string
+	Description string
+}
+
+func (f *Fix) String() string {
+	return fmt.Sprintf("keys[%d] (kid %q) member %s: %s", f.KeyIndex, f.KeyId, f.Member, f.Description)
+}
+
+// curveAliases maps common nonstandard curve names to their JWA names
+var curveAliases = map[string]string{
+	"secp256r1":  "P-256",
+	"prime256v1": "P-256",
+	"p256":       "P-256",
+	"secp384r1":  "P-384",
+	"p384":       "P-384",
+	"secp521r1":  "P-521",
+	"p521":       "P-521",
+	"ed25519":    CurveEd25519,
+	"x25519":     CurveX25519,
+}
+
+// keyTypeNames maps lower cased key types to their JWA names
+var keyTypeNames = map[string]string{
+	"rsa": KeyTypeRsa,
+	"ec":  KeyTypeEc,
+	"okp": KeyTypeOkp,
+	"oct": KeyTypeOct,
+}
+
+// normalizeKey rewrites common real-world deviations in key into canonical form and returns what was fixed: key
+// types in the wrong case, curve aliases, and padded or standard alphabet base64 in binary members.
+func normalizeKey(index int, key *Key) []*Fix {
+	var fixes []*Fix
+
+	fix := func(member, format string, args ...interface{}) {
+		fixes = append(fixes, &Fix{
+			KeyIndex:    index,
+			KeyId:       key.KeyId,
+			Member:      member,
+			Description: fmt.Sprintf(format, args...),
+		})
+	}
+
+	if name, ok := keyTypeNames[strings.ToLower(key.KeyType)]; ok && name != key.KeyType {
+		fix("kty", "normalized %q to %q", key.KeyType, name)
+		key.KeyType = name
+	}
+
+	if name, ok := curveAliases[strings.ToLower(key.Curve)]; ok && name != key.Curve {
+		fix("crv", "normalized %q to %q", key.Curve, name)
+		key.Curve = name
+	}
+
+	members := []struct {
+		name  string
+		value *string
+	}{
+		{"n", &key.N}, {"e", &key.E}, {"x", &key.X}, {"y", &key.Y}, {"k", &key.K},
+		{"d", &key.D}, {"p", &key.P}, {"q", &key.Q}, {"dp", &key.Dp}, {"dq", &key.Dq}, {"qi", &key.Qi},
+		{"x5t", &key.X509Thumbprint}, {"x5t#S256", &key.X509ThumbprintSha256},
+	}
+
+	for _, member := range members {
+		value := *member.value
+
+		if strings.HasSuffix(value, "=") {
+			value = strings.TrimRight(value, "=")
+			fix(member.name, "removed base64 padding")
+		}
+
+		if strings.ContainsAny(value, "+/") {
+			value = strings.NewReplacer("+", "-", "/", "_").Replace(value)
+			fix(member.name, "converted standard base64 to base64url")
+		}
+
+		*member.value = value
+	}
+
+	return fixes
+}
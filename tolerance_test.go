@@ -0,0 +1,84 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func Test_ParserTolerant(t *testing.T) {
+	doc := `{"keys": [
+  {"kty": "ec", "crv": "prime256v1", "kid": "ec",
+   "x": "MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4=", "y": "4Etl6SRW2YiLUrN5vfvVHuhp7x8PxltmWWlbbM4IFyM"},
+  {"kty": "oct", "kid": "sym", "k": "GawgguFyGrWKav7AX4VKUg=="}
+]}`
+
+	t.Run("normalizes deviations and reports them", func(t *testing.T) {
+		req := require.New(t)
+
+		parser := &Parser{Tolerant: true, StrictBase64: true, DisallowUnknownFields: true}
+		response, report, err := parser.ParseWithReport([]byte(doc))
+		req.NoError(err)
+
+		req.Equal(KeyTypeEc, response.Keys[0].KeyType)
+		req.Equal("P-256", response.Keys[0].Curve)
+		req.Equal("MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4", response.Keys[0].X)
+		req.Equal("GawgguFyGrWKav7AX4VKUg", response.Keys[1].K)
+
+		_, err = KeyToPublicKey(response.Keys[0])
+		req.NoError(err)
+
+		req.Len(report.Fixes, 4)
+		req.Equal("kty", report.Fixes[0].Member)
+		req.Equal("crv", report.Fixes[1].Member)
+		req.Equal("x", report.Fixes[2].Member)
+		req.Equal(1, report.Fixes[3].KeyIndex)
+		req.Equal("k", report.Fixes[3].Member)
+	})
+
+	t.Run("never reports key material", func(t *testing.T) {
+		req := require.New(t)
+
+		_, report, err := (&Parser{Tolerant: true}).ParseWithReport([]byte(doc))
+		req.NoError(err)
+
+		for _, fix := range report.Fixes {
+			req.False(strings.Contains(fix.String(), "GawgguFy"))
+		}
+	})
+
+	t.Run("converts the standard base64 alphabet", func(t *testing.T) {
+		req := require.New(t)
+
+		key := &Key{KeyType: KeyTypeOct, K: "+/+/"}
+		fixes := normalizeKey(0, key)
+		req.Len(fixes, 1)
+		req.Equal("-_-_", key.K)
+	})
+
+	t.Run("leaves documents untouched by default", func(t *testing.T) {
+		req := require.New(t)
+
+		response, report, err := (&Parser{}).ParseWithReport([]byte(doc))
+		req.NoError(err)
+		req.Empty(report.Fixes)
+		req.Equal("ec", response.Keys[0].KeyType)
+		req.Equal("prime256v1", response.Keys[0].Curve)
+	})
+}
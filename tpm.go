@@ -0,0 +1,78 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package jwks
+
+import (
+	"crypto"
+	"fmt"
+)
+
+// TpmDevice provides crypto.Signer handles for TPM 2.0 resident keys, so this package does not depend on a TPM
+// library. Implementations typically load the key at a persistent handle with go-tpm-tools and return its GetSigner.
+// The private keys never leave the TPM.
+type TpmDevice interface {
+	// Signer returns a signer for the key at the given persistent handle, for example 0x81000001
+	Signer(handle uint32) (crypto.Signer, error)
+}
+
+// TpmKey converts the public part of the TPM key at handle to a Key, so device-bound signing keys can be published. If
+// keyId is empty string, the keyId will be populated with the RFC 7638 SHA-256 thumbprint of the key.
+func TpmKey(device TpmDevice, handle uint32, keyId string, opts ...KeyOption) (*Key, error) {
+	signer, err := device.Signer(handle)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not load TPM key 0x%08x: %s", handle, err)
+	}
+
+	return NewKeyFromSigner(keyId, signer, opts...)
+}
+
+// TpmSigner returns a *KeySigner for the TPM key at handle that signs with the hash required by the alg of the Key
+// returned by TpmKey for the same arguments when called with nil opts
+func TpmSigner(device TpmDevice, handle uint32, keyId string, opts ...KeyOption) (crypto.Signer, error) {
+	signer, err := device.Signer(handle)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not load TPM key 0x%08x: %s", handle, err)
+	}
+
+	key, err := NewKeyFromSigner(keyId, signer, opts...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newKeySigner(*key, signer)
+}
+
+// TpmResponse returns the public parts of the TPM keys at handles as a Response with thumbprint kids
+func TpmResponse(device TpmDevice, handles []uint32, opts ...KeyOption) (*Response, error) {
+	ret := &Response{
+		Keys: make([]Key, 0, len(handles)),
+	}
+
+	for _, handle := range handles {
+		key, err := TpmKey(device, handle, "", opts...)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ret.Keys = append(ret.Keys, *key)
+	}
+
+	return ret, nil
+}
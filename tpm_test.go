@@ -0,0 +1,85 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// testTpmDevice is a TpmDevice holding software keys by handle
+type testTpmDevice map[uint32]crypto.Signer
+
+func (d testTpmDevice) Signer(handle uint32) (crypto.Signer, error) {
+	signer, ok := d[handle]
+
+	if !ok {
+		return nil, fmt.Errorf("handle not found")
+	}
+
+	return signer, nil
+}
+
+func Test_Tpm(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	device := testTpmDevice{0x81000001: ecKey}
+
+	t.Run("can publish TPM keys", func(t *testing.T) {
+		req := require.New(t)
+
+		key, err := TpmKey(device, 0x81000001, "device")
+		req.NoError(err)
+		req.Equal("device", key.KeyId)
+		req.Equal(AlgorithmEs256, key.Algorithm)
+		req.True(key.IsPublic())
+
+		response, err := TpmResponse(device, []uint32{0x81000001})
+		req.NoError(err)
+		req.Len(response.Keys, 1)
+		req.NotEmpty(response.Keys[0].KeyId)
+	})
+
+	t.Run("can sign with TPM keys", func(t *testing.T) {
+		req := require.New(t)
+
+		signer, err := TpmSigner(device, 0x81000001, "device")
+		req.NoError(err)
+
+		digest := sha256.Sum256([]byte("payload"))
+		signature, err := signer.Sign(rand.Reader, digest[:], nil)
+		req.NoError(err)
+		req.True(ecdsa.VerifyASN1(&ecKey.PublicKey, digest[:], signature))
+	})
+
+	t.Run("returns an error for unknown handles", func(t *testing.T) {
+		req := require.New(t)
+
+		_, err := TpmKey(device, 0x81000002, "")
+		req.EqualError(err, "could not load TPM key 0x81000002: handle not found")
+
+		_, err = TpmSigner(device, 0x81000002, "")
+		req.Error(err)
+	})
+}
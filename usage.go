@@ -0,0 +1,130 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"github.com/pkg/errors"
+	"time"
+)
+
+const ErrorKeySelectorUnconfiguredMsg = "key selector has no key source"
+
+// KeyUsage is the operation a key was selected for
+type KeyUsage string
+
+const (
+	KeyUsageVerify KeyUsage = "verify"
+	KeyUsageSign   KeyUsage = "sign"
+)
+
+// KeyUsageEvent describes the selection of a key by a KeySelector
+type KeyUsageEvent struct {
+	Usage KeyUsage
+
+	// KeyId is the kid of the selected key
+	KeyId string
+
+	// Thumbprint is the RFC 7638 SHA-256 thumbprint of the selected key, empty if it could not be computed
+	Thumbprint string
+
+	Time time.Time
+}
+
+// KeyUsageHook is called with the context supplied by the caller of a KeySelector every time a key is selected. Hooks
+// are called synchronously and should return quickly, e.g. by recording the event for later audit. Callers can attach
+// request identifiers, peers, or other audit details to the context.
+type KeyUsageHook func(ctx context.Context, event KeyUsageEvent)
+
+// KeySelector selects keys by kid for verification and signing and reports every selection to Hook, so operators can
+// audit which keys actually validate traffic and retire unused keys safely. Verification keys are selected from
+// Source, signing keys from Store. A nil Hook disables reporting.
+type KeySelector struct {
+	Source ResponseSource
+	Store  KeyStore
+	Hook   KeyUsageHook
+}
+
+// VerificationKey returns the public key with the given kid from Source if it may be used for signing. Keys that may
+// not are treated as missing, as is a nil response. Missing keys are a *KeyNotFoundError.
+func (s *KeySelector) VerificationKey(ctx context.Context, kid string) (Key, error) {
+	if s.Source == nil {
+		return Key{}, errors.New(ErrorKeySelectorUnconfiguredMsg)
+	}
+
+	response := s.Source.Response()
+
+	if response == nil {
+		return Key{}, newKeyNotFoundError(kid)
+	}
+
+	key, ok := response.GetKey(kid)
+
+	if !ok || !key.IsForSigning() {
+		return Key{}, newKeyNotFoundError(kid)
+	}
+
+	key = key.Public()
+	s.report(ctx, KeyUsageVerify, key)
+
+	return key, nil
+}
+
+// Signer returns a signer for the private key with the given kid from Store, see KeyToSigner
+func (s *KeySelector) Signer(ctx context.Context, kid string) (crypto.Signer, error) {
+	if s.Store == nil {
+		return nil, errors.New(ErrorKeySelectorUnconfiguredMsg)
+	}
+
+	key, err := s.Store.Load(kid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !key.IsForSigning() {
+		return nil, fmt.Errorf("key %s may not be used for signing", kid)
+	}
+
+	signer, err := KeyToSigner(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.report(ctx, KeyUsageSign, key)
+
+	return signer, nil
+}
+
+// report calls Hook, if any, for the selection of key
+func (s *KeySelector) report(ctx context.Context, usage KeyUsage, key Key) {
+	if s.Hook == nil {
+		return
+	}
+
+	thumbprint, _ := key.Thumbprint(crypto.SHA256)
+
+	s.Hook(ctx, KeyUsageEvent{
+		Usage:      usage,
+		KeyId:      key.KeyId,
+		Thumbprint: thumbprint,
+		Time:       time.Now(),
+	})
+}
@@ -0,0 +1,128 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type testUsageContextKey struct{}
+
+func Test_KeySelector(t *testing.T) {
+	newSelector := func(req *require.Assertions) (*KeySelector, *Key, *[]KeyUsageEvent, *[]interface{}) {
+		private, public, err := GenerateECKey("P-256", "ec")
+		req.NoError(err)
+
+		encryption, _, err := GenerateOKPKey(CurveX25519, "x25519")
+		req.NoError(err)
+
+		store := NewMemoryKeyStore(*private, *encryption)
+
+		var events []KeyUsageEvent
+		var values []interface{}
+
+		return &KeySelector{
+			Source: store,
+			Store:  store,
+			Hook: func(ctx context.Context, event KeyUsageEvent) {
+				events = append(events, event)
+				values = append(values, ctx.Value(testUsageContextKey{}))
+			},
+		}, public, &events, &values
+	}
+
+	t.Run("can report the selection of a verification key", func(t *testing.T) {
+		req := require.New(t)
+
+		selector, public, events, values := newSelector(req)
+
+		ctx := context.WithValue(context.Background(), testUsageContextKey{}, "request-1")
+		key, err := selector.VerificationKey(ctx, "ec")
+		req.NoError(err)
+		req.Equal(public.X, key.X)
+		req.Empty(key.D)
+
+		thumbprint, err := public.Thumbprint(crypto.SHA256)
+		req.NoError(err)
+
+		req.Len(*events, 1)
+		req.Equal(KeyUsageVerify, (*events)[0].Usage)
+		req.Equal("ec", (*events)[0].KeyId)
+		req.Equal(thumbprint, (*events)[0].Thumbprint)
+		req.False((*events)[0].Time.IsZero())
+		req.Equal([]interface{}{"request-1"}, *values)
+	})
+
+	t.Run("can report the selection of a signer", func(t *testing.T) {
+		req := require.New(t)
+
+		selector, public, events, _ := newSelector(req)
+
+		signer, err := selector.Signer(context.Background(), "ec")
+		req.NoError(err)
+		req.NotNil(signer)
+
+		thumbprint, err := public.Thumbprint(crypto.SHA256)
+		req.NoError(err)
+
+		req.Len(*events, 1)
+		req.Equal(KeyUsageSign, (*events)[0].Usage)
+		req.Equal(thumbprint, (*events)[0].Thumbprint)
+	})
+
+	t.Run("can not select missing or encryption keys", func(t *testing.T) {
+		req := require.New(t)
+
+		selector, _, events, _ := newSelector(req)
+
+		_, err := selector.VerificationKey(context.Background(), "missing")
+		req.IsType(&KeyNotFoundError{}, err)
+
+		_, err = selector.VerificationKey(context.Background(), "x25519")
+		req.IsType(&KeyNotFoundError{}, err)
+
+		_, err = selector.Signer(context.Background(), "x25519")
+		req.Error(err)
+
+		req.Empty(*events)
+	})
+
+	t.Run("can select keys without a hook", func(t *testing.T) {
+		req := require.New(t)
+
+		selector, _, _, _ := newSelector(req)
+		selector.Hook = nil
+
+		_, err := selector.VerificationKey(context.Background(), "ec")
+		req.NoError(err)
+	})
+
+	t.Run("can not select keys without a source", func(t *testing.T) {
+		req := require.New(t)
+
+		selector := &KeySelector{}
+
+		_, err := selector.VerificationKey(context.Background(), "ec")
+		req.EqualError(err, ErrorKeySelectorUnconfiguredMsg)
+
+		_, err = selector.Signer(context.Background(), "ec")
+		req.EqualError(err, ErrorKeySelectorUnconfiguredMsg)
+	})
+}
@@ -0,0 +1,546 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto/ed25519"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/curve25519"
+	"math/big"
+	"strings"
+	"sync"
+)
+
+// KeyValidationError is returned by Key.Validate and lists every problem found with a key
+type KeyValidationError struct {
+	KeyId  string
+	Errors []error
+}
+
+func (e *KeyValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+
+	return fmt.Sprintf("key %s is invalid: %s", e.KeyId, strings.Join(msgs, "; "))
+}
+
+// ValidationReport is the result of validating every Key in a Response
+type ValidationReport struct {
+	Keys     []KeyReport `json:"keys"`
+	Valid    int         `json:"valid"`
+	Invalid  int         `json:"invalid"`
+	Warnings int         `json:"warnings"`
+}
+
+// KeyReport lists the errors and warnings found for the Key at Index in a Response. Errors make the key invalid,
+// warnings indicate risky but valid keys.
+type KeyReport struct {
+	Index    int
+	KeyId    string
+	Errors   []error
+	Warnings []error
+}
+
+// IsValid returns true if no key in the report has errors
+func (r *ValidationReport) IsValid() bool {
+	return r.Invalid == 0
+}
+
+// Err returns nil if the report is valid, otherwise an error summarizing every invalid key
+func (r *ValidationReport) Err() error {
+	if r.IsValid() {
+		return nil
+	}
+
+	var msgs []string
+
+	for _, keyReport := range r.Keys {
+		if len(keyReport.Errors) > 0 {
+			msgs = append(msgs, (&KeyValidationError{KeyId: keyReport.KeyId, Errors: keyReport.Errors}).Error())
+		}
+	}
+
+	return fmt.Errorf("%d of %d keys are invalid: %s", r.Invalid, len(r.Keys), strings.Join(msgs, ", "))
+}
+
+// MarshalJSON renders errors and warnings as their messages
+func (r KeyReport) MarshalJSON() ([]byte, error) {
+	toStrings := func(errs []error) []string {
+		ret := make([]string, 0, len(errs))
+		for _, err := range errs {
+			ret = append(ret, err.Error())
+		}
+		return ret
+	}
+
+	return json.Marshal(struct {
+		Index    int      `json:"index"`
+		KeyId    string   `json:"kid"`
+		Errors   []string `json:"errors"`
+		Warnings []string `json:"warnings"`
+	}{
+		Index:    r.Index,
+		KeyId:    r.KeyId,
+		Errors:   toStrings(r.Errors),
+		Warnings: toStrings(r.Warnings),
+	})
+}
+
+// ValidateOption configures how Response.Validate checks a set
+type ValidateOption func(*validateOptions)
+
+type validateOptions struct {
+	parallelism int
+}
+
+// WithParallelism checks up to parallelism keys concurrently, zero or one checks them sequentially. Checking keys, and
+// decoding and parsing their x5c chains in particular, dominates validation of large sets, so federation aggregators
+// handling thousands of certificates can cut cold start latency with e.g. runtime.GOMAXPROCS(0). The report is the
+// same either way.
+func WithParallelism(parallelism int) ValidateOption {
+	return func(o *validateOptions) {
+		o.parallelism = parallelism
+	}
+}
+
+// Validate runs ValidateWithPolicy against every Key in the Response and returns a report of per-key errors and
+// warnings. A nil policy uses DefaultPolicy.
+func (r *Response) Validate(policy *Policy, opts ...ValidateOption) *ValidationReport {
+	if policy == nil {
+		policy = DefaultPolicy()
+	}
+
+	options := &validateOptions{}
+
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	report := &ValidationReport{
+		Keys: make([]KeyReport, len(r.Keys)),
+	}
+
+	check := func(i int) {
+		errs, warnings := r.Keys[i].check(policy)
+
+		report.Keys[i] = KeyReport{
+			Index:    i,
+			KeyId:    r.Keys[i].KeyId,
+			Errors:   errs,
+			Warnings: warnings,
+		}
+	}
+
+	if options.parallelism > 1 && len(r.Keys) > 1 {
+		checkParallel(len(r.Keys), options.parallelism, check)
+	} else {
+		for i := range r.Keys {
+			check(i)
+		}
+	}
+
+	for _, keyReport := range report.Keys {
+		if len(keyReport.Errors) > 0 {
+			report.Invalid++
+		} else {
+			report.Valid++
+		}
+
+		report.Warnings += len(keyReport.Warnings)
+	}
+
+	return report
+}
+
+// checkParallel calls check for every index below count from at most parallelism goroutines and waits for all of them
+func checkParallel(count, parallelism int, check func(i int)) {
+	if parallelism > count {
+		parallelism = count
+	}
+
+	indexes := make(chan int)
+	wg := sync.WaitGroup{}
+
+	for worker := 0; worker < parallelism; worker++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range indexes {
+				check(i)
+			}
+		}()
+	}
+
+	for i := 0; i < count; i++ {
+		indexes <- i
+	}
+
+	close(indexes)
+	wg.Wait()
+}
+
+// Validate checks the key against DefaultPolicy, see ValidateWithPolicy
+func (k *Key) Validate() error {
+	return k.ValidateWithPolicy(DefaultPolicy())
+}
+
+// ValidateWithPolicy checks that the key's members are well-formed for its kty: required members are present and
+// base64url encoded, EC coordinates have the curve's byte length and lie on the named curve, EC private scalars are in
+// range and match the public point, RSA moduli meet the policy's minimum size, RSA exponents are in a sane range,
+// RSA private primes multiply to the modulus, use and key_ops are legal and consistent, and kty, alg, crv, and kid
+// are allowed by the policy. If the key has an x5c member its leaf certificate must match the key
+// and any x5t/x5t#S256 thumbprints (see VerifyX5cLeaf and VerifyX5cThumbprints). A *KeyValidationError is returned
+// if any check fails.
+func (k *Key) ValidateWithPolicy(policy *Policy) error {
+	if policy == nil {
+		policy = DefaultPolicy()
+	}
+
+	errs, _ := k.check(policy)
+
+	if len(errs) > 0 {
+		return &KeyValidationError{
+			KeyId:  k.KeyId,
+			Errors: errs,
+		}
+	}
+
+	return nil
+}
+
+// check runs every validation against the key and returns the errors, which make the key invalid, and warnings, which
+// indicate risky but valid keys
+func (k *Key) check(policy *Policy) ([]error, []error) {
+	errs := append(policy.check(k), k.validate(policy)...)
+
+	if len(errs) == 0 {
+		if err := k.VerifyX5cLeaf(); err != nil {
+			errs = append(errs, err)
+		}
+
+		if err := k.VerifyX5cThumbprints(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	var warnings []error
+
+	if usageErrs := k.validateUsage(); len(usageErrs) > 0 {
+		if policy.KeyOpsWarnOnly {
+			warnings = append(warnings, usageErrs...)
+		} else {
+			errs = append(errs, usageErrs...)
+		}
+	}
+
+	if k.KeyId == "" {
+		warnings = append(warnings, errors.New("key does not have a kid"))
+	}
+
+	if k.Algorithm == "" {
+		warnings = append(warnings, errors.New("key does not have an alg"))
+	}
+
+	if k.IsPrivate() || k.K != "" {
+		warnings = append(warnings, errors.New("key contains private or symmetric key material"))
+	}
+
+	if k.X509Url != "" {
+		warnings = append(warnings, errors.New("key x5u is not verified, see X5uResolver"))
+	}
+
+	return errs, warnings
+}
+
+func (k *Key) validate(policy *Policy) []error {
+	switch k.KeyType {
+	case KeyTypeRsa:
+		return k.validateRsa(policy)
+	case KeyTypeEc:
+		return k.validateEc()
+	case KeyTypeOkp:
+		return k.validateOkp()
+	case KeyTypeOct:
+		if _, err := decodeRequiredMember("k", k.K); err != nil {
+			return []error{err}
+		}
+		return nil
+	case "":
+		return []error{fmt.Errorf("key is missing member: kty")}
+	}
+
+	return []error{fmt.Errorf("unsupported key type: %s", k.KeyType)}
+}
+
+func (k *Key) validateRsa(policy *Policy) []error {
+	var errs []error
+
+	nBytes, err := decodeRequiredMember("n", k.N)
+
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	eBytes, err := decodeRequiredMember("e", k.E)
+
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	n := new(big.Int).SetBytes(nBytes)
+	e := new(big.Int).SetBytes(eBytes)
+
+	if policy.MinRsaBits > 0 && n.BitLen() < policy.MinRsaBits {
+		errs = append(errs, fmt.Errorf("RSA modulus must be at least %d bits, got %d", policy.MinRsaBits, n.BitLen()))
+	}
+
+	if e.BitLen() > 31 || e.Int64() < 3 || e.Bit(0) == 0 {
+		errs = append(errs, fmt.Errorf("RSA exponent must be an odd value between 3 and 2^31-1"))
+	}
+
+	if k.D != "" {
+		if _, err = decodePrivateMember("d", k.D); err != nil {
+			errs = append(errs, err)
+		}
+
+		if k.P != "" || k.Q != "" {
+			pBytes, pErr := decodePrivateMember("p", k.P)
+			qBytes, qErr := decodePrivateMember("q", k.Q)
+
+			if pErr != nil {
+				errs = append(errs, pErr)
+			}
+
+			if qErr != nil {
+				errs = append(errs, qErr)
+			}
+
+			if pErr == nil && qErr == nil {
+				product := new(big.Int).Mul(new(big.Int).SetBytes(pBytes), new(big.Int).SetBytes(qBytes))
+
+				if product.Cmp(n) != 0 {
+					errs = append(errs, fmt.Errorf("RSA private primes do not multiply to the modulus"))
+				}
+			}
+		}
+	}
+
+	return errs
+}
+
+func (k *Key) validateEc() []error {
+	curve := curveFromName(k.Curve)
+
+	if curve == nil {
+		return []error{fmt.Errorf("unsupported EC curve: %s", k.Curve)}
+	}
+
+	params := curve.Params()
+	byteLen := (params.BitSize + 7) / 8
+
+	var errs []error
+
+	xBytes, err := decodeRequiredMember("x", k.X)
+
+	if err != nil {
+		errs = append(errs, err)
+	} else if len(xBytes) != byteLen {
+		errs = append(errs, fmt.Errorf("EC x coordinate must be %d bytes for %s, got %d", byteLen, k.Curve, len(xBytes)))
+	}
+
+	yBytes, err := decodeRequiredMember("y", k.Y)
+
+	if err != nil {
+		errs = append(errs, err)
+	} else if len(yBytes) != byteLen {
+		errs = append(errs, fmt.Errorf("EC y coordinate must be %d bytes for %s, got %d", byteLen, k.Curve, len(yBytes)))
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	x := new(big.Int).SetBytes(xBytes)
+	y := new(big.Int).SetBytes(yBytes)
+
+	if !curve.IsOnCurve(x, y) {
+		return []error{fmt.Errorf("EC point is not on curve %s", k.Curve)}
+	}
+
+	if k.D != "" {
+		dBytes, err := decodePrivateMember("d", k.D)
+
+		if err != nil {
+			return []error{err}
+		}
+
+		if len(dBytes) != byteLen {
+			errs = append(errs, fmt.Errorf("EC private key must be %d bytes for %s, got %d", byteLen, k.Curve, len(dBytes)))
+		}
+
+		d := new(big.Int).SetBytes(dBytes)
+
+		if d.Sign() <= 0 || d.Cmp(params.N) >= 0 {
+			return append(errs, fmt.Errorf("EC private key is out of range for curve %s", k.Curve))
+		}
+
+		pubX, pubY := curve.ScalarBaseMult(d.FillBytes(make([]byte, byteLen)))
+
+		if pubX.Cmp(x) != 0 || pubY.Cmp(y) != 0 {
+			errs = append(errs, fmt.Errorf("EC private key does not match public point"))
+		}
+	}
+
+	return errs
+}
+
+func (k *Key) validateOkp() []error {
+	if k.Curve == CurveX25519 {
+		return k.validateX25519()
+	}
+
+	if k.Curve != CurveEd25519 {
+		return []error{fmt.Errorf("unsupported OKP curve: %s", k.Curve)}
+	}
+
+	var errs []error
+
+	xBytes, err := decodeRequiredMember("x", k.X)
+
+	if err != nil {
+		errs = append(errs, err)
+	} else if len(xBytes) != ed25519.PublicKeySize {
+		errs = append(errs, fmt.Errorf("Ed25519 public key must be %d bytes, got %d", ed25519.PublicKeySize, len(xBytes)))
+	}
+
+	if k.D != "" {
+		dBytes, err := decodePrivateMember("d", k.D)
+
+		if err != nil {
+			errs = append(errs, err)
+		} else if len(dBytes) != ed25519.SeedSize {
+			errs = append(errs, fmt.Errorf("Ed25519 private key must be %d bytes, got %d", ed25519.SeedSize, len(dBytes)))
+		} else if len(errs) == 0 && !ed25519.NewKeyFromSeed(dBytes).Public().(ed25519.PublicKey).Equal(ed25519.PublicKey(xBytes)) {
+			errs = append(errs, fmt.Errorf("Ed25519 private key does not match public key"))
+		}
+	}
+
+	return errs
+}
+
+func (k *Key) validateX25519() []error {
+	var errs []error
+
+	xBytes, err := decodeRequiredMember("x", k.X)
+
+	if err != nil {
+		errs = append(errs, err)
+	} else if len(xBytes) != curve25519.PointSize {
+		errs = append(errs, fmt.Errorf("X25519 public key must be %d bytes, got %d", curve25519.PointSize, len(xBytes)))
+	}
+
+	if k.D != "" {
+		dBytes, err := decodePrivateMember("d", k.D)
+
+		if err != nil {
+			errs = append(errs, err)
+		} else if len(dBytes) != curve25519.ScalarSize {
+			errs = append(errs, fmt.Errorf("X25519 private key must be %d bytes, got %d", curve25519.ScalarSize, len(dBytes)))
+		} else if len(errs) == 0 {
+			public, err := curve25519.X25519(dBytes, curve25519.Basepoint)
+
+			if err != nil || subtle.ConstantTimeCompare(public, xBytes) != 1 {
+				errs = append(errs, fmt.Errorf("X25519 private key does not match public key"))
+			}
+		}
+	}
+
+	return errs
+}
+
+// keyOperationUses maps each registered key_ops value to the use it is consistent with
+var keyOperationUses = map[string]string{
+	KeyOperationSign:       UseSignature,
+	KeyOperationVerify:     UseSignature,
+	KeyOperationEncrypt:    UseEncryption,
+	KeyOperationDecrypt:    UseEncryption,
+	KeyOperationWrapKey:    UseEncryption,
+	KeyOperationUnwrapKey:  UseEncryption,
+	KeyOperationDeriveKey:  UseEncryption,
+	KeyOperationDeriveBits: UseEncryption,
+}
+
+// validateUsage checks that use and key_ops only contain registered values, that key_ops has no duplicates, and that
+// every key_ops value is consistent with use
+func (k *Key) validateUsage() []error {
+	var errs []error
+
+	knownUse := k.Use == UseSignature || k.Use == UseEncryption
+
+	if k.Use != "" && !knownUse {
+		errs = append(errs, fmt.Errorf("unknown use: %s", k.Use))
+	}
+
+	seen := map[string]bool{}
+
+	for _, op := range k.KeyOperations {
+		if seen[op] {
+			errs = append(errs, fmt.Errorf("duplicate key_ops value: %s", op))
+			continue
+		}
+
+		seen[op] = true
+
+		opUse, ok := keyOperationUses[op]
+
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown key_ops value: %s", op))
+		} else if knownUse && opUse != k.Use {
+			errs = append(errs, fmt.Errorf("key_ops value %s is inconsistent with use %s", op, k.Use))
+		}
+	}
+
+	return errs
+}
+
+// decodeRequiredMember base64url decodes a public key member that must be present
+func decodeRequiredMember(name, value string) ([]byte, error) {
+	if value == "" {
+		return nil, fmt.Errorf("key is missing member: %s", name)
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(value)
+
+	if err != nil {
+		return nil, fmt.Errorf("error base64 decoding key's %s: %s", name, err)
+	}
+
+	return decoded, nil
+}
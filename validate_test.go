@@ -0,0 +1,419 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/Jeffail/gabs/v2"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func Test_KeyValidate(t *testing.T) {
+	t.Run("accepts the rfc7517 example keys", func(t *testing.T) {
+		req := require.New(t)
+		response := &Response{}
+
+		err := json.Unmarshal([]byte(testJwksRfc7517Examples), response)
+		req.NoError(err)
+
+		for i := range response.Keys {
+			req.NoError(response.Keys[i].Validate())
+		}
+	})
+
+	t.Run("accepts generated EC and Ed25519 private keys", func(t *testing.T) {
+		req := require.New(t)
+
+		_, ecPrivKey, err := newEcCert()
+		req.NoError(err)
+
+		_, edPrivKey, err := newEd25519Cert()
+		req.NoError(err)
+
+		ecKey, err := NewKeyFromPrivateKey("", ecPrivKey)
+		req.NoError(err)
+		req.NoError(ecKey.Validate())
+
+		edKey, err := NewKeyFromPrivateKey("", edPrivKey)
+		req.NoError(err)
+		req.NoError(edKey.Validate())
+	})
+
+	t.Run("rejects EC points that are not on the curve", func(t *testing.T) {
+		req := require.New(t)
+
+		key := &Key{
+			KeyType: KeyTypeEc,
+			Curve:   "P-256",
+			X:       "MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4",
+			Y:       "MKBCTNIcKUSDii11ySs3526iDZ8AiTo7Tu6KPAqv7D4",
+		}
+
+		err := key.Validate()
+		req.Error(err)
+
+		validationErr, ok := err.(*KeyValidationError)
+		req.True(ok)
+		req.Len(validationErr.Errors, 1)
+
+		_, err = KeyToPublicKey(*key)
+		req.Error(err)
+	})
+
+	t.Run("rejects EC coordinates with the wrong length", func(t *testing.T) {
+		req := require.New(t)
+
+		key := &Key{
+			KeyType: KeyTypeEc,
+			Curve:   "P-256",
+			X:       base64.RawURLEncoding.EncodeToString(make([]byte, 31)),
+			Y:       "4Etl6SRW2YiLUrN5vfvVHuhp7x8PxltmWWlbbM4IFyM",
+		}
+
+		req.Error(key.Validate())
+	})
+
+	t.Run("rejects EC private scalars out of range", func(t *testing.T) {
+		req := require.New(t)
+
+		_, ecPrivKey, err := newEcCert()
+		req.NoError(err)
+
+		key, err := NewKeyFromPrivateKey("", ecPrivKey)
+		req.NoError(err)
+
+		key.D = base64.RawURLEncoding.EncodeToString(ecPrivKey.Curve.Params().N.Bytes())
+		req.Error(key.Validate())
+
+		key.D = base64.RawURLEncoding.EncodeToString(make([]byte, 32))
+		req.Error(key.Validate())
+	})
+
+	t.Run("rejects EC private keys that do not match the public point", func(t *testing.T) {
+		req := require.New(t)
+
+		_, ecPrivKey, err := newEcCert()
+		req.NoError(err)
+
+		_, otherPrivKey, err := newEcCert()
+		req.NoError(err)
+
+		key, err := NewKeyFromPrivateKey("", ecPrivKey)
+		req.NoError(err)
+
+		other, err := NewKeyFromPrivateKey("", otherPrivKey)
+		req.NoError(err)
+
+		key.D = other.D
+		req.Error(key.Validate())
+	})
+
+	t.Run("rejects unknown curves and missing members", func(t *testing.T) {
+		req := require.New(t)
+
+		req.Error((&Key{KeyType: KeyTypeEc, Curve: "secp256k1", X: "AA", Y: "AA"}).Validate())
+		req.Error((&Key{KeyType: KeyTypeRsa, N: "AQAB"}).Validate())
+		req.Error((&Key{KeyType: KeyTypeOkp, Curve: CurveEd25519, X: "AAAA"}).Validate())
+		req.Error((&Key{}).Validate())
+	})
+}
+
+func Test_KeyValidateRsa(t *testing.T) {
+	t.Run("accepts generated RSA private keys", func(t *testing.T) {
+		req := require.New(t)
+
+		_, rsaPrivKey, err := newRsaCert()
+		req.NoError(err)
+
+		key, err := NewKeyFromPrivateKey("", rsaPrivKey)
+		req.NoError(err)
+		req.NoError(key.Validate())
+	})
+
+	t.Run("rejects moduli below the policy minimum", func(t *testing.T) {
+		req := require.New(t)
+
+		_, rsaPrivKey, err := newRsaCert()
+		req.NoError(err)
+
+		key, err := NewKeyFromPublicKey("", rsaPrivKey.Public())
+		req.NoError(err)
+
+		req.Error(key.ValidateWithPolicy(&Policy{MinRsaBits: 3072}))
+		req.NoError(key.ValidateWithPolicy(&Policy{MinRsaBits: 2048}))
+		req.NoError(key.ValidateWithPolicy(&Policy{}))
+	})
+
+	t.Run("rejects huge, even, and tiny exponents", func(t *testing.T) {
+		req := require.New(t)
+
+		_, rsaPrivKey, err := newRsaCert()
+		req.NoError(err)
+
+		key, err := NewKeyFromPublicKey("", rsaPrivKey.Public())
+		req.NoError(err)
+
+		for _, e := range [][]byte{{0x01, 0x00, 0x00, 0x00, 0x01}, {0x01, 0x00, 0x00}, {0x01}} {
+			key.E = base64.RawURLEncoding.EncodeToString(e)
+			req.Error(key.Validate())
+		}
+
+		key.E = base64.RawURLEncoding.EncodeToString([]byte{0x01, 0x00, 0x00, 0x00, 0x01})
+		_, err = KeyToPublicKey(*key)
+		req.Error(err, "expected huge exponents to not be truncated")
+	})
+
+	t.Run("rejects primes that do not multiply to the modulus", func(t *testing.T) {
+		req := require.New(t)
+
+		_, rsaPrivKey, err := newRsaCert()
+		req.NoError(err)
+
+		_, otherPrivKey, err := newRsaCert()
+		req.NoError(err)
+
+		key, err := NewKeyFromPrivateKey("", rsaPrivKey)
+		req.NoError(err)
+
+		other, err := NewKeyFromPrivateKey("", otherPrivKey)
+		req.NoError(err)
+
+		key.P = other.P
+		req.Error(key.Validate())
+	})
+}
+
+func Test_ResponseValidate(t *testing.T) {
+	t.Run("reports the auth0 example as valid", func(t *testing.T) {
+		req := require.New(t)
+		response := &Response{}
+
+		err := json.Unmarshal([]byte(testPublicJwksAuth0), response)
+		req.NoError(err)
+
+		report := response.Validate(nil)
+		req.True(report.IsValid())
+		req.NoError(report.Err())
+		req.Equal(2, report.Valid)
+		req.Equal(0, report.Invalid)
+		req.Equal(0, report.Warnings)
+		req.Len(report.Keys, 2)
+	})
+
+	t.Run("reports errors and warnings per key", func(t *testing.T) {
+		req := require.New(t)
+		response := &Response{}
+
+		err := json.Unmarshal([]byte(testJwksRfc7517Examples), response)
+		req.NoError(err)
+
+		response.Keys[0].Y = response.Keys[0].X
+
+		report := response.Validate(nil)
+		req.False(report.IsValid())
+		req.Error(report.Err())
+		req.Equal(2, report.Valid)
+		req.Equal(1, report.Invalid)
+
+		req.Equal("1", report.Keys[0].KeyId)
+		req.NotEmpty(report.Keys[0].Errors)
+		req.Empty(report.Keys[1].Errors)
+
+		// the first key has no alg and the third has no alg and private material
+		req.Len(report.Keys[0].Warnings, 1)
+		req.Len(report.Keys[1].Warnings, 0)
+		req.Len(report.Keys[2].Warnings, 2)
+		req.Equal(3, report.Warnings)
+
+		t.Run("which marshals to JSON", func(t *testing.T) {
+			req := require.New(t)
+
+			reportJson, err := json.Marshal(report)
+			req.NoError(err)
+
+			parsed, err := gabs.ParseJSON(reportJson)
+			req.NoError(err)
+			req.Equal(float64(1), parsed.Path("invalid").Data())
+			req.Equal("1", parsed.Path("keys.0.kid").Data())
+			req.IsType("", parsed.Path("keys.0.errors.0").Data())
+		})
+	})
+
+	t.Run("applies the policy", func(t *testing.T) {
+		req := require.New(t)
+		response := &Response{}
+
+		err := json.Unmarshal([]byte(testPublicJwksAuth0), response)
+		req.NoError(err)
+
+		report := response.Validate(&Policy{MinRsaBits: 4096})
+		req.Equal(2, report.Invalid)
+	})
+
+	t.Run("can check keys in parallel", func(t *testing.T) {
+		req := require.New(t)
+
+		response, err := newTestChainResponse(20)
+		req.NoError(err)
+
+		response.Keys[3].X = response.Keys[4].X
+		response.Keys[11].X509Chain = response.Keys[12].X509Chain
+
+		sequential := response.Validate(nil)
+		req.Equal(2, sequential.Invalid)
+
+		expected, err := json.Marshal(sequential)
+		req.NoError(err)
+
+		for _, parallelism := range []int{2, 8, 100} {
+			parallel := response.Validate(nil, WithParallelism(parallelism))
+
+			actual, err := json.Marshal(parallel)
+			req.NoError(err)
+			req.JSONEq(string(expected), string(actual))
+		}
+	})
+}
+
+func BenchmarkResponseValidate(b *testing.B) {
+	response, err := newTestChainResponse(64)
+
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	policy := DefaultPolicy()
+
+	for _, parallelism := range []int{1, 4} {
+		parallelism := parallelism
+
+		b.Run(fmt.Sprintf("parallelism %d", parallelism), func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				if report := response.Validate(policy, WithParallelism(parallelism)); !report.IsValid() {
+					b.Fatal(report.Err())
+				}
+			}
+		})
+	}
+}
+
+// newTestChainResponse returns a response of count EC keys, each with a leaf, intermediate, and root x5c chain
+func newTestChainResponse(count int) (*Response, error) {
+	root, rootKey, err := newTestCa("TEST Root", nil, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	intermediate, intermediateKey, err := newTestCa("TEST Intermediate", root, rootKey)
+
+	if err != nil {
+		return nil, err
+	}
+
+	response := &Response{}
+
+	for i := 0; i < count; i++ {
+		leaf, _, err := newTestLeaf(intermediate, intermediateKey)
+
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := NewKey(fmt.Sprintf("key-%d", i), leaf, []*x509.Certificate{leaf, intermediate, root}, WithAlgorithm(AlgorithmEs256))
+
+		if err != nil {
+			return nil, err
+		}
+
+		response.Keys = append(response.Keys, *key)
+	}
+
+	return response, nil
+}
+
+func Test_KeyValidateUsage(t *testing.T) {
+	newKey := func(t *testing.T) *Key {
+		_, ecPrivKey, err := newEcCert()
+		require.NoError(t, err)
+
+		key, err := NewKeyFromPublicKey("", ecPrivKey.Public())
+		require.NoError(t, err)
+
+		return key
+	}
+
+	t.Run("accepts consistent use and key_ops", func(t *testing.T) {
+		req := require.New(t)
+
+		key := newKey(t)
+		req.NoError(key.Validate())
+
+		key.Use = UseEncryption
+		key.KeyOperations = []string{KeyOperationWrapKey, KeyOperationUnwrapKey}
+		req.NoError(key.Validate())
+
+		key.Use = ""
+		req.NoError(key.Validate())
+	})
+
+	t.Run("rejects key_ops inconsistent with use", func(t *testing.T) {
+		req := require.New(t)
+
+		key := newKey(t)
+		key.KeyOperations = []string{KeyOperationVerify, KeyOperationEncrypt}
+
+		err := key.Validate()
+		req.Error(err)
+		req.Len(err.(*KeyValidationError).Errors, 1)
+	})
+
+	t.Run("rejects unknown and duplicate values", func(t *testing.T) {
+		req := require.New(t)
+
+		key := newKey(t)
+		key.KeyOperations = []string{KeyOperationVerify, KeyOperationVerify, "teleport"}
+		key.Use = "everything"
+
+		err := key.Validate()
+		req.Error(err)
+		req.Len(err.(*KeyValidationError).Errors, 3)
+	})
+
+	t.Run("can report problems as warnings", func(t *testing.T) {
+		req := require.New(t)
+
+		key := newKey(t)
+		key.KeyOperations = []string{KeyOperationEncrypt}
+
+		policy := DefaultPolicy()
+		policy.KeyOpsWarnOnly = true
+
+		req.NoError(key.ValidateWithPolicy(policy))
+
+		response := &Response{Keys: []Key{*key}}
+		report := response.Validate(policy)
+		req.True(report.IsValid())
+		req.Equal(1, report.Warnings)
+	})
+}
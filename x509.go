@@ -0,0 +1,381 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"github.com/pkg/errors"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	ErrorX5cLeafMismatchMsg   = "x5c leaf certificate public key does not match the key parameters"
+	ErrorX5tMismatchMsg       = "x5t does not match the SHA-1 thumbprint of the x5c leaf certificate"
+	ErrorX5tSha256MismatchMsg = "x5t#S256 does not match the SHA-256 thumbprint of the x5c leaf certificate"
+)
+
+// X5cMismatchError is returned when the certificates in a key's x5c member do not agree with the key's own members
+type X5cMismatchError struct {
+	error
+	KeyId string
+}
+
+// ParseX509Chain decodes and parses the certificates in the key's x5c member in order, leaf first. An empty x5c
+// results in a nil slice.
+func (k *Key) ParseX509Chain() ([]*x509.Certificate, error) {
+	if len(k.X509Chain) == 0 {
+		return nil, nil
+	}
+
+	certs := make([]*x509.Certificate, 0, len(k.X509Chain))
+
+	for i, encodedCert := range k.X509Chain {
+		der, err := base64.StdEncoding.DecodeString(encodedCert)
+
+		if err != nil {
+			return nil, fmt.Errorf("error base64 decoding key's x5c[%d]: %s", i, err)
+		}
+
+		cert, err := x509.ParseCertificate(der)
+
+		if err != nil {
+			return nil, fmt.Errorf("error parsing key's x5c[%d]: %s", i, err)
+		}
+
+		certs = append(certs, cert)
+	}
+
+	return certs, nil
+}
+
+// VerifyX5cLeaf decodes the first x5c certificate and verifies that its public key matches the key's n/e, x/y, or x
+// members. Keys without an x5c member pass. An *X5cMismatchError is returned on mismatch.
+func (k *Key) VerifyX5cLeaf() error {
+	if len(k.X509Chain) == 0 {
+		return nil
+	}
+
+	certs, err := k.ParseX509Chain()
+
+	if err != nil {
+		return err
+	}
+
+	pubKey, err := KeyToPublicKey(*k)
+
+	if err != nil {
+		return err
+	}
+
+	if !certMatchesPublicKey(certs[0], pubKey) {
+		return &X5cMismatchError{
+			error: errors.New(ErrorX5cLeafMismatchMsg),
+			KeyId: k.KeyId,
+		}
+	}
+
+	return nil
+}
+
+// certMatchesPublicKey returns true if the public key of cert equals pubKey
+func certMatchesPublicKey(cert *x509.Certificate, pubKey crypto.PublicKey) bool {
+	certPubKey, ok := cert.PublicKey.(interface {
+		Equal(x crypto.PublicKey) bool
+	})
+
+	return ok && certPubKey.Equal(pubKey)
+}
+
+// SetChain sets the key's x5c member from certs, which may be in any order, and recomputes x5t and x5t#S256. The
+// certificate whose public key matches the key becomes the leaf and the remaining certificates are ordered so that
+// each is the issuer of the one before it. An *X5cMismatchError is returned if no certificate matches the key, and an
+// error if the certificates do not form a single chain. The key is not modified on error.
+func (k *Key) SetChain(certs []*x509.Certificate) error {
+	if len(certs) == 0 {
+		return errors.New("certificate chain is empty")
+	}
+
+	pubKey, err := KeyToPublicKey(*k)
+
+	if err != nil {
+		return err
+	}
+
+	remaining := append([]*x509.Certificate{}, certs...)
+
+	take := func(matches func(cert *x509.Certificate) bool) *x509.Certificate {
+		for i, cert := range remaining {
+			if matches(cert) {
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				return cert
+			}
+		}
+
+		return nil
+	}
+
+	leaf := take(func(cert *x509.Certificate) bool {
+		return certMatchesPublicKey(cert, pubKey)
+	})
+
+	if leaf == nil {
+		return &X5cMismatchError{
+			error: errors.New(ErrorX5cLeafMismatchMsg),
+			KeyId: k.KeyId,
+		}
+	}
+
+	chain := []*x509.Certificate{leaf}
+
+	for len(remaining) > 0 {
+		child := chain[len(chain)-1]
+
+		issuer := take(func(cert *x509.Certificate) bool {
+			return bytes.Equal(cert.RawSubject, child.RawIssuer) && child.CheckSignatureFrom(cert) == nil
+		})
+
+		if issuer == nil {
+			return fmt.Errorf("certificate %q is not part of the chain of key %s", remaining[0].Subject.String(), k.KeyId)
+		}
+
+		chain = append(chain, issuer)
+	}
+
+	x5c := make([]string, 0, len(chain))
+
+	for _, cert := range chain {
+		x5c = append(x5c, base64.StdEncoding.EncodeToString(cert.Raw))
+	}
+
+	k.X509Chain = x5c
+	k.X509Thumbprint, k.X509ThumbprintSha256 = x509Thumbprints(leaf)
+
+	return nil
+}
+
+// VerifyX5cThumbprints recomputes the SHA-1 and SHA-256 thumbprints of the x5c leaf certificate and compares them with
+// the key's x5t and x5t#S256 members when present. The members may be base64url encoded, as RFC 7517 requires, or hex
+// encoded, as produced by NewKey. Keys without an x5c member pass. An *X5cMismatchError is returned on mismatch.
+func (k *Key) VerifyX5cThumbprints() error {
+	if len(k.X509Chain) == 0 || (k.X509Thumbprint == "" && k.X509ThumbprintSha256 == "") {
+		return nil
+	}
+
+	certs, err := k.ParseX509Chain()
+
+	if err != nil {
+		return err
+	}
+
+	sha1Sum := sha1.Sum(certs[0].Raw)
+	sha256Sum := sha256.Sum256(certs[0].Raw)
+
+	if k.X509Thumbprint != "" && !thumbprintMatches(k.X509Thumbprint, sha1Sum[:]) {
+		return &X5cMismatchError{
+			error: errors.New(ErrorX5tMismatchMsg),
+			KeyId: k.KeyId,
+		}
+	}
+
+	if k.X509ThumbprintSha256 != "" && !thumbprintMatches(k.X509ThumbprintSha256, sha256Sum[:]) {
+		return &X5cMismatchError{
+			error: errors.New(ErrorX5tSha256MismatchMsg),
+			KeyId: k.KeyId,
+		}
+	}
+
+	return nil
+}
+
+// thumbprintMatches returns true if thumbprint is the base64url or hex encoding of sum
+func thumbprintMatches(thumbprint string, sum []byte) bool {
+	encoded := base64.RawURLEncoding.EncodeToString(sum)
+
+	if len(thumbprint) == hex.EncodedLen(len(sum)) {
+		encoded = hex.EncodeToString(sum)
+		thumbprint = strings.ToLower(thumbprint)
+	}
+
+	return subtle.ConstantTimeCompare([]byte(thumbprint), []byte(encoded)) == 1
+}
+
+// PopulateX5cThumbprints sets the x5t and x5t#S256 members from the x5c leaf certificate if they are empty. Existing
+// values are left untouched, use VerifyX5cThumbprints to check them.
+func (k *Key) PopulateX5cThumbprints() error {
+	certs, err := k.ParseX509Chain()
+
+	if err != nil {
+		return err
+	}
+
+	if len(certs) == 0 {
+		return errors.New("key does not contain an x5c certificate chain")
+	}
+
+	x5t, x5tS256 := x509Thumbprints(certs[0])
+
+	if k.X509Thumbprint == "" {
+		k.X509Thumbprint = x5t
+	}
+
+	if k.X509ThumbprintSha256 == "" {
+		k.X509ThumbprintSha256 = x5tS256
+	}
+
+	return nil
+}
+
+// x509Thumbprints returns the base64url encoded SHA-1 and SHA-256 thumbprints of the certificate's DER bytes
+func x509Thumbprints(cert *x509.Certificate) (string, string) {
+	sha1Sum := sha1.Sum(cert.Raw)
+	sha256Sum := sha256.Sum256(cert.Raw)
+
+	return base64.RawURLEncoding.EncodeToString(sha1Sum[:]), base64.RawURLEncoding.EncodeToString(sha256Sum[:])
+}
+
+// VerifyChain verifies that the key's x5c leaf certificate matches the key and chains to roots. Certificates after
+// the leaf in x5c are used as intermediates in addition to intermediates, which may be nil. The Roots and
+// Intermediates of opts are replaced, all other options are honored. The verified chains are returned.
+func (k *Key) VerifyChain(roots, intermediates *x509.CertPool, opts x509.VerifyOptions) ([][]*x509.Certificate, error) {
+	certs, err := k.ParseX509Chain()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("key does not contain an x5c certificate chain")
+	}
+
+	if err = k.VerifyX5cLeaf(); err != nil {
+		return nil, err
+	}
+
+	if intermediates != nil {
+		intermediates = intermediates.Clone()
+	} else {
+		intermediates = x509.NewCertPool()
+	}
+
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	opts.Roots = roots
+	opts.Intermediates = intermediates
+
+	chains, err := certs[0].Verify(opts)
+
+	if err != nil {
+		return nil, fmt.Errorf("error verifying key %s certificate chain: %w", k.KeyId, err)
+	}
+
+	return chains, nil
+}
+
+// x509SignatureAlgorithms maps JWS algorithms to the equivalent certificate signature algorithms
+var x509SignatureAlgorithms = map[string]x509.SignatureAlgorithm{
+	AlgorithmRs256: x509.SHA256WithRSA,
+	AlgorithmRs384: x509.SHA384WithRSA,
+	AlgorithmRs512: x509.SHA512WithRSA,
+	AlgorithmPs256: x509.SHA256WithRSAPSS,
+	AlgorithmPs384: x509.SHA384WithRSAPSS,
+	AlgorithmPs512: x509.SHA512WithRSAPSS,
+	AlgorithmEs256: x509.ECDSAWithSHA256,
+	AlgorithmEs384: x509.ECDSAWithSHA384,
+	AlgorithmEs512: x509.ECDSAWithSHA512,
+	AlgorithmEdDsa: x509.PureEd25519,
+}
+
+// SelfSignCertificate issues a self-signed certificate for a private RSA, EC, or Ed25519 key and sets the key's x5c,
+// x5t, and x5t#S256 members from it, replacing any existing values. template configures the subject, validity, SANs
+// and other fields as for x509.CreateCertificate and may be nil. Unset fields default to a random serial number, a
+// validity of one year starting now, digital signature key usage, and the signature algorithm matching the key's alg.
+// The template is not modified.
+func (k *Key) SelfSignCertificate(template *x509.Certificate) (*x509.Certificate, error) {
+	privateKey, err := KeyToPrivateKey(*k)
+
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := privateKey.(crypto.Signer)
+
+	if !ok {
+		return nil, fmt.Errorf("key %s can not sign certificates", k.KeyId)
+	}
+
+	certTemplate := x509.Certificate{}
+
+	if template != nil {
+		certTemplate = *template
+	}
+
+	if certTemplate.SerialNumber == nil {
+		serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+
+		if err != nil {
+			return nil, err
+		}
+
+		certTemplate.SerialNumber = serial
+	}
+
+	if certTemplate.NotBefore.IsZero() {
+		certTemplate.NotBefore = time.Now()
+	}
+
+	if certTemplate.NotAfter.IsZero() {
+		certTemplate.NotAfter = certTemplate.NotBefore.AddDate(1, 0, 0)
+	}
+
+	if certTemplate.KeyUsage == 0 {
+		certTemplate.KeyUsage = x509.KeyUsageDigitalSignature
+	}
+
+	if certTemplate.SignatureAlgorithm == x509.UnknownSignatureAlgorithm {
+		certTemplate.SignatureAlgorithm = x509SignatureAlgorithms[k.Algorithm]
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &certTemplate, &certTemplate, signer.Public(), signer)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create certificate for key %s: %s", k.KeyId, err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+
+	if err != nil {
+		return nil, err
+	}
+
+	k.X509Chain = []string{base64.StdEncoding.EncodeToString(der)}
+	k.X509Thumbprint, k.X509ThumbprintSha256 = x509Thumbprints(cert)
+
+	return cert, nil
+}
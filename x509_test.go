@@ -0,0 +1,518 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_ParseX509Chain(t *testing.T) {
+	t.Run("parses the auth0 example chains", func(t *testing.T) {
+		req := require.New(t)
+		response := &Response{}
+
+		err := json.Unmarshal([]byte(testPublicJwksAuth0), response)
+		req.NoError(err)
+
+		for i := range response.Keys {
+			certs, err := response.Keys[i].ParseX509Chain()
+			req.NoError(err)
+			req.Len(certs, 1)
+		}
+	})
+
+	t.Run("errors on invalid certificates", func(t *testing.T) {
+		req := require.New(t)
+
+		key := &Key{X509Chain: []string{"not base64!"}}
+		_, err := key.ParseX509Chain()
+		req.Error(err)
+
+		key = &Key{X509Chain: []string{"AAAA"}}
+		_, err = key.ParseX509Chain()
+		req.Error(err)
+	})
+}
+
+func Test_VerifyX5cLeaf(t *testing.T) {
+	t.Run("accepts the auth0 example keys", func(t *testing.T) {
+		req := require.New(t)
+		response := &Response{}
+
+		err := json.Unmarshal([]byte(testPublicJwksAuth0), response)
+		req.NoError(err)
+
+		for i := range response.Keys {
+			req.NoError(response.Keys[i].VerifyX5cLeaf())
+			req.NoError(response.Keys[i].Validate())
+		}
+	})
+
+	t.Run("accepts keys without x5c", func(t *testing.T) {
+		req := require.New(t)
+
+		ecCert, _, err := newEcCert()
+		req.NoError(err)
+
+		key, err := NewKey("", ecCert, nil)
+		req.NoError(err)
+		req.NoError(key.VerifyX5cLeaf())
+	})
+
+	t.Run("rejects a leaf for a different key", func(t *testing.T) {
+		req := require.New(t)
+
+		ecCert, _, err := newEcCert()
+		req.NoError(err)
+
+		otherCert, _, err := newEcCert()
+		req.NoError(err)
+
+		key, err := NewKey("", ecCert, []*x509.Certificate{otherCert})
+		req.NoError(err)
+
+		err = key.VerifyX5cLeaf()
+		req.Error(err)
+
+		mismatchErr, ok := err.(*X5cMismatchError)
+		req.True(ok)
+		req.Equal(key.KeyId, mismatchErr.KeyId)
+
+		req.Error(key.Validate())
+	})
+
+	t.Run("rejects a leaf of a different key type", func(t *testing.T) {
+		req := require.New(t)
+
+		ecCert, _, err := newEcCert()
+		req.NoError(err)
+
+		rsaCert, _, err := newRsaCert()
+		req.NoError(err)
+
+		key, err := NewKey("", ecCert, []*x509.Certificate{rsaCert})
+		req.NoError(err)
+
+		_, ok := key.VerifyX5cLeaf().(*X5cMismatchError)
+		req.True(ok)
+	})
+}
+
+func Test_VerifyX5cThumbprints(t *testing.T) {
+	t.Run("accepts the auth0 example x5t values", func(t *testing.T) {
+		req := require.New(t)
+		response := &Response{}
+
+		err := json.Unmarshal([]byte(testPublicJwksAuth0), response)
+		req.NoError(err)
+
+		for i := range response.Keys {
+			req.NotEmpty(response.Keys[i].X509Thumbprint)
+			req.NoError(response.Keys[i].VerifyX5cThumbprints())
+		}
+	})
+
+	t.Run("accepts thumbprints produced by NewKey", func(t *testing.T) {
+		req := require.New(t)
+
+		rsaCert, _, err := newRsaCert()
+		req.NoError(err)
+
+		key, err := NewKey("", rsaCert, []*x509.Certificate{rsaCert})
+		req.NoError(err)
+		req.NoError(key.VerifyX5cThumbprints())
+	})
+
+	t.Run("accepts base64url and legacy hex thumbprints", func(t *testing.T) {
+		req := require.New(t)
+
+		rsaCert, _, err := newRsaCert()
+		req.NoError(err)
+
+		key, err := NewKey("", rsaCert, []*x509.Certificate{rsaCert})
+		req.NoError(err)
+
+		sha1Sum := sha1.Sum(rsaCert.Raw)
+		sha256Sum := sha256.Sum256(rsaCert.Raw)
+
+		for _, encode := range []func([]byte) string{
+			base64.RawURLEncoding.EncodeToString,
+			hex.EncodeToString,
+			func(sum []byte) string { return strings.ToUpper(hex.EncodeToString(sum)) },
+		} {
+			key.X509Thumbprint = encode(sha1Sum[:])
+			key.X509ThumbprintSha256 = encode(sha256Sum[:])
+
+			req.NoError(key.VerifyX5cThumbprints(), key.X509Thumbprint)
+			req.NoError(key.Validate())
+		}
+
+		raw, err := json.Marshal(&Response{Keys: []Key{*key}})
+		req.NoError(err)
+
+		response, err := (&Parser{Lenient: true}).Parse(raw)
+		req.NoError(err)
+		req.Len(response.Keys, 1)
+	})
+
+	t.Run("rejects mismatched thumbprints", func(t *testing.T) {
+		req := require.New(t)
+
+		rsaCert, _, err := newRsaCert()
+		req.NoError(err)
+
+		otherCert, _, err := newRsaCert()
+		req.NoError(err)
+
+		key, err := NewKey("", rsaCert, []*x509.Certificate{rsaCert})
+		req.NoError(err)
+
+		other, err := NewKey("", otherCert, nil)
+		req.NoError(err)
+
+		sha1Key := *key
+		sha1Key.X509Thumbprint = other.X509Thumbprint
+
+		_, ok := sha1Key.VerifyX5cThumbprints().(*X5cMismatchError)
+		req.True(ok)
+		req.Error(sha1Key.Validate())
+
+		sha256Key := *key
+		sha256Key.X509ThumbprintSha256 = other.X509ThumbprintSha256
+
+		_, ok = sha256Key.VerifyX5cThumbprints().(*X5cMismatchError)
+		req.True(ok)
+	})
+
+	t.Run("can populate missing thumbprints", func(t *testing.T) {
+		req := require.New(t)
+
+		rsaCert, _, err := newRsaCert()
+		req.NoError(err)
+
+		key, err := NewKey("", rsaCert, []*x509.Certificate{rsaCert})
+		req.NoError(err)
+
+		key.X509Thumbprint = ""
+		key.X509ThumbprintSha256 = ""
+
+		sha1Sum := sha1.Sum(rsaCert.Raw)
+		sha256Sum := sha256.Sum256(rsaCert.Raw)
+
+		req.NoError(key.PopulateX5cThumbprints())
+		req.Equal(base64.RawURLEncoding.EncodeToString(sha1Sum[:]), key.X509Thumbprint)
+		req.Equal(base64.RawURLEncoding.EncodeToString(sha256Sum[:]), key.X509ThumbprintSha256)
+	})
+
+	t.Run("can not populate thumbprints without x5c", func(t *testing.T) {
+		req := require.New(t)
+
+		key := &Key{}
+		req.Error(key.PopulateX5cThumbprints())
+	})
+}
+
+func Test_VerifyChain(t *testing.T) {
+	rootCert, rootKey, err := newTestCa("TEST Root", nil, nil)
+	require.NoError(t, err)
+
+	intermediateCert, intermediateKey, err := newTestCa("TEST Intermediate", rootCert, rootKey)
+	require.NoError(t, err)
+
+	leafCert, _, err := newTestLeaf(intermediateCert, intermediateKey)
+	require.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(rootCert)
+
+	t.Run("verifies a chain using x5c intermediates", func(t *testing.T) {
+		req := require.New(t)
+
+		key, err := NewKey("", leafCert, []*x509.Certificate{leafCert, intermediateCert})
+		req.NoError(err)
+
+		chains, err := key.VerifyChain(roots, nil, x509.VerifyOptions{})
+		req.NoError(err)
+		req.Len(chains, 1)
+		req.Len(chains[0], 3)
+	})
+
+	t.Run("verifies a chain using supplied intermediates", func(t *testing.T) {
+		req := require.New(t)
+
+		key, err := NewKey("", leafCert, []*x509.Certificate{leafCert})
+		req.NoError(err)
+
+		intermediates := x509.NewCertPool()
+		intermediates.AddCert(intermediateCert)
+
+		_, err = key.VerifyChain(roots, intermediates, x509.VerifyOptions{})
+		req.NoError(err)
+
+		_, err = key.VerifyChain(roots, nil, x509.VerifyOptions{})
+		req.Error(err)
+	})
+
+	t.Run("rejects untrusted roots", func(t *testing.T) {
+		req := require.New(t)
+
+		otherRoot, _, err := newTestCa("TEST Other Root", nil, nil)
+		req.NoError(err)
+
+		otherRoots := x509.NewCertPool()
+		otherRoots.AddCert(otherRoot)
+
+		key, err := NewKey("", leafCert, []*x509.Certificate{leafCert, intermediateCert})
+		req.NoError(err)
+
+		_, err = key.VerifyChain(otherRoots, nil, x509.VerifyOptions{})
+		req.Error(err)
+	})
+
+	t.Run("rejects keys without x5c", func(t *testing.T) {
+		req := require.New(t)
+
+		key, err := NewKey("", leafCert, nil)
+		req.NoError(err)
+
+		_, err = key.VerifyChain(roots, nil, x509.VerifyOptions{})
+		req.Error(err)
+	})
+}
+
+func Test_SelfSignCertificate(t *testing.T) {
+	t.Run("can self-sign RSA, EC, and Ed25519 keys", func(t *testing.T) {
+		req := require.New(t)
+
+		rsaKey, _, err := GenerateRSAKey(2048, "rsa", WithAlgorithm(AlgorithmPs256))
+		req.NoError(err)
+
+		ecKey, _, err := GenerateECKey("P-384", "ec")
+		req.NoError(err)
+
+		edKey, _, err := GenerateOKPKey(CurveEd25519, "ed")
+		req.NoError(err)
+
+		for _, test := range []struct {
+			key       *Key
+			algorithm x509.SignatureAlgorithm
+		}{
+			{rsaKey, x509.SHA256WithRSAPSS},
+			{ecKey, x509.ECDSAWithSHA384},
+			{edKey, x509.PureEd25519},
+		} {
+			cert, err := test.key.SelfSignCertificate(nil)
+			req.NoError(err)
+			req.Equal(test.algorithm, cert.SignatureAlgorithm)
+			req.NoError(cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature))
+			req.WithinDuration(time.Now().AddDate(1, 0, 0), cert.NotAfter, time.Minute)
+
+			req.Len(test.key.X509Chain, 1)
+			req.NoError(test.key.VerifyX5cLeaf())
+			req.NoError(test.key.VerifyX5cThumbprints())
+
+			public := test.key.Public()
+			req.Equal(test.key.X509Chain, public.X509Chain)
+			req.NoError(public.Validate())
+		}
+	})
+
+	t.Run("honors the template", func(t *testing.T) {
+		req := require.New(t)
+
+		key, _, err := GenerateECKey("P-256", "ec")
+		req.NoError(err)
+
+		notBefore := time.Now().Add(-time.Hour).Truncate(time.Second)
+		template := &x509.Certificate{
+			Subject:   pkix.Name{CommonName: "issuer.example.com"},
+			NotBefore: notBefore,
+			NotAfter:  notBefore.Add(24 * time.Hour),
+			DNSNames:  []string{"issuer.example.com"},
+		}
+
+		cert, err := key.SelfSignCertificate(template)
+		req.NoError(err)
+		req.Equal("issuer.example.com", cert.Subject.CommonName)
+		req.Equal([]string{"issuer.example.com"}, cert.DNSNames)
+		req.True(notBefore.Equal(cert.NotBefore))
+		req.True(notBefore.Add(24 * time.Hour).Equal(cert.NotAfter))
+		req.Nil(template.SerialNumber)
+	})
+
+	t.Run("can not self-sign a public key", func(t *testing.T) {
+		req := require.New(t)
+
+		_, public, err := GenerateECKey("P-256", "ec")
+		req.NoError(err)
+
+		_, err = public.SelfSignCertificate(nil)
+		req.Error(err)
+		req.Empty(public.X509Chain)
+	})
+}
+
+func Test_SetChain(t *testing.T) {
+	rootCert, rootKey, err := newTestCa("TEST Root", nil, nil)
+	require.NoError(t, err)
+
+	intermediateCert, intermediateKey, err := newTestCa("TEST Intermediate", rootCert, rootKey)
+	require.NoError(t, err)
+
+	leafCert, leafKey, err := newTestLeaf(intermediateCert, intermediateKey)
+	require.NoError(t, err)
+
+	otherCert, _, err := newTestCa("TEST Other", nil, nil)
+	require.NoError(t, err)
+
+	t.Run("orders the chain leaf first and computes thumbprints", func(t *testing.T) {
+		req := require.New(t)
+
+		key, err := NewKeyFromPrivateKey("leaf", leafKey)
+		req.NoError(err)
+
+		req.NoError(key.SetChain([]*x509.Certificate{rootCert, leafCert, intermediateCert}))
+
+		chain, err := key.ParseX509Chain()
+		req.NoError(err)
+		req.Len(chain, 3)
+		req.Equal(leafCert.Raw, chain[0].Raw)
+		req.Equal(intermediateCert.Raw, chain[1].Raw)
+		req.Equal(rootCert.Raw, chain[2].Raw)
+
+		req.NoError(key.VerifyX5cLeaf())
+		req.NoError(key.VerifyX5cThumbprints())
+		req.Equal(CertificateX5tS256(leafCert), key.X509ThumbprintSha256)
+
+		roots := x509.NewCertPool()
+		roots.AddCert(rootCert)
+
+		_, err = key.VerifyChain(roots, nil, x509.VerifyOptions{})
+		req.NoError(err)
+	})
+
+	t.Run("can set a partial chain", func(t *testing.T) {
+		req := require.New(t)
+
+		key, err := NewKeyFromPublicKey("leaf", &leafKey.PublicKey)
+		req.NoError(err)
+
+		req.NoError(key.SetChain([]*x509.Certificate{intermediateCert, leafCert}))
+		req.Len(key.X509Chain, 2)
+	})
+
+	t.Run("can not set a chain without the key's certificate", func(t *testing.T) {
+		req := require.New(t)
+
+		key, err := NewKeyFromPublicKey("leaf", &leafKey.PublicKey)
+		req.NoError(err)
+
+		err = key.SetChain([]*x509.Certificate{intermediateCert, rootCert})
+
+		var mismatchErr *X5cMismatchError
+		req.ErrorAs(err, &mismatchErr)
+		req.Empty(key.X509Chain)
+	})
+
+	t.Run("can not set unrelated certificates", func(t *testing.T) {
+		req := require.New(t)
+
+		key, err := NewKeyFromPublicKey("leaf", &leafKey.PublicKey)
+		req.NoError(err)
+
+		req.Error(key.SetChain([]*x509.Certificate{leafCert, intermediateCert, otherCert}))
+		req.Error(key.SetChain([]*x509.Certificate{leafCert, rootCert}))
+		req.Error(key.SetChain(nil))
+		req.Empty(key.X509Chain)
+	})
+}
+
+func newTestCa(commonName string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	if parent == nil {
+		parent = template
+		parentKey = privateKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &privateKey.PublicKey, parentKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cert, privateKey, nil
+}
+
+func newTestLeaf(parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "TEST Leaf"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &privateKey.PublicKey, parentKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cert, privateKey, nil
+}
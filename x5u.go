@@ -0,0 +1,191 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	// DefaultX5uMaxBodySize is the maximum x5u response size read by an X5uResolver without a MaxBodySize
+	DefaultX5uMaxBodySize = 1024 * 1024
+
+	// DefaultX5uTimeout is the request timeout used by an X5uResolver without a Client
+	DefaultX5uTimeout = 10 * time.Second
+
+	ErrorX5uInsecureMsg      = "x5u must use https"
+	ErrorX5uTooLargeMsg      = "x5u response exceeds the maximum body size"
+	ErrorX5uNoCertsMsg       = "x5u response did not contain any certificates"
+	ErrorX5uX5cMismatchMsg   = "x5u certificate chain does not match x5c"
+	ErrorX5uKeyMismatchMsg   = "x5u leaf certificate public key does not match the key parameters"
+	ErrorX5uMissingMemberMsg = "key does not contain an x5u member"
+)
+
+// X5uResolver fetches the PEM encoded certificate chain referenced by a key's x5u member and validates it against the
+// key. Fetching x5u is opt-in, the parsers and HttpResolver never follow x5u on their own.
+type X5uResolver struct {
+	// Client is used to fetch x5u, if nil a client with DefaultX5uTimeout is used
+	Client *http.Client
+
+	// MaxBodySize limits the size of x5u responses, if zero DefaultX5uMaxBodySize is used
+	MaxBodySize int64
+
+	// AllowInsecure permits http x5u URLs, RFC 7517 requires TLS
+	AllowInsecure bool
+}
+
+// Get fetches the certificate chain referenced by the key's x5u member, verifies that the leaf certificate's public
+// key matches the key and, if the key also has an x5c member, that both chains are identical. The parsed certificates
+// are returned leaf first.
+func (r *X5uResolver) Get(key Key) ([]*x509.Certificate, error) {
+	if key.X509Url == "" {
+		return nil, errors.New(ErrorX5uMissingMemberMsg)
+	}
+
+	x5u, err := url.Parse(key.X509Url)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid x5u: %s", err)
+	}
+
+	if x5u.Scheme != "https" && !(r.AllowInsecure && x5u.Scheme == "http") {
+		return nil, errors.New(ErrorX5uInsecureMsg)
+	}
+
+	client := r.Client
+
+	if client == nil {
+		client = &http.Client{Timeout: DefaultX5uTimeout}
+	}
+
+	maxBodySize := r.MaxBodySize
+
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultX5uMaxBodySize
+	}
+
+	resp, err := client.Get(x5u.String())
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &HttpResolverError{
+			Resp:  resp,
+			error: errors.New(ErrorInvalidStatusCodeMsg),
+		}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize+1))
+
+	if err != nil {
+		return nil, &HttpResolverError{
+			Resp:  resp,
+			error: err,
+		}
+	}
+
+	if int64(len(body)) > maxBodySize {
+		return nil, &HttpResolverError{
+			Resp:  resp,
+			error: errors.New(ErrorX5uTooLargeMsg),
+		}
+	}
+
+	var certs []*x509.Certificate
+
+	for block, rest := pem.Decode(body); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+
+		if err != nil {
+			return nil, fmt.Errorf("error parsing x5u certificate: %s", err)
+		}
+
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New(ErrorX5uNoCertsMsg)
+	}
+
+	if err = verifyX5u(key, certs); err != nil {
+		return nil, err
+	}
+
+	return certs, nil
+}
+
+func verifyX5u(key Key, certs []*x509.Certificate) error {
+	pubKey, err := KeyToPublicKey(key)
+
+	if err != nil {
+		return err
+	}
+
+	leafPubKey, ok := certs[0].PublicKey.(interface {
+		Equal(x crypto.PublicKey) bool
+	})
+
+	if !ok || !leafPubKey.Equal(pubKey) {
+		return &X5cMismatchError{
+			error: errors.New(ErrorX5uKeyMismatchMsg),
+			KeyId: key.KeyId,
+		}
+	}
+
+	if len(key.X509Chain) > 0 {
+		x5c, err := key.ParseX509Chain()
+
+		if err != nil {
+			return err
+		}
+
+		if len(x5c) != len(certs) {
+			return &X5cMismatchError{
+				error: errors.New(ErrorX5uX5cMismatchMsg),
+				KeyId: key.KeyId,
+			}
+		}
+
+		for i := range x5c {
+			if !bytes.Equal(x5c[i].Raw, certs[i].Raw) {
+				return &X5cMismatchError{
+					error: errors.New(ErrorX5uX5cMismatchMsg),
+					KeyId: key.KeyId,
+				}
+			}
+		}
+	}
+
+	return nil
+}
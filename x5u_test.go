@@ -0,0 +1,139 @@
+/*
+Copyright NetFoundry, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+https://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package jwks
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_X5uResolver(t *testing.T) {
+	leafCert, _, err := newEcCert()
+	require.NoError(t, err)
+
+	otherCert, _, err := newEcCert()
+	require.NoError(t, err)
+
+	leafPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafCert.Raw})
+	otherPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: otherCert.Raw})
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/leaf.pem":
+			_, _ = rw.Write(leafPem)
+		case "/other.pem":
+			_, _ = rw.Write(otherPem)
+		case "/empty.pem":
+			_, _ = rw.Write([]byte("no certificates here"))
+		default:
+			rw.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	resolver := &X5uResolver{Client: server.Client()}
+
+	newX5uKey := func(path string) Key {
+		key, err := NewKey("", leafCert, nil)
+		require.NoError(t, err)
+		key.X509Url = server.URL + path
+		return *key
+	}
+
+	t.Run("can fetch and validate a matching chain", func(t *testing.T) {
+		req := require.New(t)
+
+		certs, err := resolver.Get(newX5uKey("/leaf.pem"))
+		req.NoError(err)
+		req.Len(certs, 1)
+		req.Equal(leafCert.Raw, certs[0].Raw)
+	})
+
+	t.Run("requires x5c to match x5u when both are present", func(t *testing.T) {
+		req := require.New(t)
+
+		key := newX5uKey("/leaf.pem")
+
+		withLeaf, err := NewKey("", leafCert, []*x509.Certificate{leafCert})
+		req.NoError(err)
+		key.X509Chain = withLeaf.X509Chain
+
+		_, err = resolver.Get(key)
+		req.NoError(err)
+
+		withOther, err := NewKey("", leafCert, []*x509.Certificate{leafCert, otherCert})
+		req.NoError(err)
+		key.X509Chain = withOther.X509Chain
+
+		_, err = resolver.Get(key)
+		_, ok := err.(*X5cMismatchError)
+		req.True(ok)
+	})
+
+	t.Run("rejects a chain for a different key", func(t *testing.T) {
+		req := require.New(t)
+
+		_, err := resolver.Get(newX5uKey("/other.pem"))
+		_, ok := err.(*X5cMismatchError)
+		req.True(ok)
+	})
+
+	t.Run("rejects responses without certificates", func(t *testing.T) {
+		req := require.New(t)
+
+		_, err := resolver.Get(newX5uKey("/empty.pem"))
+		req.EqualError(err, ErrorX5uNoCertsMsg)
+	})
+
+	t.Run("rejects non-200 responses", func(t *testing.T) {
+		req := require.New(t)
+
+		_, err := resolver.Get(newX5uKey("/missing.pem"))
+		_, ok := err.(*HttpResolverError)
+		req.True(ok)
+	})
+
+	t.Run("rejects oversized responses", func(t *testing.T) {
+		req := require.New(t)
+
+		limited := &X5uResolver{Client: server.Client(), MaxBodySize: 16}
+
+		_, err := limited.Get(newX5uKey("/leaf.pem"))
+		req.Error(err)
+	})
+
+	t.Run("rejects http unless allowed", func(t *testing.T) {
+		req := require.New(t)
+
+		key := newX5uKey("")
+		key.X509Url = "http://localhost/leaf.pem"
+
+		_, err := resolver.Get(key)
+		req.EqualError(err, ErrorX5uInsecureMsg)
+	})
+
+	t.Run("rejects keys without x5u", func(t *testing.T) {
+		req := require.New(t)
+
+		_, err := resolver.Get(Key{})
+		req.EqualError(err, ErrorX5uMissingMemberMsg)
+	})
+}